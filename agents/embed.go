@@ -0,0 +1,10 @@
+// Package agents embeds the shipped prompt templates, which a PromptBuilder falls back to
+// for templates missing from the agents directory it reads.
+package agents
+
+import "embed"
+
+// Templates holds the <name>.tmpl prompt templates
+//
+//go:embed *.tmpl
+var Templates embed.FS
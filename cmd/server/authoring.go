@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/chuanjin/OmniBridge/internal/parser"
+)
+
+// subcommands author parsers by hand, without the LLM: omnibridge <name> [flags]
+var subcommands = map[string]func(args []string, out io.Writer) error{
+	"register": runRegister,
+	"test":     runTest,
+	"route":    runRoute,
+}
+
+// bindStored binds the stored parsers like the gateway does at startup: signatures declared in
+// code first, then manifest.json on top. Protocol skip is left unbound.
+func bindStored(d *parser.Dispatcher, mgr *parser.ParserManager, skip string) error {
+	bindings, err := mgr.LoadSavedParsers()
+	if err != nil {
+		return err
+	}
+	manifest, err := mgr.LoadManifest()
+	if err != nil {
+		return err
+	}
+	for protocol, sigHex := range bindings {
+		if protocol != skip {
+			d.Bind(hexToBytes(sigHex), protocol)
+		}
+	}
+	for sigHex, protocol := range manifest {
+		if protocol != skip {
+			d.Bind(hexToBytes(sigHex), protocol)
+		}
+	}
+	return nil
+}
+
+// runRegister compiles a hand-written parser, stores it as a new version of -id and binds
+// the signature from its // Signature: comment in the manifest
+func runRegister(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("register", flag.ContinueOnError)
+	id := fs.String("id", "", "Protocol ID to register the parser under")
+	file := fs.String("file", "", "Go source file of the parser")
+	storagePath := fs.String("storage-path", "./storage", "Directory for learned parsers and the manifest")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *id == "" || *file == "" {
+		return errors.New("register: -id and -file are required")
+	}
+
+	code, err := os.ReadFile(*file)
+	if err != nil {
+		return fmt.Errorf("register: %v", err)
+	}
+	sig := parser.DeclaredSignature(string(code))
+	if sig == nil {
+		return fmt.Errorf("register: %s has no \"// Signature: <hex>\" comment", *file)
+	}
+
+	mgr := parser.NewParserManager(*storagePath, "")
+	if err := os.MkdirAll(*storagePath, 0o755); err != nil {
+		return fmt.Errorf("register: %v", err)
+	}
+	// Refuse a signature another protocol already owns, whether declared in code or the manifest
+	d := parser.NewDispatcher(mgr)
+	if err := bindStored(d, mgr, *id); err != nil {
+		return fmt.Errorf("register: %v", err)
+	}
+	if err := d.BindStrict(sig, *id); err != nil {
+		return fmt.Errorf("register: %w", err)
+	}
+
+	if err := mgr.GetEngine().Validate(string(code)); err != nil {
+		return fmt.Errorf("register: %s does not compile: %w", *file, err)
+	}
+	if err := mgr.RegisterParser(*id, string(code)); err != nil {
+		return fmt.Errorf("register: %w", err)
+	}
+
+	manifest, err := mgr.LoadManifest()
+	if err != nil {
+		return fmt.Errorf("register: %v", err)
+	}
+	for sigHex, protocol := range manifest {
+		if protocol == *id {
+			delete(manifest, sigHex) // A re-registered parser may have moved to a new signature
+		}
+	}
+	manifest[fmt.Sprintf("%X", sig)] = *id
+	if err := mgr.SaveManifest(manifest); err != nil {
+		return fmt.Errorf("register: %w", err)
+	}
+
+	fmt.Fprintf(out, "Registered %s, bound to signature 0x%X\n", *id, sig)
+	return nil
+}
+
+// runTest runs a stored parser on one hex frame and prints its result as JSON
+func runTest(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	id := fs.String("id", "", "Protocol ID of the parser to run")
+	frame := fs.String("hex", "", "Frame to parse, in hex (e.g. 410C1AF8)")
+	storagePath := fs.String("storage-path", "./storage", "Directory for learned parsers and the manifest")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *id == "" || *frame == "" {
+		return errors.New("test: -id and -hex are required")
+	}
+
+	data, err := decodeHexArg(*frame)
+	if err != nil {
+		return fmt.Errorf("test: invalid -hex %q: %v", *frame, err)
+	}
+
+	mgr := parser.NewParserManager(*storagePath, "")
+	if _, err := mgr.LoadSavedParsers(); err != nil {
+		return fmt.Errorf("test: %v", err)
+	}
+	result, err := mgr.ParseData(*id, data)
+	if err != nil {
+		return fmt.Errorf("test: %w", err)
+	}
+
+	encoded, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("test: %v", err)
+	}
+	_, err = fmt.Fprintln(out, string(encoded))
+	return err
+}
+
+// runRoute reports which stored parser a frame starting with -signature would be routed to
+func runRoute(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("route", flag.ContinueOnError)
+	signature := fs.String("signature", "", "Leading bytes of a frame, in hex (e.g. 410C)")
+	storagePath := fs.String("storage-path", "./storage", "Directory for learned parsers and the manifest")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	sig, err := decodeHexArg(*signature)
+	if err != nil || len(sig) == 0 {
+		return fmt.Errorf("route: -signature must be hex bytes such as 410C, got %q", *signature)
+	}
+
+	mgr := parser.NewParserManager(*storagePath, "")
+	d := parser.NewDispatcher(mgr)
+	if err := bindStored(d, mgr, ""); err != nil {
+		return fmt.Errorf("route: %v", err)
+	}
+	if err := mgr.LoadAliases(); err != nil {
+		return fmt.Errorf("route: %v", err)
+	}
+
+	protocol, matched := d.Route(sig)
+	if !matched {
+		_, err = fmt.Fprintf(out, "0x%X: no parser bound; the frame would go to discovery\n", sig)
+		return err
+	}
+	if alias, ok := mgr.GetAlias(protocol); ok {
+		protocol = fmt.Sprintf("%s (%s)", protocol, alias)
+	}
+	_, err = fmt.Fprintf(out, "0x%X: %s\n", sig, protocol)
+	return err
+}
+
+// decodeHexArg decodes a hex command-line argument, allowing spaces and a 0x prefix
+func decodeHexArg(s string) ([]byte, error) {
+	s = strings.Join(strings.Fields(s), "")
+	return hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"))
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chuanjin/OmniBridge/internal/parser"
+)
+
+const rpmParser = `// Signature: 410C
+package dynamic
+
+func Parse(data []byte) map[string]interface{} {
+	if len(data) < 4 {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{"rpm": (int(data[2])*256 + int(data[3])) / 4}
+}
+`
+
+func writeParserFile(t *testing.T, dir, name, code string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(code), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestSubcommands_RegisterAndTest(t *testing.T) {
+	dir := t.TempDir()
+	storage := filepath.Join(dir, "storage")
+	file := writeParserFile(t, dir, "rpm.go", rpmParser)
+
+	var out bytes.Buffer
+	if err := runRegister([]string{"-id", "OBD_RPM", "-file", file, "-storage-path", storage}, &out); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "0x410C") {
+		t.Errorf("Expected the bound signature in the output, got %q", out.String())
+	}
+
+	mgr := parser.NewParserManager(storage, "")
+	manifest, err := mgr.LoadManifest()
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	if manifest["410C"] != "OBD_RPM" {
+		t.Errorf("Expected 410C bound to OBD_RPM in the manifest, got %v", manifest)
+	}
+
+	out.Reset()
+	if err := runTest([]string{"-id", "OBD_RPM", "-hex", "410C1AF8", "-storage-path", storage}, &out); err != nil {
+		t.Fatalf("test failed: %v", err)
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatalf("test output is not JSON: %v\n%s", err, out.String())
+	}
+	if result["rpm"] != float64(1726) {
+		t.Errorf("Expected rpm 1726, got %v", result)
+	}
+
+	if err := runTest([]string{"-id", "OBD_RPM", "-hex", "41ZZ", "-storage-path", storage}, &out); err == nil {
+		t.Error("Expected an error for an invalid hex frame")
+	}
+	if err := runTest([]string{"-id", "MISSING", "-hex", "410C", "-storage-path", storage}, &out); err == nil {
+		t.Error("Expected an error for an unknown parser")
+	}
+}
+
+func TestSubcommands_RegisterRejects(t *testing.T) {
+	dir := t.TempDir()
+	storage := filepath.Join(dir, "storage")
+	if err := runRegister([]string{"-id", "OBD_RPM", "-file", writeParserFile(t, dir, "rpm.go", rpmParser), "-storage-path", storage}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		code string
+	}{
+		{"missing signature", "NO_SIG", strings.Replace(rpmParser, "// Signature: 410C\n", "", 1)},
+		{"compile error", "BROKEN", "// Signature: 55AA\npackage dynamic\n\nfunc Parse(data []byte) map[string]interface{} {\n\treturn undefined\n}\n"},
+		{"signature owned by another protocol", "OTHER_RPM", rpmParser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := writeParserFile(t, dir, tt.id+".go", tt.code)
+			if err := runRegister([]string{"-id", tt.id, "-file", file, "-storage-path", storage}, &bytes.Buffer{}); err == nil {
+				t.Fatal("Expected register to fail")
+			}
+			if _, err := os.Stat(filepath.Join(storage, tt.id)); !os.IsNotExist(err) {
+				t.Errorf("Rejected parser %s should not be stored", tt.id)
+			}
+		})
+	}
+
+	file := writeParserFile(t, dir, "other.go", rpmParser)
+	err := runRegister([]string{"-id", "OTHER_RPM", "-file", file, "-storage-path", storage}, &bytes.Buffer{})
+	if !errors.Is(err, parser.ErrSignatureConflict) {
+		t.Errorf("Expected ErrSignatureConflict, got %v", err)
+	}
+	if err := runRegister([]string{"-id", "OBD_RPM"}, &bytes.Buffer{}); err == nil {
+		t.Error("Expected an error without -file")
+	}
+}
+
+func TestSubcommands_Route(t *testing.T) {
+	dir := t.TempDir()
+	storage := filepath.Join(dir, "storage")
+	generic := strings.Replace(rpmParser, "// Signature: 410C", "// Signature: 41", 1)
+	for id, code := range map[string]string{"OBD_RPM": rpmParser, "OBDII_Service01": generic} {
+		if err := runRegister([]string{"-id", id, "-file", writeParserFile(t, dir, id+".go", code), "-storage-path", storage}, &bytes.Buffer{}); err != nil {
+			t.Fatalf("register %s failed: %v", id, err)
+		}
+	}
+
+	tests := []struct {
+		signature string
+		want      string
+	}{
+		{"410C", "0x410C: OBD_RPM\n"},
+		{"0x41 05", "0x4105: OBDII_Service01\n"},
+		{"99", "0x99: no parser bound; the frame would go to discovery\n"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := runRoute([]string{"-signature", tt.signature, "-storage-path", storage}, &out); err != nil {
+			t.Fatalf("route %s failed: %v", tt.signature, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("route %s printed %q, want %q", tt.signature, out.String(), tt.want)
+		}
+	}
+	if err := runRoute([]string{"-signature", "4G", "-storage-path", storage}, &bytes.Buffer{}); err == nil {
+		t.Error("Expected an error for an invalid signature")
+	}
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/parser"
+)
+
+// Config is the fully-resolved gateway configuration built from flags and the environment
+type Config struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Endpoint string `json:"endpoint"`
+	ApiKey   string `json:"api_key"`
+	Stream   bool   `json:"stream"`
+
+	MaxRetries int           `json:"max_retries"`
+	RetryDelay time.Duration `json:"retry_delay"`
+
+	SystemPromptPath string `json:"system_prompt_path"`
+
+	ProviderOptions map[string]string `json:"provider_options,omitempty"` // Provider-specific settings, see parser.DiscoveryConfig
+
+	ExecCommand string        `json:"exec_command"`
+	ExecTimeout time.Duration `json:"exec_timeout"`
+
+	DiscoveryTimeout time.Duration `json:"discovery_timeout"`
+	RequestTimeout   time.Duration `json:"request_timeout"`
+
+	Mode     string `json:"mode"`
+	Addr     string `json:"addr"`
+	GRPCAddr string `json:"grpc_addr"`
+	Debug    bool   `json:"debug"`
+
+	IdleTimeout    time.Duration      `json:"idle_timeout"`
+	MaxConnections int                `json:"max_connections"`
+	ReadBufferSize int                `json:"read_buffer_size"`
+	MaxFrameSize   int                `json:"max_frame_size"`
+	Framing        parser.FramingMode `json:"framing"`
+
+	AuthToken   string `json:"auth_token"`
+	TLSCert     string `json:"tls_cert"`
+	TLSKey      string `json:"tls_key"`
+	TLSClientCA string `json:"tls_client_ca"`
+
+	DiscoveryGrace       time.Duration `json:"discovery_grace"`
+	DiscoveryGraceFrames int           `json:"discovery_grace_frames"`
+	PinProtocol          bool          `json:"pin_protocol"`
+
+	ReplayFile string  `json:"replay_file"`
+	ReplayRate float64 `json:"replay_rate"`
+
+	MQTTBroker      string `json:"mqtt_broker"`
+	MQTTTopic       string `json:"mqtt_topic"`
+	MQTTResultTopic string `json:"mqtt_result_topic"`
+	MQTTClientID    string `json:"mqtt_client_id"`
+	MQTTUsername    string `json:"mqtt_username"`
+	MQTTPassword    string `json:"mqtt_password"`
+
+	StoragePath    string `json:"storage_path"`
+	SeedPath       string `json:"seed_path"`
+	EmbeddedSeeds  bool   `json:"embedded_seeds"`
+	SpecPath       string `json:"spec"`
+	WatchParsers   bool   `json:"watch_parsers"`
+	Reconcile      bool   `json:"reconcile"`
+	PersistResults string `json:"persist_results"`
+	OTLPLogs       string `json:"otlp_logs"`
+	StdoutResults  bool   `json:"stdout_results"`
+	ResultWebhook  string `json:"result_webhook"`
+
+	DeadLetterStore   string                   `json:"dead_letter_store"`
+	ReplayDeadLetters bool                     `json:"replay_dead_letters"`
+	EmptyResults      parser.EmptyResultPolicy `json:"empty_results"`
+
+	ParseTimeout    time.Duration `json:"parse_timeout"`
+	CompileTimeout  time.Duration `json:"compile_timeout"`
+	ParserCacheSize int           `json:"parser_cache_size"`
+
+	ResultCacheTTL     time.Duration `json:"result_cache_ttl"`
+	ResultCacheEntries int           `json:"result_cache_entries"`
+
+	MetricsAddr    string    `json:"metrics_addr"`
+	HealthAddr     string    `json:"health_addr"`
+	MetricsBuckets []float64 `json:"metrics_buckets"`
+
+	DeadLetterThreshold float64       `json:"dead_letter_threshold"`
+	DeadLetterWindow    time.Duration `json:"dead_letter_window"`
+
+	ProtocolFamilies map[string]string `json:"protocol_families"` // Hex signature prefix -> family label
+	MaskPolicy       parser.MaskPolicy `json:"mask_policy"`
+	ValidateOBD2     bool              `json:"validate_obd2"`
+	RawFallback      bool              `json:"raw_fallback"`
+
+	CheckDeterminism bool `json:"check_determinism"`
+	PrivacyMode      bool `json:"privacy_mode"`
+	AuditLog         bool `json:"audit_log"`
+
+	MaxDiscoveriesPerMinute int `json:"max_discoveries_per_minute"`
+	MaxSignaturesPerHour    int `json:"max_signatures_per_hour"`
+
+	MaxRepairsPerMinute int           `json:"max_repairs_per_minute"`
+	RepairBudget        int           `json:"repair_budget"`
+	RepairWindow        time.Duration `json:"repair_window"`
+
+	FailClosed        bool   `json:"fail_closed"`
+	EscalateAfter     int    `json:"escalate_after"`
+	EscalationWebhook string `json:"escalation_webhook"`
+
+	PrintConfig  bool   `json:"-"`
+	SnapshotPath string `json:"-"`
+	RestorePath  string `json:"-"`
+
+	// Scaffold mode: the protocol to write a parser skeleton for
+	ScaffoldName      string `json:"-"`
+	ScaffoldSignature []byte `json:"-"`
+
+	// Inspect mode: the frame to decode
+	InspectFrame []byte `json:"-"`
+}
+
+// envAuthToken provides the default of -auth-token, keeping the secret out of process listings
+const envAuthToken = "OMNI_AUTH_TOKEN"
+
+// envMQTTPassword provides the default of -mqtt-password
+const envMQTTPassword = "OMNI_MQTT_PASSWORD"
+
+// envCompileTimeout provides the default of -compile-timeout
+const envCompileTimeout = "OMNI_COMPILE_TIMEOUT"
+
+// parseConfig resolves the configuration from command-line arguments and environment variables
+func parseConfig(args []string) (*Config, error) {
+	cfg := &Config{
+		ParseTimeout:   parser.DefaultParseTimeout,
+		CompileTimeout: parser.DefaultCompileTimeout,
+	}
+	var buckets, families, maskPolicy, emptyResults, framing, signature, input string
+
+	// OMNI_* environment variables provide the defaults, flags override them
+	env := parser.LoadConfigFromEnv()
+	if env.Provider == "" {
+		env.Provider = "gemini"
+	}
+	compileTimeout := parser.DefaultCompileTimeout
+	if v := os.Getenv(envCompileTimeout); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid $%s: %w", envCompileTimeout, err)
+		}
+		compileTimeout = d
+	}
+
+	fs := flag.NewFlagSet("omnibridge", flag.ContinueOnError)
+	fs.StringVar(&cfg.Provider, "provider", env.Provider, "LLM Provider (gemini, ollama, openai, anthropic, exec) [$OMNI_PROVIDER]")
+	fs.StringVar(&cfg.Model, "model", env.Model, "Model Name (default: gemini-2.0-flash for gemini, deepseek-coder:1.3b for ollama, gpt-4o-mini for openai, claude-3-5-haiku-latest for anthropic) [$OMNI_MODEL]")
+	fs.StringVar(&cfg.Endpoint, "endpoint", env.Endpoint, "API Endpoint [$OMNI_ENDPOINT]")
+	fs.IntVar(&cfg.MaxRetries, "max-retries", env.MaxRetries, "LLM attempts per discovery, also bounding compile-fix rounds (0 = one attempt) [$OMNI_MAX_RETRIES]")
+	fs.DurationVar(&cfg.RetryDelay, "retry-delay", env.RetryDelay, "Initial backoff between failed LLM requests, doubled each time (0 = 2s) [$OMNI_RETRY_DELAY]")
+	fs.BoolVar(&cfg.Stream, "stream", false, "Stream the generation from Ollama instead of waiting for the full response")
+	fs.StringVar(&cfg.SystemPromptPath, "system-prompt", parser.DefaultSystemPromptPath, "System prompt file prepended to every discovery and repair request")
+	fs.Func("provider-option", "Provider-specific key=value setting, repeatable (e.g. keep_alive=30m for ollama, azure_deployment=NAME for openai)", func(v string) error {
+		key, value, ok := strings.Cut(v, "=")
+		if key = strings.TrimSpace(key); !ok || key == "" {
+			return fmt.Errorf("want key=value, got %q", v)
+		}
+		if cfg.ProviderOptions == nil {
+			cfg.ProviderOptions = make(map[string]string)
+		}
+		cfg.ProviderOptions[key] = strings.TrimSpace(value)
+		return nil
+	})
+	fs.StringVar(&cfg.ExecCommand, "exec-command", "", "Command for the exec provider; receives the prompt on stdin and prints Go code")
+	fs.DurationVar(&cfg.ExecTimeout, "exec-timeout", 2*time.Minute, "Maximum run time of the exec provider command")
+	fs.DurationVar(&cfg.RequestTimeout, "request-timeout", parser.DefaultRequestTimeout, "Timeout of each HTTP request to the LLM provider")
+	fs.DurationVar(&cfg.DiscoveryTimeout, "discovery-timeout", 10*time.Minute, "Overall deadline for one discovery or repair, across all retries (0 = no limit)")
+	fs.StringVar(&cfg.Mode, "mode", "simulate", "Mode (simulate, server, http, grpc, ws, mcp, mqtt, replay, scaffold, inspect)")
+	fs.StringVar(&cfg.Addr, "addr", ":8080", "Listen address (used in server, http and ws modes)")
+	fs.StringVar(&cfg.GRPCAddr, "grpc-addr", ":9090", "Listen address (grpc mode)")
+	fs.BoolVar(&cfg.Debug, "debug", false, "Enable debug logging")
+	fs.DurationVar(&cfg.IdleTimeout, "idle-timeout", parser.DefaultIdleTimeout, "Close TCP connections that send nothing for this long (server mode)")
+	fs.IntVar(&cfg.MaxConnections, "max-connections", parser.DefaultMaxConnections, "Maximum concurrent TCP connections, 0 for unlimited (server mode)")
+	fs.IntVar(&cfg.ReadBufferSize, "read-buffer-size", parser.DefaultReadBufferSize, "Bytes per read from a TCP connection; a read filling it is joined with the data that follows. Only exact when writes arrive whole, see -framing (server mode)")
+	fs.IntVar(&cfg.MaxFrameSize, "max-frame-size", parser.DefaultMaxFrameSize, "Largest TCP frame in bytes; connections sending bigger ones are closed (server mode)")
+	fs.StringVar(&framing, "framing", string(parser.FramingRead), "How TCP frames are delimited: read (one read per frame) or length-prefix (big-endian 2-byte length before each frame) (server mode)")
+	fs.StringVar(&cfg.AuthToken, "auth-token", os.Getenv(envAuthToken), "Shared token TCP clients must send, newline-terminated, before their first frame (disabled if empty, server mode) [$OMNI_AUTH_TOKEN]")
+	fs.StringVar(&cfg.TLSCert, "tls-cert", "", "Serve TCP over TLS with this PEM certificate (requires -tls-key, server mode)")
+	fs.StringVar(&cfg.TLSKey, "tls-key", "", "PEM private key of -tls-cert")
+	fs.StringVar(&cfg.TLSClientCA, "tls-client-ca", "", "Require TCP clients to present a certificate signed by this PEM CA bundle (mTLS, requires -tls-cert)")
+	fs.DurationVar(&cfg.DiscoveryGrace, "discovery-grace", 0, "Buffer a new TCP connection's unknown frames this long before discovering, to skip junk/handshake frames (0 disables, server mode)")
+	fs.IntVar(&cfg.DiscoveryGraceFrames, "discovery-grace-frames", parser.DefaultGraceFrames, "Unknown frames buffered during the discovery grace period (server mode)")
+	fs.BoolVar(&cfg.PinProtocol, "pin-protocol", false, "Parse a TCP connection's frames with the protocol of its first parsed frame, skipping signature lookup (server mode)")
+	fs.StringVar(&cfg.ReplayFile, "replay-file", "", "Capture to replay: newline-separated hex frames or uint16-length-prefixed binary (replay mode)")
+	fs.Float64Var(&cfg.ReplayRate, "replay-rate", 0, "Frames per second to replay, 0 for as fast as possible (replay mode)")
+	fs.StringVar(&cfg.MQTTBroker, "mqtt-broker", "localhost:1883", "MQTT broker address, host:port (mqtt mode)")
+	fs.StringVar(&cfg.MQTTTopic, "mqtt-topic", "", "Topic filter to ingest frames from, '+' and '#' wildcards allowed (mqtt mode)")
+	fs.StringVar(&cfg.MQTTResultTopic, "mqtt-result-topic", parser.DefaultMQTTResultTopic, "Prefix of the topics parsed results are published to: <prefix>/<source topic> (mqtt mode)")
+	fs.StringVar(&cfg.MQTTClientID, "mqtt-client-id", "omnibridge", "MQTT client identifier (mqtt mode)")
+	fs.StringVar(&cfg.MQTTUsername, "mqtt-username", "", "MQTT user name (mqtt mode)")
+	fs.StringVar(&cfg.MQTTPassword, "mqtt-password", os.Getenv(envMQTTPassword), "MQTT password (mqtt mode) [$OMNI_MQTT_PASSWORD]")
+	fs.StringVar(&cfg.StoragePath, "storage-path", "./storage", "Directory for learned parsers and the manifest")
+	fs.StringVar(&cfg.SeedPath, "seed-path", "./seeds", "Directory of built-in parser seeds")
+	fs.BoolVar(&cfg.EmbeddedSeeds, "embedded-seeds", false, "Seed from the parsers compiled into the binary instead of -seed-path")
+	fs.StringVar(&cfg.SpecPath, "spec", "", "Declarative protocol spec (JSON or YAML) of parsers, signatures and checksums applied at startup")
+	fs.StringVar(&cfg.PersistResults, "persist-results", "", "Append every successfully parsed frame and its result to this JSONL file (disabled if empty)")
+	fs.StringVar(&cfg.OTLPLogs, "otlp-logs", "", "Emit every processed frame as an OpenTelemetry log record to this OTLP/HTTP endpoint, e.g. http://localhost:4318/v1/logs (disabled if empty)")
+	fs.BoolVar(&cfg.StdoutResults, "stdout-results", false, "Print every successfully parsed frame and its result to stdout as a JSON line")
+	fs.StringVar(&cfg.ResultWebhook, "result-webhook", "", "POST every successfully parsed frame and its result as JSON to this URL (disabled if empty)")
+	fs.StringVar(&cfg.DeadLetterStore, "dead-letter-store", "", "Keep frames that fail to parse or match no protocol in this JSONL file for later replay (disabled if empty)")
+	fs.BoolVar(&cfg.ReplayDeadLetters, "replay-dead-letters", false, "At startup, re-ingest the stored dead letters and remove the ones that parse now (requires -dead-letter-store)")
+	fs.StringVar(&emptyResults, "empty-results", string(parser.EmptyEmit), "What output sinks get for a parse that succeeds with no fields (emit, drop, dead-letter)")
+	fs.BoolVar(&cfg.WatchParsers, "watch-parsers", false, "Reload parsers edited by hand in the storage path without restarting")
+	fs.BoolVar(&cfg.Reconcile, "reconcile", false, "At startup, re-run every parser against its stored vectors; repair or quarantine the ones that fail")
+	fs.DurationVar(&cfg.ParseTimeout, "parse-timeout", parser.DefaultParseTimeout, "Time limit of every parser execution")
+	fs.DurationVar(&cfg.CompileTimeout, "compile-timeout", compileTimeout, "Time limit of compiling one parser, raise it for large generated parsers [$OMNI_COMPILE_TIMEOUT]")
+	fs.IntVar(&cfg.ParserCacheSize, "parser-cache-size", 0, "Maximum compiled parsers kept in memory; the least recently used is recompiled on demand (0 for unlimited)")
+	fs.DurationVar(&cfg.ResultCacheTTL, "result-cache-ttl", 0, "Reuse parse results of byte-identical frames (e.g. heartbeats) for this long (0 disables)")
+	fs.IntVar(&cfg.ResultCacheEntries, "result-cache-entries", parser.DefaultResultCacheEntries, "Maximum parse results kept by the result cache")
+	fs.StringVar(&cfg.MetricsAddr, "metrics-addr", "", "Address to serve Prometheus /metrics on (disabled if empty)")
+	fs.StringVar(&cfg.HealthAddr, "health-addr", "", "Address to serve /healthz and /readyz probes on, in any mode (disabled if empty)")
+	fs.StringVar(&buckets, "metrics-buckets", "", "Comma-separated parser latency histogram buckets in seconds (default tuned for sub-millisecond parsers)")
+	fs.Float64Var(&cfg.DeadLetterThreshold, "dead-letter-threshold", 0, "Rolling unparseable-frame rate (0-1) that marks the gateway degraded (0 disables)")
+	fs.DurationVar(&cfg.DeadLetterWindow, "dead-letter-window", time.Minute, "Rolling window for the dead-letter rate")
+	fs.StringVar(&families, "protocol-families", "", "Comma-separated prefix=family labels added to ingest logs (e.g. 41=obd2,55AA=meter)")
+	fs.StringVar(&maskPolicy, "mask-policy", string(parser.MaskFirstRegistered), "How a frame matching several masked bindings is resolved (first-registered, most-specific, highest-priority)")
+	fs.BoolVar(&cfg.ValidateOBD2, "validate-obd2", false, "Reject parsed OBD-II (0x41) values outside their standard PID range, triggering a repair")
+	fs.BoolVar(&cfg.RawFallback, "raw-fallback", false, "Pass frames no parser handles through as {raw, length, first_byte} instead of failing (after discovery, if it fails)")
+	fs.BoolVar(&cfg.CheckDeterminism, "check-determinism", false, "Reject generated parsers whose output differs between two runs on the same sample")
+	fs.BoolVar(&cfg.PrivacyMode, "privacy-mode", env.PrivacyMode, "Mask emails, VINs and phone numbers in samples before sending them to the LLM [$OMNI_PRIVACY_MODE]")
+	fs.BoolVar(&cfg.AuditLog, "audit-log", false, "Append every discovery/repair prompt, raw response and outcome to audit.jsonl in the storage path")
+	fs.IntVar(&cfg.MaxDiscoveriesPerMinute, "max-discoveries-per-minute", 0, "LLM discoveries allowed per minute; frames beyond it are dropped (0 = unlimited)")
+	fs.IntVar(&cfg.MaxSignaturesPerHour, "max-signatures-per-hour", 0, "Distinct signatures discovered per hour; new ones beyond it are dropped (0 = unlimited)")
+	fs.IntVar(&cfg.MaxRepairsPerMinute, "max-repairs-per-minute", 0, "Self-healing repairs allowed per minute; repairs beyond it are queued until the budget refills (0 = unlimited)")
+	fs.IntVar(&cfg.RepairBudget, "repair-budget", 0, "Self-healing repairs allowed per -repair-window; repairs beyond it are queued (0 = unlimited)")
+	fs.DurationVar(&cfg.RepairWindow, "repair-window", parser.DefaultRepairWindow, "Sliding window -repair-budget counts repairs over")
+	fs.BoolVar(&cfg.FailClosed, "fail-closed", false, "Escalate signatures that still fail discovery after all retries")
+	fs.IntVar(&cfg.EscalateAfter, "escalate-after", 1, "Consecutive failed discoveries for a signature before escalating (fail-closed mode)")
+	fs.StringVar(&cfg.EscalationWebhook, "escalation-webhook", "", "URL to POST escalations to (fail-closed mode)")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "Print the resolved configuration as JSON and exit")
+	fs.StringVar(&cfg.ScaffoldName, "name", "", "Protocol name of the parser skeleton (scaffold mode)")
+	fs.StringVar(&signature, "signature", "", "Hex signature of the parser skeleton, e.g. 41 (scaffold mode)")
+	fs.StringVar(&input, "input", "", "Frame to decode field by field, in hex, e.g. 410C1AF8 (inspect mode)")
+	fs.StringVar(&cfg.SnapshotPath, "snapshot", "", "Write a tar.gz snapshot of all parsers, bindings and state to this file and exit")
+	fs.StringVar(&cfg.RestorePath, "restore", "", "Restore a snapshot written by -snapshot before starting")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if cfg.MetricsBuckets, err = parseBuckets(buckets); err != nil {
+		return nil, err
+	}
+	if cfg.ProtocolFamilies, err = parseFamilies(families); err != nil {
+		return nil, err
+	}
+	if cfg.MaskPolicy, err = parser.ParseMaskPolicy(maskPolicy); err != nil {
+		return nil, err
+	}
+	if cfg.EmptyResults, err = parser.ParseEmptyResultPolicy(emptyResults); err != nil {
+		return nil, err
+	}
+	if cfg.Framing, err = parser.ParseFramingMode(framing); err != nil {
+		return nil, err
+	}
+
+	if cfg.ReplayDeadLetters && cfg.DeadLetterStore == "" {
+		return nil, fmt.Errorf("-replay-dead-letters requires -dead-letter-store")
+	}
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return nil, fmt.Errorf("-tls-cert and -tls-key must be given together")
+	}
+	if cfg.TLSClientCA != "" && cfg.TLSCert == "" {
+		return nil, fmt.Errorf("-tls-client-ca requires -tls-cert")
+	}
+	if cfg.Mode == "scaffold" {
+		if cfg.ScaffoldName == "" || signature == "" {
+			return nil, fmt.Errorf("-name and -signature are required in scaffold mode")
+		}
+		hexSig := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(signature), "0x"), "0X")
+		if cfg.ScaffoldSignature, err = hex.DecodeString(hexSig); err != nil || len(cfg.ScaffoldSignature) == 0 {
+			return nil, fmt.Errorf("invalid -signature %q: want hex bytes such as 41 or 55AA", signature)
+		}
+	}
+	if cfg.Mode == "inspect" {
+		if cfg.InspectFrame, err = decodeHexArg(input); err != nil || len(cfg.InspectFrame) == 0 {
+			return nil, fmt.Errorf("inspect mode needs -input, a frame in hex such as 410C1AF8, got %q", input)
+		}
+	}
+	if cfg.Mode == "mqtt" && cfg.MQTTTopic == "" {
+		return nil, fmt.Errorf("-mqtt-topic is required in mqtt mode")
+	}
+	if cfg.Mode == "mcp" && cfg.StdoutResults {
+		return nil, fmt.Errorf("-stdout-results can't be used in mcp mode, which speaks the protocol on stdout")
+	}
+	if cfg.Mode == "replay" && cfg.ReplayFile == "" {
+		return nil, fmt.Errorf("-replay-file is required in replay mode")
+	}
+
+	if cfg.Provider == "exec" {
+		if strings.TrimSpace(cfg.ExecCommand) == "" {
+			return nil, fmt.Errorf("-exec-command is required for the exec provider")
+		}
+		return cfg, nil
+	}
+
+	// Set defaults based on provider if not specified
+	if cfg.Model == "" {
+		switch cfg.Provider {
+		case "ollama":
+			cfg.Model = "deepseek-coder:1.3b"
+		case "openai":
+			cfg.Model = "gpt-4o-mini"
+		case "anthropic":
+			cfg.Model = "claude-3-5-haiku-latest"
+		default:
+			cfg.Model = "gemini-2.0-flash"
+		}
+	}
+
+	if cfg.Endpoint == "" {
+		switch cfg.Provider {
+		case "ollama":
+			cfg.Endpoint = "http://localhost:11434/api/generate"
+		case "openai":
+			cfg.Endpoint = "https://api.openai.com/v1"
+		case "anthropic":
+			cfg.Endpoint = "https://api.anthropic.com/v1"
+		default:
+			cfg.Endpoint = "https://generativelanguage.googleapis.com/v1beta/models"
+		}
+	}
+
+	// The provider may come from a flag, so resolve its key variable only now
+	cfg.ApiKey = os.Getenv(parser.EnvAPIKey)
+	if cfg.ApiKey == "" {
+		cfg.ApiKey = parser.ProviderAPIKey(cfg.Provider)
+	}
+
+	return cfg, nil
+}
+
+// MarshalJSON renders durations in human-readable form and masks secrets
+func (c Config) MarshalJSON() ([]byte, error) {
+	type plain Config
+	mask := func(secret string) string {
+		if secret == "" {
+			return ""
+		}
+		return "********"
+	}
+	return json.Marshal(struct {
+		plain
+		ApiKey           string `json:"api_key"`
+		AuthToken        string `json:"auth_token"`
+		MQTTPassword     string `json:"mqtt_password"`
+		RetryDelay       string `json:"retry_delay"`
+		ParseTimeout     string `json:"parse_timeout"`
+		CompileTimeout   string `json:"compile_timeout"`
+		DeadLetterWindow string `json:"dead_letter_window"`
+		ExecTimeout      string `json:"exec_timeout"`
+		DiscoveryTimeout string `json:"discovery_timeout"`
+		RequestTimeout   string `json:"request_timeout"`
+		IdleTimeout      string `json:"idle_timeout"`
+		DiscoveryGrace   string `json:"discovery_grace"`
+		ResultCacheTTL   string `json:"result_cache_ttl"`
+		RepairWindow     string `json:"repair_window"`
+	}{
+		plain:            plain(c),
+		ApiKey:           mask(c.ApiKey),
+		AuthToken:        mask(c.AuthToken),
+		MQTTPassword:     mask(c.MQTTPassword),
+		RetryDelay:       c.RetryDelay.String(),
+		ParseTimeout:     c.ParseTimeout.String(),
+		CompileTimeout:   c.CompileTimeout.String(),
+		DeadLetterWindow: c.DeadLetterWindow.String(),
+		ExecTimeout:      c.ExecTimeout.String(),
+		DiscoveryTimeout: c.DiscoveryTimeout.String(),
+		RequestTimeout:   c.RequestTimeout.String(),
+		IdleTimeout:      c.IdleTimeout.String(),
+		DiscoveryGrace:   c.DiscoveryGrace.String(),
+		ResultCacheTTL:   c.ResultCacheTTL.String(),
+		RepairWindow:     c.RepairWindow.String(),
+	})
+}
+
+// printConfig writes the resolved configuration as indented JSON with secrets redacted
+func printConfig(w io.Writer, cfg *Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func parseBuckets(s string) ([]float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var buckets []float64
+	for _, part := range strings.Split(s, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket %q: %v", part, err)
+		}
+		buckets = append(buckets, v)
+	}
+	return buckets, nil
+}
+
+func parseFamilies(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	families := make(map[string]string)
+	for _, part := range strings.Split(s, ",") {
+		prefix, family, ok := strings.Cut(strings.TrimSpace(part), "=")
+		prefix = strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(prefix), "0x"))
+		if _, err := hex.DecodeString(prefix); !ok || err != nil || prefix == "" || family == "" {
+			return nil, fmt.Errorf("invalid protocol family %q (want HEXPREFIX=family)", part)
+		}
+		families[prefix] = strings.TrimSpace(family)
+	}
+	return families, nil
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/parser"
+)
+
+func TestParseConfig_Defaults(t *testing.T) {
+	t.Setenv("GEMINI_API_KEY", "")
+
+	cfg, err := parseConfig(nil)
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if cfg.Provider != "gemini" || cfg.Model != "gemini-2.0-flash" {
+		t.Errorf("Unexpected provider defaults: %s/%s", cfg.Provider, cfg.Model)
+	}
+	if cfg.Endpoint != "https://generativelanguage.googleapis.com/v1beta/models" {
+		t.Errorf("Unexpected default endpoint: %s", cfg.Endpoint)
+	}
+	if cfg.ParseTimeout != 50*time.Millisecond {
+		t.Errorf("Unexpected parse timeout: %v", cfg.ParseTimeout)
+	}
+	if cfg.CompileTimeout != 5*time.Second {
+		t.Errorf("Unexpected compile timeout: %v", cfg.CompileTimeout)
+	}
+}
+
+func TestParseConfig_CompileTimeout(t *testing.T) {
+	t.Setenv("OMNI_COMPILE_TIMEOUT", "20s")
+
+	cfg, err := parseConfig(nil)
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if cfg.CompileTimeout != 20*time.Second {
+		t.Errorf("Expected the environment's compile timeout, got %v", cfg.CompileTimeout)
+	}
+
+	cfg, err = parseConfig([]string{"-compile-timeout", "30s"})
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if cfg.CompileTimeout != 30*time.Second {
+		t.Errorf("Expected the flag to override the environment, got %v", cfg.CompileTimeout)
+	}
+
+	t.Setenv("OMNI_COMPILE_TIMEOUT", "soon")
+	if _, err := parseConfig(nil); err == nil {
+		t.Error("Expected error for an invalid OMNI_COMPILE_TIMEOUT")
+	}
+}
+
+func TestPrintConfig_OverridesAndRedaction(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "sk-super-secret")
+
+	cfg, err := parseConfig([]string{
+		"-provider", "openai",
+		"-endpoint", "http://vllm:8000/v1",
+		"-storage-path", "/data/storage",
+		"-dead-letter-window", "5m",
+		"-parse-timeout", "200ms",
+		"-compile-timeout", "15s",
+		"-print-config",
+	})
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if !cfg.PrintConfig {
+		t.Fatal("Expected PrintConfig to be set")
+	}
+
+	var buf bytes.Buffer
+	if err := printConfig(&buf, cfg); err != nil {
+		t.Fatalf("printConfig failed: %v", err)
+	}
+	out := buf.String()
+
+	if strings.Contains(out, "sk-super-secret") {
+		t.Fatalf("API key leaked in printed config:\n%s", out)
+	}
+
+	var printed map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &printed); err != nil {
+		t.Fatalf("Printed config is not valid JSON: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"provider":           "openai",
+		"model":              "gpt-4o-mini",
+		"endpoint":           "http://vllm:8000/v1",
+		"storage_path":       "/data/storage",
+		"dead_letter_window": "5m0s",
+		"parse_timeout":      "200ms",
+		"compile_timeout":    "15s",
+		"request_timeout":    "2m0s",
+		"api_key":            "********",
+	}
+	for k, v := range expected {
+		if printed[k] != v {
+			t.Errorf("Expected %s=%v, got %v", k, v, printed[k])
+		}
+	}
+	if _, ok := printed["PrintConfig"]; ok {
+		t.Error("print-config flag itself should not be printed")
+	}
+}
+
+func TestParseConfig_InvalidBuckets(t *testing.T) {
+	if _, err := parseConfig([]string{"-metrics-buckets", "0.1,abc"}); err == nil {
+		t.Error("Expected error for invalid metrics buckets")
+	}
+}
+
+func TestParseConfig_ExecProvider(t *testing.T) {
+	if _, err := parseConfig([]string{"-provider", "exec"}); err == nil {
+		t.Error("Expected error when exec provider has no command")
+	}
+
+	cfg, err := parseConfig([]string{"-provider", "exec", "-exec-command", "python3 gen.py --quiet", "-exec-timeout", "30s"})
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if cfg.ExecCommand != "python3 gen.py --quiet" || cfg.ExecTimeout != 30*time.Second {
+		t.Errorf("Unexpected exec settings: %q %v", cfg.ExecCommand, cfg.ExecTimeout)
+	}
+	if cfg.Model != "" || cfg.Endpoint != "" {
+		t.Errorf("Expected no HTTP model defaults for exec provider, got %q %q", cfg.Model, cfg.Endpoint)
+	}
+}
+
+func TestParseConfig_ProtocolFamilies(t *testing.T) {
+	cfg, err := parseConfig([]string{"-protocol-families", "41=obd2, 0x55aa=meter"})
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if cfg.ProtocolFamilies["41"] != "obd2" || cfg.ProtocolFamilies["55AA"] != "meter" {
+		t.Errorf("Unexpected families: %v", cfg.ProtocolFamilies)
+	}
+
+	for _, bad := range []string{"41", "zz=obd2", "41="} {
+		if _, err := parseConfig([]string{"-protocol-families", bad}); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestParseConfig_ReplayRequiresFile(t *testing.T) {
+	if _, err := parseConfig([]string{"-mode", "replay"}); err == nil {
+		t.Error("Expected error for replay mode without -replay-file")
+	}
+	cfg, err := parseConfig([]string{"-mode", "replay", "-replay-file", "bench.hex", "-replay-rate", "20"})
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if cfg.ReplayFile != "bench.hex" || cfg.ReplayRate != 20 {
+		t.Errorf("Unexpected replay settings: %q %v", cfg.ReplayFile, cfg.ReplayRate)
+	}
+}
+
+func TestParseConfig_Scaffold(t *testing.T) {
+	cfg, err := parseConfig([]string{"-mode", "scaffold", "-name", "OBDII", "-signature", "0x41"})
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if cfg.ScaffoldName != "OBDII" || !bytes.Equal(cfg.ScaffoldSignature, []byte{0x41}) {
+		t.Errorf("Unexpected scaffold settings: %q %X", cfg.ScaffoldName, cfg.ScaffoldSignature)
+	}
+	if _, err := parseConfig([]string{"-mode", "scaffold", "-name", "OBDII"}); err == nil {
+		t.Error("Expected error for scaffold mode without -signature")
+	}
+	if _, err := parseConfig([]string{"-mode", "scaffold", "-name", "OBDII", "-signature", "4G"}); err == nil {
+		t.Error("Expected error for a non-hex signature")
+	}
+}
+
+func TestParseConfig_ReplayDeadLettersRequiresStore(t *testing.T) {
+	if _, err := parseConfig([]string{"-replay-dead-letters"}); err == nil {
+		t.Error("Expected error for -replay-dead-letters without -dead-letter-store")
+	}
+	cfg, err := parseConfig([]string{"-replay-dead-letters", "-dead-letter-store", "dead.jsonl"})
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if !cfg.ReplayDeadLetters || cfg.DeadLetterStore != "dead.jsonl" {
+		t.Errorf("Unexpected dead-letter settings: %v %q", cfg.ReplayDeadLetters, cfg.DeadLetterStore)
+	}
+}
+
+func TestParseConfig_ResultSinks(t *testing.T) {
+	cfg, err := parseConfig([]string{"-stdout-results", "-result-webhook", "http://collector:8080/frames"})
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if !cfg.StdoutResults || cfg.ResultWebhook != "http://collector:8080/frames" {
+		t.Errorf("Unexpected sink settings: %v %q", cfg.StdoutResults, cfg.ResultWebhook)
+	}
+	if _, err := parseConfig([]string{"-mode", "mcp", "-stdout-results"}); err == nil {
+		t.Error("Expected error for -stdout-results in mcp mode")
+	}
+}
+
+func TestParseConfig_MQTT(t *testing.T) {
+	t.Setenv(envMQTTPassword, "hunter2")
+	if _, err := parseConfig([]string{"-mode", "mqtt"}); err == nil {
+		t.Error("Expected error for mqtt mode without -mqtt-topic")
+	}
+	cfg, err := parseConfig([]string{"-mode", "mqtt", "-mqtt-broker", "mosquitto:1883", "-mqtt-topic", "sensors/+/raw"})
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if cfg.MQTTBroker != "mosquitto:1883" || cfg.MQTTTopic != "sensors/+/raw" || cfg.MQTTResultTopic != parser.DefaultMQTTResultTopic {
+		t.Errorf("Unexpected MQTT settings: %+v", cfg)
+	}
+	if cfg.MQTTPassword != "hunter2" {
+		t.Errorf("Expected the password from $%s, got %q", envMQTTPassword, cfg.MQTTPassword)
+	}
+
+	var buf bytes.Buffer
+	if err := printConfig(&buf, cfg); err != nil {
+		t.Fatalf("printConfig failed: %v", err)
+	}
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Errorf("MQTT password leaked in printed config:\n%s", buf.String())
+	}
+}
+
+func TestParseConfig_EmptyResults(t *testing.T) {
+	if _, err := parseConfig([]string{"-empty-results", "ignore"}); err == nil {
+		t.Error("Expected error for an unknown -empty-results policy")
+	}
+	cfg, err := parseConfig([]string{"-empty-results", "dead-letter"})
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if cfg.EmptyResults != parser.EmptyDeadLetter {
+		t.Errorf("EmptyResults = %q, want %q", cfg.EmptyResults, parser.EmptyDeadLetter)
+	}
+}
+
+func TestParseConfig_Framing(t *testing.T) {
+	if _, err := parseConfig([]string{"-framing", "newline"}); err == nil {
+		t.Error("Expected error for an unknown -framing")
+	}
+	cfg, err := parseConfig([]string{"-framing", "length-prefix"})
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if cfg.Framing != parser.FramingLengthPrefix {
+		t.Errorf("Framing = %q, want %q", cfg.Framing, parser.FramingLengthPrefix)
+	}
+}
+
+func TestParseConfig_TCPAuth(t *testing.T) {
+	t.Setenv("OMNI_AUTH_TOKEN", "tok-from-env")
+	for _, args := range [][]string{
+		{"-tls-cert", "server.pem"},
+		{"-tls-key", "server.key"},
+		{"-tls-client-ca", "ca.pem"},
+	} {
+		if _, err := parseConfig(args); err == nil {
+			t.Errorf("Expected error for incomplete TLS flags %v", args)
+		}
+	}
+
+	cfg, err := parseConfig([]string{"-tls-cert", "server.pem", "-tls-key", "server.key", "-tls-client-ca", "ca.pem"})
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if cfg.AuthToken != "tok-from-env" {
+		t.Errorf("AuthToken = %q, want the environment value", cfg.AuthToken)
+	}
+	var buf bytes.Buffer
+	if err := printConfig(&buf, cfg); err != nil {
+		t.Fatalf("printConfig failed: %v", err)
+	}
+	if strings.Contains(buf.String(), "tok-from-env") {
+		t.Errorf("Auth token leaked in printed config:\n%s", buf.String())
+	}
+}
+
+func TestParseConfig_ProviderOptions(t *testing.T) {
+	cfg, err := parseConfig([]string{"-provider", "openai", "-provider-option", "azure_deployment=parser-gen", "-provider-option", "api_version = 2024-10-21"})
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	want := map[string]string{"azure_deployment": "parser-gen", "api_version": "2024-10-21"}
+	if !reflect.DeepEqual(cfg.ProviderOptions, want) {
+		t.Errorf("ProviderOptions = %v, want %v", cfg.ProviderOptions, want)
+	}
+	if _, err := parseConfig([]string{"-provider-option", "keep_alive"}); err == nil {
+		t.Error("Expected error for a provider option without a value")
+	}
+}
+
+func TestParseConfig_Environment(t *testing.T) {
+	t.Setenv("OMNI_PROVIDER", "anthropic")
+	t.Setenv("OMNI_MODEL", "claude-test")
+	t.Setenv("OMNI_ENDPOINT", "http://proxy/v1")
+	t.Setenv("OMNI_API_KEY", "")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-ant")
+	t.Setenv("OPENAI_API_KEY", "sk-openai")
+	t.Setenv("OMNI_MAX_RETRIES", "5")
+	t.Setenv("OMNI_RETRY_DELAY", "1s")
+	t.Setenv("OMNI_PRIVACY_MODE", "1")
+
+	cfg, err := parseConfig(nil)
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if cfg.Provider != "anthropic" || cfg.Model != "claude-test" || cfg.Endpoint != "http://proxy/v1" || cfg.ApiKey != "sk-ant" {
+		t.Errorf("Unexpected provider settings from the environment: %s %s %s %q", cfg.Provider, cfg.Model, cfg.Endpoint, cfg.ApiKey)
+	}
+	if cfg.MaxRetries != 5 || cfg.RetryDelay != time.Second || !cfg.PrivacyMode {
+		t.Errorf("Unexpected retry/privacy settings from the environment: %d %v %v", cfg.MaxRetries, cfg.RetryDelay, cfg.PrivacyMode)
+	}
+
+	// Flags override the environment, and the key follows the flag's provider
+	cfg, err = parseConfig([]string{"-provider", "openai", "-model", "gpt-test", "-max-retries", "2", "-privacy-mode=false"})
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if cfg.Provider != "openai" || cfg.Model != "gpt-test" || cfg.ApiKey != "sk-openai" || cfg.MaxRetries != 2 || cfg.PrivacyMode {
+		t.Errorf("Flags did not override the environment: %+v", cfg)
+	}
+	if cfg.Endpoint != "http://proxy/v1" || cfg.RetryDelay != time.Second {
+		t.Errorf("Unset flags should keep the environment values: %s %v", cfg.Endpoint, cfg.RetryDelay)
+	}
+}
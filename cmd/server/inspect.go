@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/chuanjin/OmniBridge/internal/parser"
+)
+
+// runInspect prints how frame is decoded: the protocol it routes to, then one row per result
+// field with its value, unit and the frame bytes it was computed from
+func runInspect(out io.Writer, d *parser.Dispatcher, frame []byte) error {
+	in, err := d.Inspect(frame)
+	if err != nil {
+		return fmt.Errorf("inspect % X: %w", frame, err)
+	}
+
+	protocol := in.Protocol
+	if in.Alias != "" {
+		protocol = fmt.Sprintf("%s (%s)", in.Protocol, in.Alias)
+	}
+	fmt.Fprintf(out, "Frame:    % X\n", in.Frame)
+	fmt.Fprintf(out, "Protocol: %s\n", protocol)
+	if in.Family != "" {
+		fmt.Fprintf(out, "Family:   %s\n", in.Family)
+	}
+	fmt.Fprintln(out)
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELD\tVALUE\tUNIT\tBYTES\tSOURCE")
+	for _, f := range in.Fields {
+		unit := f.Unit
+		if unit == "" {
+			unit = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%v\t%s\t%s\t%s\n", f.Name, f.Value, unit, byteRanges(f.Bytes), sourceBytes(in.Frame, f.Bytes))
+	}
+	return tw.Flush()
+}
+
+// byteRanges renders sorted frame offsets compactly, e.g. [2 3 5] as "2-3,5"
+func byteRanges(offsets []int) string {
+	if len(offsets) == 0 {
+		return "-"
+	}
+	var parts []string
+	for i := 0; i < len(offsets); {
+		j := i
+		for j+1 < len(offsets) && offsets[j+1] == offsets[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, fmt.Sprint(offsets[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", offsets[i], offsets[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// sourceBytes shows the frame bytes at offsets in hex
+func sourceBytes(frame []byte, offsets []int) string {
+	if len(offsets) == 0 {
+		return "-"
+	}
+	hexBytes := make([]string, len(offsets))
+	for i, off := range offsets {
+		hexBytes[i] = fmt.Sprintf("%02X", frame[off])
+	}
+	return strings.Join(hexBytes, " ")
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/chuanjin/OmniBridge/internal/parser"
+)
+
+func TestRunInspect_OBD2RPM(t *testing.T) {
+	mgr := parser.NewParserManager(t.TempDir(), "../../seeds")
+	if err := mgr.SeedParsers(); err != nil {
+		t.Fatalf("SeedParsers failed: %v", err)
+	}
+	d := parser.NewDispatcher(mgr)
+	if err := bindStored(d, mgr, ""); err != nil {
+		t.Fatalf("bindStored failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := runInspect(&out, d, []byte{0x41, 0x0C, 0x1A, 0xF8}); err != nil {
+		t.Fatalf("runInspect failed: %v", err)
+	}
+	text := out.String()
+	if !strings.Contains(text, "Protocol: OBDII_Service01") {
+		t.Errorf("expected the OBD-II seed to match, got:\n%s", text)
+	}
+
+	// (0x1A*256 + 0xF8) / 4 = 1726 rpm, computed from bytes 2 and 3
+	var rpmRow []string
+	for _, line := range strings.Split(text, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == "value" {
+			rpmRow = fields
+		}
+	}
+	want := []string{"value", "1726", "rpm", "2-3", "1A", "F8"}
+	if strings.Join(rpmRow, " ") != strings.Join(want, " ") {
+		t.Errorf("value row = %q, want %q in:\n%s", rpmRow, want, text)
+	}
+
+	if err := runInspect(&out, d, []byte{0xEE, 0x00}); err == nil {
+		t.Error("expected an error for a frame no parser is bound to")
+	}
+}
+
+func TestByteRanges(t *testing.T) {
+	tests := []struct {
+		offsets []int
+		want    string
+	}{
+		{nil, "-"},
+		{[]int{4}, "4"},
+		{[]int{2, 3}, "2-3"},
+		{[]int{0, 2, 3, 4, 7}, "0,2-4,7"},
+	}
+	for _, tt := range tests {
+		if got := byteRanges(tt.offsets); got != tt.want {
+			t.Errorf("byteRanges(%v) = %q, want %q", tt.offsets, got, tt.want)
+		}
+	}
+}
@@ -2,31 +2,71 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
-
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/grpcapi"
+	"github.com/chuanjin/OmniBridge/internal/grpcapi/pb"
+	"github.com/chuanjin/OmniBridge/internal/health"
+	"github.com/chuanjin/OmniBridge/internal/httpapi"
 	"github.com/chuanjin/OmniBridge/internal/logger"
 	"github.com/chuanjin/OmniBridge/internal/mcp"
+	"github.com/chuanjin/OmniBridge/internal/metrics"
 	"github.com/chuanjin/OmniBridge/internal/parser"
+	"github.com/chuanjin/OmniBridge/internal/parser/obd2"
+	"github.com/chuanjin/OmniBridge/seeds"
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 func main() {
-	// Define flags
-	provider := flag.String("provider", "gemini", "LLM Provider (gemini, ollama)")
-	model := flag.String("model", "", "Model Name (default: gemini-2.0-flash for gemini, deepseek-coder:1.3b for ollama)")
-	endpoint := flag.String("endpoint", "", "API Endpoint")
-	mode := flag.String("mode", "simulate", "Mode (simulate, server, mcp)")
-	addr := flag.String("addr", ":8080", "TCP Server Address (only used in server mode)")
-	debug := flag.Bool("debug", false, "Enable debug logging")
+	// Load .env file before resolving configuration so API keys are picked up
+	envErr := godotenv.Load()
+
+	// Parser authoring subcommands run on their own and exit
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := run(os.Args[2:], os.Stdout); err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return
+				}
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	cfg, err := parseConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		os.Exit(2)
+	}
 
-	flag.Parse()
+	if cfg.PrintConfig {
+		if err := printConfig(os.Stdout, cfg); err != nil {
+			fmt.Printf("Failed to print config: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	// Initialize Logger
-	if err := logger.Init(*debug); err != nil {
+	if err := logger.Init(cfg.Debug); err != nil {
 		fmt.Printf("Failed to initialize logger: %v\n", err)
 		os.Exit(1)
 	}
@@ -34,14 +74,23 @@ func main() {
 
 	logger.Info("Starting OmniBridge Gateway...")
 
-	// Load .env file
-	err := godotenv.Load()
-	if err != nil {
+	if envErr != nil {
 		logger.Warn("No .env file found, using system environment variables")
 	}
 
+	// Initialize metrics and optionally expose them over HTTP
+	metrics.Init(metrics.Config{LatencyBuckets: cfg.MetricsBuckets})
+	if cfg.MetricsAddr != "" {
+		go serveMetrics(cfg.MetricsAddr)
+	}
+
 	// 1. Initialize the Manager (Persistence) and Dispatcher (Routing)
-	mgr := parser.NewParserManager("./storage", "./seeds")
+	var mgr *parser.ParserManager
+	if cfg.EmbeddedSeeds {
+		mgr = parser.NewParserManagerWithFS(cfg.StoragePath, seeds.FS)
+	} else {
+		mgr = parser.NewParserManager(cfg.StoragePath, cfg.SeedPath)
+	}
 	if err := mgr.SeedParsers(); err != nil {
 		logger.Error("Failed to seed parsers", zap.Error(err))
 	}
@@ -52,7 +101,88 @@ func main() {
 		logger.Error("Error loading parsers", zap.Error(err))
 	}
 
+	if cfg.Mode == "scaffold" {
+		path, err := mgr.Scaffold(cfg.ScaffoldName, cfg.ScaffoldSignature)
+		if err != nil {
+			logger.Fatal("Scaffold failed", zap.Error(err))
+		}
+		fmt.Printf("Parser skeleton written to %s\nEdit Parse, then restart the gateway (or run it with --watch-parsers) to use it.\n", path)
+		return
+	}
+
+	mgr.GetEngine().SetParseTimeout(cfg.ParseTimeout)
+	mgr.GetEngine().SetCompileTimeout(cfg.CompileTimeout)
+	mgr.GetEngine().SetResultCache(cfg.ResultCacheTTL, cfg.ResultCacheEntries)
+	mgr.GetEngine().SetCacheLimit(cfg.ParserCacheSize)
+
+	// Pre-compile everything we know about so the first frames don't pay for yaegi
+	if err := mgr.GetEngine().WarmCache(mgr.Parsers()); err != nil {
+		logger.Warn("Some parsers failed to compile during warm-up", zap.Error(err))
+	}
+
 	dispatcher := parser.NewDispatcher(mgr)
+	// Discoveries queue manifest updates; make sure the last one hits disk
+	defer func() {
+		if err := mgr.FlushManifest(); err != nil {
+			logger.Error("Failed to save manifest", zap.Error(err))
+		}
+	}()
+	deadLetterMonitor := parser.NewDeadLetterMonitor(parser.DeadLetterConfig{
+		Window:    cfg.DeadLetterWindow,
+		Threshold: cfg.DeadLetterThreshold,
+	})
+	dispatcher.SetDeadLetterMonitor(deadLetterMonitor)
+
+	router := parser.NewOutputRouter()
+	router.SetEmptyResultPolicy(cfg.EmptyResults)
+	if cfg.PersistResults != "" {
+		sink, err := parser.NewFileSink(cfg.PersistResults)
+		if err != nil {
+			logger.Fatal("Cannot persist results", zap.Error(err))
+		}
+		defer func() { _ = sink.Close() }()
+		router.AddSink(sink, parser.OutcomeSuccess)
+	}
+	if cfg.OTLPLogs != "" {
+		sink, err := parser.NewOTLPSink(context.Background(), cfg.OTLPLogs)
+		if err != nil {
+			logger.Fatal("Cannot export results to OTLP", zap.Error(err))
+		}
+		defer func() { _ = sink.Close() }()
+		router.AddSink(sink, parser.OutcomeSuccess, parser.OutcomeParseError, parser.OutcomeUnknownProtocol)
+	}
+	if cfg.StdoutResults {
+		router.AddSink(parser.NewWriterSink(os.Stdout), parser.OutcomeSuccess)
+	}
+	if cfg.ResultWebhook != "" {
+		sink := parser.NewWebhookSink(cfg.ResultWebhook, nil)
+		defer func() { _ = sink.Close() }()
+		router.AddSink(sink, parser.OutcomeSuccess)
+	}
+	var deadLetters *parser.DeadLetterStore
+	if cfg.DeadLetterStore != "" {
+		deadLetters, err = parser.NewDeadLetterStore(cfg.DeadLetterStore)
+		if err != nil {
+			logger.Fatal("Cannot store dead letters", zap.Error(err))
+		}
+		defer func() { _ = deadLetters.Close() }()
+		router.AddSink(deadLetters, parser.OutcomeParseError, parser.OutcomeUnknownProtocol)
+	}
+	if cfg.PersistResults != "" || cfg.OTLPLogs != "" || cfg.StdoutResults || cfg.ResultWebhook != "" || deadLetters != nil {
+		dispatcher.SetOutputRouter(router)
+	}
+
+	for prefix, family := range cfg.ProtocolFamilies {
+		dispatcher.BindFamily(hexToBytes(prefix), family)
+	}
+	if cfg.ValidateOBD2 {
+		if _, labelled := cfg.ProtocolFamilies["41"]; !labelled {
+			dispatcher.BindFamily([]byte{obd2.ResponseMode}, obd2.Family)
+		}
+		dispatcher.SetResultValidator(obd2.Family, obd2.Validate)
+	}
+	dispatcher.SetMaskPolicy(cfg.MaskPolicy)
+	dispatcher.SetFallback(cfg.RawFallback)
 
 	// Bind from code-extracted signatures
 	for name, sigHex := range bindings {
@@ -61,51 +191,269 @@ func main() {
 		logger.Info("Auto-Bound parser", zap.String("signature", fmt.Sprintf("0x%X", sig)), zap.String("protocol", name))
 	}
 
-	// Also restore from manifest.json for any that don't have source signatures
-	manifest, err := mgr.LoadManifest()
-	if err == nil {
-		for sigHex, name := range manifest {
-			sig := hexToBytes(sigHex)
-			dispatcher.Bind(sig, name) // Will overwrite if already bound, which is fine
+	// Also restore from manifest.json for any that don't have source signatures, and the
+	// length and masked bindings. Overwriting a code-declared binding is fine.
+	if err := dispatcher.RestoreManifest(); err != nil {
+		logger.Warn("Failed to restore bindings from manifest", zap.Error(err))
+	}
+	if err := mgr.LoadAliases(); err != nil {
+		logger.Warn("Failed to load protocol aliases", zap.Error(err))
+	}
+	if cfg.SpecPath != "" {
+		spec, err := parser.LoadSpec(cfg.SpecPath)
+		if err != nil {
+			logger.Fatal("Failed to load protocol spec", zap.Error(err))
+		}
+		if err := dispatcher.ApplySpec(spec); err != nil {
+			logger.Fatal("Failed to apply protocol spec", zap.String("path", cfg.SpecPath), zap.Error(err))
+		}
+		logger.Info("Applied protocol spec", zap.String("path", cfg.SpecPath), zap.Int("protocols", len(spec.Protocols)))
+	}
+
+	if cfg.Mode == "inspect" {
+		if err := runInspect(os.Stdout, dispatcher, cfg.InspectFrame); err != nil {
+			logger.Fatal("Inspect failed", zap.Error(err))
+		}
+		return
+	}
+
+	discCfg := parser.DiscoveryConfig{
+		Provider: cfg.Provider,
+		Model:    cfg.Model,
+		Endpoint: cfg.Endpoint,
+		ApiKey:   cfg.ApiKey,
+		Stream:   cfg.Stream,
+
+		ProviderOptions: cfg.ProviderOptions,
+
+		MaxRetries: cfg.MaxRetries,
+		RetryDelay: cfg.RetryDelay,
+
+		SystemPromptPath: cfg.SystemPromptPath,
+
+		Command:        strings.Fields(cfg.ExecCommand),
+		CommandTimeout: cfg.ExecTimeout,
+		Timeout:        cfg.DiscoveryTimeout,
+		RequestTimeout: cfg.RequestTimeout,
+
+		CheckDeterminism: cfg.CheckDeterminism,
+		PrivacyMode:      cfg.PrivacyMode,
+		AuditLog:         cfg.AuditLog,
+
+		MaxDiscoveriesPerMinute: cfg.MaxDiscoveriesPerMinute,
+		MaxSignaturesPerHour:    cfg.MaxSignaturesPerHour,
+
+		MaxRepairsPerMinute: cfg.MaxRepairsPerMinute,
+		RepairBudget:        cfg.RepairBudget,
+		RepairWindow:        cfg.RepairWindow,
+
+		FailClosed:    cfg.FailClosed,
+		EscalateAfter: cfg.EscalateAfter,
+	}
+	discovery := parser.NewDiscoveryService(dispatcher, mgr, discCfg)
+	if err := discovery.LoadSystemPrompt(); err != nil {
+		logger.Warn("System prompt not loaded; discovery will fail until it exists", zap.Error(err))
+	}
+	if cfg.EscalationWebhook != "" {
+		discovery.AddEscalationHook(parser.WebhookEscalation(cfg.EscalationWebhook, nil))
+	}
+
+	gateway := parser.NewGateway(dispatcher, discovery)
+	if deadLetters != nil {
+		gateway.SetDeadLetterStore(deadLetters)
+	}
+	if cfg.RestorePath != "" {
+		if err := restoreSnapshot(gateway, cfg.RestorePath); err != nil {
+			logger.Fatal("Restore failed", zap.String("path", cfg.RestorePath), zap.Error(err))
+		}
+		if err := mgr.GetEngine().WarmCache(mgr.Parsers()); err != nil {
+			logger.Warn("Some restored parsers failed to compile during warm-up", zap.Error(err))
 		}
 	}
+	if cfg.SnapshotPath != "" {
+		if err := writeSnapshot(gateway, cfg.SnapshotPath); err != nil {
+			logger.Fatal("Snapshot failed", zap.String("path", cfg.SnapshotPath), zap.Error(err))
+		}
+		logger.Info("Snapshot written", zap.String("path", cfg.SnapshotPath))
+		return
+	}
 
-	// Set defaults based on provider if not specified
-	effectiveModel := *model
-	if effectiveModel == "" {
-		if *provider == "ollama" {
-			effectiveModel = "deepseek-coder:1.3b"
-		} else {
-			effectiveModel = "gemini-2.0-flash"
+	if cfg.Reconcile {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		report := gateway.ReconcileAll(ctx)
+		stop()
+		for id, failure := range report.Failures {
+			logger.Warn("Quarantined parser", zap.String("protocol", id), zap.String("failure", failure))
+		}
+	}
+	if cfg.ReplayDeadLetters {
+		if _, err := gateway.ReplayDeadLetters(); err != nil {
+			logger.Error("Dead-letter replay failed", zap.Error(err))
 		}
 	}
 
-	effectiveEndpoint := *endpoint
-	if effectiveEndpoint == "" {
-		if *provider == "ollama" {
-			effectiveEndpoint = "http://localhost:11434/api/generate"
-		} else {
-			effectiveEndpoint = "https://generativelanguage.googleapis.com/v1beta/models"
+	if cfg.WatchParsers {
+		watchCtx, stopWatching := context.WithCancel(context.Background())
+		defer stopWatching()
+		if err := mgr.WatchParsers(watchCtx); err != nil {
+			logger.Warn("Parser hot-reload disabled", zap.Error(err))
 		}
 	}
 
-	cfg := parser.DiscoveryConfig{
-		Provider: *provider,
-		Model:    effectiveModel,
-		Endpoint: effectiveEndpoint,
+	if cfg.HealthAddr != "" {
+		go serveHealth(cfg.HealthAddr, health.NewServer(dispatcher, discovery.Ping,
+			health.Check{Name: "discovery", Healthy: discovery.Healthy},
+			health.Check{Name: "dead_letters", Healthy: deadLetterMonitor.Healthy},
+		))
 	}
-	discovery := parser.NewDiscoveryService(dispatcher, mgr, cfg)
 
 	// 3. Mode selection
-	if *mode == "server" {
-		srv := parser.NewTCPServer(*addr, dispatcher, discovery)
-		if err := srv.ListenAndServe(); err != nil {
+	if cfg.Mode == "server" {
+		srv := parser.NewTCPServer(cfg.Addr, dispatcher, discovery)
+		srv.SetIdleTimeout(cfg.IdleTimeout)
+		srv.SetMaxConnections(cfg.MaxConnections)
+		srv.SetReadBufferSize(cfg.ReadBufferSize)
+		srv.SetMaxFrameSize(cfg.MaxFrameSize)
+		srv.SetFraming(cfg.Framing)
+		srv.SetDiscoveryGrace(cfg.DiscoveryGrace, cfg.DiscoveryGraceFrames)
+		srv.SetAuthToken(cfg.AuthToken)
+		srv.SetPinProtocol(cfg.PinProtocol)
+		if cfg.TLSCert != "" {
+			tlsConfig, err := loadTLSConfig(cfg.TLSCert, cfg.TLSKey, cfg.TLSClientCA)
+			if err != nil {
+				logger.Fatal("Invalid TLS configuration", zap.Error(err))
+			}
+			srv.SetTLSConfig(tlsConfig)
+		}
+
+		// Ctrl-C / SIGTERM triggers a graceful shutdown
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			logger.Info("Shutdown signal received, draining connections...")
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				logger.Error("Graceful shutdown incomplete", zap.Error(err))
+			}
+		}()
+
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, parser.ErrServerClosed) {
 			logger.Fatal("Server failed", zap.Error(err))
 		}
 		return
 	}
 
-	if *mode == "mcp" {
+	if cfg.Mode == "http" {
+		srv := &http.Server{
+			Addr:              cfg.Addr,
+			Handler:           httpapi.NewServer(dispatcher, mgr, discovery),
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			logger.Info("Shutdown signal received, stopping HTTP API...")
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				logger.Error("Graceful shutdown incomplete", zap.Error(err))
+			}
+		}()
+
+		logger.Info("OmniBridge HTTP API listening", zap.String("addr", cfg.Addr))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal("HTTP API failed", zap.Error(err))
+		}
+		return
+	}
+
+	if cfg.Mode == "grpc" {
+		lis, err := net.Listen("tcp", cfg.GRPCAddr)
+		if err != nil {
+			logger.Fatal("gRPC listen failed", zap.Error(err))
+		}
+		srv := grpc.NewServer()
+		pb.RegisterOmniBridgeServer(srv, grpcapi.NewServer(dispatcher, mgr, discovery))
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			logger.Info("Shutdown signal received, stopping gRPC API...")
+			done := make(chan struct{})
+			go func() {
+				srv.GracefulStop()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				logger.Error("Graceful shutdown incomplete, closing remaining RPCs")
+				srv.Stop()
+			}
+		}()
+
+		logger.Info("OmniBridge gRPC API listening", zap.String("addr", cfg.GRPCAddr))
+		if err := srv.Serve(lis); err != nil {
+			logger.Fatal("gRPC API failed", zap.Error(err))
+		}
+		return
+	}
+
+	if cfg.Mode == "ws" {
+		wsServer := parser.NewWSServer(dispatcher, discovery)
+		srv := &http.Server{
+			Addr:              cfg.Addr,
+			Handler:           wsServer,
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			logger.Info("Shutdown signal received, closing WebSocket clients...")
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			// Upgraded connections are hijacked, so the http.Server doesn't track them
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				logger.Error("Graceful shutdown incomplete", zap.Error(err))
+			}
+			if err := wsServer.Shutdown(shutdownCtx); err != nil {
+				logger.Error("Graceful shutdown incomplete", zap.Error(err))
+			}
+		}()
+
+		logger.Info("OmniBridge WebSocket server listening", zap.String("addr", cfg.Addr))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal("WebSocket server failed", zap.Error(err))
+		}
+		return
+	}
+
+	if cfg.Mode == "mqtt" {
+		bridge := parser.NewMQTTBridge(dispatcher, discovery, parser.MQTTConfig{
+			Broker:      cfg.MQTTBroker,
+			Topic:       cfg.MQTTTopic,
+			ResultTopic: cfg.MQTTResultTopic,
+			ClientID:    cfg.MQTTClientID,
+			Username:    cfg.MQTTUsername,
+			Password:    cfg.MQTTPassword,
+		})
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		logger.Info("OmniBridge MQTT bridge starting", zap.String("broker", cfg.MQTTBroker), zap.String("topic", cfg.MQTTTopic))
+		if err := bridge.Run(ctx); err != nil {
+			logger.Fatal("MQTT bridge failed", zap.Error(err))
+		}
+		return
+	}
+
+	if cfg.Mode == "mcp" {
 		mcpServer := mcp.NewServer(dispatcher, mgr, discovery)
 		ctx := context.Background()
 		if err := mcpServer.Run(ctx); err != nil {
@@ -114,6 +462,26 @@ func main() {
 		return
 	}
 
+	if cfg.Mode == "replay" {
+		f, err := os.Open(cfg.ReplayFile)
+		if err != nil {
+			logger.Fatal("Cannot open replay file", zap.Error(err))
+		}
+		frames, err := readReplayFrames(f)
+		_ = f.Close()
+		if err != nil {
+			logger.Fatal("Cannot read replay file", zap.String("path", cfg.ReplayFile), zap.Error(err))
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		logger.Info("Replaying capture", zap.String("path", cfg.ReplayFile), zap.Int("frames", len(frames)), zap.Float64("rate", cfg.ReplayRate))
+		summary := replay(ctx, frames, dispatcher, discovery, cfg.ReplayRate, os.Stdout)
+		fmt.Println("--------------------------------------------")
+		fmt.Println("Replay done:", summary)
+		return
+	}
+
 	// 4. Simulated Data Stream (Original Loop)
 	logger.Info("OmniBridge Gateway Started (SIMULATION MODE)")
 	fmt.Println("--------------------------------------------")
@@ -136,66 +504,76 @@ func main() {
 		{0x99, 0xFF, 0x00, 0x01},       // NEW Signature
 	}
 
+	// The AI will identify the signature of unknown frames from the raw data
+	simulationHint := "Industrial Voltage Sensor. Byte 0 is Signature, Byte 1-2 is Big-Endian Voltage (mV)."
 	for _, raw := range incomingStream {
-		// Attempt to parse using cached/known logic
-		result, proto, err := dispatcher.Ingest(raw)
-
-		// 5. SELF-HEALING: If ingest fails for a KNOWN protocol (e.g., compile error), try to repair it
-		if err != nil && proto != "" {
-			logger.Warn("Detected error in protocol", zap.String("protocol", proto), zap.Error(err))
-			logger.Info("Attempting repair", zap.String("protocol", proto))
-
-			// Get the faulty code from the manager to send back to the AI
-			faultyCode, exists := mgr.GetParserCode(proto)
-			if !exists {
-				logger.Error("Could not find code for protocol to repair", zap.String("protocol", proto))
-				continue
-			}
-
-			// With generalized discovery, we can just pass nil or empty signature
-			// if we want the AI to re-verify it, or use the one we know.
-			sig := []byte(nil)
-
-			_, repairErr := discovery.RepairParser(proto, faultyCode, err.Error(), raw, sig)
-			if repairErr != nil {
-				logger.Error("Repair failed", zap.Error(repairErr))
-				continue
-			}
-
-			// Re-attempt ingestion after repair
-			result, proto, err = dispatcher.Ingest(raw)
-			if err == nil {
-				logger.Info("Protocol repaired successfully", zap.String("protocol", proto))
-			}
+		result, proto, _, err := parser.ProcessFrame(context.Background(), dispatcher, discovery, raw, simulationHint)
+		if err == nil {
+			logger.Info("Success", zap.String("protocol", proto), zap.Any("data", result))
 		}
+	}
 
-		// 6. DISCOVERY: If protocol is entirely unknown
-		if err != nil && proto == "" {
-			logger.Info("Unknown signature, consulting AI", zap.String("signature", fmt.Sprintf("0x%X", raw[0])))
+	fmt.Println("--------------------------------------------")
+	fmt.Println("Done. Check the ./storage folder for the generated Go parsers.")
+}
 
-			// Trigger Discovery Mode
-			// Trigger Discovery Mode WITHOUT hardcoded signatures
-			// The AI will now identify the signature from the raw data.
-			context := "Industrial Voltage Sensor. Byte 0 is Signature, Byte 1-2 is Big-Endian Voltage (mV)."
-			newName, discErr := discovery.DiscoverNewProtocol(raw, nil, context)
+func writeSnapshot(g *parser.Gateway, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := g.Snapshot(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
 
-			if discErr != nil {
-				logger.Error("Discovery failed", zap.Error(discErr))
-				continue
-			}
+func restoreSnapshot(g *parser.Gateway, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	return g.Restore(f)
+}
 
-			// Re-attempt Ingestion
-			result, proto, _ = dispatcher.Ingest(raw)
-			logger.Info("New Protocol Learned", zap.String("protocol", newName))
+// loadTLSConfig builds the TCP server's TLS settings, requiring client certificates when clientCA is set
+func loadTLSConfig(certFile, keyFile, clientCA string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if clientCA != "" {
+		pem, err := os.ReadFile(clientCA)
+		if err != nil {
+			return nil, err
 		}
-
-		if err == nil {
-			logger.Info("Success", zap.String("protocol", proto), zap.Any("data", result))
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", clientCA)
 		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
 	}
+	return cfg, nil
+}
 
-	fmt.Println("--------------------------------------------")
-	fmt.Println("Done. Check the ./storage folder for the generated Go parsers.")
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	logger.Info("Metrics server listening", zap.String("address", addr))
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		logger.Error("Metrics server failed", zap.Error(err))
+	}
+}
+
+func serveHealth(addr string, probes *health.Server) {
+	logger.Info("Health probes listening", zap.String("address", addr))
+	if err := http.ListenAndServe(addr, probes); err != nil {
+		logger.Error("Health server failed", zap.Error(err))
+	}
 }
 
 func hexToBytes(h string) []byte {
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/parser"
+)
+
+// replayContextHint is the protocol hint given to the LLM for frames discovered during a replay
+const replayContextHint = "Captured binary traffic replayed offline."
+
+// replaySummary counts how the frames of a replay were handled
+type replaySummary struct {
+	Frames     int
+	Parsed     int // Parsed by an existing parser
+	Repaired   int // Parsed after repairing the parser
+	Discovered int // Parsed after discovering the protocol
+	Failed     int
+}
+
+func (s replaySummary) String() string {
+	return fmt.Sprintf("%d frames: %d parsed, %d repaired, %d discovered, %d failed",
+		s.Frames, s.Parsed, s.Repaired, s.Discovered, s.Failed)
+}
+
+// readReplayFrames reads a capture as newline-separated hex frames (blank lines and
+// '#' comments skipped, optional 0x prefix and inner spaces allowed) or, when the input
+// isn't hex text, as a binary dump of frames each prefixed by a big-endian uint16 length.
+func readReplayFrames(r io.Reader) ([][]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if frames, ok := parseHexFrames(data); ok {
+		return frames, nil
+	}
+
+	var frames [][]byte
+	for offset := 0; offset < len(data); {
+		if len(data)-offset < 2 {
+			return nil, fmt.Errorf("truncated length prefix at offset %d", offset)
+		}
+		n := int(binary.BigEndian.Uint16(data[offset:]))
+		offset += 2
+		if n == 0 || len(data)-offset < n {
+			return nil, fmt.Errorf("invalid frame length %d at offset %d", n, offset-2)
+		}
+		frames = append(frames, data[offset:offset+n])
+		offset += n
+	}
+	return frames, nil
+}
+
+// parseHexFrames decodes data as hex lines; ok is false if any line isn't hex
+func parseHexFrames(data []byte) ([][]byte, bool) {
+	var frames [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(strings.TrimPrefix(line, "0x"), "0X")
+		frame, err := hex.DecodeString(strings.Join(strings.Fields(line), ""))
+		if err != nil || len(frame) == 0 {
+			return nil, false
+		}
+		frames = append(frames, frame)
+	}
+	return frames, scanner.Err() == nil
+}
+
+// replay runs every frame through the servers' ingest pipeline in order, at most rate frames per
+// second (0 for no limit), printing each result to out. It stops early if ctx is cancelled.
+func replay(ctx context.Context, frames [][]byte, d *parser.Dispatcher, disc *parser.DiscoveryService, rate float64, out io.Writer) replaySummary {
+	var summary replaySummary
+	var tick <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for i, raw := range frames {
+		if i > 0 && tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return summary
+			}
+		}
+		if ctx.Err() != nil {
+			return summary
+		}
+
+		result, proto, outcome, err := parser.ProcessFrame(ctx, d, disc, raw, replayContextHint)
+		summary.Frames++
+		switch outcome {
+		case parser.FrameParsed:
+			summary.Parsed++
+		case parser.FrameRepaired:
+			summary.Repaired++
+		case parser.FrameDiscovered:
+			summary.Discovered++
+		default:
+			summary.Failed++
+		}
+
+		if err != nil {
+			_, _ = fmt.Fprintf(out, "%X\t%s\terror: %v\n", raw, proto, err)
+		} else {
+			_, _ = fmt.Fprintf(out, "%X\t%s\t%v\n", raw, proto, result)
+		}
+	}
+	return summary
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/parser"
+)
+
+// newTestDiscovery returns a discovery service over a fresh storage directory, with a
+// placeholder system prompt unless cfg names one
+func newTestDiscovery(t *testing.T, cfg parser.DiscoveryConfig) (*parser.DiscoveryService, *parser.Dispatcher, *parser.ParserManager) {
+	t.Helper()
+	if cfg.SystemPromptPath == "" {
+		cfg.SystemPromptPath = filepath.Join(t.TempDir(), "system_prompt.md")
+		if err := os.WriteFile(cfg.SystemPromptPath, []byte("System prompt context"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mgr := parser.NewParserManager(t.TempDir(), "")
+	dispatcher := parser.NewDispatcher(mgr)
+	return parser.NewDiscoveryService(dispatcher, mgr, cfg), dispatcher, mgr
+}
+
+func TestReadReplayFrames(t *testing.T) {
+	hexDump := "# captured on bench\n410C1AF8\n\n0x55 AA 03\n"
+	frames, err := readReplayFrames(strings.NewReader(hexDump))
+	if err != nil {
+		t.Fatalf("hex dump: %v", err)
+	}
+	if want := [][]byte{{0x41, 0x0C, 0x1A, 0xF8}, {0x55, 0xAA, 0x03}}; !reflect.DeepEqual(frames, want) {
+		t.Errorf("hex dump frames = %X, want %X", frames, want)
+	}
+
+	binDump := []byte{0x00, 0x02, 0x41, 0x0D, 0x00, 0x03, 0x55, 0xAA, 0x03}
+	frames, err = readReplayFrames(bytes.NewReader(binDump))
+	if err != nil {
+		t.Fatalf("binary dump: %v", err)
+	}
+	if want := [][]byte{{0x41, 0x0D}, {0x55, 0xAA, 0x03}}; !reflect.DeepEqual(frames, want) {
+		t.Errorf("binary dump frames = %X, want %X", frames, want)
+	}
+
+	if _, err := readReplayFrames(bytes.NewReader([]byte{0x00, 0x05, 0x41})); err == nil {
+		t.Error("expected error for a truncated binary frame")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	// Discovery learns 0x55AA frames; repairs fix the 0x77 parser
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		code := `// Signature: 55AA
+package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": int(data[2])} }`
+		if strings.Contains(string(body), "ERROR TO FIX") {
+			code = `// Signature: 77
+package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"ok": true} }`
+		}
+		_ = json.NewEncoder(w).Encode(parser.OllamaResponse{Response: code})
+	}))
+	defer llm.Close()
+
+	disc, d, mgr := newTestDiscovery(t, parser.DiscoveryConfig{Provider: "ollama", Endpoint: llm.URL})
+	parsers := map[string]string{
+		"rpm": `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"rpm": (int(data[2])*256 + int(data[3])) / 4} }`,
+		"broken": `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": int(data[9])} }`,
+		"off": `package dynamic
+func Parse(data []byte) map[string]interface{} { return nil }`,
+	}
+	for id, code := range parsers {
+		if err := mgr.RegisterParser(id, code); err != nil {
+			t.Fatalf("RegisterParser(%s) failed: %v", id, err)
+		}
+	}
+	d.Bind([]byte{0x41, 0x0C}, "rpm")
+	d.Bind([]byte{0x77}, "broken")
+	d.Bind([]byte{0x88}, "off")
+	d.SetEnabled("off", false)
+
+	capture := "410C1AF8\n77\n55AA2A\n55AA2B\n88\n410C0000\n"
+	frames, err := readReplayFrames(strings.NewReader(capture))
+	if err != nil {
+		t.Fatalf("readReplayFrames failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	start := time.Now()
+	summary := replay(context.Background(), frames, d, disc, 100, &out)
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("replay at 100 frames/s took %v for %d frames", elapsed, len(frames))
+	}
+
+	want := replaySummary{Frames: 6, Parsed: 3, Repaired: 1, Discovered: 1, Failed: 1}
+	if summary != want {
+		t.Errorf("summary = %+v, want %+v", summary, want)
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != len(frames) {
+		t.Errorf("expected one output line per frame, got %d:\n%s", lines, out.String())
+	}
+}
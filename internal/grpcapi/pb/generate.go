@@ -0,0 +1,4 @@
+// Package pb holds the generated protobuf and gRPC code for the OmniBridge service.
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative omnibridge.proto
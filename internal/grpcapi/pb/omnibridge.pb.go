@@ -0,0 +1,482 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        v5.29.3
+// source: omnibridge.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ParseRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ParseRequest) Reset() {
+	*x = ParseRequest{}
+	mi := &file_omnibridge_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ParseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ParseRequest) ProtoMessage() {}
+
+func (x *ParseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_omnibridge_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ParseRequest.ProtoReflect.Descriptor instead.
+func (*ParseRequest) Descriptor() ([]byte, []int) {
+	return file_omnibridge_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ParseRequest) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type ParseResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Protocol      string                 `protobuf:"bytes,1,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	Alias         string                 `protobuf:"bytes,2,opt,name=alias,proto3" json:"alias,omitempty"`
+	Result        *structpb.Struct       `protobuf:"bytes,3,opt,name=result,proto3" json:"result,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ParseResponse) Reset() {
+	*x = ParseResponse{}
+	mi := &file_omnibridge_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ParseResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ParseResponse) ProtoMessage() {}
+
+func (x *ParseResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_omnibridge_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ParseResponse.ProtoReflect.Descriptor instead.
+func (*ParseResponse) Descriptor() ([]byte, []int) {
+	return file_omnibridge_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ParseResponse) GetProtocol() string {
+	if x != nil {
+		return x.Protocol
+	}
+	return ""
+}
+
+func (x *ParseResponse) GetAlias() string {
+	if x != nil {
+		return x.Alias
+	}
+	return ""
+}
+
+func (x *ParseResponse) GetResult() *structpb.Struct {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+type DiscoverRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sample        []byte                 `protobuf:"bytes,1,opt,name=sample,proto3" json:"sample,omitempty"`
+	Context       string                 `protobuf:"bytes,2,opt,name=context,proto3" json:"context,omitempty"` // Optional hint about the protocol
+	Model         string                 `protobuf:"bytes,3,opt,name=model,proto3" json:"model,omitempty"`     // Optional LLM model overriding the configured one
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DiscoverRequest) Reset() {
+	*x = DiscoverRequest{}
+	mi := &file_omnibridge_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DiscoverRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiscoverRequest) ProtoMessage() {}
+
+func (x *DiscoverRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_omnibridge_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiscoverRequest.ProtoReflect.Descriptor instead.
+func (*DiscoverRequest) Descriptor() ([]byte, []int) {
+	return file_omnibridge_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *DiscoverRequest) GetSample() []byte {
+	if x != nil {
+		return x.Sample
+	}
+	return nil
+}
+
+func (x *DiscoverRequest) GetContext() string {
+	if x != nil {
+		return x.Context
+	}
+	return ""
+}
+
+func (x *DiscoverRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+type DiscoverResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ProtocolName  string                 `protobuf:"bytes,1,opt,name=protocol_name,json=protocolName,proto3" json:"protocol_name,omitempty"`
+	Signature     string                 `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DiscoverResponse) Reset() {
+	*x = DiscoverResponse{}
+	mi := &file_omnibridge_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DiscoverResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiscoverResponse) ProtoMessage() {}
+
+func (x *DiscoverResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_omnibridge_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiscoverResponse.ProtoReflect.Descriptor instead.
+func (*DiscoverResponse) Descriptor() ([]byte, []int) {
+	return file_omnibridge_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *DiscoverResponse) GetProtocolName() string {
+	if x != nil {
+		return x.ProtocolName
+	}
+	return ""
+}
+
+func (x *DiscoverResponse) GetSignature() string {
+	if x != nil {
+		return x.Signature
+	}
+	return ""
+}
+
+type ListProtocolsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListProtocolsRequest) Reset() {
+	*x = ListProtocolsRequest{}
+	mi := &file_omnibridge_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListProtocolsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListProtocolsRequest) ProtoMessage() {}
+
+func (x *ListProtocolsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_omnibridge_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListProtocolsRequest.ProtoReflect.Descriptor instead.
+func (*ListProtocolsRequest) Descriptor() ([]byte, []int) {
+	return file_omnibridge_proto_rawDescGZIP(), []int{4}
+}
+
+type ProtocolInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Alias         string                 `protobuf:"bytes,2,opt,name=alias,proto3" json:"alias,omitempty"`
+	Signature     string                 `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"` // Hex-encoded
+	Summary       string                 `protobuf:"bytes,4,opt,name=summary,proto3" json:"summary,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProtocolInfo) Reset() {
+	*x = ProtocolInfo{}
+	mi := &file_omnibridge_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProtocolInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProtocolInfo) ProtoMessage() {}
+
+func (x *ProtocolInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_omnibridge_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProtocolInfo.ProtoReflect.Descriptor instead.
+func (*ProtocolInfo) Descriptor() ([]byte, []int) {
+	return file_omnibridge_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ProtocolInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ProtocolInfo) GetAlias() string {
+	if x != nil {
+		return x.Alias
+	}
+	return ""
+}
+
+func (x *ProtocolInfo) GetSignature() string {
+	if x != nil {
+		return x.Signature
+	}
+	return ""
+}
+
+func (x *ProtocolInfo) GetSummary() string {
+	if x != nil {
+		return x.Summary
+	}
+	return ""
+}
+
+type ListProtocolsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Protocols     []*ProtocolInfo        `protobuf:"bytes,1,rep,name=protocols,proto3" json:"protocols,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListProtocolsResponse) Reset() {
+	*x = ListProtocolsResponse{}
+	mi := &file_omnibridge_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListProtocolsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListProtocolsResponse) ProtoMessage() {}
+
+func (x *ListProtocolsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_omnibridge_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListProtocolsResponse.ProtoReflect.Descriptor instead.
+func (*ListProtocolsResponse) Descriptor() ([]byte, []int) {
+	return file_omnibridge_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListProtocolsResponse) GetProtocols() []*ProtocolInfo {
+	if x != nil {
+		return x.Protocols
+	}
+	return nil
+}
+
+var File_omnibridge_proto protoreflect.FileDescriptor
+
+const file_omnibridge_proto_rawDesc = "" +
+	"\n" +
+	"\x10omnibridge.proto\x12\romnibridge.v1\x1a\x1cgoogle/protobuf/struct.proto\"\"\n" +
+	"\fParseRequest\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\"r\n" +
+	"\rParseResponse\x12\x1a\n" +
+	"\bprotocol\x18\x01 \x01(\tR\bprotocol\x12\x14\n" +
+	"\x05alias\x18\x02 \x01(\tR\x05alias\x12/\n" +
+	"\x06result\x18\x03 \x01(\v2\x17.google.protobuf.StructR\x06result\"Y\n" +
+	"\x0fDiscoverRequest\x12\x16\n" +
+	"\x06sample\x18\x01 \x01(\fR\x06sample\x12\x18\n" +
+	"\acontext\x18\x02 \x01(\tR\acontext\x12\x14\n" +
+	"\x05model\x18\x03 \x01(\tR\x05model\"U\n" +
+	"\x10DiscoverResponse\x12#\n" +
+	"\rprotocol_name\x18\x01 \x01(\tR\fprotocolName\x12\x1c\n" +
+	"\tsignature\x18\x02 \x01(\tR\tsignature\"\x16\n" +
+	"\x14ListProtocolsRequest\"p\n" +
+	"\fProtocolInfo\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05alias\x18\x02 \x01(\tR\x05alias\x12\x1c\n" +
+	"\tsignature\x18\x03 \x01(\tR\tsignature\x12\x18\n" +
+	"\asummary\x18\x04 \x01(\tR\asummary\"R\n" +
+	"\x15ListProtocolsResponse\x129\n" +
+	"\tprotocols\x18\x01 \x03(\v2\x1b.omnibridge.v1.ProtocolInfoR\tprotocols2\xf9\x01\n" +
+	"\n" +
+	"OmniBridge\x12B\n" +
+	"\x05Parse\x12\x1b.omnibridge.v1.ParseRequest\x1a\x1c.omnibridge.v1.ParseResponse\x12K\n" +
+	"\bDiscover\x12\x1e.omnibridge.v1.DiscoverRequest\x1a\x1f.omnibridge.v1.DiscoverResponse\x12Z\n" +
+	"\rListProtocols\x12#.omnibridge.v1.ListProtocolsRequest\x1a$.omnibridge.v1.ListProtocolsResponseB4Z2github.com/chuanjin/OmniBridge/internal/grpcapi/pbb\x06proto3"
+
+var (
+	file_omnibridge_proto_rawDescOnce sync.Once
+	file_omnibridge_proto_rawDescData []byte
+)
+
+func file_omnibridge_proto_rawDescGZIP() []byte {
+	file_omnibridge_proto_rawDescOnce.Do(func() {
+		file_omnibridge_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_omnibridge_proto_rawDesc), len(file_omnibridge_proto_rawDesc)))
+	})
+	return file_omnibridge_proto_rawDescData
+}
+
+var file_omnibridge_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_omnibridge_proto_goTypes = []any{
+	(*ParseRequest)(nil),          // 0: omnibridge.v1.ParseRequest
+	(*ParseResponse)(nil),         // 1: omnibridge.v1.ParseResponse
+	(*DiscoverRequest)(nil),       // 2: omnibridge.v1.DiscoverRequest
+	(*DiscoverResponse)(nil),      // 3: omnibridge.v1.DiscoverResponse
+	(*ListProtocolsRequest)(nil),  // 4: omnibridge.v1.ListProtocolsRequest
+	(*ProtocolInfo)(nil),          // 5: omnibridge.v1.ProtocolInfo
+	(*ListProtocolsResponse)(nil), // 6: omnibridge.v1.ListProtocolsResponse
+	(*structpb.Struct)(nil),       // 7: google.protobuf.Struct
+}
+var file_omnibridge_proto_depIdxs = []int32{
+	7, // 0: omnibridge.v1.ParseResponse.result:type_name -> google.protobuf.Struct
+	5, // 1: omnibridge.v1.ListProtocolsResponse.protocols:type_name -> omnibridge.v1.ProtocolInfo
+	0, // 2: omnibridge.v1.OmniBridge.Parse:input_type -> omnibridge.v1.ParseRequest
+	2, // 3: omnibridge.v1.OmniBridge.Discover:input_type -> omnibridge.v1.DiscoverRequest
+	4, // 4: omnibridge.v1.OmniBridge.ListProtocols:input_type -> omnibridge.v1.ListProtocolsRequest
+	1, // 5: omnibridge.v1.OmniBridge.Parse:output_type -> omnibridge.v1.ParseResponse
+	3, // 6: omnibridge.v1.OmniBridge.Discover:output_type -> omnibridge.v1.DiscoverResponse
+	6, // 7: omnibridge.v1.OmniBridge.ListProtocols:output_type -> omnibridge.v1.ListProtocolsResponse
+	5, // [5:8] is the sub-list for method output_type
+	2, // [2:5] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_omnibridge_proto_init() }
+func file_omnibridge_proto_init() {
+	if File_omnibridge_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_omnibridge_proto_rawDesc), len(file_omnibridge_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_omnibridge_proto_goTypes,
+		DependencyIndexes: file_omnibridge_proto_depIdxs,
+		MessageInfos:      file_omnibridge_proto_msgTypes,
+	}.Build()
+	File_omnibridge_proto = out.File
+	file_omnibridge_proto_goTypes = nil
+	file_omnibridge_proto_depIdxs = nil
+}
@@ -0,0 +1,207 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: omnibridge.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	OmniBridge_Parse_FullMethodName         = "/omnibridge.v1.OmniBridge/Parse"
+	OmniBridge_Discover_FullMethodName      = "/omnibridge.v1.OmniBridge/Discover"
+	OmniBridge_ListProtocols_FullMethodName = "/omnibridge.v1.OmniBridge/ListProtocols"
+)
+
+// OmniBridgeClient is the client API for OmniBridge service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// OmniBridge parses binary frames with learned parsers and discovers new protocols
+type OmniBridgeClient interface {
+	// Parse routes a frame to its bound parser
+	Parse(ctx context.Context, in *ParseRequest, opts ...grpc.CallOption) (*ParseResponse, error)
+	// Discover generates and binds a parser for a new protocol from a sample frame
+	Discover(ctx context.Context, in *DiscoverRequest, opts ...grpc.CallOption) (*DiscoverResponse, error)
+	// ListProtocols returns every bound protocol
+	ListProtocols(ctx context.Context, in *ListProtocolsRequest, opts ...grpc.CallOption) (*ListProtocolsResponse, error)
+}
+
+type omniBridgeClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewOmniBridgeClient(cc grpc.ClientConnInterface) OmniBridgeClient {
+	return &omniBridgeClient{cc}
+}
+
+func (c *omniBridgeClient) Parse(ctx context.Context, in *ParseRequest, opts ...grpc.CallOption) (*ParseResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ParseResponse)
+	err := c.cc.Invoke(ctx, OmniBridge_Parse_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *omniBridgeClient) Discover(ctx context.Context, in *DiscoverRequest, opts ...grpc.CallOption) (*DiscoverResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DiscoverResponse)
+	err := c.cc.Invoke(ctx, OmniBridge_Discover_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *omniBridgeClient) ListProtocols(ctx context.Context, in *ListProtocolsRequest, opts ...grpc.CallOption) (*ListProtocolsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListProtocolsResponse)
+	err := c.cc.Invoke(ctx, OmniBridge_ListProtocols_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// OmniBridgeServer is the server API for OmniBridge service.
+// All implementations must embed UnimplementedOmniBridgeServer
+// for forward compatibility.
+//
+// OmniBridge parses binary frames with learned parsers and discovers new protocols
+type OmniBridgeServer interface {
+	// Parse routes a frame to its bound parser
+	Parse(context.Context, *ParseRequest) (*ParseResponse, error)
+	// Discover generates and binds a parser for a new protocol from a sample frame
+	Discover(context.Context, *DiscoverRequest) (*DiscoverResponse, error)
+	// ListProtocols returns every bound protocol
+	ListProtocols(context.Context, *ListProtocolsRequest) (*ListProtocolsResponse, error)
+	mustEmbedUnimplementedOmniBridgeServer()
+}
+
+// UnimplementedOmniBridgeServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedOmniBridgeServer struct{}
+
+func (UnimplementedOmniBridgeServer) Parse(context.Context, *ParseRequest) (*ParseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Parse not implemented")
+}
+func (UnimplementedOmniBridgeServer) Discover(context.Context, *DiscoverRequest) (*DiscoverResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Discover not implemented")
+}
+func (UnimplementedOmniBridgeServer) ListProtocols(context.Context, *ListProtocolsRequest) (*ListProtocolsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListProtocols not implemented")
+}
+func (UnimplementedOmniBridgeServer) mustEmbedUnimplementedOmniBridgeServer() {}
+func (UnimplementedOmniBridgeServer) testEmbeddedByValue()                    {}
+
+// UnsafeOmniBridgeServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to OmniBridgeServer will
+// result in compilation errors.
+type UnsafeOmniBridgeServer interface {
+	mustEmbedUnimplementedOmniBridgeServer()
+}
+
+func RegisterOmniBridgeServer(s grpc.ServiceRegistrar, srv OmniBridgeServer) {
+	// If the following call pancis, it indicates UnimplementedOmniBridgeServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&OmniBridge_ServiceDesc, srv)
+}
+
+func _OmniBridge_Parse_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ParseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OmniBridgeServer).Parse(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OmniBridge_Parse_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OmniBridgeServer).Parse(ctx, req.(*ParseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OmniBridge_Discover_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DiscoverRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OmniBridgeServer).Discover(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OmniBridge_Discover_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OmniBridgeServer).Discover(ctx, req.(*DiscoverRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OmniBridge_ListProtocols_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListProtocolsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OmniBridgeServer).ListProtocols(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OmniBridge_ListProtocols_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OmniBridgeServer).ListProtocols(ctx, req.(*ListProtocolsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// OmniBridge_ServiceDesc is the grpc.ServiceDesc for OmniBridge service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var OmniBridge_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "omnibridge.v1.OmniBridge",
+	HandlerType: (*OmniBridgeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Parse",
+			Handler:    _OmniBridge_Parse_Handler,
+		},
+		{
+			MethodName: "Discover",
+			Handler:    _OmniBridge_Discover_Handler,
+		},
+		{
+			MethodName: "ListProtocols",
+			Handler:    _OmniBridge_ListProtocols_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "omnibridge.proto",
+}
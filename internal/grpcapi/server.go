@@ -0,0 +1,137 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/chuanjin/OmniBridge/internal/grpcapi/pb"
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"github.com/chuanjin/OmniBridge/internal/parser"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Server implements the OmniBridge gRPC service over the dispatcher, manager and discovery service
+type Server struct {
+	pb.UnimplementedOmniBridgeServer
+
+	dispatcher *parser.Dispatcher
+	manager    *parser.ParserManager
+	discovery  *parser.DiscoveryService
+}
+
+// NewServer creates a new gRPC service for OmniBridge; register it with pb.RegisterOmniBridgeServer
+func NewServer(d *parser.Dispatcher, m *parser.ParserManager, disc *parser.DiscoveryService) *Server {
+	return &Server{dispatcher: d, manager: m, discovery: disc}
+}
+
+// Parse routes a frame to its bound parser
+func (s *Server) Parse(ctx context.Context, req *pb.ParseRequest) (*pb.ParseResponse, error) {
+	if len(req.GetData()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty payload")
+	}
+
+	result, proto, err := s.dispatcher.Ingest(req.GetData())
+	if err != nil {
+		return nil, parseStatus(proto, err)
+	}
+
+	fields, err := toStruct(result)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "cannot encode result: %v", err)
+	}
+
+	logger.Info("gRPC: Parsed binary data", zap.String("protocol", proto))
+	alias, _ := s.manager.GetAlias(proto)
+	return &pb.ParseResponse{Protocol: proto, Alias: alias, Result: fields}, nil
+}
+
+// Discover generates and binds a parser for a new protocol
+func (s *Server) Discover(ctx context.Context, req *pb.DiscoverRequest) (*pb.DiscoverResponse, error) {
+	if len(req.GetSample()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty sample")
+	}
+
+	contextHint := req.GetContext()
+	if contextHint == "" {
+		contextHint = "Unknown binary protocol"
+	}
+
+	logger.Info("gRPC: Starting protocol discovery", zap.String("context", contextHint))
+
+	protoName, err := s.discovery.DiscoverNewProtocolContext(parser.WithDiscoveryModel(ctx, req.GetModel()), req.GetSample(), nil, contextHint)
+	if errors.Is(err, parser.ErrRateLimited) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, status.FromContextError(ctxErr).Err()
+		}
+		return nil, status.Errorf(codes.Unavailable, "discovery failed: %v", err)
+	}
+
+	var signature string
+	for sig, name := range s.dispatcher.GetBindings() {
+		if name == protoName {
+			signature = sig
+			break
+		}
+	}
+
+	return &pb.DiscoverResponse{ProtocolName: protoName, Signature: signature}, nil
+}
+
+// ListProtocols returns every bound protocol, ordered by signature
+func (s *Server) ListProtocols(ctx context.Context, req *pb.ListProtocolsRequest) (*pb.ListProtocolsResponse, error) {
+	bindings := s.dispatcher.GetBindings()
+	summaries := s.manager.Summaries()
+
+	resp := &pb.ListProtocolsResponse{Protocols: make([]*pb.ProtocolInfo, 0, len(bindings))}
+	for sig, name := range bindings {
+		alias, _ := s.manager.GetAlias(name)
+		resp.Protocols = append(resp.Protocols, &pb.ProtocolInfo{Name: name, Alias: alias, Signature: sig, Summary: summaries[name]})
+	}
+	sort.Slice(resp.Protocols, func(i, j int) bool {
+		return resp.Protocols[i].Signature < resp.Protocols[j].Signature
+	})
+	return resp, nil
+}
+
+// toStruct converts a parser result to a Struct via JSON, so any value the HTTP API
+// can encode (typed slices, nested maps, byte slices) serializes the same way here
+// parseStatus maps an ingest error to a gRPC status, so clients can tell a bad frame from an
+// overloaded or broken parser
+func parseStatus(proto string, err error) error {
+	switch {
+	case proto == "", errors.Is(err, parser.ErrUnknownProtocol):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, parser.ErrChecksum):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, parser.ErrProtocolDisabled), errors.Is(err, parser.ErrOutOfRange):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, parser.ErrBulkheadFull):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, parser.ErrCircuitOpen):
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Errorf(codes.Internal, "parse failed: %v", err)
+}
+
+func toStruct(result map[string]interface{}) (*structpb.Struct, error) {
+	fields := &structpb.Struct{}
+	if result == nil {
+		return fields, nil
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	if err := fields.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
@@ -0,0 +1,154 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chuanjin/OmniBridge/internal/grpcapi/pb"
+	"github.com/chuanjin/OmniBridge/internal/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testParser = `// Signature: 01
+package dynamic
+func Parse(data []byte) map[string]interface{} {
+	if len(data) < 2 { return nil }
+	return map[string]interface{}{"val": int(data[1]), "raw": []int{int(data[0]), int(data[1])}}
+}`
+
+// newTestDiscovery returns a discovery service over a fresh storage directory, with a
+// placeholder system prompt unless cfg names one
+func newTestDiscovery(t *testing.T, cfg parser.DiscoveryConfig) (*parser.DiscoveryService, *parser.Dispatcher, *parser.ParserManager) {
+	t.Helper()
+	if cfg.SystemPromptPath == "" {
+		cfg.SystemPromptPath = filepath.Join(t.TempDir(), "system_prompt.md")
+		require.NoError(t, os.WriteFile(cfg.SystemPromptPath, []byte("System prompt context"), 0o644))
+	}
+	mgr := parser.NewParserManager(t.TempDir(), "")
+	dispatcher := parser.NewDispatcher(mgr)
+	return parser.NewDiscoveryService(dispatcher, mgr, cfg), dispatcher, mgr
+}
+
+// newTestClient serves the OmniBridge service over an in-memory listener and returns a client for it
+func newTestClient(t *testing.T, llmEndpoint string) (pb.OmniBridgeClient, *parser.Dispatcher) {
+	t.Helper()
+	discovery, dispatcher, mgr := newTestDiscovery(t, parser.DiscoveryConfig{
+		Provider: "ollama",
+		Model:    "test-model",
+		Endpoint: llmEndpoint,
+	})
+
+	require.NoError(t, mgr.RegisterParser("test_protocol", testParser))
+	dispatcher.Bind([]byte{0x01}, "test_protocol")
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterOmniBridgeServer(srv, NewServer(dispatcher, mgr, discovery))
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return pb.NewOmniBridgeClient(conn), dispatcher
+}
+
+func TestParse(t *testing.T) {
+	client, _ := newTestClient(t, "")
+	ctx := context.Background()
+
+	resp, err := client.Parse(ctx, &pb.ParseRequest{Data: []byte{0x01, 0x2A}})
+	require.NoError(t, err)
+	assert.Equal(t, "test_protocol", resp.Protocol)
+	result := resp.Result.AsMap()
+	assert.Equal(t, float64(42), result["val"])
+	assert.Equal(t, []interface{}{float64(1), float64(42)}, result["raw"])
+
+	_, err = client.Parse(ctx, &pb.ParseRequest{Data: []byte{0xFF, 0x00}})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.Parse(ctx, &pb.ParseRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		proto string
+		err   error
+		want  codes.Code
+	}{
+		{"", parser.ErrUnknownProtocol, codes.NotFound},
+		{"meter", fmt.Errorf("frame 0x01: %w", parser.ErrChecksum), codes.InvalidArgument},
+		{"meter", parser.ErrProtocolDisabled, codes.FailedPrecondition},
+		{"meter", fmt.Errorf("rpm: %w", parser.ErrOutOfRange), codes.FailedPrecondition},
+		{"meter", fmt.Errorf("meter: %w", parser.ErrBulkheadFull), codes.ResourceExhausted},
+		{"meter", fmt.Errorf("meter: %w", parser.ErrCircuitOpen), codes.Unavailable},
+		{"meter", errors.New("PANIC: index out of range"), codes.Internal},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, status.Code(parseStatus(tt.proto, tt.err)), "%v", tt.err)
+	}
+}
+
+func TestListProtocols(t *testing.T) {
+	client, _ := newTestClient(t, "")
+
+	resp, err := client.ListProtocols(context.Background(), &pb.ListProtocolsRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Protocols, 1)
+	assert.Equal(t, "test_protocol", resp.Protocols[0].Name)
+	assert.Equal(t, "01", resp.Protocols[0].Signature)
+}
+
+func TestDiscover(t *testing.T) {
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(parser.OllamaResponse{Response: `// Signature: 55AA
+package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"len": len(data)} }`})
+	}))
+	defer llm.Close()
+
+	client, dispatcher := newTestClient(t, llm.URL)
+	ctx := context.Background()
+
+	resp, err := client.Discover(ctx, &pb.DiscoverRequest{Sample: []byte{0x55, 0xAA, 0x01, 0x02}, Context: "test sensor"})
+	require.NoError(t, err)
+	assert.Equal(t, "auto_proto_0x55AA", resp.ProtocolName)
+	assert.Equal(t, "55AA", resp.Signature)
+	assert.Equal(t, "auto_proto_0x55AA", dispatcher.GetBindings()["55AA"])
+
+	parsed, err := client.Parse(ctx, &pb.ParseRequest{Data: []byte{0x55, 0xAA, 0x01, 0x02}})
+	require.NoError(t, err)
+	assert.Equal(t, float64(4), parsed.Result.AsMap()["len"])
+
+	_, err = client.Discover(ctx, &pb.DiscoverRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestDiscover_UpstreamFailure(t *testing.T) {
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model unavailable", http.StatusServiceUnavailable)
+	}))
+	defer llm.Close()
+
+	client, _ := newTestClient(t, llm.URL)
+
+	_, err := client.Discover(context.Background(), &pb.DiscoverRequest{Sample: []byte{0x77, 0x00}})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
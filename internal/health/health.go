@@ -0,0 +1,134 @@
+// Package health serves liveness and readiness probes, e.g. for Kubernetes, next to any mode.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/parser"
+)
+
+const (
+	// DefaultPingTimeout bounds one LLM reachability check
+	DefaultPingTimeout = 2 * time.Second
+	// DefaultPingInterval is how long the outcome of an LLM check is reused by /readyz
+	DefaultPingInterval = 15 * time.Second
+)
+
+// Pinger checks that a dependency is reachable, such as parser.DiscoveryService.Ping
+type Pinger func(ctx context.Context) error
+
+// Check is a cheap readiness condition, such as parser.DiscoveryService.Healthy or
+// parser.DeadLetterMonitor.Healthy, reported under Name while it fails
+type Check struct {
+	Name    string
+	Healthy func() bool
+}
+
+// Server answers /healthz while the process is alive and /readyz once the dispatcher has
+// bindings, every Check passes and, if a Pinger is set, the LLM endpoint was reachable on its
+// last check. LLM checks run in the background, so a slow endpoint never blocks a probe.
+type Server struct {
+	dispatcher *parser.Dispatcher
+	ping       Pinger // nil when no LLM is configured
+	checks     []Check
+	timeout    time.Duration
+	interval   time.Duration
+	mux        *http.ServeMux
+
+	llmErr  error
+	checked time.Time // Completion of the last LLM check, zero before the first
+	pinging bool
+	mu      sync.Mutex
+}
+
+// Status is the JSON body of both probes
+type Status struct {
+	Status   string   `json:"status"` // "ok", "ready" or "not ready"
+	Bindings int      `json:"bindings,omitempty"`
+	LLM      string   `json:"llm,omitempty"`     // "ok", "checking", "not configured" or the last error
+	Failing  []string `json:"failing,omitempty"` // Names of the failing checks
+}
+
+// NewServer creates the probe handler. ping may be nil to skip the LLM check.
+func NewServer(d *parser.Dispatcher, ping Pinger, checks ...Check) *Server {
+	s := &Server{
+		dispatcher: d,
+		ping:       ping,
+		checks:     checks,
+		timeout:    DefaultPingTimeout,
+		interval:   DefaultPingInterval,
+		mux:        http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /healthz", s.handleHealthz)
+	s.mux.HandleFunc("GET /readyz", s.handleReadyz)
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	writeStatus(w, http.StatusOK, Status{Status: "ok"})
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	status := Status{Status: "ready", Bindings: s.bindings(), LLM: s.llmStatus()}
+	for _, c := range s.checks {
+		if !c.Healthy() {
+			status.Failing = append(status.Failing, c.Name)
+		}
+	}
+	code := http.StatusOK
+	if status.Bindings == 0 || len(status.Failing) > 0 || (status.LLM != "ok" && status.LLM != "not configured") {
+		status.Status, code = "not ready", http.StatusServiceUnavailable
+	}
+	writeStatus(w, code, status)
+}
+
+// bindings counts the dispatcher's plain, length and masked bindings
+func (s *Server) bindings() int {
+	return len(s.dispatcher.GetBindings()) + len(s.dispatcher.GetLengthBindings()) + len(s.dispatcher.GetMaskedBindings())
+}
+
+// llmStatus reports the last LLM check, starting a new one in the background when it is stale
+func (s *Server) llmStatus() string {
+	if s.ping == nil {
+		return "not configured"
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.pinging && time.Since(s.checked) >= s.interval {
+		s.pinging = true
+		go s.check()
+	}
+	switch {
+	case s.checked.IsZero():
+		return "checking"
+	case s.llmErr != nil:
+		return s.llmErr.Error()
+	default:
+		return "ok"
+	}
+}
+
+func (s *Server) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	err := s.ping(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.llmErr, s.checked, s.pinging = err, time.Now(), false
+}
+
+func writeStatus(w http.ResponseWriter, code int, status Status) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(status)
+}
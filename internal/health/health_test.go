@@ -0,0 +1,117 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDispatcher(t *testing.T, bound bool) *parser.Dispatcher {
+	t.Helper()
+	d := parser.NewDispatcher(parser.NewParserManager(t.TempDir(), ""))
+	if bound {
+		d.Bind([]byte{0x01}, "test_protocol")
+	}
+	return d
+}
+
+func probe(t *testing.T, s *Server, path string) (int, Status) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var status Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status), rec.Body.String())
+	return rec.Code, status
+}
+
+func TestHealthz(t *testing.T) {
+	// Alive even when nothing is bound
+	code, status := probe(t, NewServer(newDispatcher(t, false), nil), "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", status.Status)
+}
+
+func TestReadyz_Bindings(t *testing.T) {
+	code, status := probe(t, NewServer(newDispatcher(t, false), nil), "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not ready", status.Status)
+	assert.Equal(t, 0, status.Bindings)
+
+	code, status = probe(t, NewServer(newDispatcher(t, true), nil), "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, Status{Status: "ready", Bindings: 1, LLM: "not configured"}, status)
+}
+
+func TestReadyz_LLM(t *testing.T) {
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ollama answers GET on its generate endpoint with 405; it is still reachable
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	defer llm.Close()
+
+	d := newDispatcher(t, true)
+	discovery := parser.NewDiscoveryService(d, d.GetManager(), parser.DiscoveryConfig{Provider: "ollama", Endpoint: llm.URL})
+	s := NewServer(d, discovery.Ping)
+
+	// The first probe starts the check without waiting for it
+	code, status := probe(t, s, "/readyz")
+	if status.LLM == "checking" {
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+	}
+	assert.Eventually(t, func() bool {
+		code, status := probe(t, s, "/readyz")
+		return code == http.StatusOK && status.LLM == "ok"
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// Once the endpoint goes away, the next check marks the gateway not ready
+	llm.Close()
+	s.interval = 0
+	assert.Eventually(t, func() bool {
+		code, status := probe(t, s, "/readyz")
+		return code == http.StatusServiceUnavailable && status.LLM != "ok" && status.LLM != "checking"
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestReadyz_SlowLLMDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	s := NewServer(newDispatcher(t, true), func(ctx context.Context) error {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return errors.New("timed out")
+	})
+
+	start := time.Now()
+	code, status := probe(t, s, "/readyz")
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "checking", status.LLM)
+}
+
+func TestReadyz_Checks(t *testing.T) {
+	healthy := true
+	s := NewServer(newDispatcher(t, true), nil,
+		Check{Name: "discovery", Healthy: func() bool { return healthy }},
+		Check{Name: "dead_letters", Healthy: func() bool { return true }},
+	)
+
+	code, status := probe(t, s, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, status.Failing)
+
+	// A fail-closed escalation takes the gateway out of rotation
+	healthy = false
+	code, status = probe(t, s, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, Status{Status: "not ready", Bindings: 1, LLM: "not configured", Failing: []string{"discovery"}}, status)
+}
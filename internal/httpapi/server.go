@@ -0,0 +1,214 @@
+package httpapi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"github.com/chuanjin/OmniBridge/internal/parser"
+	"go.uber.org/zap"
+)
+
+// maxBodyBytes caps request bodies; frames and samples are small
+const maxBodyBytes = 1 << 20
+
+// Server exposes the OmniBridge dispatcher, manager and discovery service over HTTP
+type Server struct {
+	dispatcher *parser.Dispatcher
+	manager    *parser.ParserManager
+	discovery  *parser.DiscoveryService
+	mux        *http.ServeMux
+}
+
+// NewServer creates a new HTTP API server for OmniBridge
+func NewServer(d *parser.Dispatcher, m *parser.ParserManager, disc *parser.DiscoveryService) *Server {
+	s := &Server{
+		dispatcher: d,
+		manager:    m,
+		discovery:  disc,
+		mux:        http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("POST /parse", s.handleParse)
+	s.mux.HandleFunc("GET /protocols", s.handleListProtocols)
+	s.mux.HandleFunc("POST /discover", s.handleDiscover)
+	s.mux.HandleFunc("DELETE /protocols/{id}", s.handleDeleteProtocol)
+
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+type ParseResponse struct {
+	Protocol string                 `json:"protocol"`
+	Alias    string                 `json:"alias,omitempty"`
+	Result   map[string]interface{} `json:"result"`
+}
+
+type DiscoverRequest struct {
+	Sample  string `json:"sample"`  // Hex-encoded binary sample
+	Context string `json:"context"` // Optional hint about the protocol
+	Model   string `json:"model"`   // Optional LLM model overriding the configured one
+}
+
+type DiscoverResponse struct {
+	ProtocolName string `json:"protocol_name"`
+	Signature    string `json:"signature"`
+}
+
+type ProtocolInfo struct {
+	Name      string `json:"name"`
+	Alias     string `json:"alias,omitempty"`
+	Signature string `json:"signature"`
+	Summary   string `json:"summary,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// handleParse parses a hex-encoded frame sent as the request body
+func (s *Server) handleParse(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("failed to read body: %v", err))
+		return
+	}
+
+	data, err := decodeHex(string(body))
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	result, proto, err := s.dispatcher.Ingest(data)
+	if err != nil {
+		if proto == "" {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, parser.ErrProtocolDisabled) {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		if errors.Is(err, parser.ErrChecksum) {
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("parse failed: %v", err))
+		return
+	}
+
+	logger.Info("HTTP: Parsed binary data", zap.String("protocol", proto))
+	alias, _ := s.manager.GetAlias(proto)
+	writeJSON(w, http.StatusOK, ParseResponse{Protocol: proto, Alias: alias, Result: result})
+}
+
+func (s *Server) handleListProtocols(w http.ResponseWriter, r *http.Request) {
+	bindings := s.dispatcher.GetBindings()
+	summaries := s.manager.Summaries()
+
+	protocols := make([]ProtocolInfo, 0, len(bindings))
+	for sig, name := range bindings {
+		alias, _ := s.manager.GetAlias(name)
+		protocols = append(protocols, ProtocolInfo{Name: name, Alias: alias, Signature: sig, Summary: summaries[name]})
+	}
+
+	writeJSON(w, http.StatusOK, protocols)
+}
+
+func (s *Server) handleDiscover(w http.ResponseWriter, r *http.Request) {
+	var req DiscoverRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBodyBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %v", err))
+		return
+	}
+
+	sample, err := decodeHex(req.Sample)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	contextHint := req.Context
+	if contextHint == "" {
+		contextHint = "Unknown binary protocol"
+	}
+
+	logger.Info("HTTP: Starting protocol discovery", zap.String("context", contextHint))
+
+	protoName, err := s.discovery.DiscoverNewProtocolContext(parser.WithDiscoveryModel(r.Context(), req.Model), sample, nil, contextHint)
+	if errors.Is(err, parser.ErrRateLimited) {
+		writeError(w, http.StatusTooManyRequests, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, fmt.Errorf("discovery failed: %v", err))
+		return
+	}
+
+	var signature string
+	for sig, name := range s.dispatcher.GetBindings() {
+		if name == protoName {
+			signature = sig
+			break
+		}
+	}
+
+	writeJSON(w, http.StatusCreated, DiscoverResponse{ProtocolName: protoName, Signature: signature})
+}
+
+func (s *Server) handleDeleteProtocol(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if _, exists := s.manager.GetParserCode(id); !exists {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown protocol: %s", id))
+		return
+	}
+	if err := s.manager.DeleteParser(id); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.dispatcher.Unbind(id)
+	if err := s.dispatcher.SaveManifest(); err != nil {
+		logger.Error("Failed to save manifest", zap.Error(err))
+	}
+
+	logger.Info("HTTP: Deleted protocol", zap.String("protocol", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeHex accepts hex with optional whitespace and 0x prefix
+func decodeHex(s string) ([]byte, error) {
+	s = strings.Join(strings.Fields(s), "")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex data: %v", err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty payload")
+	}
+	return data, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Error("Failed to write response", zap.Error(err))
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
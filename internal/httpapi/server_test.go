@@ -0,0 +1,133 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chuanjin/OmniBridge/internal/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testParser = `// Signature: 01
+package dynamic
+func Parse(data []byte) map[string]interface{} {
+	if len(data) < 2 { return nil }
+	return map[string]interface{}{"val": int(data[1])}
+}`
+
+// newTestDiscovery returns a discovery service over a fresh storage directory, with a
+// placeholder system prompt unless cfg names one
+func newTestDiscovery(t *testing.T, cfg parser.DiscoveryConfig) (*parser.DiscoveryService, *parser.Dispatcher, *parser.ParserManager) {
+	t.Helper()
+	if cfg.SystemPromptPath == "" {
+		cfg.SystemPromptPath = filepath.Join(t.TempDir(), "system_prompt.md")
+		require.NoError(t, os.WriteFile(cfg.SystemPromptPath, []byte("System prompt context"), 0o644))
+	}
+	mgr := parser.NewParserManager(t.TempDir(), "")
+	dispatcher := parser.NewDispatcher(mgr)
+	return parser.NewDiscoveryService(dispatcher, mgr, cfg), dispatcher, mgr
+}
+
+func newTestServer(t *testing.T, llmEndpoint string) (*Server, *parser.Dispatcher, *parser.ParserManager) {
+	t.Helper()
+	discovery, dispatcher, mgr := newTestDiscovery(t, parser.DiscoveryConfig{
+		Provider: "ollama",
+		Model:    "test-model",
+		Endpoint: llmEndpoint,
+	})
+
+	require.NoError(t, mgr.RegisterParser("test_protocol", testParser))
+	dispatcher.Bind([]byte{0x01}, "test_protocol")
+
+	return NewServer(dispatcher, mgr, discovery), dispatcher, mgr
+}
+
+func do(s *Server, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestParseHandler(t *testing.T) {
+	s, _, _ := newTestServer(t, "")
+
+	rec := do(s, http.MethodPost, "/parse", "01 2A")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp ParseResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "test_protocol", resp.Protocol)
+	assert.Equal(t, float64(42), resp.Result["val"])
+
+	assert.Equal(t, http.StatusNotFound, do(s, http.MethodPost, "/parse", "FF00").Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, do(s, http.MethodPost, "/parse", "not-hex").Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, do(s, http.MethodPost, "/parse", "").Code)
+}
+
+func TestListProtocolsHandler(t *testing.T) {
+	s, _, _ := newTestServer(t, "")
+
+	rec := do(s, http.MethodGet, "/protocols", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var protocols []ProtocolInfo
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &protocols))
+	assert.Equal(t, []ProtocolInfo{{Name: "test_protocol", Signature: "01"}}, protocols)
+}
+
+func TestDeleteProtocolHandler(t *testing.T) {
+	s, dispatcher, mgr := newTestServer(t, "")
+
+	rec := do(s, http.MethodDelete, "/protocols/test_protocol", "")
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	assert.Empty(t, dispatcher.GetBindings())
+	_, exists := mgr.GetParserCode("test_protocol")
+	assert.False(t, exists)
+	assert.Equal(t, http.StatusNotFound, do(s, http.MethodPost, "/parse", "012A").Code)
+
+	assert.Equal(t, http.StatusNotFound, do(s, http.MethodDelete, "/protocols/test_protocol", "").Code)
+}
+
+func TestDiscoverHandler(t *testing.T) {
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(parser.OllamaResponse{Response: `// Signature: 55AA
+package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"len": len(data)} }`})
+	}))
+	defer llm.Close()
+
+	s, _, _ := newTestServer(t, llm.URL)
+
+	rec := do(s, http.MethodPost, "/discover", `{"sample":"55AA0102","context":"test sensor"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var resp DiscoverResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "auto_proto_0x55AA", resp.ProtocolName)
+	assert.Equal(t, "55AA", resp.Signature)
+
+	rec = do(s, http.MethodPost, "/parse", "55AA0102")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	assert.Equal(t, http.StatusUnprocessableEntity, do(s, http.MethodPost, "/discover", `{"sample":"zz"}`).Code)
+}
+
+func TestDiscoverHandler_UpstreamFailure(t *testing.T) {
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model unavailable", http.StatusServiceUnavailable)
+	}))
+	defer llm.Close()
+
+	s, _, _ := newTestServer(t, llm.URL)
+
+	rec := do(s, http.MethodPost, "/discover", `{"sample":"7700"}`)
+	assert.Equal(t, http.StatusBadGateway, rec.Code, rec.Body.String())
+}
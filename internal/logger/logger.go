@@ -2,13 +2,14 @@ package logger
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 var (
-	globalLogger *zap.Logger
+	globalLogger atomic.Pointer[zap.Logger]
 	once         sync.Once
 )
 
@@ -29,26 +30,39 @@ func Init(debug bool) error {
 
 		// Customize output to stdout/stderr or file if needed
 		// For now, we stick to stdout/stderr which is container-friendly
-		globalLogger, err = config.Build(zap.AddCallerSkip(1)) // Skip 1 caller level for wrapper functions if we had them
+		var l *zap.Logger
+		l, err = config.Build(zap.AddCallerSkip(1)) // Skip 1 caller level for wrapper functions if we had them
+		if err == nil {
+			globalLogger.Store(l)
+		}
 	})
 	return err
 }
 
+// Set replaces the global logger and returns the previous one (nil if none was set).
+// Mainly useful for capturing logs in tests.
+func Set(l *zap.Logger) *zap.Logger {
+	return globalLogger.Swap(l)
+}
+
 // Get returns the global logger.
 // It initializes a default production logger if Init hasn't been called.
 func Get() *zap.Logger {
-	if globalLogger == nil {
-		// Fallback to a basic production logger if not initialized
-		l, _ := zap.NewProduction()
+	if l := globalLogger.Load(); l != nil {
+		return l
+	}
+	// Fallback to a basic production logger if not initialized
+	l, _ := zap.NewProduction(zap.AddCallerSkip(1))
+	if globalLogger.CompareAndSwap(nil, l) {
 		return l
 	}
-	return globalLogger
+	return globalLogger.Load()
 }
 
 // Sync flushes any buffered log entries.
 func Sync() {
-	if globalLogger != nil {
-		_ = globalLogger.Sync()
+	if l := globalLogger.Load(); l != nil {
+		_ = l.Sync()
 	}
 }
 
@@ -5,8 +5,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
+	"strings"
 
 	"github.com/chuanjin/OmniBridge/internal/logger"
 	"github.com/chuanjin/OmniBridge/internal/parser"
@@ -20,6 +19,8 @@ type Server struct {
 	manager    *parser.ParserManager
 	discovery  *parser.DiscoveryService
 	mcpServer  *mcp.Server
+
+	systemPromptPath string // Prompt used by the MCP prompt templates
 }
 
 // NewServer creates a new MCP server for OmniBridge
@@ -29,6 +30,9 @@ func NewServer(d *parser.Dispatcher, m *parser.ParserManager, disc *parser.Disco
 		manager:    m,
 		discovery:  disc,
 	}
+	if disc != nil {
+		s.systemPromptPath = disc.Config.SystemPromptPath
+	}
 
 	// Create MCP server with implementation info
 	impl := &mcp.Implementation{
@@ -46,6 +50,12 @@ func NewServer(d *parser.Dispatcher, m *parser.ParserManager, disc *parser.Disco
 	return s
 }
 
+// SetSystemPromptPath changes the system prompt file used by the prompt templates.
+// It defaults to the discovery service's SystemPromptPath.
+func (s *Server) SetSystemPromptPath(path string) {
+	s.systemPromptPath = path
+}
+
 // Run starts the MCP server over stdio transport
 func (s *Server) Run(ctx context.Context) error {
 	logger.Info("Starting OmniBridge MCP Server...")
@@ -70,6 +80,14 @@ func (s *Server) registerResources() {
 		Description: "Complete manifest mapping signatures to protocol parsers",
 		MIMEType:    "application/json",
 	}, s.handleManifest)
+
+	// Resource template: protocol://schema/{id} - Result fields a protocol produces
+	s.mcpServer.AddResourceTemplate(&mcp.ResourceTemplate{
+		URITemplate: schemaURIPrefix + "{id}",
+		Name:        "Protocol Schema",
+		Description: "Go type of each result field of a protocol, inferred by running its parser over its stored samples",
+		MIMEType:    "application/json",
+	}, s.handleSchema)
 }
 
 // registerTools adds all MCP tools
@@ -80,17 +98,65 @@ func (s *Server) registerTools() {
 		Description: "Parse binary data using known protocol parsers",
 	}, s.handleParseBinary)
 
+	// Tool: route_signature - Which parser would handle a frame, without parsing it
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "route_signature",
+		Description: "Report which protocol parser a frame starting with the given bytes would be routed to, without parsing anything",
+	}, s.handleRouteSignature)
+
 	// Tool: discover_protocol - Discover new protocol
 	mcp.AddTool(s.mcpServer, &mcp.Tool{
 		Name:        "discover_protocol",
 		Description: "Trigger AI-based protocol discovery for unknown binary data",
 	}, s.handleDiscoverProtocol)
 
+	// Tool: repair_protocol - Ask the LLM to fix a broken parser
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "repair_protocol",
+		Description: "Repair a protocol parser that fails to compile or parse, using the LLM and an optional failing sample",
+	}, s.handleRepairProtocol)
+
 	// Tool: list_protocols - List all protocols
 	mcp.AddTool(s.mcpServer, &mcp.Tool{
 		Name:        "list_protocols",
 		Description: "List all available protocol parsers",
 	}, s.handleListProtocols)
+
+	// Tool: delete_protocol - Remove a bad protocol for good
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "delete_protocol",
+		Description: "Delete a protocol parser with its version history and unbind all its signatures, so its frames become unknown again",
+	}, s.handleDeleteProtocol)
+
+	// Tool: describe_protocol - Natural-language summary of a parser
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "describe_protocol",
+		Description: "Describe in plain language what a protocol parser decodes (generated by the LLM once and cached)",
+	}, s.handleDescribeProtocol)
+
+	// Tool: describe_frame - Plain-language breakdown of unknown bytes, without generating code
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "describe_frame",
+		Description: "Ask the LLM to explain the byte layout of a frame in plain language, without generating or registering a parser",
+	}, s.handleDescribeFrame)
+
+	// Tool: diff_parser - Compare two stored parser versions
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "diff_parser",
+		Description: "Show a unified diff between two stored versions of a protocol parser",
+	}, s.handleDiffParser)
+
+	// Tool: validate_protocol - Regression-check a parser against its stored vectors
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "validate_protocol",
+		Description: "Run a protocol parser against its stored golden cases and schema samples and report pass/fail per sample",
+	}, s.handleValidateProtocol)
+
+	// Tool: profile_protocol - Latency and allocations of a parser
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "profile_protocol",
+		Description: "Run a protocol parser repeatedly over sample frames and report min/max/mean/p99 execution time and allocations per run, to find parsers worth optimizing",
+	}, s.handleProfileProtocol)
 }
 
 // registerPrompts adds all MCP prompts
@@ -112,13 +178,21 @@ func (s *Server) registerPrompts() {
 
 func (s *Server) handleProtocolList(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
 	bindings := s.dispatcher.GetBindings()
+	summaries := s.manager.Summaries()
 
 	protocols := make([]map[string]string, 0, len(bindings))
 	for sig, name := range bindings {
-		protocols = append(protocols, map[string]string{
+		protocol := map[string]string{
 			"signature": sig,
 			"name":      name,
-		})
+		}
+		if alias, ok := s.manager.GetAlias(name); ok {
+			protocol["alias"] = alias
+		}
+		if summary, ok := summaries[name]; ok {
+			protocol["summary"] = summary
+		}
+		protocols = append(protocols, protocol)
 	}
 
 	data, err := json.MarshalIndent(protocols, "", "  ")
@@ -159,6 +233,53 @@ func (s *Server) handleManifest(ctx context.Context, req *mcp.ReadResourceReques
 	}, nil
 }
 
+// schemaURIPrefix is the protocol://schema/{id} resource minus the protocol ID
+const schemaURIPrefix = "protocol://schema/"
+
+// SchemaResource is the content of a protocol://schema/{id} resource
+type SchemaResource struct {
+	Protocol string            `json:"protocol"`
+	Fields   map[string]string `json:"fields"`  // Field name -> observed Go type
+	Samples  int               `json:"samples"` // Stored samples the fields were inferred from
+}
+
+func (s *Server) handleSchema(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	id := strings.TrimPrefix(req.Params.URI, schemaURIPrefix)
+	if id == "" || id == req.Params.URI {
+		return nil, mcp.ResourceNotFoundError(req.Params.URI)
+	}
+	if _, ok := s.manager.GetParserCode(id); !ok {
+		return nil, mcp.ResourceNotFoundError(req.Params.URI)
+	}
+
+	samples, err := s.manager.StoredSamples(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no stored samples for %s: add golden cases or a schema to infer its fields", id)
+	}
+	fields, err := s.manager.InferSchema(id, samples)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(SchemaResource{Protocol: id, Fields: fields, Samples: len(samples)}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return &mcp.ReadResourceResult{
+		Contents: []*mcp.ResourceContents{
+			{
+				URI:      req.Params.URI,
+				MIMEType: "application/json",
+				Text:     string(data),
+			},
+		},
+	}, nil
+}
+
 // Tool Handlers
 
 type ParseBinaryInput struct {
@@ -166,8 +287,11 @@ type ParseBinaryInput struct {
 }
 
 type ParseBinaryOutput struct {
-	Protocol string                 `json:"protocol" jsonschema:"Name of the protocol used to parse the data"`
-	Result   map[string]interface{} `json:"result" jsonschema:"Parsed data structure"`
+	Protocol  string                 `json:"protocol" jsonschema:"Name of the protocol used to parse the data"`
+	Alias     string                 `json:"alias,omitempty" jsonschema:"Human-friendly name of the protocol, if set"`
+	Result    map[string]interface{} `json:"result,omitempty" jsonschema:"Parsed data structure"`
+	Error     string                 `json:"error,omitempty" jsonschema:"Why parsing failed, if it did"`
+	ErrorKind string                 `json:"error_kind,omitempty" jsonschema:"unknown (call discover_protocol), compile/timeout/panic/parse (call repair_protocol), checksum, disabled or overloaded"`
 }
 
 func (s *Server) handleParseBinary(ctx context.Context, req *mcp.CallToolRequest, input ParseBinaryInput) (*mcp.CallToolResult, ParseBinaryOutput, error) {
@@ -180,20 +304,77 @@ func (s *Server) handleParseBinary(ctx context.Context, req *mcp.CallToolRequest
 	// Attempt to parse
 	result, proto, err := s.dispatcher.Ingest(data)
 	if err != nil {
-		return nil, ParseBinaryOutput{}, fmt.Errorf("parse failed: %v", err)
+		// Report the kind alongside the message so the agent can choose repair or discovery
+		res := &mcp.CallToolResult{}
+		res.SetError(fmt.Errorf("parse failed: %v", err))
+		return res, ParseBinaryOutput{Protocol: proto, Error: err.Error(), ErrorKind: parser.ErrorKind(proto, err)}, nil
 	}
 
 	logger.Info("MCP: Parsed binary data", zap.String("protocol", proto))
 
+	alias, _ := s.manager.GetAlias(proto)
 	return nil, ParseBinaryOutput{
 		Protocol: proto,
+		Alias:    alias,
 		Result:   result,
 	}, nil
 }
 
+type RouteSignatureInput struct {
+	Signature string `json:"signature" jsonschema:"Hex-encoded leading bytes of a frame, e.g. 410C"`
+}
+
+type RouteSignatureOutput struct {
+	Matched  bool   `json:"matched" jsonschema:"Whether a bound protocol would handle the frame"`
+	Protocol string `json:"protocol,omitempty" jsonschema:"Protocol the frame would be parsed by"`
+	Alias    string `json:"alias,omitempty" jsonschema:"Human-friendly name of the protocol, if set"`
+	Enabled  bool   `json:"enabled,omitempty" jsonschema:"False if the protocol is disabled and its frames would be rejected"`
+}
+
+func (s *Server) handleRouteSignature(ctx context.Context, req *mcp.CallToolRequest, input RouteSignatureInput) (*mcp.CallToolResult, RouteSignatureOutput, error) {
+	signature, err := hex.DecodeString(strings.Join(strings.Fields(input.Signature), ""))
+	if err != nil || len(signature) == 0 {
+		return nil, RouteSignatureOutput{}, fmt.Errorf("invalid hex signature %q", input.Signature)
+	}
+
+	proto, matched := s.dispatcher.Route(signature)
+	if !matched {
+		return nil, RouteSignatureOutput{}, nil
+	}
+	alias, _ := s.manager.GetAlias(proto)
+	return nil, RouteSignatureOutput{Matched: true, Protocol: proto, Alias: alias, Enabled: s.dispatcher.IsEnabled(proto)}, nil
+}
+
+type DeleteProtocolInput struct {
+	Protocol string `json:"protocol" jsonschema:"Name of the protocol to delete"`
+}
+
+type DeleteProtocolOutput struct {
+	Unbound int `json:"unbound" jsonschema:"Number of signature bindings removed"`
+}
+
+func (s *Server) handleDeleteProtocol(ctx context.Context, req *mcp.CallToolRequest, input DeleteProtocolInput) (*mcp.CallToolResult, DeleteProtocolOutput, error) {
+	if _, exists := s.manager.GetParserCode(input.Protocol); !exists {
+		return nil, DeleteProtocolOutput{}, fmt.Errorf("unknown protocol: %s", input.Protocol)
+	}
+	if err := s.manager.DeleteParser(input.Protocol); err != nil {
+		return nil, DeleteProtocolOutput{}, err
+	}
+
+	// DeleteParser has dropped the compiled parser; drop its routes too
+	unbound := s.dispatcher.Unbind(input.Protocol)
+	if err := s.dispatcher.SaveManifest(); err != nil {
+		logger.Error("Failed to save manifest", zap.Error(err))
+	}
+
+	logger.Info("MCP: Deleted protocol", zap.String("protocol", input.Protocol), zap.Int("unbound", unbound))
+	return nil, DeleteProtocolOutput{Unbound: unbound}, nil
+}
+
 type DiscoverProtocolInput struct {
 	Sample  string `json:"sample" jsonschema:"Hex-encoded binary sample data"`
 	Context string `json:"context" jsonschema:"Optional context hint about the protocol"`
+	Model   string `json:"model,omitempty" jsonschema:"Optional LLM model for this discovery instead of the configured one"`
 }
 
 type DiscoverProtocolOutput struct {
@@ -216,7 +397,7 @@ func (s *Server) handleDiscoverProtocol(ctx context.Context, req *mcp.CallToolRe
 
 	logger.Info("MCP: Starting protocol discovery", zap.String("context", contextHint))
 
-	protoName, err := s.discovery.DiscoverNewProtocol(sample, nil, contextHint)
+	protoName, err := s.discovery.DiscoverNewProtocolContext(parser.WithDiscoveryModel(withProgress(ctx, req), input.Model), sample, nil, contextHint)
 	if err != nil {
 		return nil, DiscoverProtocolOutput{}, fmt.Errorf("discovery failed: %v", err)
 	}
@@ -239,24 +420,130 @@ func (s *Server) handleDiscoverProtocol(ctx context.Context, req *mcp.CallToolRe
 	}, nil
 }
 
+// withProgress makes a streamed discovery or repair send progress notifications to the
+// client, if its tool call asked for them with a progress token
+func withProgress(ctx context.Context, req *mcp.CallToolRequest) context.Context {
+	if req.Session == nil || req.Params == nil || req.Params.GetProgressToken() == nil {
+		return ctx
+	}
+	token, last := req.Params.GetProgressToken(), -1
+	return parser.WithDiscoveryProgress(ctx, func(p parser.DiscoveryProgress) {
+		// One notification per percent, not per streamed token
+		if p.Percent == last {
+			return
+		}
+		last = p.Percent
+		_ = req.Session.NotifyProgress(ctx, &mcp.ProgressNotificationParams{
+			ProgressToken: token,
+			Progress:      float64(p.Percent),
+			Total:         100,
+			Message:       fmt.Sprintf("%d bytes of parser code received", p.Bytes),
+		})
+	})
+}
+
+type RepairProtocolInput struct {
+	Protocol string `json:"protocol" jsonschema:"Protocol ID"`
+	Sample   string `json:"sample,omitempty" jsonschema:"Optional hex-encoded frame the parser fails on"`
+	Error    string `json:"error,omitempty" jsonschema:"Optional error message to give the LLM; reproduced from the sample if omitted"`
+	Model    string `json:"model,omitempty" jsonschema:"Optional LLM model for this repair instead of the configured one"`
+}
+
+type RepairProtocolOutput struct {
+	Protocol string                 `json:"protocol" jsonschema:"Protocol ID of the repaired parser"`
+	Repaired bool                   `json:"repaired" jsonschema:"Whether a fixed parser was registered"`
+	Version  int                    `json:"version,omitempty" jsonschema:"Version number of the repaired parser"`
+	Result   map[string]interface{} `json:"result,omitempty" jsonschema:"Output of the repaired parser on the sample"`
+	Error    string                 `json:"error,omitempty" jsonschema:"Why the sample still fails after the repair, if it does"`
+}
+
+func (s *Server) handleRepairProtocol(ctx context.Context, req *mcp.CallToolRequest, input RepairProtocolInput) (*mcp.CallToolResult, RepairProtocolOutput, error) {
+	faultyCode, ok := s.manager.GetParserCode(input.Protocol)
+	if !ok {
+		return nil, RepairProtocolOutput{}, fmt.Errorf("unknown protocol: %s", input.Protocol)
+	}
+	sample, err := hex.DecodeString(input.Sample)
+	if err != nil {
+		return nil, RepairProtocolOutput{}, fmt.Errorf("invalid hex sample: %v", err)
+	}
+	signature := s.signatureOf(input.Protocol)
+	if len(sample) == 0 {
+		sample = signature
+	}
+	if len(sample) == 0 {
+		return nil, RepairProtocolOutput{}, fmt.Errorf("%s has no binding; provide a sample", input.Protocol)
+	}
+
+	errorMsg := input.Error
+	if errorMsg == "" {
+		if _, parseErr := s.manager.ParseData(input.Protocol, sample); parseErr != nil {
+			errorMsg = parseErr.Error()
+		} else {
+			errorMsg = "The parser runs but its output was reported as wrong."
+		}
+	}
+
+	logger.Info("MCP: Repairing protocol", zap.String("protocol", input.Protocol))
+
+	protoName, err := s.discovery.RepairParserContext(parser.WithDiscoveryModel(withProgress(ctx, req), input.Model), input.Protocol, faultyCode, errorMsg, sample, signature)
+	if err != nil {
+		return nil, RepairProtocolOutput{}, fmt.Errorf("repair failed: %v", err)
+	}
+
+	output := RepairProtocolOutput{Protocol: protoName, Repaired: true}
+	if versions, err := s.manager.ListVersions(protoName); err == nil && len(versions) > 0 {
+		output.Version = versions[len(versions)-1]
+	}
+	if output.Result, err = s.manager.ParseData(protoName, sample); err != nil {
+		output.Error = err.Error()
+	}
+
+	logger.Info("MCP: Protocol repaired", zap.String("protocol", protoName), zap.Int("version", output.Version))
+	return nil, output, nil
+}
+
+// signatureOf returns the shortest signature bound to a protocol, or nil if it has none
+func (s *Server) signatureOf(protocolID string) []byte {
+	var best string
+	for sig, name := range s.dispatcher.GetBindings() {
+		if name == protocolID && (best == "" || len(sig) < len(best) || (len(sig) == len(best) && sig < best)) {
+			best = sig
+		}
+	}
+	sig, _ := hex.DecodeString(best)
+	return sig
+}
+
 type ListProtocolsOutput struct {
 	Protocols []ProtocolInfo `json:"protocols" jsonschema:"List of available protocols"`
 }
 
 type ProtocolInfo struct {
 	Name      string `json:"name" jsonschema:"Protocol name"`
+	Alias     string `json:"alias,omitempty" jsonschema:"Human-friendly protocol name, if set"`
 	Signature string `json:"signature" jsonschema:"Hex signature"`
+	Summary   string `json:"summary,omitempty" jsonschema:"What the parser decodes, if describe_protocol has been run"`
+
+	Meta *parser.ParserMeta `json:"meta,omitempty" jsonschema:"Where the parser came from (context hint, provider/model, creation time, repaired), if recorded"`
 }
 
 func (s *Server) handleListProtocols(ctx context.Context, req *mcp.CallToolRequest, input struct{}) (*mcp.CallToolResult, ListProtocolsOutput, error) {
 	bindings := s.dispatcher.GetBindings()
+	summaries := s.manager.Summaries()
 
 	protocols := make([]ProtocolInfo, 0, len(bindings))
 	for sig, name := range bindings {
-		protocols = append(protocols, ProtocolInfo{
+		alias, _ := s.manager.GetAlias(name)
+		info := ProtocolInfo{
 			Name:      name,
+			Alias:     alias,
 			Signature: sig,
-		})
+			Summary:   summaries[name],
+		}
+		if meta, ok := s.manager.GetParserMeta(name); ok {
+			info.Meta = &meta
+		}
+		protocols = append(protocols, info)
 	}
 
 	logger.Info("MCP: Listed protocols", zap.Int("count", len(protocols)))
@@ -266,6 +553,167 @@ func (s *Server) handleListProtocols(ctx context.Context, req *mcp.CallToolReque
 	}, nil
 }
 
+type DescribeProtocolInput struct {
+	Protocol string `json:"protocol" jsonschema:"Protocol ID"`
+}
+
+type DescribeProtocolOutput struct {
+	Protocol string `json:"protocol" jsonschema:"Protocol ID"`
+	Summary  string `json:"summary" jsonschema:"Natural-language description of the decoded fields"`
+}
+
+func (s *Server) handleDescribeProtocol(ctx context.Context, req *mcp.CallToolRequest, input DescribeProtocolInput) (*mcp.CallToolResult, DescribeProtocolOutput, error) {
+	summary, err := s.discovery.Summarize(input.Protocol)
+	if err != nil {
+		return nil, DescribeProtocolOutput{}, fmt.Errorf("describe failed: %v", err)
+	}
+
+	logger.Info("MCP: Described protocol", zap.String("protocol", input.Protocol))
+
+	return nil, DescribeProtocolOutput{Protocol: input.Protocol, Summary: summary}, nil
+}
+
+type DescribeFrameInput struct {
+	Data        string `json:"data" jsonschema:"Hex-encoded frame to explain"`
+	ContextHint string `json:"context_hint,omitempty" jsonschema:"Optional hint about the device or protocol"`
+}
+
+type DescribeFrameOutput struct {
+	Description string `json:"description" jsonschema:"Plain-language breakdown of the frame's fields and byte offsets"`
+}
+
+func (s *Server) handleDescribeFrame(ctx context.Context, req *mcp.CallToolRequest, input DescribeFrameInput) (*mcp.CallToolResult, DescribeFrameOutput, error) {
+	data, err := hex.DecodeString(strings.Join(strings.Fields(input.Data), ""))
+	if err != nil || len(data) == 0 {
+		return nil, DescribeFrameOutput{}, fmt.Errorf("invalid hex data %q", input.Data)
+	}
+
+	description, err := s.discovery.DescribeFrame(ctx, data, input.ContextHint)
+	if err != nil {
+		return nil, DescribeFrameOutput{}, fmt.Errorf("describe failed: %v", err)
+	}
+
+	logger.Info("MCP: Described frame", zap.Int("bytes", len(data)))
+
+	return nil, DescribeFrameOutput{Description: description}, nil
+}
+
+type DiffParserInput struct {
+	Protocol string `json:"protocol" jsonschema:"Protocol ID"`
+	From     int    `json:"from" jsonschema:"Older version number"`
+	To       int    `json:"to" jsonschema:"Newer version number"`
+}
+
+type DiffParserOutput struct {
+	Diff string `json:"diff" jsonschema:"Unified diff between the two versions"`
+}
+
+func (s *Server) handleDiffParser(ctx context.Context, req *mcp.CallToolRequest, input DiffParserInput) (*mcp.CallToolResult, DiffParserOutput, error) {
+	diff, err := s.manager.Diff(input.Protocol, input.From, input.To)
+	if err != nil {
+		return nil, DiffParserOutput{}, fmt.Errorf("diff failed: %v", err)
+	}
+
+	logger.Info("MCP: Diffed parser versions",
+		zap.String("protocol", input.Protocol), zap.Int("from", input.From), zap.Int("to", input.To))
+
+	return nil, DiffParserOutput{Diff: diff}, nil
+}
+
+type ValidateProtocolInput struct {
+	Protocol string `json:"protocol" jsonschema:"Protocol ID"`
+}
+
+type SampleResult struct {
+	Name   string                 `json:"name,omitempty" jsonschema:"Name of the stored case, if any"`
+	Input  string                 `json:"input" jsonschema:"Hex-encoded frame"`
+	Passed bool                   `json:"passed" jsonschema:"Whether the parser produced the expected output"`
+	Got    map[string]interface{} `json:"got,omitempty" jsonschema:"Output of the parser"`
+	Error  string                 `json:"error,omitempty" jsonschema:"Parse or schema error, if any"`
+}
+
+type ValidateProtocolOutput struct {
+	Passed  int            `json:"passed" jsonschema:"Number of passing samples"`
+	Failed  int            `json:"failed" jsonschema:"Number of failing samples"`
+	Results []SampleResult `json:"results" jsonschema:"Per-sample results"`
+}
+
+func (s *Server) handleValidateProtocol(ctx context.Context, req *mcp.CallToolRequest, input ValidateProtocolInput) (*mcp.CallToolResult, ValidateProtocolOutput, error) {
+	results, err := s.manager.ValidateStored(input.Protocol)
+	if err != nil {
+		return nil, ValidateProtocolOutput{}, fmt.Errorf("validation failed: %v", err)
+	}
+	if len(results) == 0 {
+		return nil, ValidateProtocolOutput{}, fmt.Errorf("no stored samples for %s", input.Protocol)
+	}
+
+	output := ValidateProtocolOutput{Results: make([]SampleResult, 0, len(results))}
+	for _, r := range results {
+		sample := SampleResult{Name: r.Case.Name, Input: r.Case.Input, Passed: r.Passed, Got: r.Got}
+		if r.Err != nil {
+			sample.Error = r.Err.Error()
+		}
+		if r.Passed {
+			output.Passed++
+		} else {
+			output.Failed++
+		}
+		output.Results = append(output.Results, sample)
+	}
+
+	logger.Info("MCP: Validated protocol",
+		zap.String("protocol", input.Protocol), zap.Int("passed", output.Passed), zap.Int("failed", output.Failed))
+
+	return nil, output, nil
+}
+
+type ProfileProtocolInput struct {
+	Protocol   string   `json:"protocol" jsonschema:"Protocol ID"`
+	Samples    []string `json:"samples,omitempty" jsonschema:"Hex-encoded frames to run; defaults to the protocol's stored golden and schema samples"`
+	Iterations int      `json:"iterations,omitempty" jsonschema:"Passes over the samples (default 100, at most 10000)"`
+}
+
+type ProfileProtocolOutput struct {
+	Executions  int    `json:"executions" jsonschema:"Parser runs measured"`
+	Errors      int    `json:"errors" jsonschema:"Runs where the parser returned an error"`
+	Min         string `json:"min" jsonschema:"Fastest run, e.g. 12.5µs"`
+	Max         string `json:"max" jsonschema:"Slowest run"`
+	Mean        string `json:"mean" jsonschema:"Mean run time"`
+	P99         string `json:"p99" jsonschema:"99th percentile run time"`
+	AllocsPerOp uint64 `json:"allocs_per_op" jsonschema:"Heap allocations per run (approximate)"`
+	BytesPerOp  uint64 `json:"bytes_per_op" jsonschema:"Bytes allocated per run (approximate)"`
+}
+
+func (s *Server) handleProfileProtocol(ctx context.Context, req *mcp.CallToolRequest, input ProfileProtocolInput) (*mcp.CallToolResult, ProfileProtocolOutput, error) {
+	samples := make([][]byte, 0, len(input.Samples))
+	for _, sample := range input.Samples {
+		data, err := hex.DecodeString(strings.Join(strings.Fields(sample), ""))
+		if err != nil {
+			return nil, ProfileProtocolOutput{}, fmt.Errorf("invalid hex sample %q", sample)
+		}
+		samples = append(samples, data)
+	}
+
+	stats, err := s.manager.ProfileParser(ctx, input.Protocol, samples, input.Iterations)
+	if err != nil {
+		return nil, ProfileProtocolOutput{}, fmt.Errorf("profiling failed: %v", err)
+	}
+
+	logger.Info("MCP: Profiled protocol",
+		zap.String("protocol", input.Protocol), zap.Int("executions", stats.Executions), zap.Duration("mean", stats.Mean))
+
+	return nil, ProfileProtocolOutput{
+		Executions:  stats.Executions,
+		Errors:      stats.Errors,
+		Min:         stats.Min.String(),
+		Max:         stats.Max.String(),
+		Mean:        stats.Mean.String(),
+		P99:         stats.P99.String(),
+		AllocsPerOp: stats.AllocsPerOp,
+		BytesPerOp:  stats.BytesPerOp,
+	}, nil
+}
+
 // Prompt Handlers
 
 type ProtocolDiscoveryPromptArgs struct {
@@ -287,10 +735,9 @@ func (s *Server) handleProtocolDiscoveryPrompt(ctx context.Context, req *mcp.Get
 	}
 
 	// Load system prompt
-	absPath, _ := filepath.Abs("agents/system_prompt.md")
-	systemPrompt, err := os.ReadFile(absPath)
+	systemPrompt, err := parser.ReadSystemPrompt(s.systemPromptPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load system_prompt.md: %v", err)
+		return nil, err
 	}
 
 	contextHint := args.ContextHint
@@ -298,8 +745,14 @@ func (s *Server) handleProtocolDiscoveryPrompt(ctx context.Context, req *mcp.Get
 		contextHint = "Unknown binary protocol"
 	}
 
-	fullPrompt := fmt.Sprintf("%s\n\nINPUT:\nHex Sample: %s\nProtocol Hints: %s",
-		string(systemPrompt), args.SampleData, contextHint)
+	fullPrompt, err := parser.PromptBuilderFor(s.systemPromptPath).Render(parser.PromptDiscovery, parser.PromptData{
+		SystemPrompt: systemPrompt,
+		HexSample:    args.SampleData,
+		ContextHint:  contextHint,
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	return &mcp.GetPromptResult{
 		Description: "Protocol discovery prompt for AI-based binary protocol analysis",
@@ -334,10 +787,9 @@ func (s *Server) handleParserRepairPrompt(ctx context.Context, req *mcp.GetPromp
 	}
 
 	// Load system prompt
-	absPath, _ := filepath.Abs("agents/system_prompt.md")
-	systemPrompt, err := os.ReadFile(absPath)
+	systemPrompt, err := parser.ReadSystemPrompt(s.systemPromptPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load system_prompt.md: %v", err)
+		return nil, err
 	}
 
 	// Get faulty code
@@ -346,8 +798,15 @@ func (s *Server) handleParserRepairPrompt(ctx context.Context, req *mcp.GetPromp
 		return nil, fmt.Errorf("protocol %s not found", args.ProtocolName)
 	}
 
-	fullPrompt := fmt.Sprintf("%s\n\n### ERROR TO FIX\nYou previously generated code that failed.\n\nFAULTY CODE:\n```go\n%s\n```\n\nERROR MESSAGE:\n%s\n\nINPUT DATA (Hex): %s\n\nPlease fix the code and return only the valid Go code.",
-		string(systemPrompt), faultyCode, args.ErrorMessage, args.SampleData)
+	fullPrompt, err := parser.PromptBuilderFor(s.systemPromptPath).Render(parser.PromptRepair, parser.PromptData{
+		SystemPrompt: systemPrompt,
+		HexSample:    args.SampleData,
+		FaultyCode:   faultyCode,
+		ErrorMessage: args.ErrorMessage,
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	return &mcp.GetPromptResult{
 		Description: "Parser repair prompt for fixing broken protocol parsers",
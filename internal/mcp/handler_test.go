@@ -3,7 +3,14 @@ package mcp
 import (
 	"context"
 	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/chuanjin/OmniBridge/internal/parser"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -11,6 +18,19 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// newTestDiscovery returns a discovery service over a fresh storage directory, with a
+// placeholder system prompt unless cfg names one
+func newTestDiscovery(t *testing.T, cfg parser.DiscoveryConfig) (*parser.DiscoveryService, *parser.Dispatcher, *parser.ParserManager) {
+	t.Helper()
+	if cfg.SystemPromptPath == "" {
+		cfg.SystemPromptPath = filepath.Join(t.TempDir(), "system_prompt.md")
+		require.NoError(t, os.WriteFile(cfg.SystemPromptPath, []byte("System prompt context"), 0o644))
+	}
+	mgr := parser.NewParserManager(t.TempDir(), "")
+	dispatcher := parser.NewDispatcher(mgr)
+	return parser.NewDiscoveryService(dispatcher, mgr, cfg), dispatcher, mgr
+}
+
 func TestMCPServerInitialization(t *testing.T) {
 	// Setup
 	mgr := parser.NewParserManager("./test_storage", "")
@@ -142,3 +162,380 @@ func TestManifestResource(t *testing.T) {
 	err = json.Unmarshal([]byte(result.Contents[0].Text), &manifest)
 	require.NoError(t, err)
 }
+
+func TestListProtocolsHandler_Alias(t *testing.T) {
+	mgr := parser.NewParserManager(t.TempDir(), "")
+	dispatcher := parser.NewDispatcher(mgr)
+	discovery := parser.NewDiscoveryService(dispatcher, mgr, parser.DiscoveryConfig{Provider: "ollama"})
+
+	require.NoError(t, mgr.RegisterParser("auto_proto_0x01", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": 1} }`))
+	dispatcher.Bind([]byte{0x01}, "auto_proto_0x01")
+	require.NoError(t, mgr.SetAlias("auto_proto_0x01", "Door Sensor"))
+
+	server := NewServer(dispatcher, mgr, discovery)
+
+	_, output, err := server.handleListProtocols(context.Background(), &mcp.CallToolRequest{}, struct{}{})
+	require.NoError(t, err)
+	require.Len(t, output.Protocols, 1)
+	assert.Equal(t, "auto_proto_0x01", output.Protocols[0].Name)
+	assert.Equal(t, "Door Sensor", output.Protocols[0].Alias)
+	require.NotNil(t, output.Protocols[0].Meta, "registered parsers carry metadata")
+	assert.False(t, output.Protocols[0].Meta.CreatedAt.IsZero())
+
+	_, parsed, err := server.handleParseBinary(context.Background(), &mcp.CallToolRequest{}, ParseBinaryInput{Data: "0100"})
+	require.NoError(t, err)
+	assert.Equal(t, "Door Sensor", parsed.Alias)
+
+	result, err := server.handleProtocolList(context.Background(), &mcp.ReadResourceRequest{Params: &mcp.ReadResourceParams{URI: "protocol://list"}})
+	require.NoError(t, err)
+	assert.Contains(t, result.Contents[0].Text, `"alias": "Door Sensor"`)
+}
+
+func TestDiffParserHandler(t *testing.T) {
+	mgr := parser.NewParserManager(t.TempDir(), "")
+	dispatcher := parser.NewDispatcher(mgr)
+	discovery := parser.NewDiscoveryService(dispatcher, mgr, parser.DiscoveryConfig{Provider: "ollama"})
+
+	require.NoError(t, mgr.RegisterParser("meter", "package dynamic\nfunc Parse(data []byte) map[string]interface{} { return map[string]interface{}{\"v\": 1} }\n"))
+	require.NoError(t, mgr.RegisterParser("meter", "package dynamic\nfunc Parse(data []byte) map[string]interface{} { return map[string]interface{}{\"v\": 2} }\n"))
+
+	server := NewServer(dispatcher, mgr, discovery)
+
+	_, output, err := server.handleDiffParser(context.Background(), &mcp.CallToolRequest{}, DiffParserInput{Protocol: "meter", From: 1, To: 2})
+	require.NoError(t, err)
+	assert.Contains(t, output.Diff, `+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": 2} }`)
+
+	_, _, err = server.handleDiffParser(context.Background(), &mcp.CallToolRequest{}, DiffParserInput{Protocol: "meter", From: 1, To: 5})
+	assert.Error(t, err)
+}
+
+func TestValidateProtocolHandler(t *testing.T) {
+	dir := t.TempDir()
+	mgr := parser.NewParserManager(dir, "")
+	dispatcher := parser.NewDispatcher(mgr)
+	discovery := parser.NewDiscoveryService(dispatcher, mgr, parser.DiscoveryConfig{Provider: "ollama"})
+
+	good := "package dynamic\nfunc Parse(data []byte) map[string]interface{} { return map[string]interface{}{\"v\": int(data[1])} }\n"
+	broken := "package dynamic\nfunc Parse(data []byte) map[string]interface{} { return map[string]interface{}{\"v\": int(data[1]) + 1} }\n"
+	require.NoError(t, mgr.RegisterParser("meter", good))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "meter.golden.json"),
+		[]byte(`[{"name":"low","input":"0101","expected":{"v":1}},{"input":"01FF","expected":{"v":255}}]`), 0o644))
+
+	server := NewServer(dispatcher, mgr, discovery)
+
+	_, output, err := server.handleValidateProtocol(context.Background(), &mcp.CallToolRequest{}, ValidateProtocolInput{Protocol: "meter"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, output.Passed)
+	assert.Equal(t, 0, output.Failed)
+	require.Len(t, output.Results, 2)
+	assert.Equal(t, "low", output.Results[0].Name)
+
+	require.NoError(t, mgr.RegisterParser("meter", broken))
+
+	_, output, err = server.handleValidateProtocol(context.Background(), &mcp.CallToolRequest{}, ValidateProtocolInput{Protocol: "meter"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, output.Passed)
+	assert.Equal(t, 2, output.Failed)
+	assert.Equal(t, 2, output.Results[0].Got["v"])
+
+	_, _, err = server.handleValidateProtocol(context.Background(), &mcp.CallToolRequest{}, ValidateProtocolInput{Protocol: "unknown"})
+	assert.Error(t, err)
+}
+
+func TestProfileProtocolHandler(t *testing.T) {
+	mgr := parser.NewParserManager(t.TempDir(), "")
+	dispatcher := parser.NewDispatcher(mgr)
+	server := NewServer(dispatcher, mgr, parser.NewDiscoveryService(dispatcher, mgr, parser.DiscoveryConfig{Provider: "ollama"}))
+
+	require.NoError(t, mgr.RegisterParser("meter", "package dynamic\nfunc Parse(data []byte) map[string]interface{} { return map[string]interface{}{\"v\": int(data[1])} }\n"))
+
+	_, output, err := server.handleProfileProtocol(context.Background(), &mcp.CallToolRequest{}, ProfileProtocolInput{
+		Protocol: "meter", Samples: []string{"01 2A", "0107"}, Iterations: 10,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 20, output.Executions)
+	assert.Zero(t, output.Errors)
+	assert.NotEmpty(t, output.P99)
+
+	// No samples given and none stored
+	_, _, err = server.handleProfileProtocol(context.Background(), &mcp.CallToolRequest{}, ProfileProtocolInput{Protocol: "meter"})
+	assert.Error(t, err)
+
+	_, _, err = server.handleProfileProtocol(context.Background(), &mcp.CallToolRequest{}, ProfileProtocolInput{Protocol: "meter", Samples: []string{"zz"}})
+	assert.Error(t, err)
+}
+
+func TestServer_SetSystemPromptPath(t *testing.T) {
+	promptPath := filepath.Join(t.TempDir(), "prompt.md")
+	require.NoError(t, os.WriteFile(promptPath, []byte("CUSTOM PROMPT"), 0o644))
+
+	mgr := parser.NewParserManager(t.TempDir(), "")
+	dispatcher := parser.NewDispatcher(mgr)
+	server := NewServer(dispatcher, mgr, parser.NewDiscoveryService(dispatcher, mgr, parser.DiscoveryConfig{Provider: "ollama"}))
+	server.SetSystemPromptPath(promptPath)
+
+	result, err := server.handleProtocolDiscoveryPrompt(context.Background(), &mcp.GetPromptRequest{
+		Params: &mcp.GetPromptParams{Arguments: map[string]string{"sample_data": "55AA"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Messages, 1)
+	assert.Contains(t, result.Messages[0].Content.(*mcp.TextContent).Text, "CUSTOM PROMPT")
+}
+
+func TestDescribeProtocolHandler(t *testing.T) {
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(parser.OllamaResponse{Response: "Door state in byte 1: 0 closed, 1 open."})
+	}))
+	defer llm.Close()
+
+	mgr := parser.NewParserManager(t.TempDir(), "")
+	dispatcher := parser.NewDispatcher(mgr)
+	discovery := parser.NewDiscoveryService(dispatcher, mgr, parser.DiscoveryConfig{Provider: "ollama", Endpoint: llm.URL})
+
+	require.NoError(t, mgr.RegisterParser("door", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"open": data[1] == 1} }`))
+	dispatcher.Bind([]byte{0x0D}, "door")
+
+	server := NewServer(dispatcher, mgr, discovery)
+
+	_, listed, err := server.handleListProtocols(context.Background(), &mcp.CallToolRequest{}, struct{}{})
+	require.NoError(t, err)
+	require.Len(t, listed.Protocols, 1)
+	assert.Empty(t, listed.Protocols[0].Summary)
+
+	_, output, err := server.handleDescribeProtocol(context.Background(), &mcp.CallToolRequest{}, DescribeProtocolInput{Protocol: "door"})
+	require.NoError(t, err)
+	assert.Equal(t, "Door state in byte 1: 0 closed, 1 open.", output.Summary)
+
+	_, listed, err = server.handleListProtocols(context.Background(), &mcp.CallToolRequest{}, struct{}{})
+	require.NoError(t, err)
+	assert.Equal(t, output.Summary, listed.Protocols[0].Summary)
+
+	_, _, err = server.handleDescribeProtocol(context.Background(), &mcp.CallToolRequest{}, DescribeProtocolInput{Protocol: "unknown"})
+	assert.Error(t, err)
+}
+
+func TestDescribeFrameHandler(t *testing.T) {
+	var prompt string
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req parser.OllamaRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		prompt = req.Prompt
+		_ = json.NewEncoder(w).Encode(parser.OllamaResponse{Response: "Byte 0 is a door sensor tag, byte 1 its state."})
+	}))
+	defer llm.Close()
+
+	mgr := parser.NewParserManager(t.TempDir(), "")
+	dispatcher := parser.NewDispatcher(mgr)
+	server := NewServer(dispatcher, mgr, parser.NewDiscoveryService(dispatcher, mgr, parser.DiscoveryConfig{Provider: "ollama", Endpoint: llm.URL}))
+
+	_, output, err := server.handleDescribeFrame(context.Background(), &mcp.CallToolRequest{}, DescribeFrameInput{Data: "0D 01", ContextHint: "door"})
+	require.NoError(t, err)
+	assert.Equal(t, "Byte 0 is a door sensor tag, byte 1 its state.", output.Description)
+	assert.Contains(t, prompt, "0D01")
+	assert.Empty(t, mgr.Parsers())
+
+	_, _, err = server.handleDescribeFrame(context.Background(), &mcp.CallToolRequest{}, DescribeFrameInput{Data: "xyz"})
+	assert.Error(t, err)
+}
+
+func TestParseBinaryHandler_ErrorKind(t *testing.T) {
+	mgr := parser.NewParserManager(t.TempDir(), "")
+	dispatcher := parser.NewDispatcher(mgr)
+	server := NewServer(dispatcher, mgr, parser.NewDiscoveryService(dispatcher, mgr, parser.DiscoveryConfig{Provider: "ollama"}))
+
+	require.NoError(t, mgr.RegisterParser("broken", "package dynamic\nfunc Parse(data []byte) map[string]interface{} { return undefined }"))
+	require.NoError(t, mgr.RegisterParser("crashy", "package dynamic\nfunc Parse(data []byte) map[string]interface{} { return map[string]interface{}{\"v\": data[9]} }"))
+	dispatcher.Bind([]byte{0x01}, "broken")
+	dispatcher.Bind([]byte{0x02}, "crashy")
+
+	tests := []struct {
+		data, protocol, kind string
+	}{
+		{"0100", "broken", parser.ErrorKindCompile},
+		{"0200", "crashy", parser.ErrorKindPanic},
+		{"FF00", "", parser.ErrorKindUnknown},
+	}
+	for _, tt := range tests {
+		res, output, err := server.handleParseBinary(context.Background(), &mcp.CallToolRequest{}, ParseBinaryInput{Data: tt.data})
+		require.NoError(t, err)
+		require.NotNil(t, res)
+		assert.True(t, res.IsError, tt.data)
+		assert.Equal(t, tt.protocol, output.Protocol, tt.data)
+		assert.Equal(t, tt.kind, output.ErrorKind, tt.data)
+		assert.NotEmpty(t, output.Error, tt.data)
+	}
+}
+
+func TestRepairProtocolHandler(t *testing.T) {
+	var prompt string
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req parser.OllamaRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		prompt = req.Prompt
+		_ = json.NewEncoder(w).Encode(parser.OllamaResponse{Response: "package dynamic\nfunc Parse(data []byte) map[string]interface{} {\n\tif len(data) < 2 {\n\t\treturn nil\n\t}\n\treturn map[string]interface{}{\"open\": data[1] == 1}\n}"})
+	}))
+	defer llm.Close()
+
+	discovery, dispatcher, mgr := newTestDiscovery(t, parser.DiscoveryConfig{Provider: "ollama", Endpoint: llm.URL})
+	server := NewServer(dispatcher, mgr, discovery)
+
+	faulty := "package dynamic\nfunc Parse(data []byte) map[string]interface{} { return map[string]interface{}{\"open\": data[5] == 1} }"
+	require.NoError(t, mgr.RegisterParser("door", faulty))
+	dispatcher.Bind([]byte{0x0D}, "door")
+
+	_, output, err := server.handleRepairProtocol(context.Background(), &mcp.CallToolRequest{}, RepairProtocolInput{Protocol: "door", Sample: "0D01"})
+	require.NoError(t, err)
+	assert.True(t, output.Repaired)
+	assert.Equal(t, "door", output.Protocol)
+	assert.Equal(t, 2, output.Version)
+	assert.Equal(t, true, output.Result["open"])
+	assert.Empty(t, output.Error)
+	assert.Contains(t, prompt, faulty)
+	assert.Contains(t, prompt, "index out of range")
+
+	_, parsed, err := server.handleParseBinary(context.Background(), &mcp.CallToolRequest{}, ParseBinaryInput{Data: "0D01"})
+	require.NoError(t, err)
+	assert.Equal(t, "door", parsed.Protocol)
+
+	_, _, err = server.handleRepairProtocol(context.Background(), &mcp.CallToolRequest{}, RepairProtocolInput{Protocol: "missing"})
+	assert.Error(t, err)
+}
+
+func TestDiscoverProtocolHandler_Progress(t *testing.T) {
+	chunks := []string{"// Signature: 03BB\n", "package dynamic\n", "func Parse(data []byte) map[string]interface{} {\n", "\treturn map[string]interface{}{\"value\": int(data[2])}\n", "}"}
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		enc := json.NewEncoder(w)
+		for _, c := range chunks {
+			_ = enc.Encode(parser.OllamaResponse{Response: c})
+		}
+		_ = enc.Encode(parser.OllamaResponse{Done: true})
+	}))
+	defer llm.Close()
+
+	discovery, dispatcher, mgr := newTestDiscovery(t, parser.DiscoveryConfig{Provider: "ollama", Endpoint: llm.URL, Stream: true})
+	server := NewServer(dispatcher, mgr, discovery)
+
+	var mu sync.Mutex
+	var progress []float64
+	client := mcp.NewClient(&mcp.Implementation{Name: "test", Version: "v0.0.1"}, &mcp.ClientOptions{
+		ProgressNotificationHandler: func(_ context.Context, req *mcp.ProgressNotificationClientRequest) {
+			mu.Lock()
+			defer mu.Unlock()
+			if req.Params.ProgressToken == "discover-1" {
+				progress = append(progress, req.Params.Progress)
+			}
+		},
+	})
+	ctx := context.Background()
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.mcpServer.Connect(ctx, serverTransport, nil)
+	require.NoError(t, err)
+	defer func() { _ = serverSession.Close() }()
+	session, err := client.Connect(ctx, clientTransport, nil)
+	require.NoError(t, err)
+	defer func() { _ = session.Close() }()
+
+	params := &mcp.CallToolParams{Meta: mcp.Meta{}, Name: "discover_protocol", Arguments: map[string]any{"sample": "03BB07", "context": "sensor"}}
+	params.SetProgressToken("discover-1")
+	res, err := session.CallTool(ctx, params)
+	require.NoError(t, err)
+	require.False(t, res.IsError, "discover_protocol failed: %v", res.Content)
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(progress) > 1 && progress[len(progress)-1] == 100
+	}, time.Second, 10*time.Millisecond, "expected progress up to completion")
+}
+
+func TestSchemaResource(t *testing.T) {
+	dir := t.TempDir()
+	mgr := parser.NewParserManager(dir, filepath.Join("..", "..", "seeds"))
+	require.NoError(t, mgr.SeedParsers())
+	_, err := mgr.LoadSavedParsers()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "OBDII_Service01.golden.json"),
+		[]byte(`[{"input":"410C1AF8"},{"input":"410D3C"},{"input":"41 05 7B"}]`), 0o644))
+
+	dispatcher := parser.NewDispatcher(mgr)
+	server := NewServer(dispatcher, mgr, parser.NewDiscoveryService(dispatcher, mgr, parser.DiscoveryConfig{Provider: "ollama"}))
+
+	uri := "protocol://schema/OBDII_Service01"
+	result, err := server.handleSchema(context.Background(), &mcp.ReadResourceRequest{Params: &mcp.ReadResourceParams{URI: uri}})
+	require.NoError(t, err)
+	require.Len(t, result.Contents, 1)
+	assert.Equal(t, uri, result.Contents[0].URI)
+
+	var schema SchemaResource
+	require.NoError(t, json.Unmarshal([]byte(result.Contents[0].Text), &schema))
+	assert.Equal(t, "OBDII_Service01", schema.Protocol)
+	assert.Equal(t, 3, schema.Samples)
+	assert.Equal(t, "float64", schema.Fields["value"])
+	assert.Equal(t, "string", schema.Fields["unit"])
+
+	_, err = server.handleSchema(context.Background(), &mcp.ReadResourceRequest{Params: &mcp.ReadResourceParams{URI: "protocol://schema/unknown"}})
+	assert.Error(t, err)
+	_, err = server.handleSchema(context.Background(), &mcp.ReadResourceRequest{Params: &mcp.ReadResourceParams{URI: "protocol://schema/Engine_System"}})
+	assert.Error(t, err, "a protocol without stored samples has no schema to infer")
+}
+
+func TestRouteSignatureHandler(t *testing.T) {
+	mgr := parser.NewParserManager(t.TempDir(), "")
+	dispatcher := parser.NewDispatcher(mgr)
+	code := "package dynamic\nfunc Parse(data []byte) map[string]interface{} { return map[string]interface{}{} }\n"
+	require.NoError(t, mgr.RegisterParser("OBDII_Service01", code))
+	require.NoError(t, mgr.RegisterParser("OBD_RPM", code))
+	dispatcher.Bind([]byte{0x41}, "OBDII_Service01")
+	dispatcher.Bind([]byte{0x41, 0x0C}, "OBD_RPM")
+	require.NoError(t, mgr.SetAlias("OBD_RPM", "Engine RPM"))
+	server := NewServer(dispatcher, mgr, nil)
+
+	_, output, err := server.handleRouteSignature(context.Background(), &mcp.CallToolRequest{}, RouteSignatureInput{Signature: "41 0C"})
+	require.NoError(t, err)
+	assert.Equal(t, RouteSignatureOutput{Matched: true, Protocol: "OBD_RPM", Alias: "Engine RPM", Enabled: true}, output)
+
+	dispatcher.SetEnabled("OBDII_Service01", false)
+	_, output, err = server.handleRouteSignature(context.Background(), &mcp.CallToolRequest{}, RouteSignatureInput{Signature: "4105"})
+	require.NoError(t, err)
+	assert.Equal(t, "OBDII_Service01", output.Protocol)
+	assert.False(t, output.Enabled)
+
+	_, output, err = server.handleRouteSignature(context.Background(), &mcp.CallToolRequest{}, RouteSignatureInput{Signature: "99"})
+	require.NoError(t, err)
+	assert.False(t, output.Matched)
+
+	_, _, err = server.handleRouteSignature(context.Background(), &mcp.CallToolRequest{}, RouteSignatureInput{Signature: "zz"})
+	assert.Error(t, err)
+}
+
+func TestDeleteProtocolHandler(t *testing.T) {
+	mgr := parser.NewParserManager(t.TempDir(), "")
+	dispatcher := parser.NewDispatcher(mgr)
+	code := "package dynamic\nfunc Parse(data []byte) map[string]interface{} { return map[string]interface{}{\"v\": 1} }\n"
+	require.NoError(t, mgr.RegisterParser("bad_proto", code))
+	require.NoError(t, mgr.RegisterParser("good_proto", code))
+	dispatcher.Bind([]byte{0x10}, "bad_proto")
+	dispatcher.Bind([]byte{0x11, 0x22}, "bad_proto")
+	dispatcher.Bind([]byte{0x20}, "good_proto")
+	_, _, err := dispatcher.Ingest([]byte{0x10, 0x00})
+	require.NoError(t, err)
+	server := NewServer(dispatcher, mgr, nil)
+
+	_, output, err := server.handleDeleteProtocol(context.Background(), &mcp.CallToolRequest{}, DeleteProtocolInput{Protocol: "bad_proto"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, output.Unbound)
+
+	_, _, err = dispatcher.Ingest([]byte{0x10, 0x00})
+	assert.ErrorIs(t, err, parser.ErrUnknownProtocol)
+	_, exists := mgr.GetParserCode("bad_proto")
+	assert.False(t, exists)
+	manifest, err := mgr.LoadManifest()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"20": "good_proto"}, manifest)
+
+	_, _, err = server.handleDeleteProtocol(context.Background(), &mcp.CallToolRequest{}, DeleteProtocolInput{Protocol: "bad_proto"})
+	assert.Error(t, err)
+}
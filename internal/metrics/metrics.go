@@ -0,0 +1,182 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultLatencyBuckets are tuned for parsers that usually finish well under a
+// millisecond, while still covering the 50ms execution timeout.
+var DefaultLatencyBuckets = []float64{
+	0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005,
+	0.001, 0.0025, 0.005, 0.01, 0.025, 0.05,
+}
+
+// Config controls how the collectors are built.
+type Config struct {
+	// LatencyBuckets are the histogram buckets (in seconds) for parser execution latency.
+	// If empty, DefaultLatencyBuckets is used.
+	LatencyBuckets []float64
+}
+
+// Collectors holds every Prometheus collector exported by OmniBridge.
+type Collectors struct {
+	registry *prometheus.Registry
+
+	IngestTotal      prometheus.Counter
+	ParseTotal       *prometheus.CounterVec
+	DiscoveryTotal   *prometheus.CounterVec
+	RepairTotal      *prometheus.CounterVec
+	ExecutionLatency *prometheus.HistogramVec
+	DeadLetterRate   prometheus.Gauge
+	DeadLetterHealth prometheus.Gauge
+	EscalationTotal  prometheus.Counter
+}
+
+var (
+	global *Collectors
+	once   sync.Once
+)
+
+// New builds a fresh set of collectors registered on their own registry.
+func New(cfg Config) *Collectors {
+	buckets := cfg.LatencyBuckets
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+
+	c := &Collectors{
+		registry: prometheus.NewRegistry(),
+		IngestTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "omnibridge",
+			Name:      "ingest_total",
+			Help:      "Total number of frames passed to the dispatcher.",
+		}),
+		ParseTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "omnibridge",
+			Name:      "parse_total",
+			Help:      "Parse outcomes per protocol.",
+		}, []string{"protocol", "status"}),
+		DiscoveryTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "omnibridge",
+			Name:      "discovery_total",
+			Help:      "AI discovery invocations by outcome.",
+		}, []string{"status"}),
+		RepairTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "omnibridge",
+			Name:      "repair_total",
+			Help:      "AI repair attempts by outcome.",
+		}, []string{"status"}),
+		ExecutionLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "omnibridge",
+			Name:      "parser_execution_seconds",
+			Help:      "Parser execution latency in seconds.",
+			Buckets:   buckets,
+		}, []string{"protocol"}),
+		DeadLetterRate: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "omnibridge",
+			Name:      "dead_letter_rate",
+			Help:      "Rolling fraction of frames that could not be parsed.",
+		}),
+		DeadLetterHealth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "omnibridge",
+			Name:      "dead_letter_degraded",
+			Help:      "1 while the dead-letter rate is above the configured threshold.",
+		}),
+		EscalationTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "omnibridge",
+			Name:      "discovery_escalations_total",
+			Help:      "Signatures escalated after exhausting discovery retries in fail-closed mode.",
+		}),
+	}
+
+	c.registry.MustRegister(
+		c.IngestTotal,
+		c.ParseTotal,
+		c.DiscoveryTotal,
+		c.RepairTotal,
+		c.ExecutionLatency,
+		c.DeadLetterRate,
+		c.DeadLetterHealth,
+		c.EscalationTotal,
+	)
+	return c
+}
+
+// Init initializes the global collectors. Only the first call has an effect.
+func Init(cfg Config) {
+	once.Do(func() {
+		global = New(cfg)
+	})
+}
+
+// Get returns the global collectors, initializing them with defaults if Init hasn't been called.
+func Get() *Collectors {
+	Init(Config{})
+	return global
+}
+
+// Handler returns an HTTP handler serving the collectors in the Prometheus text format.
+func (c *Collectors) Handler() http.Handler {
+	return promhttp.HandlerFor(c.registry, promhttp.HandlerOpts{})
+}
+
+// Handler serves the global collectors.
+func Handler() http.Handler {
+	return Get().Handler()
+}
+
+// IncIngest records a frame entering the dispatcher.
+func IncIngest() {
+	Get().IngestTotal.Inc()
+}
+
+// ObserveParse records the outcome of parsing a frame for a protocol.
+func ObserveParse(protocol string, err error) {
+	Get().ParseTotal.WithLabelValues(protocol, status(err)).Inc()
+}
+
+// ObserveDiscovery records the outcome of an AI discovery.
+func ObserveDiscovery(err error) {
+	Get().DiscoveryTotal.WithLabelValues(status(err)).Inc()
+}
+
+// ObserveRepair records the outcome of an AI repair.
+func ObserveRepair(err error) {
+	Get().RepairTotal.WithLabelValues(status(err)).Inc()
+}
+
+// ObserveExecution records how long a parser took to execute.
+func ObserveExecution(protocol string, d time.Duration) {
+	Get().ExecutionLatency.WithLabelValues(protocol).Observe(d.Seconds())
+}
+
+// SetDeadLetterRate publishes the rolling dead-letter rate.
+func SetDeadLetterRate(rate float64) {
+	Get().DeadLetterRate.Set(rate)
+}
+
+// SetDeadLetterDegraded publishes whether the dead-letter rate crossed its threshold.
+func SetDeadLetterDegraded(degraded bool) {
+	v := 0.0
+	if degraded {
+		v = 1
+	}
+	Get().DeadLetterHealth.Set(v)
+}
+
+// IncEscalation records a fail-closed discovery escalation.
+func IncEscalation() {
+	Get().EscalationTotal.Inc()
+}
+
+func status(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
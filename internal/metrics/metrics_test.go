@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollectors_Counters(t *testing.T) {
+	c := New(Config{})
+
+	c.IngestTotal.Inc()
+	c.ParseTotal.WithLabelValues("proto_a", status(nil)).Inc()
+	c.ParseTotal.WithLabelValues("proto_a", status(errors.New("boom"))).Inc()
+	c.DiscoveryTotal.WithLabelValues(status(nil)).Inc()
+	c.RepairTotal.WithLabelValues(status(errors.New("boom"))).Inc()
+
+	if got := testutil.ToFloat64(c.IngestTotal); got != 1 {
+		t.Errorf("Expected 1 ingest, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.ParseTotal.WithLabelValues("proto_a", "success")); got != 1 {
+		t.Errorf("Expected 1 success, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.ParseTotal.WithLabelValues("proto_a", "failure")); got != 1 {
+		t.Errorf("Expected 1 failure, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.DiscoveryTotal.WithLabelValues("success")); got != 1 {
+		t.Errorf("Expected 1 discovery, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.RepairTotal.WithLabelValues("failure")); got != 1 {
+		t.Errorf("Expected 1 failed repair, got %v", got)
+	}
+}
+
+func TestCollectors_CustomBuckets(t *testing.T) {
+	c := New(Config{LatencyBuckets: []float64{0.001, 0.002}})
+	c.ExecutionLatency.WithLabelValues("proto_a").Observe((500 * time.Microsecond).Seconds())
+
+	rec := httptest.NewRecorder()
+	c.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	if !strings.Contains(body, `omnibridge_parser_execution_seconds_bucket{protocol="proto_a",le="0.001"} 1`) {
+		t.Errorf("Expected custom bucket in output, got:\n%s", body)
+	}
+	if strings.Contains(body, `le="0.05"`) {
+		t.Error("Default buckets should not be used when custom buckets are configured")
+	}
+}
@@ -0,0 +1,291 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Defaults for Options left zero
+const (
+	DefaultKeepAlive      = 30 * time.Second
+	DefaultConnectTimeout = 10 * time.Second
+)
+
+// ErrClosed is returned by calls on a client after Close
+var ErrClosed = errors.New("mqtt: client closed")
+
+// Options configure a connection
+type Options struct {
+	ClientID       string
+	Username       string
+	Password       string
+	KeepAlive      time.Duration // Interval between pings; the broker drops the client after 1.5x of silence
+	ConnectTimeout time.Duration // Bounds the TCP dial, CONNECT and each SUBSCRIBE round trip
+}
+
+// Handler receives a message for a subscription. It runs on the client's read loop, so it
+// must not block: hand the message off to another goroutine for slow work.
+type Handler func(topic string, payload []byte)
+
+type subscription struct {
+	id      uint16 // Of the SUBSCRIBE packet that created it
+	filter  string
+	handler Handler
+}
+
+// Client is a connection to an MQTT broker with a clean session. Subscriptions and
+// publishes use QoS 0. It does not reconnect: when Done is closed, Dial again.
+type Client struct {
+	conn net.Conn
+	opts Options
+
+	subs    []subscription
+	pending map[uint16]chan byte // SUBSCRIBE packet ID -> granted QoS from the SUBACK
+	nextID  uint16
+	mu      sync.Mutex
+
+	writeMu   sync.Mutex // Serializes packet writes
+	done      chan struct{}
+	err       error
+	closeOnce sync.Once
+}
+
+// Dial connects to the broker at addr (host:port) and completes the MQTT handshake
+func Dial(ctx context.Context, addr string, opts Options) (*Client, error) {
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = DefaultKeepAlive
+	}
+	if opts.ConnectTimeout <= 0 {
+		opts.ConnectTimeout = DefaultConnectTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.ConnectTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("mqtt: %w", err)
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+	if err := WritePacket(conn, connectPacket(opts)); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("mqtt: CONNECT: %w", err)
+	}
+	ack, err := ReadPacket(conn)
+	if err == nil && (ack.Type != TypeConnack || len(ack.Body) != 2) {
+		err = fmt.Errorf("expected CONNACK, got packet type %d", ack.Type)
+	}
+	if err == nil && ack.Body[1] != 0 {
+		err = fmt.Errorf("connection refused: %s", connackReason(ack.Body[1]))
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("mqtt: %w", err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	c := &Client{conn: conn, opts: opts, pending: make(map[uint16]chan byte), done: make(chan struct{})}
+	go c.readLoop()
+	go c.pingLoop()
+	return c, nil
+}
+
+func connectPacket(opts Options) Packet {
+	flags := byte(0x02) // Clean session
+	if opts.Username != "" {
+		flags |= 0x80
+		if opts.Password != "" {
+			flags |= 0x40
+		}
+	}
+	body := appendString(nil, "MQTT")
+	body = append(body, protocolLevel, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(opts.KeepAlive/time.Second))
+	body = appendString(body, opts.ClientID)
+	if flags&0x80 != 0 {
+		body = appendString(body, opts.Username)
+	}
+	if flags&0x40 != 0 {
+		body = appendString(body, opts.Password)
+	}
+	return Packet{Type: TypeConnect, Body: body}
+}
+
+func connackReason(code byte) string {
+	switch code {
+	case 1:
+		return "unacceptable protocol version"
+	case 2:
+		return "client identifier rejected"
+	case 3:
+		return "server unavailable"
+	case 4:
+		return "bad user name or password"
+	case 5:
+		return "not authorized"
+	}
+	return fmt.Sprintf("return code %d", code)
+}
+
+// Subscribe subscribes to filter, which may contain '+' and '#' wildcards, and routes
+// matching messages to h. It waits for the broker's SUBACK.
+func (c *Client) Subscribe(ctx context.Context, filter string, h Handler) (err error) {
+	c.mu.Lock()
+	c.nextID++
+	if c.nextID == 0 {
+		c.nextID = 1 // Packet identifiers must be non-zero
+	}
+	id := c.nextID
+	granted := make(chan byte, 1)
+	c.pending[id] = granted
+	// Register first so messages arriving right after the SUBACK aren't lost
+	c.subs = append(c.subs, subscription{id: id, filter: filter, handler: h})
+	c.mu.Unlock()
+	defer func() {
+		if err != nil {
+			c.mu.Lock()
+			c.subs = slices.DeleteFunc(c.subs, func(s subscription) bool { return s.id == id })
+			delete(c.pending, id)
+			c.mu.Unlock()
+		}
+	}()
+
+	body := binary.BigEndian.AppendUint16(nil, id)
+	body = appendString(body, filter)
+	body = append(body, 0) // QoS 0
+	if err := c.write(Packet{Type: TypeSubscribe, Flags: subscribeFlags, Body: body}); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.ConnectTimeout)
+	defer cancel()
+	select {
+	case qos := <-granted:
+		if qos == 0x80 {
+			return fmt.Errorf("mqtt: broker refused subscription to %q", filter)
+		}
+		return nil
+	case <-c.done:
+		return c.Err()
+	case <-ctx.Done():
+		return fmt.Errorf("mqtt: no SUBACK for %q: %w", filter, ctx.Err())
+	}
+}
+
+// Publish sends payload to topic at QoS 0
+func (c *Client) Publish(topic string, payload []byte) error {
+	return c.write(EncodePublish(topic, payload))
+}
+
+// Done is closed when the connection ends, after Close or a network error
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the connection ended, or nil while it is up
+func (c *Client) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// Close disconnects from the broker
+func (c *Client) Close() error {
+	_ = c.write(Packet{Type: TypeDisconnect})
+	c.shutdown(ErrClosed)
+	return nil
+}
+
+func (c *Client) shutdown(err error) {
+	c.closeOnce.Do(func() {
+		c.err = err
+		close(c.done)
+		_ = c.conn.Close()
+	})
+}
+
+func (c *Client) write(p Packet) error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.opts.KeepAlive))
+	if err := WritePacket(c.conn, p); err != nil {
+		c.shutdown(fmt.Errorf("mqtt: write: %w", err))
+		return c.err
+	}
+	return nil
+}
+
+func (c *Client) readLoop() {
+	for {
+		// The broker answers our pings, so silence for 1.5x the keep-alive means it's gone
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.opts.KeepAlive * 3 / 2))
+		p, err := ReadPacket(c.conn)
+		if err != nil {
+			c.shutdown(fmt.Errorf("mqtt: connection lost: %w", err))
+			return
+		}
+		switch p.Type {
+		case TypePublish:
+			topic, payload, err := DecodePublish(p)
+			if err != nil {
+				c.shutdown(fmt.Errorf("mqtt: %w", err))
+				return
+			}
+			c.deliver(topic, payload)
+		case TypeSuback:
+			if len(p.Body) >= 3 {
+				c.mu.Lock()
+				id := binary.BigEndian.Uint16(p.Body)
+				if granted, ok := c.pending[id]; ok {
+					granted <- p.Body[2]
+					delete(c.pending, id)
+				}
+				c.mu.Unlock()
+			}
+		}
+	}
+}
+
+func (c *Client) deliver(topic string, payload []byte) {
+	c.mu.Lock()
+	var handlers []Handler
+	for _, s := range c.subs {
+		if MatchTopic(s.filter, topic) {
+			handlers = append(handlers, s.handler)
+		}
+	}
+	c.mu.Unlock()
+	for _, h := range handlers {
+		h(topic, payload)
+	}
+}
+
+func (c *Client) pingLoop() {
+	ticker := time.NewTicker(c.opts.KeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.write(Packet{Type: TypePingreq}); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package mqtt_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/mqtt"
+	"github.com/chuanjin/OmniBridge/internal/mqtt/mqtttest"
+)
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"sensors/temp", "sensors/temp", true},
+		{"sensors/temp", "sensors/hum", false},
+		{"sensors/+/raw", "sensors/dev1/raw", true},
+		{"sensors/+/raw", "sensors/dev1/x/raw", false},
+		{"sensors/+", "sensors", false},
+		{"sensors/#", "sensors/dev1/raw", true},
+		{"sensors/#", "sensors", true},
+		{"#", "anything/at/all", true},
+		{"+/+", "a/b", true},
+		{"#", "$SYS/broker/load", false},
+		{"$SYS/#", "$SYS/broker/load", true},
+	}
+	for _, tt := range tests {
+		if got := mqtt.MatchTopic(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("MatchTopic(%q, %q) = %v, want %v", tt.filter, tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestPacket_RoundTrip(t *testing.T) {
+	// 200 bytes needs a two-byte remaining length
+	payload := bytes.Repeat([]byte{0xAB}, 200)
+	var buf bytes.Buffer
+	if err := mqtt.WritePacket(&buf, mqtt.EncodePublish("a/b", payload)); err != nil {
+		t.Fatalf("WritePacket failed: %v", err)
+	}
+	p, err := mqtt.ReadPacket(&buf)
+	if err != nil {
+		t.Fatalf("ReadPacket failed: %v", err)
+	}
+	topic, got, err := mqtt.DecodePublish(p)
+	if err != nil || topic != "a/b" || !bytes.Equal(got, payload) {
+		t.Errorf("DecodePublish = %q, %d bytes, %v", topic, len(got), err)
+	}
+}
+
+func TestClient_SubscribeAndPublish(t *testing.T) {
+	broker, err := mqtttest.NewBroker()
+	if err != nil {
+		t.Fatalf("NewBroker failed: %v", err)
+	}
+	defer broker.Close()
+
+	ctx := context.Background()
+	client, err := mqtt.Dial(ctx, broker.Addr, mqtt.Options{ClientID: "test", Username: "u", Password: "p"})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	received := make(chan mqtttest.Message, 4)
+	if err := client.Subscribe(ctx, "sensors/+/raw", func(topic string, payload []byte) {
+		received <- mqtttest.Message{Topic: topic, Payload: payload}
+	}); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	broker.Publish("sensors/other/cooked", []byte{0x00})
+	broker.Publish("sensors/dev7/raw", []byte{0x41, 0x0C})
+	select {
+	case msg := <-received:
+		if msg.Topic != "sensors/dev7/raw" || !bytes.Equal(msg.Payload, []byte{0x41, 0x0C}) {
+			t.Errorf("unexpected message %+v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscribed message not delivered")
+	}
+
+	notified := broker.Received()
+	if err := client.Publish("results/dev7", []byte(`{"rpm":1726}`)); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	select {
+	case <-notified:
+	case <-time.After(2 * time.Second):
+		t.Fatal("published message not received by the broker")
+	}
+	if msgs := broker.Messages(); len(msgs) != 1 || msgs[0].Topic != "results/dev7" {
+		t.Errorf("broker got %+v", msgs)
+	}
+
+	broker.DropClients()
+	select {
+	case <-client.Done():
+		if client.Err() == nil {
+			t.Error("expected an error after the broker dropped the connection")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("client did not notice the dropped connection")
+	}
+	if err := client.Publish("results/dev7", nil); err == nil || errors.Is(err, mqtt.ErrClosed) {
+		t.Errorf("expected the connection error from Publish, got %v", err)
+	}
+}
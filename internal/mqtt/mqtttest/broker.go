@@ -0,0 +1,185 @@
+// Package mqtttest provides an in-process MQTT broker for tests, in the spirit of httptest
+package mqtttest
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+
+	"github.com/chuanjin/OmniBridge/internal/mqtt"
+)
+
+// Message is a PUBLISH the broker received
+type Message struct {
+	Topic   string
+	Payload []byte
+}
+
+// Broker is a minimal QoS 0 broker on a loopback port. It accepts any client, routes
+// publishes to matching subscriptions and records every message it receives.
+type Broker struct {
+	Addr string // host:port to dial
+
+	listener net.Listener
+	clients  map[net.Conn][]string // Connection -> its subscription filters
+	refused  map[string]bool       // Filters whose subscriptions fail
+	messages []Message
+	notify   chan struct{} // Closed and replaced whenever a message arrives
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+}
+
+// NewBroker starts a broker; Close it when done
+func NewBroker() (*Broker, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	b := &Broker{Addr: l.Addr().String(), listener: l, clients: make(map[net.Conn][]string), refused: make(map[string]bool), notify: make(chan struct{})}
+	b.wg.Add(1)
+	go b.accept()
+	return b, nil
+}
+
+// Publish sends a message to the matching subscribers, as if a device had published it
+func (b *Broker) Publish(topic string, payload []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.routeLocked(topic, payload)
+}
+
+// Messages returns the messages published by clients so far
+func (b *Broker) Messages() []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Message(nil), b.messages...)
+}
+
+// Received returns a channel closed when the next client message arrives
+func (b *Broker) Received() <-chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.notify
+}
+
+// Subscribed reports whether some client holds a subscription to exactly filter
+func (b *Broker) Subscribed(filter string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, filters := range b.clients {
+		for _, f := range filters {
+			if f == filter {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Refuse makes later subscriptions to exactly filter fail, e.g. to test an ACL rejection
+func (b *Broker) Refuse(filter string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refused[filter] = true
+}
+
+// DropClients closes every client connection, e.g. to test reconnection
+func (b *Broker) DropClients() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for conn := range b.clients {
+		_ = conn.Close()
+	}
+}
+
+// Close stops the broker and disconnects its clients
+func (b *Broker) Close() {
+	_ = b.listener.Close()
+	b.DropClients()
+	b.wg.Wait()
+}
+
+func (b *Broker) accept() {
+	defer b.wg.Done()
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		b.clients[conn] = nil
+		b.mu.Unlock()
+		b.wg.Add(1)
+		go b.serve(conn)
+	}
+}
+
+func (b *Broker) serve(conn net.Conn) {
+	defer b.wg.Done()
+	defer func() {
+		b.mu.Lock()
+		delete(b.clients, conn)
+		b.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	for {
+		p, err := mqtt.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		switch p.Type {
+		case mqtt.TypeConnect:
+			b.write(conn, mqtt.Packet{Type: mqtt.TypeConnack, Body: []byte{0, 0}})
+		case mqtt.TypeSubscribe:
+			id, filters, err := mqtt.DecodeSubscribe(p)
+			if err != nil {
+				return
+			}
+			body := binary.BigEndian.AppendUint16(nil, id)
+			b.mu.Lock()
+			for _, f := range filters {
+				if b.refused[f] {
+					body = append(body, 0x80) // Failure
+					continue
+				}
+				b.clients[conn] = append(b.clients[conn], f)
+				body = append(body, 0) // Granted QoS 0
+			}
+			b.mu.Unlock()
+			b.write(conn, mqtt.Packet{Type: mqtt.TypeSuback, Body: body})
+		case mqtt.TypePublish:
+			topic, payload, err := mqtt.DecodePublish(p)
+			if err != nil {
+				return
+			}
+			b.mu.Lock()
+			b.messages = append(b.messages, Message{Topic: topic, Payload: payload})
+			close(b.notify)
+			b.notify = make(chan struct{})
+			b.routeLocked(topic, payload)
+			b.mu.Unlock()
+		case mqtt.TypePingreq:
+			b.write(conn, mqtt.Packet{Type: mqtt.TypePingresp})
+		case mqtt.TypeDisconnect:
+			return
+		}
+	}
+}
+
+// routeLocked forwards a message to every client with a matching filter, once per client
+func (b *Broker) routeLocked(topic string, payload []byte) {
+	for conn, filters := range b.clients {
+		for _, f := range filters {
+			if mqtt.MatchTopic(f, topic) {
+				b.write(conn, mqtt.EncodePublish(topic, payload))
+				break
+			}
+		}
+	}
+}
+
+func (b *Broker) write(conn net.Conn, p mqtt.Packet) {
+	// A failed write surfaces as a read error on the client's serve loop
+	_ = mqtt.WritePacket(conn, p)
+}
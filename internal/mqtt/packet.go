@@ -0,0 +1,182 @@
+// Package mqtt is a minimal MQTT 3.1.1 client: connect, subscribe and publish at QoS 0,
+// which is all the gateway needs to bridge sensor topics. See mqtttest for a test broker.
+package mqtt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PacketType is an MQTT control packet type, the high nibble of the fixed header
+type PacketType byte
+
+const (
+	TypeConnect    PacketType = 1
+	TypeConnack    PacketType = 2
+	TypePublish    PacketType = 3
+	TypePuback     PacketType = 4
+	TypeSubscribe  PacketType = 8
+	TypeSuback     PacketType = 9
+	TypePingreq    PacketType = 12
+	TypePingresp   PacketType = 13
+	TypeDisconnect PacketType = 14
+)
+
+const (
+	protocolLevel  = 4       // MQTT 3.1.1
+	maxPacketSize  = 1 << 20 // Frames are small; larger packets close the connection
+	subscribeFlags = 0x02    // Reserved flags the spec requires on SUBSCRIBE
+)
+
+// errMalformed reports a packet body that doesn't follow the spec
+var errMalformed = errors.New("malformed MQTT packet")
+
+// Packet is one control packet: its type, the low nibble of the fixed header and the body
+type Packet struct {
+	Type  PacketType
+	Flags byte
+	Body  []byte
+}
+
+// ReadPacket reads one packet; bodies over 1 MiB are rejected
+func ReadPacket(r io.Reader) (Packet, error) {
+	var header [1]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return Packet{}, err
+	}
+
+	// Remaining length: up to four 7-bit groups, least significant first
+	var length, shift int
+	for i := 0; ; i++ {
+		if i == 4 {
+			return Packet{}, fmt.Errorf("%w: remaining length too long", errMalformed)
+		}
+		var b [1]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return Packet{}, err
+		}
+		length |= int(b[0]&0x7F) << shift
+		if b[0]&0x80 == 0 {
+			break
+		}
+		shift += 7
+	}
+	if length > maxPacketSize {
+		return Packet{}, fmt.Errorf("MQTT packet of %d bytes exceeds the %d byte limit", length, maxPacketSize)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return Packet{}, err
+	}
+	return Packet{Type: PacketType(header[0] >> 4), Flags: header[0] & 0x0F, Body: body}, nil
+}
+
+// WritePacket writes p in a single Write call
+func WritePacket(w io.Writer, p Packet) error {
+	buf := []byte{byte(p.Type)<<4 | p.Flags&0x0F}
+	length := len(p.Body)
+	for {
+		b := byte(length & 0x7F)
+		length >>= 7
+		if length > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if length == 0 {
+			break
+		}
+	}
+	_, err := w.Write(append(buf, p.Body...))
+	return err
+}
+
+// appendString appends s with its two-byte length prefix
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// readString consumes a length-prefixed string from b
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errMalformed
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errMalformed
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+// EncodePublish builds a QoS 0 PUBLISH packet
+func EncodePublish(topic string, payload []byte) Packet {
+	body := appendString(nil, topic)
+	return Packet{Type: TypePublish, Body: append(body, payload...)}
+}
+
+// DecodePublish returns the topic and payload of a PUBLISH packet at any QoS
+func DecodePublish(p Packet) (topic string, payload []byte, err error) {
+	topic, rest, err := readString(p.Body)
+	if err != nil {
+		return "", nil, err
+	}
+	if qos := (p.Flags >> 1) & 0x03; qos > 0 {
+		if len(rest) < 2 {
+			return "", nil, errMalformed
+		}
+		rest = rest[2:] // Packet identifier
+	}
+	return topic, rest, nil
+}
+
+// DecodeSubscribe returns the packet identifier and topic filters of a SUBSCRIBE packet
+func DecodeSubscribe(p Packet) (id uint16, filters []string, err error) {
+	if len(p.Body) < 2 {
+		return 0, nil, errMalformed
+	}
+	id = binary.BigEndian.Uint16(p.Body)
+	rest := p.Body[2:]
+	for len(rest) > 0 {
+		var filter string
+		if filter, rest, err = readString(rest); err != nil || len(rest) < 1 {
+			return 0, nil, errMalformed
+		}
+		filters = append(filters, filter)
+		rest = rest[1:] // Requested QoS
+	}
+	if len(filters) == 0 {
+		return 0, nil, errMalformed
+	}
+	return id, filters, nil
+}
+
+// MatchTopic reports whether topic matches filter, where '+' matches one level and a
+// trailing '#' the remaining levels (including none). Wildcards at the first level
+// don't match topics starting with '$', which are reserved for broker internals.
+func MatchTopic(filter, topic string) bool {
+	if len(topic) > 0 && topic[0] == '$' && len(filter) > 0 && (filter[0] == '+' || filter[0] == '#') {
+		return false
+	}
+	for {
+		fLevel, fRest, fMore := strings.Cut(filter, "/")
+		tLevel, tRest, tMore := strings.Cut(topic, "/")
+		switch {
+		case fLevel == "#":
+			return true
+		case fLevel != "+" && fLevel != tLevel:
+			return false
+		case !fMore && !tMore:
+			return true
+		case !tMore:
+			// "a/#" matches "a"
+			return fRest == "#"
+		case !fMore:
+			return false
+		}
+		filter, topic = fRest, tRest
+	}
+}
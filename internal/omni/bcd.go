@@ -0,0 +1,55 @@
+package omni
+
+// BCD readers decode the n bytes starting at data[off] as a decimal number. Like the
+// byte readers they return 0 when the value doesn't fit in data, and -1 when a digit
+// is not 0-9, so a mis-aligned field shows up instead of decoding to garbage.
+
+// BCD reads packed BCD, two digits per byte, most significant first: 0x12 0x34 is 1234.
+func BCD(data []byte, off, n int) int {
+	if !fits(data, off, n) {
+		return 0
+	}
+	v := 0
+	for _, b := range data[off : off+n] {
+		hi, lo := int(b>>4), int(b&0x0F)
+		if hi > 9 || lo > 9 {
+			return -1
+		}
+		v = v*100 + hi*10 + lo
+	}
+	return v
+}
+
+// BCDLE reads packed BCD stored least significant byte first, as M-Bus meters do:
+// 0x34 0x12 is 1234.
+func BCDLE(data []byte, off, n int) int {
+	if !fits(data, off, n) {
+		return 0
+	}
+	v := 0
+	for i := off + n - 1; i >= off; i-- {
+		hi, lo := int(data[i]>>4), int(data[i]&0x0F)
+		if hi > 9 || lo > 9 {
+			return -1
+		}
+		v = v*100 + hi*10 + lo
+	}
+	return v
+}
+
+// UnpackedBCD reads one digit per byte from the low nibble, most significant first:
+// 0x01 0x02 0x03 is 123. The high (zone) nibble is ignored, so ASCII digits decode too.
+func UnpackedBCD(data []byte, off, n int) int {
+	if !fits(data, off, n) {
+		return 0
+	}
+	v := 0
+	for _, b := range data[off : off+n] {
+		d := int(b & 0x0F)
+		if d > 9 {
+			return -1
+		}
+		v = v*10 + d
+	}
+	return v
+}
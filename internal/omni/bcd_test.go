@@ -0,0 +1,32 @@
+package omni
+
+import "testing"
+
+func TestBCDReaders(t *testing.T) {
+	data := []byte{0x12, 0x34, 0x56, 0x1A, 0x35}
+
+	tests := []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"BCD", BCD(data, 0, 2), 1234},
+		{"BCD single byte", BCD(data, 2, 1), 56},
+		{"BCD three bytes", BCD(data, 0, 3), 123456},
+		{"BCD short", BCD(data, 4, 2), 0},
+		{"BCD invalid digit", BCD(data, 3, 1), -1},
+		{"BCDLE", BCDLE(data, 0, 2), 3412},
+		{"BCDLE three bytes", BCDLE(data, 0, 3), 563412},
+		{"BCDLE invalid digit", BCDLE(data, 2, 2), -1},
+		{"BCDLE short", BCDLE(data, -1, 2), 0},
+		{"UnpackedBCD", UnpackedBCD([]byte{0x01, 0x02, 0x03}, 0, 3), 123},
+		{"UnpackedBCD ASCII", UnpackedBCD([]byte("0815"), 0, 4), 815},
+		{"UnpackedBCD invalid digit", UnpackedBCD(data, 3, 1), -1},
+		{"UnpackedBCD short", UnpackedBCD(data, 4, 2), 0},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+}
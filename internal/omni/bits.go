@@ -0,0 +1,35 @@
+// Package omni holds helpers exposed to AI-generated parsers inside the sandbox.
+// Parsers reach them via `import "omni"`.
+package omni
+
+// Bit reports whether bit n (0 = least significant) of v is set.
+// Out-of-range bits are reported as unset.
+func Bit(v uint64, n int) bool {
+	if n < 0 || n > 63 {
+		return false
+	}
+	return v&(1<<uint(n)) != 0
+}
+
+// Bits extracts width bits of v starting at bit offset (0 = least significant).
+// For example, Bits(0b1011_0100, 2, 3) returns 0b101.
+func Bits(v uint64, offset, width int) uint64 {
+	if offset < 0 || offset > 63 || width <= 0 {
+		return 0
+	}
+	v >>= uint(offset)
+	if width >= 64 {
+		return v
+	}
+	return v & (1<<uint(width) - 1)
+}
+
+// Signed sign-extends the low width bits of v, e.g. for a 12-bit signed field
+// extracted with Bits. Signed(0xFFF, 12) returns -1.
+func Signed(v uint64, width int) int64 {
+	if width <= 0 || width >= 64 {
+		return int64(v)
+	}
+	shift := uint(64 - width)
+	return int64(v<<shift) >> shift
+}
@@ -0,0 +1,53 @@
+package omni
+
+import "testing"
+
+func TestBit(t *testing.T) {
+	if !Bit(0x81, 0) || !Bit(0x81, 7) {
+		t.Error("expected bits 0 and 7 of 0x81 to be set")
+	}
+	if Bit(0x81, 1) {
+		t.Error("expected bit 1 of 0x81 to be clear")
+	}
+	if Bit(0xFF, -1) || Bit(0xFF, 64) {
+		t.Error("expected out-of-range bits to be clear")
+	}
+}
+
+func TestBits(t *testing.T) {
+	tests := []struct {
+		v             uint64
+		offset, width int
+		want          uint64
+	}{
+		{0xB4, 2, 3, 0x5},
+		{0xB4, 4, 4, 0xB},
+		{0xFFFF, 0, 64, 0xFFFF},
+		{0xFF, 0, 0, 0},
+		{0xFF, 64, 1, 0},
+	}
+	for _, tt := range tests {
+		if got := Bits(tt.v, tt.offset, tt.width); got != tt.want {
+			t.Errorf("Bits(%#x, %d, %d) = %#x, want %#x", tt.v, tt.offset, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestSigned(t *testing.T) {
+	tests := []struct {
+		v     uint64
+		width int
+		want  int64
+	}{
+		{0xFFF, 12, -1},
+		{0x7FF, 12, 2047},
+		{0xC8, 8, -56},
+		{0xC8, 9, 200},
+		{0xFFFFFFFFFFFFFFFF, 64, -1},
+	}
+	for _, tt := range tests {
+		if got := Signed(tt.v, tt.width); got != tt.want {
+			t.Errorf("Signed(%#x, %d) = %d, want %d", tt.v, tt.width, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package omni
+
+// Multi-byte readers decode the value starting at data[off]. Like Byte, they
+// return 0 instead of panicking when the value doesn't fit in data.
+
+// Byte returns data[idx], or 0 if idx is out of range.
+func Byte(data []byte, idx int) int {
+	if idx < 0 || idx >= len(data) {
+		return 0
+	}
+	return int(data[idx])
+}
+
+// U16BE reads a big-endian unsigned 16-bit value.
+func U16BE(data []byte, off int) int {
+	if !fits(data, off, 2) {
+		return 0
+	}
+	return int(data[off])<<8 | int(data[off+1])
+}
+
+// U16LE reads a little-endian unsigned 16-bit value.
+func U16LE(data []byte, off int) int {
+	if !fits(data, off, 2) {
+		return 0
+	}
+	return int(data[off+1])<<8 | int(data[off])
+}
+
+// U32BE reads a big-endian unsigned 32-bit value.
+func U32BE(data []byte, off int) int {
+	if !fits(data, off, 4) {
+		return 0
+	}
+	return int(data[off])<<24 | int(data[off+1])<<16 | int(data[off+2])<<8 | int(data[off+3])
+}
+
+// U32LE reads a little-endian unsigned 32-bit value.
+func U32LE(data []byte, off int) int {
+	if !fits(data, off, 4) {
+		return 0
+	}
+	return int(data[off+3])<<24 | int(data[off+2])<<16 | int(data[off+1])<<8 | int(data[off])
+}
+
+// I8 reads data[idx] as a two's-complement signed 8-bit value (0xC8 is -56).
+func I8(data []byte, idx int) int {
+	return int(int8(Byte(data, idx)))
+}
+
+// I16BE reads a big-endian two's-complement signed 16-bit value.
+func I16BE(data []byte, off int) int {
+	return int(int16(U16BE(data, off)))
+}
+
+// I16LE reads a little-endian two's-complement signed 16-bit value.
+func I16LE(data []byte, off int) int {
+	return int(int16(U16LE(data, off)))
+}
+
+// I32BE reads a big-endian two's-complement signed 32-bit value.
+func I32BE(data []byte, off int) int {
+	return int(int32(U32BE(data, off)))
+}
+
+// I32LE reads a little-endian two's-complement signed 32-bit value.
+func I32LE(data []byte, off int) int {
+	return int(int32(U32LE(data, off)))
+}
+
+func fits(data []byte, off, n int) bool {
+	return off >= 0 && off+n <= len(data)
+}
@@ -0,0 +1,38 @@
+package omni
+
+import "testing"
+
+func TestByteReaders(t *testing.T) {
+	data := []byte{0x1A, 0xF8, 0xFF, 0x38}
+
+	tests := []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"Byte", Byte(data, 1), 0xF8},
+		{"Byte out of range", Byte(data, 4), 0},
+		{"Byte negative", Byte(data, -1), 0},
+		{"U16BE", U16BE(data, 0), 0x1AF8},
+		{"U16LE", U16LE(data, 0), 0xF81A},
+		{"U16BE short", U16BE(data, 3), 0},
+		{"U32BE", U32BE(data, 0), 0x1AF8FF38},
+		{"U32BE short", U32BE(data, 1), 0},
+		{"I16BE negative", I16BE(data, 2), -200},
+		{"I16BE positive", I16BE(data, 0), 0x1AF8},
+		{"U32LE", U32LE(data, 0), 0x38FFF81A},
+		{"I8 negative", I8([]byte{0xC8}, 0), -56},
+		{"I8 positive", I8(data, 0), 0x1A},
+		{"I8 out of range", I8(data, 4), 0},
+		{"I16LE positive", I16LE(data, 2), 0x38FF},
+		{"I16LE", I16LE([]byte{0x38, 0xFF}, 0), -200},
+		{"I32BE negative", I32BE([]byte{0xFF, 0xFF, 0xFF, 0xFE}, 0), -2},
+		{"I32LE negative", I32LE([]byte{0xFE, 0xFF, 0xFF, 0xFF}, 0), -2},
+		{"I32BE short", I32BE(data, 1), 0},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+// Package exports holds the yaegi symbol table for the omni helpers, so the
+// engine can offer them to sandboxed parsers as `import "omni"`.
+package exports
+
+import "reflect"
+
+//go:generate go run github.com/traefik/yaegi/cmd/yaegi extract github.com/chuanjin/OmniBridge/internal/omni
+
+// Symbols is filled in by the generated export files in this package.
+var Symbols = map[string]map[string]reflect.Value{}
@@ -0,0 +1,30 @@
+// Code generated by 'yaegi extract github.com/chuanjin/OmniBridge/internal/omni'. DO NOT EDIT.
+
+package exports
+
+import (
+	"github.com/chuanjin/OmniBridge/internal/omni"
+	"reflect"
+)
+
+func init() {
+	Symbols["github.com/chuanjin/OmniBridge/internal/omni/omni"] = map[string]reflect.Value{
+		// function, constant and variable definitions
+		"BCD":         reflect.ValueOf(omni.BCD),
+		"BCDLE":       reflect.ValueOf(omni.BCDLE),
+		"Bit":         reflect.ValueOf(omni.Bit),
+		"Bits":        reflect.ValueOf(omni.Bits),
+		"Byte":        reflect.ValueOf(omni.Byte),
+		"I16BE":       reflect.ValueOf(omni.I16BE),
+		"I16LE":       reflect.ValueOf(omni.I16LE),
+		"I32BE":       reflect.ValueOf(omni.I32BE),
+		"I32LE":       reflect.ValueOf(omni.I32LE),
+		"I8":          reflect.ValueOf(omni.I8),
+		"Signed":      reflect.ValueOf(omni.Signed),
+		"U16BE":       reflect.ValueOf(omni.U16BE),
+		"U16LE":       reflect.ValueOf(omni.U16LE),
+		"U32BE":       reflect.ValueOf(omni.U32BE),
+		"U32LE":       reflect.ValueOf(omni.U32LE),
+		"UnpackedBCD": reflect.ValueOf(omni.UnpackedBCD),
+	}
+}
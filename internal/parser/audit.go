@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"go.uber.org/zap"
+)
+
+// auditLogFile is the audit trail's file name inside the storage directory
+const auditLogFile = "audit.jsonl"
+
+// AuditEntry records one discovery, repair or summary request and what the LLM returned for it
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	Kind       string    `json:"kind"` // "discovery", "repair" or "summary"
+	ProtocolID string    `json:"protocol_id,omitempty"`
+	Provider   string    `json:"provider"`
+	Model      string    `json:"model,omitempty"`
+	Prompt     string    `json:"prompt"`
+	Response   string    `json:"response,omitempty"` // Raw text of the last LLM response
+	Code       string    `json:"code,omitempty"`     // Response after the code sanitizer
+	Attempts   int       `json:"attempts"`
+	Outcome    string    `json:"outcome"` // "success" or "error"
+	Error      string    `json:"error,omitempty"`
+}
+
+// AuditLog appends AuditEntry records to a JSONL file. Writes are serialized, so it is safe
+// for concurrent discoveries.
+type AuditLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path}
+}
+
+// Path returns the file the log appends to
+func (a *AuditLog) Path() string {
+	return a.path
+}
+
+// Record appends one entry as a single JSON line
+func (a *AuditLog) Record(entry AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write audit log: %v", err)
+	}
+	return f.Close()
+}
+
+// audit records a finished request if the audit log is enabled; failures are only logged
+func (s *DiscoveryService) audit(entry AuditEntry) {
+	if s.auditLog == nil {
+		return
+	}
+	entry.Time = time.Now().UTC()
+	entry.Provider = s.Config.Provider
+	if entry.Model == "" {
+		entry.Model = s.Config.Model
+	}
+	entry.Outcome = "success"
+	if entry.Error != "" {
+		entry.Outcome = "error"
+	}
+	if err := s.auditLog.Record(entry); err != nil {
+		logger.Error("Failed to record discovery audit entry", zap.Error(err))
+	}
+}
@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiscoveryService_AuditLog(t *testing.T) {
+	const response = "Here you go:\n// Signature: 66AB\npackage dynamic\nfunc Parse(data []byte) map[string]interface{} { return map[string]interface{}{\"v\": 1} }\nHope it helps"
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 3 {
+			http.Error(w, "model unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: response})
+	}))
+	defer server.Close()
+
+	service, _, manager := newTestDiscovery(t, DiscoveryConfig{
+		Provider: "ollama",
+		Endpoint: server.URL,
+		Model:    "llama3",
+		AuditLog: true,
+	})
+
+	if _, err := service.DiscoverNewProtocol([]byte{0x66, 0xAB, 0x01}, nil, "audit hint"); err != nil {
+		t.Fatalf("DiscoverNewProtocol failed: %v", err)
+	}
+	code, _ := manager.GetParserCode("auto_proto_0x66AB")
+	if _, err := service.RepairParser("auto_proto_0x66AB", code, "boom", []byte{0x66, 0xAB, 0x01}, nil); err != nil {
+		t.Fatalf("RepairParser failed: %v", err)
+	}
+	if _, err := service.DiscoverNewProtocol([]byte{0x67, 0x01}, nil, "fails"); err == nil {
+		t.Fatal("expected the third call to fail")
+	}
+
+	f, err := os.Open(filepath.Join(manager.storagePath, auditLogFile))
+	if err != nil {
+		t.Fatalf("audit log not written: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid audit line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected one entry per call, got %d", len(entries))
+	}
+
+	first := entries[0]
+	if first.Kind != "discovery" || first.ProtocolID != "auto_proto_0x66AB" || first.Provider != "ollama" || first.Model != "llama3" ||
+		first.Outcome != "success" || first.Attempts != 1 || first.Time.IsZero() {
+		t.Errorf("unexpected discovery entry: %+v", first)
+	}
+	if first.Response != response || first.Code != sanitizeAiCode(response) {
+		t.Errorf("expected raw response and sanitized code, got %q / %q", first.Response, first.Code)
+	}
+	if first.Prompt == "" || !strings.Contains(first.Prompt, "audit hint") {
+		t.Errorf("expected the full prompt, got %q", first.Prompt)
+	}
+	if entries[1].Kind != "repair" || entries[1].Outcome != "success" || !strings.Contains(entries[1].Prompt, "boom") {
+		t.Errorf("unexpected repair entry: %+v", entries[1])
+	}
+	if entries[2].Outcome != "error" || entries[2].Error == "" || entries[2].ProtocolID != "" {
+		t.Errorf("unexpected failed entry: %+v", entries[2])
+	}
+}
+
+func TestDiscoveryService_AuditLogOffByDefault(t *testing.T) {
+	storage := t.TempDir()
+	manager := NewParserManager(storage, "")
+	service := NewDiscoveryService(NewDispatcher(manager), manager, DiscoveryConfig{Provider: "ollama"})
+	service.audit(AuditEntry{Kind: "discovery"})
+
+	if _, err := os.Stat(filepath.Join(storage, "audit.jsonl")); !os.IsNotExist(err) {
+		t.Errorf("audit log written while disabled: %v", err)
+	}
+}
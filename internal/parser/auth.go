@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"net"
+	"time"
+)
+
+// DefaultHandshakeTimeout is how long a new connection has to authenticate
+const DefaultHandshakeTimeout = 5 * time.Second
+
+// maxAuthLine bounds the token line so an unauthenticated client can't make us buffer much
+const maxAuthLine = 512
+
+// errAuthFailed is sent to clients whose token is missing or wrong
+var errAuthFailed = errors.New("authentication failed")
+
+// SetAuthToken requires every connection to send token followed by a newline before its
+// first frame; other connections are closed. The server answers "Authenticated" on success.
+// An empty token disables the handshake. Call before Serve.
+func (s *TCPServer) SetAuthToken(token string) {
+	s.authToken = []byte(token)
+}
+
+// SetTLSConfig serves connections over TLS. Set ClientAuth to tls.RequireAndVerifyClientCert
+// for mTLS: clients without a trusted certificate are closed before any frame is read.
+// Call before Serve.
+func (s *TCPServer) SetTLSConfig(cfg *tls.Config) {
+	s.tlsConfig = cfg
+}
+
+// authenticate completes the TLS handshake and checks the client's token, whichever are enabled
+func (s *TCPServer) authenticate(ctx context.Context, conn net.Conn) error {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		ctx, cancel := context.WithTimeout(ctx, s.handshakeTimeout)
+		defer cancel()
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return err
+		}
+	}
+	if len(s.authToken) == 0 {
+		return nil
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(s.handshakeTimeout)); err != nil {
+		return err
+	}
+	line, err := readAuthLine(conn)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(line, s.authToken) != 1 {
+		return errAuthFailed
+	}
+	return nil
+}
+
+// readAuthLine reads a single newline-terminated line byte by byte, so no frame data sent
+// right after it is consumed
+func readAuthLine(conn net.Conn) ([]byte, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for len(line) <= maxAuthLine {
+		if _, err := conn.Read(b); err != nil {
+			return nil, err
+		}
+		if b[0] == '\n' {
+			return bytes.TrimSuffix(line, []byte("\r")), nil
+		}
+		line = append(line, b[0])
+	}
+	return nil, errAuthFailed
+}
@@ -0,0 +1,141 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrCircuitOpen is returned without running the parser while a protocol is short-circuited
+	ErrCircuitOpen = errors.New("protocol circuit open after repeated timeouts")
+	// ErrBulkheadFull is returned when a protocol already has its maximum executions in flight
+	ErrBulkheadFull = errors.New("protocol concurrency limit reached")
+)
+
+// Bulkhead defaults: runaway executions keep their slot until they actually return
+const (
+	DefaultMaxConcurrentPerProtocol = 8
+	DefaultCircuitTripAfter         = 5                // Consecutive timeouts before short-circuiting
+	DefaultCircuitCooldown          = 30 * time.Second // Fast-fail period before the protocol is tried again
+)
+
+// BulkheadConfig isolates protocols from each other inside the engine
+type BulkheadConfig struct {
+	MaxConcurrent int           // Executions per protocol in flight at once, 0 for unlimited
+	TripAfter     int           // Consecutive timeouts that open the circuit, 0 never opens it
+	Cooldown      time.Duration // How long an open circuit fast-fails
+}
+
+// DefaultBulkheadConfig returns the bulkhead settings used by NewEngine
+func DefaultBulkheadConfig() BulkheadConfig {
+	return BulkheadConfig{
+		MaxConcurrent: DefaultMaxConcurrentPerProtocol,
+		TripAfter:     DefaultCircuitTripAfter,
+		Cooldown:      DefaultCircuitCooldown,
+	}
+}
+
+// bulkhead tracks one protocol's in-flight executions and circuit state
+type bulkhead struct {
+	slots     chan struct{} // nil means unlimited
+	timeouts  int           // Consecutive timeouts
+	openUntil time.Time
+	mu        sync.Mutex
+}
+
+// SetBulkhead changes per-protocol isolation. It applies to protocols first executed afterwards,
+// so call it before parsing starts.
+func (e *Engine) SetBulkhead(cfg BulkheadConfig) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.bulkheadCfg = cfg
+	e.bulkheads = make(map[string]*bulkhead)
+}
+
+func (e *Engine) bulkheadFor(id string) (*bulkhead, BulkheadConfig) {
+	e.mu.RLock()
+	b, ok := e.bulkheads[id]
+	cfg := e.bulkheadCfg
+	e.mu.RUnlock()
+	if ok {
+		return b, cfg
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if b, ok = e.bulkheads[id]; !ok {
+		b = &bulkhead{}
+		if cfg.MaxConcurrent > 0 {
+			b.slots = make(chan struct{}, cfg.MaxConcurrent)
+		}
+		e.bulkheads[id] = b
+	}
+	return b, cfg
+}
+
+// acquire reserves an execution slot without blocking, failing fast while the circuit is open
+func (b *bulkhead) acquire(now time.Time) error {
+	b.mu.Lock()
+	open := now.Before(b.openUntil)
+	b.mu.Unlock()
+	if open {
+		return ErrCircuitOpen
+	}
+	if b.slots == nil {
+		return nil
+	}
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	default:
+		return ErrBulkheadFull
+	}
+}
+
+func (b *bulkhead) release() {
+	if b.slots != nil {
+		<-b.slots
+	}
+}
+
+// record updates the circuit after an execution and reports whether it just opened
+func (b *bulkhead) record(timedOut bool, cfg BulkheadConfig, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !timedOut {
+		b.timeouts = 0
+		return false
+	}
+	b.timeouts++
+	if cfg.TripAfter > 0 && b.timeouts >= cfg.TripAfter {
+		b.openUntil = now.Add(cfg.Cooldown)
+		return true
+	}
+	return false
+}
+
+// runIsolated executes a protocol's parser inside its bulkhead. The slot is held until the
+// parser returns, even past its timeout, so a runaway protocol can't pile up goroutines.
+func (e *Engine) runIsolated(ctx context.Context, id string, fn compiledParser, rawData []byte) (map[string]interface{}, error) {
+	b, cfg := e.bulkheadFor(id)
+	if err := b.acquire(time.Now()); err != nil {
+		return nil, fmt.Errorf("%s: %w", id, err)
+	}
+	guarded := func(data []byte, out *Fields) (map[string]interface{}, error) {
+		defer b.release()
+		return fn(data, out)
+	}
+
+	res, err := e.run(ctx, id, guarded, rawData)
+	if b.record(errors.Is(err, errExecutionTimeout), cfg, time.Now()) {
+		logger.Warn("Protocol short-circuited after repeated timeouts",
+			zap.String("protocol", id), zap.Int("timeouts", cfg.TripAfter), zap.Duration("cooldown", cfg.Cooldown))
+	}
+	return res, err
+}
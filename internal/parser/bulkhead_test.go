@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const slowParser = `package dynamic
+import "time"
+func Parse(data []byte) map[string]interface{} {
+	time.Sleep(150 * time.Millisecond)
+	return map[string]interface{}{"slow": true}
+}`
+
+const fastParser = `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": int(data[0])} }`
+
+func TestEngine_BulkheadIsolatesTimingOutProtocol(t *testing.T) {
+	e := NewEngine()
+	e.SetBulkhead(BulkheadConfig{MaxConcurrent: 4, TripAfter: 3, Cooldown: time.Minute})
+	if err := e.CompileAndCache("slow", slowParser); err != nil {
+		t.Fatalf("CompileAndCache failed: %v", err)
+	}
+	if err := e.CompileAndCache("fast", fastParser); err != nil {
+		t.Fatalf("CompileAndCache failed: %v", err)
+	}
+
+	// Hammer the slow protocol while measuring the fast one
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	var slowErrs []error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			_, err := e.Execute("slow", []byte{0x01}, slowParser)
+			slowErrs = append(slowErrs, err)
+		}
+	}()
+
+	latencies := make([]time.Duration, 0, 50)
+	for i := 0; i < 50; i++ {
+		start := time.Now()
+		if _, err := e.Execute("fast", []byte{0x2A}, fastParser); err != nil {
+			t.Fatalf("fast protocol failed: %v", err)
+		}
+		latencies = append(latencies, time.Since(start))
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(stop)
+	wg.Wait()
+
+	// The median stays far below the 50ms parse timeout the slow protocol keeps hitting
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	if median := latencies[len(latencies)/2]; median > 10*time.Millisecond {
+		t.Errorf("fast protocol slowed down to a median of %v", median)
+	}
+
+	var timeouts, shortCircuited int
+	for _, err := range slowErrs {
+		switch {
+		case errors.Is(err, errExecutionTimeout):
+			timeouts++
+		case errors.Is(err, ErrCircuitOpen):
+			shortCircuited++
+		}
+	}
+	if timeouts != 3 {
+		t.Errorf("expected the circuit to open after 3 timeouts, got %d", timeouts)
+	}
+	if shortCircuited == 0 {
+		t.Error("expected later slow executions to fail fast")
+	}
+}
+
+func TestEngine_BulkheadCapsConcurrentExecutions(t *testing.T) {
+	e := NewEngine()
+	e.SetBulkhead(BulkheadConfig{MaxConcurrent: 1})
+
+	// The timed-out execution keeps running and holds the only slot
+	if _, err := e.Execute("slow", []byte{0x01}, slowParser); !errors.Is(err, errExecutionTimeout) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	start := time.Now()
+	if _, err := e.Execute("slow", []byte{0x01}, slowParser); !errors.Is(err, ErrBulkheadFull) {
+		t.Errorf("expected the bulkhead to be full, got %v", err)
+	}
+	if d := time.Since(start); d > 20*time.Millisecond {
+		t.Errorf("full bulkhead took %v to reject", d)
+	}
+
+	// Once the runaway execution returns, the slot is free again
+	time.Sleep(200 * time.Millisecond)
+	if _, err := e.Execute("slow", []byte{0x01}, slowParser); !errors.Is(err, errExecutionTimeout) {
+		t.Errorf("expected the slot to be released, got %v", err)
+	}
+}
+
+func TestProcessFrame_OverloadedProtocolIsNotRepaired(t *testing.T) {
+	var calls atomic.Int32
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "unexpected LLM call", http.StatusInternalServerError)
+	}))
+	defer llm.Close()
+
+	disc, d, mgr := newTestDiscovery(t, DiscoveryConfig{Provider: "ollama", Endpoint: llm.URL})
+	mgr.GetEngine().SetBulkhead(BulkheadConfig{MaxConcurrent: 1})
+	if err := mgr.RegisterParser("slow", slowParser); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d.Bind([]byte{0x01}, "slow")
+
+	// The timed-out execution keeps running and holds the only slot
+	if _, _, err := d.Ingest([]byte{0x01}); !errors.Is(err, errExecutionTimeout) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	_, proto, err := processFrame(context.Background(), d, disc, []byte{0x01}, tcpContextHint)
+	if !errors.Is(err, ErrBulkheadFull) || proto != "slow" {
+		t.Errorf("processFrame = %q, %v; want ErrBulkheadFull", proto, err)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("a full bulkhead triggered %d repair calls", n)
+	}
+	// Let the runaway execution return before the storage directory goes away
+	time.Sleep(200 * time.Millisecond)
+}
@@ -0,0 +1,88 @@
+// Package checksum provides frame validators for the trailing checksums and CRCs common
+// in binary protocols. Each validator has the parser.FrameValidator signature and checks
+// the last bytes of a frame against a checksum computed over everything before them.
+package checksum
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// CRC16Modbus checks a trailing CRC-16/MODBUS (poly 0xA001 reflected, init 0xFFFF),
+// stored little-endian as Modbus RTU does
+func CRC16Modbus(frame []byte) error {
+	body, trailer, err := split(frame, 2)
+	if err != nil {
+		return err
+	}
+	crc := uint16(0xFFFF)
+	for _, b := range body {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return compare("CRC16", uint32(binary.LittleEndian.Uint16(trailer)), uint32(crc))
+}
+
+// CRC16CCITT checks a trailing CRC-16/CCITT-FALSE (poly 0x1021, init 0xFFFF), stored big-endian
+func CRC16CCITT(frame []byte) error {
+	body, trailer, err := split(frame, 2)
+	if err != nil {
+		return err
+	}
+	crc := uint16(0xFFFF)
+	for _, b := range body {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return compare("CRC16", uint32(binary.BigEndian.Uint16(trailer)), uint32(crc))
+}
+
+// CRC32 checks a trailing IEEE CRC-32 (as used by Ethernet and zlib), stored little-endian
+func CRC32(frame []byte) error {
+	body, trailer, err := split(frame, 4)
+	if err != nil {
+		return err
+	}
+	return compare("CRC32", binary.LittleEndian.Uint32(trailer), crc32.ChecksumIEEE(body))
+}
+
+// XOR checks a trailing byte holding the XOR of all preceding bytes
+func XOR(frame []byte) error {
+	body, trailer, err := split(frame, 1)
+	if err != nil {
+		return err
+	}
+	var sum byte
+	for _, b := range body {
+		sum ^= b
+	}
+	return compare("XOR", uint32(trailer[0]), uint32(sum))
+}
+
+// split separates a frame into its body and an n-byte trailing checksum
+func split(frame []byte, n int) (body, trailer []byte, err error) {
+	if len(frame) <= n {
+		return nil, nil, fmt.Errorf("frame too short for a %d-byte checksum: %d bytes", n, len(frame))
+	}
+	return frame[:len(frame)-n], frame[len(frame)-n:], nil
+}
+
+func compare(kind string, got, want uint32) error {
+	if got != want {
+		return fmt.Errorf("%s mismatch: frame has 0x%X, computed 0x%X", kind, got, want)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package checksum
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidators(t *testing.T) {
+	tests := []struct {
+		name     string
+		validate func([]byte) error
+		good     []byte
+	}{
+		// Modbus RTU "read holding registers" request; CRC 0x0A84 sent low byte first
+		{"CRC16Modbus", CRC16Modbus, []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A, 0xC5, 0xCD}},
+		// "123456789" check value 0x29B1
+		{"CRC16CCITT", CRC16CCITT, []byte("123456789\x29\xB1")},
+		// "123456789" check value 0xCBF43926
+		{"CRC32", CRC32, []byte("123456789\x26\x39\xF4\xCB")},
+		{"XOR", XOR, []byte{0x24, 0x01, 0x02, 0x27}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.validate(tt.good); err != nil {
+				t.Errorf("known-good frame rejected: %v", err)
+			}
+
+			bad := append([]byte(nil), tt.good...)
+			bad[1] ^= 0x40
+			if err := tt.validate(bad); err == nil || !strings.Contains(err.Error(), "mismatch") {
+				t.Errorf("corrupt frame accepted: %v", err)
+			}
+
+			if err := tt.validate(tt.good[:1]); err == nil {
+				t.Error("expected error for a frame shorter than its checksum")
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"sync"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"github.com/chuanjin/OmniBridge/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// deadLetterBuckets is the number of slots the rolling window is divided into
+const deadLetterBuckets = 10
+
+// DeadLetterConfig controls how the dead-letter rate is computed and when it is considered unhealthy
+type DeadLetterConfig struct {
+	Window    time.Duration // Rolling window the rate is computed over (default 1m)
+	Threshold float64       // Rate (0-1) at or above which the gateway is degraded; 0 disables the check
+	MinFrames int           // Minimum frames in the window before the threshold applies (default 10)
+}
+
+type deadLetterBucket struct {
+	start  int64 // Bucket start in units of bucket length since the epoch
+	total  int
+	failed int
+}
+
+// DeadLetterMonitor tracks the rolling rate of frames that could not be parsed.
+// A spike usually means a device firmware change or a broken parser.
+type DeadLetterMonitor struct {
+	cfg       DeadLetterConfig
+	bucketLen time.Duration
+	buckets   [deadLetterBuckets]deadLetterBucket
+	degraded  bool
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+func NewDeadLetterMonitor(cfg DeadLetterConfig) *DeadLetterMonitor {
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+	if cfg.MinFrames <= 0 {
+		cfg.MinFrames = 10
+	}
+	return &DeadLetterMonitor{
+		cfg:       cfg,
+		bucketLen: cfg.Window / deadLetterBuckets,
+		now:       time.Now,
+	}
+}
+
+// Record registers the outcome of a single frame
+func (m *DeadLetterMonitor) Record(failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	slot := m.now().UnixNano() / int64(m.bucketLen)
+	b := &m.buckets[slot%deadLetterBuckets]
+	if b.start != slot {
+		*b = deadLetterBucket{start: slot}
+	}
+	b.total++
+	if failed {
+		b.failed++
+	}
+
+	rate, total := m.rateLocked()
+	metrics.SetDeadLetterRate(rate)
+
+	exceeded := m.exceeded(rate, total)
+	if exceeded && !m.degraded {
+		m.degraded = true
+		metrics.SetDeadLetterDegraded(true)
+		logger.Warn("Dead-letter rate exceeded threshold",
+			zap.Float64("rate", rate), zap.Float64("threshold", m.cfg.Threshold), zap.Duration("window", m.cfg.Window))
+	} else if !exceeded && m.degraded {
+		m.degraded = false
+		metrics.SetDeadLetterDegraded(false)
+		logger.Info("Dead-letter rate recovered", zap.Float64("rate", rate), zap.Float64("threshold", m.cfg.Threshold))
+	}
+}
+
+// Rate returns the fraction of frames in the rolling window that could not be parsed
+func (m *DeadLetterMonitor) Rate() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rate, _ := m.rateLocked()
+	return rate
+}
+
+// Healthy reports false while the dead-letter rate is at or above the configured threshold
+func (m *DeadLetterMonitor) Healthy() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return !m.exceeded(m.rateLocked())
+}
+
+func (m *DeadLetterMonitor) exceeded(rate float64, total int) bool {
+	return m.cfg.Threshold > 0 && total >= m.cfg.MinFrames && rate >= m.cfg.Threshold
+}
+
+func (m *DeadLetterMonitor) rateLocked() (float64, int) {
+	current := m.now().UnixNano() / int64(m.bucketLen)
+	var total, failed int
+	for _, b := range m.buckets {
+		if current-b.start < deadLetterBuckets {
+			total += b.total
+			failed += b.failed
+		}
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	return float64(failed) / float64(total), total
+}
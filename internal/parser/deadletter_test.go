@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDeadLetterMonitor_DegradesAboveThreshold(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "deadletter_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	d := NewDispatcher(mgr)
+	code := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"ok": true} }`
+	if err := mgr.RegisterParser("good_proto", code); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d.Bind([]byte{0x01}, "good_proto")
+
+	now := time.Unix(1000, 0)
+	monitor := NewDeadLetterMonitor(DeadLetterConfig{Window: 10 * time.Second, Threshold: 0.5, MinFrames: 4})
+	monitor.now = func() time.Time { return now }
+	d.SetDeadLetterMonitor(monitor)
+
+	// Healthy traffic
+	for i := 0; i < 4; i++ {
+		if _, _, err := d.Ingest([]byte{0x01, 0x02}); err != nil {
+			t.Fatalf("Ingest failed: %v", err)
+		}
+	}
+	if !monitor.Healthy() {
+		t.Fatal("Expected monitor to be healthy with no failures")
+	}
+
+	// Drive unparseable frames above the threshold
+	for i := 0; i < 6; i++ {
+		_, _, _ = d.Ingest([]byte{0xFF, 0x00})
+	}
+	if rate := monitor.Rate(); rate != 0.6 {
+		t.Errorf("Expected dead-letter rate 0.6, got %v", rate)
+	}
+	if monitor.Healthy() {
+		t.Error("Expected monitor to be degraded above threshold")
+	}
+
+	// Once the failures age out of the window the signal recovers
+	now = now.Add(11 * time.Second)
+	if !monitor.Healthy() {
+		t.Error("Expected monitor to recover after the window elapsed")
+	}
+	if rate := monitor.Rate(); rate != 0 {
+		t.Errorf("Expected dead-letter rate 0 after window, got %v", rate)
+	}
+}
+
+func TestDeadLetterMonitor_MinFrames(t *testing.T) {
+	monitor := NewDeadLetterMonitor(DeadLetterConfig{Threshold: 0.1, MinFrames: 5})
+
+	monitor.Record(true)
+	monitor.Record(true)
+	if !monitor.Healthy() {
+		t.Error("Expected monitor to stay healthy below MinFrames")
+	}
+}
@@ -0,0 +1,178 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"go.uber.org/zap"
+)
+
+// maxRecordLine bounds one stored record; frames are small but results can be large
+const maxRecordLine = 1 << 20
+
+// DeadLetterStore is a Sink that keeps the frames no parser could handle in a JSONL file
+// (the FileSink record format), so they can be replayed with Gateway.ReplayDeadLetters
+// once parsers are fixed. Register it for OutcomeParseError and OutcomeUnknownProtocol.
+type DeadLetterStore struct {
+	path string
+	f    *os.File
+	mu   sync.Mutex
+}
+
+// NewDeadLetterStore opens (or creates) path, keeping the dead letters already in it
+func NewDeadLetterStore(path string) (*DeadLetterStore, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead-letter store: %v", err)
+	}
+	return &DeadLetterStore{path: path, f: f}, nil
+}
+
+// Emit appends out as one dead letter
+func (s *DeadLetterStore) Emit(out Output) error {
+	data, err := encodeRecord(newResultRecord(out))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.f.Write(data)
+	return err
+}
+
+// List returns the stored dead letters, oldest first
+func (s *DeadLetterStore) List() ([]ResultRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.readLocked()
+}
+
+// Close closes the file
+func (s *DeadLetterStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// readLocked decodes every record in the file, skipping lines that aren't valid records
+func (s *DeadLetterStore) readLocked() ([]ResultRecord, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	var records []ResultRecord
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, maxRecordLine)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var record ResultRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			logger.Warn("Skipping malformed dead letter", zap.String("path", s.path), zap.Error(err))
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// settle replaces the first n records (the ones a replay started from) with kept, leaving
+// dead letters stored since then in place. The file is swapped atomically.
+func (s *DeadLetterStore) settle(n int, kept []ResultRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.readLocked()
+	if err != nil {
+		return err
+	}
+	if n < len(current) {
+		kept = append(kept, current[n:]...)
+	}
+
+	var buf bytes.Buffer
+	for _, record := range kept {
+		data, err := encodeRecord(record)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+
+	// Appends must go to the new file
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen dead-letter store: %v", err)
+	}
+	_ = s.f.Close()
+	s.f = f
+	return nil
+}
+
+// DeadLetterReplayReport counts the outcome of a ReplayDeadLetters run
+type DeadLetterReplayReport struct {
+	Recovered int // Parsed now and removed from the store
+	Failing   int // Still unparseable and kept
+}
+
+func (r DeadLetterReplayReport) String() string {
+	return fmt.Sprintf("%d recovered, %d still failing", r.Recovered, r.Failing)
+}
+
+// ReplayDeadLetters re-ingests every stored dead letter through the current parsers.
+// Frames that parse now are delivered to the success sinks and removed from the store;
+// the rest, including empty results under EmptyDeadLetter, are kept with their latest error. Nothing is sent for repair or discovery.
+func (g *Gateway) ReplayDeadLetters() (DeadLetterReplayReport, error) {
+	var report DeadLetterReplayReport
+	if g.deadLetters == nil {
+		return report, errors.New("no dead-letter store configured")
+	}
+
+	records, err := g.deadLetters.List()
+	if err != nil {
+		return report, fmt.Errorf("failed to read dead letters: %v", err)
+	}
+
+	var kept []ResultRecord
+	for _, record := range records {
+		frame, err := hex.DecodeString(record.Frame)
+		if err != nil || len(frame) == 0 {
+			report.Failing++
+			kept = append(kept, record)
+			continue
+		}
+		_, proto, err := g.dispatcher.redeliver(frame)
+		if err == nil && proto != FallbackProtocol {
+			report.Recovered++
+			continue
+		}
+		report.Failing++
+		if err != nil {
+			record.Protocol, record.Error = proto, err.Error()
+		}
+		kept = append(kept, record)
+	}
+
+	if err := g.deadLetters.settle(len(records), kept); err != nil {
+		return report, fmt.Errorf("failed to update dead letters: %v", err)
+	}
+	logger.Info("Dead letters replayed", zap.Int("recovered", report.Recovered), zap.Int("failing", report.Failing))
+	return report, nil
+}
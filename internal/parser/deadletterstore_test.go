@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGateway_ReplayDeadLetters(t *testing.T) {
+	tmpDir := t.TempDir()
+	mgr := NewParserManager(filepath.Join(tmpDir, "storage"), "")
+	d := NewDispatcher(mgr)
+
+	store, err := NewDeadLetterStore(filepath.Join(tmpDir, "deadletters.jsonl"))
+	if err != nil {
+		t.Fatalf("NewDeadLetterStore failed: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	success := &captureSink{}
+	router := NewOutputRouter()
+	router.AddSink(store, OutcomeParseError, OutcomeUnknownProtocol)
+	router.AddSink(success, OutcomeSuccess)
+	d.SetOutputRouter(router)
+
+	g := NewGateway(d, nil)
+	if _, err := g.ReplayDeadLetters(); err == nil {
+		t.Error("expected an error without a dead-letter store")
+	}
+	g.SetDeadLetterStore(store)
+
+	// Neither protocol is known yet, so both frames are dead-lettered
+	for _, frame := range [][]byte{{0xC3, 0x2A}, {0xD4, 0x01}} {
+		if _, _, err := d.Ingest(frame); err == nil {
+			t.Fatalf("expected frame %X to fail", frame)
+		}
+	}
+	letters, err := store.List()
+	if err != nil || len(letters) != 2 || letters[0].Frame != "C32A" || letters[1].Frame != "D401" {
+		t.Fatalf("List() = %+v, %v", letters, err)
+	}
+
+	// A parser for 0xC3 arrives: replay recovers that frame and keeps the other
+	if err := mgr.RegisterParser("level", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"level": int(data[1])} }`); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d.Bind([]byte{0xC3}, "level")
+
+	report, err := g.ReplayDeadLetters()
+	if err != nil {
+		t.Fatalf("ReplayDeadLetters failed: %v", err)
+	}
+	if report != (DeadLetterReplayReport{Recovered: 1, Failing: 1}) {
+		t.Errorf("report = %v, want 1 recovered, 1 still failing", report)
+	}
+	if protos := success.protocols(); !reflect.DeepEqual(protos, []string{"level"}) {
+		t.Errorf("expected the recovered frame delivered to the success sink, got %v", protos)
+	}
+	letters, err = store.List()
+	if err != nil || len(letters) != 1 || letters[0].Frame != "D401" {
+		t.Fatalf("List() after replay = %+v, %v", letters, err)
+	}
+
+	// New dead letters still land in the rewritten store, and replaying again changes nothing
+	_, _, _ = d.Ingest([]byte{0xE5})
+	if letters, _ := store.List(); len(letters) != 2 {
+		t.Errorf("expected the new dead letter appended after the replay, got %+v", letters)
+	}
+	if report, err := g.ReplayDeadLetters(); err != nil || report != (DeadLetterReplayReport{Failing: 2}) {
+		t.Errorf("second replay = %v, %v", report, err)
+	}
+	if letters, _ := store.List(); len(letters) != 2 {
+		t.Errorf("a replay must not duplicate frames that still fail, got %+v", letters)
+	}
+
+	// A parser that now returns nothing is still a dead letter under the dead-letter policy
+	router.SetEmptyResultPolicy(EmptyDeadLetter)
+	if err := mgr.RegisterParser("blank", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{} }`); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d.Bind([]byte{0xD4}, "blank")
+	if report, err := g.ReplayDeadLetters(); err != nil || report != (DeadLetterReplayReport{Failing: 2}) {
+		t.Errorf("replay with an empty result = %v, %v; want both frames still failing", report, err)
+	}
+	letters, _ = store.List()
+	if len(letters) != 2 || letters[0].Protocol != "blank" {
+		t.Errorf("expected the empty parse kept with its error, got %+v", letters)
+	}
+}
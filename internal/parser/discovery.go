@@ -2,12 +2,14 @@ package parser
 
 import (
 	"bytes"
-	"encoding/hex"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -15,7 +17,9 @@ import (
 	"time"
 
 	"github.com/chuanjin/OmniBridge/internal/logger"
+	"github.com/chuanjin/OmniBridge/internal/metrics"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 )
 
 // DiscoveryService handles the interaction with LLMs to generate new parsers
@@ -25,189 +29,632 @@ type DiscoveryService struct {
 	httpClient *http.Client
 	Config     DiscoveryConfig
 
-	// Async discovery state tracking
-	pending map[string]bool
-	mu      sync.Mutex
+	// In-flight discoveries: concurrent callers for one signature share a single LLM request
+	inflight sync.Map // Hex signature -> struct{}, while a discovery runs
+	group    singleflight.Group
+	// Concurrent self-healing repairs of one protocol share a single LLM request
+	repairGroup singleflight.Group
+
+	// Fail-closed escalation state
+	failures        map[string]int
+	escalated       map[string]Escalation
+	escalationHooks []EscalationHook
+
+	auditLog *AuditLog         // nil unless Config.AuditLog is set
+	limiter  *discoveryLimiter // nil unless a discovery budget is configured
+	repairs  *repairThrottle   // nil unless a repair budget is configured
+
+	systemPrompt       string // Cached contents of Config.SystemPromptPath
+	systemPromptLoaded bool
+	prompts            *PromptBuilder // Templates next to the system prompt
+
+	mu sync.Mutex
 }
 
 type DiscoveryConfig struct {
-	Provider    string // "ollama" or "anthropic"
+	Provider    string // "ollama", "openai", "anthropic", "exec", or "gemini"
 	Endpoint    string // e.g., "http://localhost:11434/api/generate"
 	Model       string // e.g., "llama3" or "deepseek-coder"
 	ApiKey      string // Optional for local, required for cloud
-	PrivacyMode bool   // If true, masks potential PII before sending
+	PrivacyMode bool   // If true, masks likely PII (emails, VINs, phone numbers) in samples before sending
 	MaxRetries  int    // Maximum number of retries for LLM calls
 	RetryDelay  time.Duration
+	Timeout     time.Duration // Bounds a whole discovery or repair across retries and fix-up attempts (0 = no limit)
+	Stream      bool          // Ollama only: read the generation as it is produced instead of waiting for it
+
+	// RequestTimeout bounds each HTTP request to the provider (default DefaultRequestTimeout).
+	// Keep it short in server mode, where a discovery holds up the client connection.
+	RequestTimeout time.Duration
+
+	// ProviderOptions carries provider-specific settings so new ones don't need new fields.
+	// Recognized keys: ollama keep_alive; anthropic anthropic_version, anthropic_beta;
+	// openai organization, azure_deployment, api_version. Others are logged and ignored.
+	ProviderOptions map[string]string
+
+	// SystemPromptPath is the prompt prepended to every request (default DefaultSystemPromptPath,
+	// relative to the working directory). It is read once and cached.
+	SystemPromptPath string
+
+	// Command is run by the "exec" provider: the prompt is written to its stdin and
+	// the generated code read from its stdout (e.g. llama.cpp or a python script)
+	Command        []string
+	CommandTimeout time.Duration // Kills the command if it runs longer (default 2m)
+
+	// AuditLog appends every prompt, raw response, sanitized code and outcome to
+	// audit.jsonl in the storage directory. Off by default since prompts are large.
+	AuditLog bool
+
+	// Sanitizer extracts the parser source from a raw LLM response (default DefaultSanitizer)
+	Sanitizer CodeSanitizer
+
+	// CheckDeterminism runs each generated parser twice on the sample and rejects it if the outputs differ
+	CheckDeterminism bool
+
+	// Discovery budget: beyond it DiscoverNewProtocol fails fast with ErrRateLimited
+	// instead of calling the LLM. Zero means unlimited.
+	MaxDiscoveriesPerMinute int // Token bucket; bursts of up to this many are allowed
+	MaxSignaturesPerHour    int // Distinct signatures discovered in any one-hour window
+
+	// Repair budget of the self-healing loop (RequestRepair): repairs beyond it are queued
+	// and run as it refills. Zero means unlimited.
+	MaxRepairsPerMinute int           // Token bucket; bursts of up to this many are allowed
+	RepairBudget        int           // Repairs started in any RepairWindow
+	RepairWindow        time.Duration // Default DefaultRepairWindow
+
+	// FailClosed escalates signatures that repeatedly fail discovery (metric, hooks, readiness)
+	FailClosed    bool
+	EscalateAfter int // Consecutive failed discoveries before escalating (default 1)
 }
 
+// Generation settings shared by all cloud providers
+const (
+	llmTemperature     = 0.1 // Low temperature for code precision
+	llmMaxOutputTokens = 1024
+)
+
 type OllamaRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
+	Model     string         `json:"model"`
+	Prompt    string         `json:"prompt"`
+	Stream    bool           `json:"stream"`
+	KeepAlive string         `json:"keep_alive,omitempty"`
+	Options   *OllamaOptions `json:"options,omitempty"`
+}
+
+// OllamaOptions are the model parameters of an Ollama request
+type OllamaOptions struct {
+	NumPredict int `json:"num_predict,omitempty"` // Output token limit
 }
 
 type OllamaResponse struct {
-	Response string `json:"response"`
+	Response   string `json:"response"`
+	Done       bool   `json:"done"`
+	DoneReason string `json:"done_reason,omitempty"` // "length" when num_predict cut the output off
+	Error      string `json:"error,omitempty"`
 }
 
+// withDeadline bounds ctx by Config.Timeout, if set
+func (s *DiscoveryService) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.Config.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.Config.Timeout)
+}
+
+type modelKey struct{}
+
+// WithDiscoveryModel returns a context that makes discoveries and repairs started with it
+// ask for model instead of DiscoveryConfig.Model, e.g. a stronger model for a complex
+// protocol. An empty model keeps the configured one. The exec provider ignores it.
+func WithDiscoveryModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, modelKey{}, model)
+}
+
+// model returns the model to request for ctx
+func (s *DiscoveryService) model(ctx context.Context) string {
+	if model, _ := ctx.Value(modelKey{}).(string); model != "" {
+		return model
+	}
+	return s.Config.Model
+}
+
+// DefaultSystemPromptPath is where the system prompt is read from when none is configured
+const DefaultSystemPromptPath = "agents/system_prompt.md"
+
+// ollamaProgressInterval is how many streamed chunks pass between progress logs
+const ollamaProgressInterval = 50
+
+// DefaultRequestTimeout bounds one LLM HTTP request when DiscoveryConfig.RequestTimeout is unset
+const DefaultRequestTimeout = 120 * time.Second
+
+// maxStreamBytes bounds a streamed generation; parsers are a few KB, so more means a runaway model
+const maxStreamBytes = 256 << 10
+
 func NewDiscoveryService(d *Dispatcher, m *ParserManager, cfg DiscoveryConfig) *DiscoveryService {
-	return &DiscoveryService{
+	if cfg.SystemPromptPath == "" {
+		cfg.SystemPromptPath = DefaultSystemPromptPath
+	}
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = DefaultRequestTimeout
+	}
+	warnUnknownOptions(cfg.Provider, cfg.ProviderOptions)
+	var auditLog *AuditLog
+	if cfg.AuditLog {
+		auditLog = NewAuditLog(filepath.Join(m.storagePath, auditLogFile))
+	}
+	var limiter *discoveryLimiter
+	if cfg.MaxDiscoveriesPerMinute > 0 || cfg.MaxSignaturesPerHour > 0 {
+		limiter = newDiscoveryLimiter(cfg.MaxDiscoveriesPerMinute, cfg.MaxSignaturesPerHour)
+	}
+	s := &DiscoveryService{
+		auditLog:   auditLog,
+		limiter:    limiter,
 		dispatcher: d,
 		manager:    m,
-		httpClient: &http.Client{Timeout: 600 * time.Second},
+		httpClient: &http.Client{Timeout: cfg.RequestTimeout},
 		Config:     cfg,
-		pending:    make(map[string]bool),
+		failures:   make(map[string]int),
+		escalated:  make(map[string]Escalation),
+		prompts:    PromptBuilderFor(cfg.SystemPromptPath),
 	}
+	if cfg.MaxRepairsPerMinute > 0 || cfg.RepairBudget > 0 {
+		s.repairs = newRepairThrottle(cfg.MaxRepairsPerMinute, cfg.RepairBudget, cfg.RepairWindow, s.runDeferredRepair)
+	}
+	return s
 }
 
-// IsDiscovering checks if a discovery is already in progress for the given signature.
-func (s *DiscoveryService) IsDiscovering(signature []byte) bool {
+// ReadSystemPrompt reads a system prompt file; an empty path means DefaultSystemPromptPath
+func ReadSystemPrompt(path string) (string, error) {
+	if path == "" {
+		path = DefaultSystemPromptPath
+	}
+	absPath, _ := filepath.Abs(path)
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to load system prompt %s: %v", path, err)
+	}
+	return string(data), nil
+}
+
+// LoadSystemPrompt (re)reads the system prompt from Config.SystemPromptPath and caches it.
+// Calling it at startup surfaces a missing prompt or a broken prompt template before
+// the first discovery.
+func (s *DiscoveryService) LoadSystemPrompt() error {
+	prompt, err := ReadSystemPrompt(s.Config.SystemPromptPath)
+	if err != nil {
+		return err
+	}
+	if err := s.prompts.Check(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.systemPrompt, s.systemPromptLoaded = prompt, true
+	s.mu.Unlock()
+	return nil
+}
+
+// loadSystemPrompt returns the cached system prompt, reading it on first use
+func (s *DiscoveryService) loadSystemPrompt() (string, error) {
+	s.mu.Lock()
+	prompt, loaded := s.systemPrompt, s.systemPromptLoaded
+	s.mu.Unlock()
+	if loaded {
+		return prompt, nil
+	}
+	if err := s.LoadSystemPrompt(); err != nil {
+		return "", err
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	return s.pending[fmt.Sprintf("%X", signature)]
+	return s.systemPrompt, nil
+}
+
+// IsDiscovering checks if a discovery is already in progress for the given signature.
+func (s *DiscoveryService) IsDiscovering(signature []byte) bool {
+	_, ok := s.inflight.Load(fmt.Sprintf("%X", signature))
+	return ok
 }
 
 // StartDiscovery attempts to mark a signature for discovery.
 // Returns true if successfully marked (started), false if already in progress.
 func (s *DiscoveryService) StartDiscovery(signature []byte) bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	key := fmt.Sprintf("%X", signature)
-	if s.pending[key] {
-		return false
-	}
-	s.pending[key] = true
-	return true
+	_, loaded := s.inflight.LoadOrStore(fmt.Sprintf("%X", signature), struct{}{})
+	return !loaded
 }
 
 // FinishDiscovery clears the pending status for a signature.
 func (s *DiscoveryService) FinishDiscovery(signature []byte) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	delete(s.pending, fmt.Sprintf("%X", signature))
+	s.inflight.Delete(fmt.Sprintf("%X", signature))
+}
+
+// maxInferredSignatureLen caps signatures inferred from samples; magic headers are rarely longer
+const maxInferredSignatureLen = 4
+
+// InferSignature returns the longest common prefix of samples of the same protocol,
+// which is usually its magic header. It returns nil for fewer than two samples.
+func InferSignature(samples [][]byte) []byte {
+	if len(samples) < 2 {
+		return nil
+	}
+	prefix := samples[0]
+	for _, sample := range samples[1:] {
+		n := 0
+		for n < len(prefix) && n < len(sample) && prefix[n] == sample[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	if len(prefix) == 0 {
+		return nil
+	}
+	return append([]byte(nil), prefix...)
+}
+
+// DiscoverNewProtocol asks the LLM for a parser for rawSample. Without an explicit signature,
+// one is inferred from the common prefix of rawSample and extraSamples, falling back to the first byte.
+func (s *DiscoveryService) DiscoverNewProtocol(rawSample []byte, signature []byte, contextHint string, extraSamples ...[]byte) (string, error) {
+	return s.DiscoverNewProtocolContext(context.Background(), rawSample, signature, contextHint, extraSamples...)
 }
 
-func (s *DiscoveryService) DiscoverNewProtocol(rawSample []byte, signature []byte, contextHint string) (string, error) {
+// DiscoverNewProtocolContext is DiscoverNewProtocol bound to ctx: cancelling it stops waiting
+// for the discovery. Attach a progress callback with WithDiscoveryProgress, or pick the model
+// for this discovery with WithDiscoveryModel.
+//
+// Calls for a signature that is already being discovered wait for that discovery and
+// share its result instead of sending another request; the first caller's samples and
+// context values are the ones used. The discovery itself is detached from every caller's
+// cancellation and bounded by DiscoveryConfig.Timeout instead, so one caller giving up
+// doesn't fail the others.
+func (s *DiscoveryService) DiscoverNewProtocolContext(ctx context.Context, rawSample []byte, signature []byte, contextHint string, extraSamples ...[]byte) (string, error) {
+	if len(signature) == 0 && len(extraSamples) > 0 {
+		signature = InferSignature(append([][]byte{rawSample}, extraSamples...))
+		if len(signature) > maxInferredSignatureLen {
+			signature = signature[:maxInferredSignatureLen]
+		}
+	}
 	if len(signature) == 0 {
 		signature = []byte{rawSample[0]}
 	}
+	key := fmt.Sprintf("%X", signature)
+	results := s.group.DoChan(key, func() (interface{}, error) {
+		s.inflight.Store(key, struct{}{})
+		defer s.inflight.Delete(key)
+		shared, cancel := s.withDeadline(context.WithoutCancel(ctx))
+		defer cancel()
+		return s.discover(shared, rawSample, signature, contextHint, extraSamples)
+	})
+	select {
+	case res := <-results:
+		if res.Shared {
+			logger.Debug("Shared in-flight discovery", zap.String("signature", "0x"+key))
+		}
+		protocolID, _ := res.Val.(string)
+		return protocolID, res.Err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// discover runs one discovery for signature: budget check, prompt, LLM request and registration
+func (s *DiscoveryService) discover(ctx context.Context, rawSample, signature []byte, contextHint string, extraSamples [][]byte) (string, error) {
+	if s.limiter != nil {
+		if err := s.limiter.allow(fmt.Sprintf("%X", signature)); err != nil {
+			logger.Warn("Discovery skipped", zap.String("signature", fmt.Sprintf("0x%X", signature)), zap.Error(err))
+			return "", err
+		}
+	}
 	logger.Info("Discovery Mode: Analyzing signature", zap.String("provider", s.Config.Provider), zap.String("signature", fmt.Sprintf("0x%X", signature)))
 
-	// 1. Load System Prompt from the agents folder
-	absPath, _ := filepath.Abs("agents/system_prompt.md")
-	systemPrompt, err := os.ReadFile(absPath)
+	// 1. Load the system prompt
+	systemPrompt, err := s.loadSystemPrompt()
 	if err != nil {
-		return "", fmt.Errorf("failed to load system_prompt.md: %v", err)
+		return "", err
 	}
 
 	// 2. Combine with the specific instance data
-	fullPrompt := fmt.Sprintf("%s\n\nINPUT:\nHex Sample: %X\nProtocol Hints: %s",
-		string(systemPrompt), rawSample, contextHint)
+	data := PromptData{
+		SystemPrompt: systemPrompt,
+		HexSample:    fmt.Sprintf("%X", s.promptSample(rawSample, len(signature))),
+		ContextHint:  contextHint,
+	}
+	for _, sample := range extraSamples {
+		data.ExtraSamples = append(data.ExtraSamples, fmt.Sprintf("%X", s.promptSample(sample, len(signature))))
+	}
+	fullPrompt, err := s.prompts.Render(PromptDiscovery, data)
+	if err != nil {
+		return "", err
+	}
 
-	return s.requestAndRegister(fullPrompt, signature)
+	protocolID, err := s.requestAndRegister(ctx, fullPrompt, signature, rawSample, "", ParserMeta{ContextHint: contextHint})
+	s.recordDiscoveryOutcome(signature, err)
+	return protocolID, err
 }
 
+// RepairParser asks the LLM to fix a protocol's parser that fails on rawSample with errorMsg
+// and registers the fix as a new version of the same protocol.
 func (s *DiscoveryService) RepairParser(protocolID string, faultyCode string, errorMsg string, rawSample []byte, signature []byte) (string, error) {
+	return s.RepairParserContext(context.Background(), protocolID, faultyCode, errorMsg, rawSample, signature)
+}
+
+// RepairParserContext is RepairParser bound to ctx: cancelling it aborts the LLM request,
+// and a model set with WithDiscoveryModel is used for the repair.
+func (s *DiscoveryService) RepairParserContext(ctx context.Context, protocolID string, faultyCode string, errorMsg string, rawSample []byte, signature []byte) (string, error) {
+	ctx, cancel := s.withDeadline(ctx)
+	defer cancel()
 	logger.Info("Repair Mode: Fixing protocol", zap.String("provider", s.Config.Provider), zap.String("protocol", protocolID))
 
-	absPath, _ := filepath.Abs("agents/system_prompt.md")
-	systemPrompt, err := os.ReadFile(absPath)
+	systemPrompt, err := s.loadSystemPrompt()
 	if err != nil {
-		return "", fmt.Errorf("failed to load system_prompt.md: %v", err)
+		return "", err
 	}
 
-	fullPrompt := fmt.Sprintf("%s\n\n### ERROR TO FIX\nYou previously generated code that failed.\n\nFAULTY CODE:\n```go\n%s\n```\n\nERROR MESSAGE:\n%s\n\nINPUT DATA (Hex): %X\n\nPlease fix the code and return only the valid Go code.",
-		string(systemPrompt), faultyCode, errorMsg, rawSample)
-
 	if len(signature) == 0 {
 		signature = []byte{rawSample[0]}
 	}
 
-	return s.requestAndRegister(fullPrompt, signature)
-}
+	fullPrompt, err := s.prompts.Render(PromptRepair, PromptData{
+		SystemPrompt: systemPrompt,
+		HexSample:    fmt.Sprintf("%X", s.promptSample(rawSample, len(signature))),
+		FaultyCode:   faultyCode,
+		ErrorMessage: errorMsg,
+	})
+	if err != nil {
+		return "", err
+	}
 
-func (s *DiscoveryService) requestAndRegister(prompt string, signature []byte) (string, error) {
-	var generatedCode string
-	var err error
+	return s.requestAndRegister(ctx, fullPrompt, signature, rawSample, protocolID, ParserMeta{Repaired: true})
+}
 
-	maxRetries := s.Config.MaxRetries
-	if maxRetries <= 0 {
-		maxRetries = 1 // Default to at least one attempt
+// promptSample returns the sample as it may be sent to the LLM: with PrivacyMode on,
+// likely PII after the signature is masked (see MaskPII)
+func (s *DiscoveryService) promptSample(sample []byte, signatureLen int) []byte {
+	if !s.Config.PrivacyMode {
+		return sample
 	}
-	retryDelay := s.Config.RetryDelay
-	if retryDelay <= 0 {
-		retryDelay = 2 * time.Second // Default initial delay
+	masked, kinds := MaskPII(sample, signatureLen)
+	if len(kinds) > 0 {
+		logger.Info("Privacy mode: masked sample before sending to LLM", zap.Strings("kinds", kinds))
 	}
+	return masked
+}
 
-	for i := 0; i < maxRetries; i++ {
-		// 3. Route to provider (Ollama/Cloud)
-		if s.Config.Provider == "ollama" {
-			generatedCode, err = s.callOllama(prompt)
+// requestAndRegister asks the LLM for a parser and registers it. A discovery names the
+// protocol after its signature; a repair (repairID set) replaces that protocol's parser.
+// meta is stored with the parser along with the provider and model that wrote it.
+func (s *DiscoveryService) requestAndRegister(ctx context.Context, prompt string, signature []byte, sample []byte, repairID string, meta ParserMeta) (protocolID string, err error) {
+	repair := repairID != ""
+	var rawResponse, cleanCode string
+	var attempts int
+	defer func() {
+		if repair {
+			metrics.ObserveRepair(err)
 		} else {
-			generatedCode, err = s.callCloud(prompt)
+			metrics.ObserveDiscovery(err)
 		}
 
-		if err == nil {
-			break
+		entry := AuditEntry{Kind: "discovery", ProtocolID: protocolID, Model: s.model(ctx), Prompt: prompt, Response: rawResponse, Code: cleanCode, Attempts: attempts}
+		if repair {
+			entry.Kind = "repair"
 		}
-
-		if i < maxRetries-1 {
-			logger.Warn("LLM request failed, retrying", zap.Int("attempt", i+1), zap.Int("max_retries", maxRetries), zap.Error(err), zap.Duration("retry_delay", retryDelay))
-			time.Sleep(retryDelay)
-			retryDelay *= 2 // Exponential backoff
-		} else {
-			return "", fmt.Errorf("all LLM attempts failed: %v", err)
+		if err != nil {
+			entry.Error = err.Error()
 		}
+		s.audit(entry)
+	}()
+
+	// One budget of LLM calls covers both failed requests and rejected code
+	maxRetries := s.Config.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = 1 // Default to at least one attempt
 	}
+	calls := 0
 
-	// 4. Extract Signature from code if it exists (// Signature: 01AA)
-	reSig := regexp.MustCompile(`// Signature:\s*([0-9A-Fa-f]+)`)
-	matches := reSig.FindStringSubmatch(generatedCode)
+	// Only accept code that compiles (and is deterministic, if enabled); feed errors back to the LLM
+	var declaredSigs [][]byte
+	request := prompt
+	for attempt := 1; ; attempt++ {
+		attempts = attempt
+		generatedCode, used, err := s.callLLMCounted(ctx, request, maxRetries-calls)
+		calls += used
+		if err != nil {
+			return "", err
+		}
+		rawResponse = generatedCode
+		// A fixed version may drop the signature comments; keep the earlier ones
+		if sigs := declaredSignatures(generatedCode); len(sigs) > 0 {
+			declaredSigs = sigs
+		}
 
-	finalSig := signature
-	if len(matches) > 1 {
-		hexStr := matches[1]
-		if len(hexStr)%2 != 0 {
-			hexStr = "0" + hexStr
+		cleanCode = s.sanitize(generatedCode)
+		// Providers without a finish reason (and ones that ignore the limit) give away
+		// truncation by code that stops mid-block; the sanitizer's last-brace cut would
+		// otherwise "complete" it into something that can't be fixed by asking again
+		if unbalancedBraces(cleanCode) {
+			if calls >= maxRetries {
+				return "", fmt.Errorf("generated code after %d attempt(s): %w", attempt, ErrTruncated)
+			}
+			budget := min(maxTokens(ctx)*2, llmMaxOutputTokensLimit)
+			ctx = withMaxTokens(ctx, budget)
+			logger.Warn("Generated code looks truncated, retrying with a larger token budget",
+				zap.Int("attempt", attempt), zap.Int("max_retries", maxRetries), zap.Int("max_tokens", budget))
+			continue
+		}
+		// Compile once for both checks
+		engine := s.manager.GetEngine()
+		fn, checkErr := engine.compileUncached(cleanCode)
+		problem := "failed to compile"
+		if checkErr == nil && s.Config.CheckDeterminism && len(sample) > 0 {
+			problem, checkErr = "is not deterministic", engine.checkDeterminism(fn, sample)
+			if checkErr != nil {
+				logger.Warn("Generated parser flagged as non-deterministic",
+					zap.String("signature", fmt.Sprintf("0x%X", signature)), zap.Int("attempt", attempt), zap.Error(checkErr))
+			}
+		}
+		if checkErr == nil {
+			break
 		}
-		sigBytes, _ := hex.DecodeString(hexStr)
-		if len(sigBytes) > 0 {
-			finalSig = sigBytes
+		if calls >= maxRetries {
+			return "", fmt.Errorf("generated code %s after %d attempt(s): %w", problem, attempt, checkErr)
 		}
+
+		logger.Warn("Generated code rejected, asking for a fix",
+			zap.String("reason", problem), zap.Int("attempt", attempt), zap.Int("max_retries", maxRetries), zap.Error(checkErr))
+		request = fmt.Sprintf("%s\n\n### ERROR TO FIX\nThe code you generated %s.\n\nFAULTY CODE:\n```go\n%s\n```\n\nERROR MESSAGE:\n%s\n\nPlease fix the code and return only the valid Go code.",
+			prompt, problem, cleanCode, checkErr)
 	}
 
-	if len(finalSig) == 0 {
-		return "", fmt.Errorf("no signature found in AI response and none provided")
+	// 4. Extract Signatures from code if they exist (// Signature: 01AA). The first one names
+	// the protocol; a parser covering a family may declare more, all bound to it. A repair
+	// keeps the protocol's bindings and only adds the signatures its new code declares.
+	sigs := declaredSigs
+	if repair {
+		protocolID = repairID
+	} else {
+		if len(sigs) == 0 {
+			if len(signature) == 0 {
+				return "", fmt.Errorf("no signature found in AI response and none provided")
+			}
+			sigs = [][]byte{signature}
+		}
+		protocolID = fmt.Sprintf("auto_proto_0x%X", sigs[0])
 	}
 
-	protocolID := fmt.Sprintf("auto_proto_0x%X", finalSig)
+	// Repairs and rediscoveries must still pass the protocol's recorded golden cases
+	if err := s.checkGoldenCases(protocolID, cleanCode); err != nil {
+		return "", err
+	}
 
-	cleanCode := sanitizeAiCode(generatedCode)
-	// Register the CLEAN code
-	err = s.manager.RegisterParser(protocolID, cleanCode)
+	// Register the CLEAN code, recording which model wrote it. A repair keeps the origin
+	// of the parser and records the repairing model on its own.
+	author := s.Config.Provider
+	if model := s.model(ctx); model != "" {
+		author += "/" + model
+	}
+	if repair {
+		meta.RepairedBy = author
+	} else {
+		meta.Provider, meta.Model, meta.Author = s.Config.Provider, s.model(ctx), author
+	}
+	err = s.manager.RegisterParserWithMeta(protocolID, cleanCode, meta)
 	if err != nil {
 		return "", err
 	}
 
-	s.dispatcher.Bind(finalSig, protocolID)
+	// Every signature of a multi-signature parser routes to the same protocol
+	for _, sig := range sigs {
+		s.dispatcher.Bind(sig, protocolID)
+	}
+	if len(sigs) > 1 {
+		logger.Info("Parser declares multiple signatures",
+			zap.String("protocol", protocolID), zap.Int("signatures", len(sigs)))
+	}
 
-	// Persist the new binding to the manifest file
-	if err := s.manager.SaveManifest(s.dispatcher.GetBindings()); err != nil {
-		logger.Error("Failed to save manifest", zap.Error(err))
+	// Flag likely sign-extension mistakes (200 instead of -56) for review
+	if len(sample) > 0 {
+		if res, parseErr := s.manager.ParseData(protocolID, sample); parseErr == nil {
+			if suspects := SuspectSignErrors(res); len(suspects) > 0 {
+				logger.Warn("Generated parser may decode signed fields as unsigned",
+					zap.String("protocol", protocolID), zap.Strings("fields", suspects))
+			}
+		}
 	}
+
+	// Persist the new binding to the manifest file; bursts of discoveries are coalesced
+	s.dispatcher.QueueManifest()
 	return protocolID, nil
 }
 
-func (s *DiscoveryService) callOllama(prompt string) (string, error) {
+// checkGoldenCases rejects code that fails any case in storage/<id>.golden.json
+func (s *DiscoveryService) checkGoldenCases(protocolID, code string) error {
+	cases, err := s.manager.LoadGoldenCases(protocolID)
+	if err != nil || len(cases) == 0 {
+		return err
+	}
+	results, err := s.manager.testCode(code, cases)
+	if err != nil {
+		return fmt.Errorf("golden cases for %s: %v", protocolID, err)
+	}
+	if failed := failedCases(results); failed > 0 {
+		logger.Warn("Generated parser rejected by golden cases",
+			zap.String("protocol", protocolID), zap.Int("failed", failed), zap.Int("cases", len(cases)))
+		return fmt.Errorf("generated parser for %s fails %d of %d golden case(s)", protocolID, failed, len(cases))
+	}
+	return nil
+}
+
+// callLLM routes the prompt to the configured provider, retrying failed requests with exponential backoff
+func (s *DiscoveryService) callLLM(ctx context.Context, prompt string, maxRetries int) (string, error) {
+	code, _, err := s.callLLMCounted(ctx, prompt, maxRetries)
+	return code, err
+}
+
+// callLLMCounted is callLLM that also reports how many requests it made
+func (s *DiscoveryService) callLLMCounted(ctx context.Context, prompt string, maxRetries int) (string, int, error) {
+	retryDelay := s.Config.RetryDelay
+	if retryDelay <= 0 {
+		retryDelay = 2 * time.Second // Default initial delay
+	}
+
+	budget := maxTokens(ctx)
+	for i := 0; ; i++ {
+		ctx := withMaxTokens(ctx, budget)
+		// 3. Route to provider (Ollama/OpenAI/Cloud)
+		var generatedCode string
+		var err error
+		switch s.Config.Provider {
+		case "ollama":
+			generatedCode, err = s.callOllama(ctx, prompt)
+		case "openai":
+			generatedCode, err = s.callOpenAI(ctx, prompt)
+		case "anthropic":
+			generatedCode, err = s.callAnthropic(ctx, prompt)
+		case "exec":
+			generatedCode, err = s.callExec(ctx, prompt)
+		default:
+			generatedCode, err = s.callCloud(ctx, prompt)
+		}
+
+		if err == nil {
+			return generatedCode, i + 1, nil
+		}
+		if ctx.Err() != nil {
+			return "", i + 1, fmt.Errorf("discovery cancelled: %w", ctx.Err())
+		}
+		if i >= maxRetries-1 {
+			return "", i + 1, fmt.Errorf("all LLM attempts failed: %w", err)
+		}
+		if errors.Is(err, ErrTruncated) {
+			// Not a transient failure: ask again right away with room to finish
+			budget = min(budget*2, llmMaxOutputTokensLimit)
+			logger.Warn("LLM response truncated, retrying with a larger token budget",
+				zap.Int("attempt", i+1), zap.Int("max_retries", maxRetries), zap.Int("max_tokens", budget), zap.Error(err))
+			continue
+		}
+
+		logger.Warn("LLM request failed, retrying", zap.Int("attempt", i+1), zap.Int("max_retries", maxRetries), zap.Error(err), zap.Duration("retry_delay", retryDelay))
+		select {
+		case <-ctx.Done():
+			return "", i + 1, fmt.Errorf("discovery cancelled: %w", ctx.Err())
+		case <-time.After(retryDelay):
+		}
+		retryDelay *= 2 // Exponential backoff
+	}
+}
+
+func (s *DiscoveryService) callOllama(ctx context.Context, prompt string) (string, error) {
 	reqBody := OllamaRequest{
-		Model:  s.Config.Model,
-		Prompt: prompt,
-		Stream: false,
+		Model:     s.model(ctx),
+		Prompt:    prompt,
+		Stream:    s.Config.Stream,
+		KeepAlive: s.option(OptionOllamaKeepAlive, ""),
+	}
+	// Ollama generates until the model stops by default; only cap it once a retry raised the budget
+	if budget := maxTokens(ctx); budget > llmMaxOutputTokens {
+		reqBody.Options = &OllamaOptions{NumPredict: budget}
 	}
 
 	jsonData, _ := json.Marshal(reqBody)
 	logger.Debug("LLM is thinking...")
-	resp, err := s.httpClient.Post(s.Config.Endpoint, "application/json", bytes.NewBuffer(jsonData))
+	resp, err := s.postJSON(ctx, s.Config.Endpoint, jsonData)
 	if err != nil {
 		return "", fmt.Errorf("ollama connection failed: %v", err)
 	}
@@ -222,6 +669,10 @@ func (s *DiscoveryService) callOllama(prompt string) (string, error) {
 		return "", fmt.Errorf("ollama error (status %d): %s", resp.StatusCode, string(body))
 	}
 
+	if reqBody.Stream {
+		return readOllamaStream(resp.Body, progressFrom(ctx))
+	}
+
 	body, _ := io.ReadAll(resp.Body)
 	var ollamaResp OllamaResponse
 	if err := json.Unmarshal(body, &ollamaResp); err != nil {
@@ -231,20 +682,65 @@ func (s *DiscoveryService) callOllama(prompt string) (string, error) {
 	if ollamaResp.Response == "" {
 		return "", fmt.Errorf("ollama returned empty response")
 	}
+	if ollamaResp.DoneReason == "length" {
+		return "", fmt.Errorf("%w: ollama stopped at the token limit", ErrTruncated)
+	}
 
 	return ollamaResp.Response, nil
 }
 
-func (s *DiscoveryService) callCloud(prompt string) (string, error) {
-	apiKey := os.Getenv("GEMINI_API_KEY")
+// readOllamaStream concatenates the newline-delimited JSON chunks of a streaming
+// Ollama response until one reports done. onProgress, if set, is called after every chunk.
+func readOllamaStream(r io.Reader, onProgress ProgressFunc) (string, error) {
+	var sb strings.Builder
+	dec := json.NewDecoder(r)
+	for chunks := 1; ; chunks++ {
+		var chunk OllamaResponse
+		if err := dec.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				return "", fmt.Errorf("ollama stream ended before completion after %d bytes", sb.Len())
+			}
+			return "", fmt.Errorf("failed to decode ollama stream: %v", err)
+		}
+		if chunk.Error != "" {
+			return "", fmt.Errorf("ollama stream error: %s", chunk.Error)
+		}
+		sb.WriteString(chunk.Response)
+		if sb.Len() > maxStreamBytes {
+			return "", fmt.Errorf("ollama stream exceeded %d bytes without completing", maxStreamBytes)
+		}
+		if onProgress != nil {
+			onProgress(newDiscoveryProgress(chunks, sb.Len(), chunk.Done))
+		}
+
+		if chunk.Done {
+			if chunk.DoneReason == "length" {
+				return "", fmt.Errorf("%w: ollama stopped at the token limit after %d bytes", ErrTruncated, sb.Len())
+			}
+			logger.Debug("LLM generation complete", zap.Int("chunks", chunks), zap.Int("bytes", sb.Len()))
+			break
+		}
+		if chunks%ollamaProgressInterval == 0 {
+			logger.Debug("LLM is generating...", zap.Int("chunks", chunks), zap.Int("bytes", sb.Len()))
+		}
+	}
+
+	if sb.Len() == 0 {
+		return "", fmt.Errorf("ollama returned empty response")
+	}
+	return sb.String(), nil
+}
+
+func (s *DiscoveryService) callCloud(ctx context.Context, prompt string) (string, error) {
+	apiKey := s.Config.ApiKey
 	if apiKey == "" {
-		return "", fmt.Errorf("GEMINI_API_KEY environment variable is not set")
+		return "", fmt.Errorf("gemini API key is not set (ApiKey, OMNI_API_KEY or GEMINI_API_KEY)")
 	}
 
 	// Construct URL dynamically using Endpoint and Model
 	// Default Endpoint: https://generativelanguage.googleapis.com/v1beta/models
 	// Format: <Endpoint>/<Model>:generateContent?key=<ApiKey>
-	url := fmt.Sprintf("%s/%s:generateContent?key=%s", s.Config.Endpoint, s.Config.Model, apiKey)
+	url := fmt.Sprintf("%s/%s:generateContent?key=%s", s.Config.Endpoint, s.model(ctx), apiKey)
 
 	payload := map[string]interface{}{
 		"contents": []map[string]interface{}{
@@ -255,13 +751,13 @@ func (s *DiscoveryService) callCloud(prompt string) (string, error) {
 			},
 		},
 		"generationConfig": map[string]interface{}{
-			"temperature":     0.1, // Low temperature for code precision
-			"maxOutputTokens": 1024,
+			"temperature":     llmTemperature,
+			"maxOutputTokens": maxTokens(ctx),
 		},
 	}
 
 	jsonData, _ := json.Marshal(payload)
-	resp, err := s.httpClient.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	resp, err := s.postJSON(ctx, url, jsonData)
 	if err != nil {
 		return "", fmt.Errorf("gemini connection failed: %v", err)
 	}
@@ -283,6 +779,7 @@ func (s *DiscoveryService) callCloud(prompt string) (string, error) {
 					Text string `json:"text"`
 				} `json:"parts"`
 			} `json:"content"`
+			FinishReason string `json:"finishReason"`
 		} `json:"candidates"`
 	}
 
@@ -290,6 +787,9 @@ func (s *DiscoveryService) callCloud(prompt string) (string, error) {
 		return "", err
 	}
 
+	if len(result.Candidates) > 0 && result.Candidates[0].FinishReason == "MAX_TOKENS" {
+		return "", fmt.Errorf("%w: gemini stopped at the token limit", ErrTruncated)
+	}
 	if len(result.Candidates) > 0 && len(result.Candidates[0].Content.Parts) > 0 {
 		return result.Candidates[0].Content.Parts[0].Text, nil
 	}
@@ -297,13 +797,278 @@ func (s *DiscoveryService) callCloud(prompt string) (string, error) {
 	return "", fmt.Errorf("no content returned from gemini")
 }
 
+// callOpenAI talks to any endpoint implementing the OpenAI chat completions API (OpenAI, vLLM, etc.)
+func (s *DiscoveryService) callOpenAI(ctx context.Context, prompt string) (string, error) {
+	apiKey := s.Config.ApiKey
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+
+	// Format: <Endpoint>/chat/completions, or the deployment's URL on Azure OpenAI
+	url := strings.TrimSuffix(s.Config.Endpoint, "/") + "/chat/completions"
+	deployment := s.option(OptionAzureDeployment, "")
+	if deployment != "" {
+		url = fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+			strings.TrimSuffix(s.Config.Endpoint, "/"), deployment, s.option(OptionAzureAPIVersion, azureAPIVersion))
+	}
+
+	payload := map[string]interface{}{
+		"model": s.model(ctx),
+		"messages": []map[string]interface{}{
+			{"role": "user", "content": prompt},
+		},
+		"temperature": llmTemperature,
+		"max_tokens":  maxTokens(ctx),
+	}
+
+	jsonData, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to build openai request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	switch {
+	case apiKey == "":
+	case deployment != "":
+		req.Header.Set("api-key", apiKey)
+	default:
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	if org := s.option(OptionOpenAIOrganization, ""); org != "" {
+		req.Header.Set("OpenAI-Organization", org)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("openai connection failed: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.Error("Failed to close response body", zap.Error(err))
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("openai api error (%d): %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	if len(result.Choices) > 0 && result.Choices[0].FinishReason == "length" {
+		return "", fmt.Errorf("%w: openai stopped at the token limit", ErrTruncated)
+	}
+	if len(result.Choices) > 0 && result.Choices[0].Message.Content != "" {
+		return result.Choices[0].Message.Content, nil
+	}
+
+	return "", fmt.Errorf("no content returned from openai")
+}
+
+// anthropicVersion is the Messages API version sent in the anthropic-version header
+const anthropicVersion = "2023-06-01"
+
+// azureAPIVersion is the api-version used for Azure OpenAI deployments unless overridden
+const azureAPIVersion = "2024-06-01"
+
+func (s *DiscoveryService) callAnthropic(ctx context.Context, prompt string) (string, error) {
+	apiKey := s.Config.ApiKey
+	if apiKey == "" {
+		apiKey = os.Getenv("ANTHROPIC_API_KEY")
+	}
+
+	// Format: <Endpoint>/messages, the endpoint including the API version (e.g. https://api.anthropic.com/v1)
+	url := strings.TrimSuffix(s.Config.Endpoint, "/") + "/messages"
+
+	payload := map[string]interface{}{
+		"model": s.model(ctx),
+		"messages": []map[string]interface{}{
+			{"role": "user", "content": prompt},
+		},
+		"temperature": llmTemperature,
+		"max_tokens":  maxTokens(ctx),
+	}
+
+	jsonData, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to build anthropic request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", apiKey)
+	req.Header.Set("anthropic-version", s.option(OptionAnthropicVersion, anthropicVersion))
+	if beta := s.option(OptionAnthropicBeta, ""); beta != "" {
+		req.Header.Set("anthropic-beta", beta)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("anthropic connection failed: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.Error("Failed to close response body", zap.Error(err))
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("anthropic api error (%d): %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	if result.StopReason == "max_tokens" {
+		return "", fmt.Errorf("%w: anthropic stopped at the token limit", ErrTruncated)
+	}
+	if len(result.Content) > 0 && result.Content[0].Text != "" {
+		return result.Content[0].Text, nil
+	}
+
+	return "", fmt.Errorf("no content returned from anthropic")
+}
+
+// defaultCommandTimeout bounds a single run of the exec provider's command
+const defaultCommandTimeout = 2 * time.Minute
+
+func (s *DiscoveryService) callExec(parent context.Context, prompt string) (string, error) {
+	if len(s.Config.Command) == 0 {
+		return "", fmt.Errorf("exec provider requires a command")
+	}
+	timeout := s.Config.CommandTimeout
+	if timeout <= 0 {
+		timeout = defaultCommandTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, s.Config.Command[0], s.Config.Command[1:]...)
+	cmd.Stdin = strings.NewReader(prompt)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("exec command timed out after %v", timeout)
+		}
+		return "", fmt.Errorf("exec command failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	if strings.TrimSpace(stdout.String()) == "" {
+		return "", fmt.Errorf("exec command returned empty output")
+	}
+	return stdout.String(), nil
+}
+
+// postJSON sends a JSON POST request bound to ctx
+func (s *DiscoveryService) postJSON(ctx context.Context, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return s.httpClient.Do(req)
+}
+
+// Ping checks that the LLM endpoint answers HTTP, e.g. for a readiness probe. Any response
+// counts, since an error status still proves the endpoint is reachable; nothing is generated.
+// The exec provider has no endpoint and always succeeds.
+func (s *DiscoveryService) Ping(ctx context.Context) error {
+	if s.Config.Provider == "exec" || s.Config.Endpoint == "" {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.Config.Endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s endpoint unreachable: %w", s.Config.Provider, err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// CodeSanitizer turns a raw LLM response into Go source for the engine, e.g. to strip a
+// model's own wrapping. It should return code declaring package dynamic with a Parse func.
+type CodeSanitizer func(response string) string
+
+// DefaultSanitizer is the built-in CodeSanitizer; custom ones can pre-process and delegate to it
+func DefaultSanitizer(response string) string {
+	return sanitizeAiCode(response)
+}
+
+// sanitize applies Config.Sanitizer, falling back to DefaultSanitizer
+func (s *DiscoveryService) sanitize(response string) string {
+	if s.Config.Sanitizer != nil {
+		return s.Config.Sanitizer(response)
+	}
+	return DefaultSanitizer(response)
+}
+
+// codeFenceRe matches a markdown code fence with its optional language tag; an
+// unterminated fence (truncated output) runs to the end of the input
+var codeFenceRe = regexp.MustCompile("(?s)```[ \t]*([A-Za-z0-9_+#.-]*)[^\n]*\n(.*?)(?:```|$)")
+
+// extractFencedCode returns the body of the first ```go fence holding Go code, falling
+// back to the first untagged fence that does. ok is false when no fence qualifies.
+func extractFencedCode(input string) (code string, ok bool) {
+	var untagged string
+	for _, m := range codeFenceRe.FindAllStringSubmatch(input, -1) {
+		lang, body := strings.ToLower(m[1]), m[2]
+		if !strings.Contains(body, "package ") && !strings.Contains(body, "func ") {
+			continue
+		}
+		switch lang {
+		case "go", "golang":
+			return body, true
+		case "":
+			if untagged == "" {
+				untagged = body
+			}
+		}
+	}
+	return untagged, untagged != ""
+}
+
 func sanitizeAiCode(input string) string {
-	// 1. Force remove any "Here is your code" or preamble
-	// Detect where the package declaration starts
+	// 1. Prefer the fenced Go block, then force remove any "Here is your code" preamble
+	// by starting at the package declaration
+	code, fenced := extractFencedCode(input)
+	if fenced {
+		input = code
+	}
 	pkgIdx := strings.Index(input, "package ")
 	if pkgIdx != -1 {
 		input = input[pkgIdx:]
 	}
+	// Without a usable fence, any markdown after the code starts at the next fence
+	if fence := strings.Index(input, "```"); !fenced && fence != -1 {
+		input = input[:fence]
+	}
 
 	// 2. Ensure package is "dynamic" and add build ignore tag
 	// Replace "package something" with "//go:build ignore\n\npackage dynamic"
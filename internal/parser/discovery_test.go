@@ -1,16 +1,44 @@
 package parser
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
+// newTestDiscovery returns a discovery service over a fresh storage directory, with a
+// placeholder system prompt unless cfg names one
+func newTestDiscovery(t *testing.T, cfg DiscoveryConfig) (*DiscoveryService, *Dispatcher, *ParserManager) {
+	t.Helper()
+	if cfg.SystemPromptPath == "" {
+		cfg.SystemPromptPath = testSystemPrompt(t)
+	}
+	manager := NewParserManager(t.TempDir(), "")
+	dispatcher := NewDispatcher(manager)
+	return NewDiscoveryService(dispatcher, manager, cfg), dispatcher, manager
+}
+
+// testSystemPrompt writes a placeholder system prompt and returns its path
+func testSystemPrompt(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "system_prompt.md")
+	if err := os.WriteFile(path, []byte("System prompt context"), 0o644); err != nil {
+		t.Fatalf("Failed to write system prompt: %v", err)
+	}
+	return path
+}
+
 func TestDiscoveryService_DiscoverNewProtocol_Ollama(t *testing.T) {
 	// 1. Setup mock Ollama server
 	mockResponse := OllamaResponse{
@@ -106,6 +134,61 @@ func Parse(data []byte) map[string]interface{} {
 	}
 }
 
+func TestDiscoveryService_DiscoverNewProtocol_OllamaStream(t *testing.T) {
+	chunks := []string{
+		"// Signature: 03BB\n",
+		"package dynamic\n",
+		"func Parse(data []byte) map[string]interface{} {\n",
+		"\treturn map[string]interface{}{\"value\": int(data[2])}\n",
+		"}",
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream {
+			t.Error("Expected stream to be requested")
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		flusher := w.(http.Flusher)
+		enc := json.NewEncoder(w)
+		for _, c := range chunks {
+			_ = enc.Encode(OllamaResponse{Response: c})
+			flusher.Flush()
+		}
+		_ = enc.Encode(OllamaResponse{Done: true})
+	}))
+	defer server.Close()
+
+	service, dispatcher, _ := newTestDiscovery(t, DiscoveryConfig{
+		Provider: "ollama",
+		Endpoint: server.URL,
+		Stream:   true,
+	})
+
+	protocolID, err := service.DiscoverNewProtocol([]byte{0x03, 0xBB, 0x07}, nil, "hint")
+	if err != nil {
+		t.Fatalf("DiscoverNewProtocol failed: %v", err)
+	}
+	if protocolID != "auto_proto_0x03BB" {
+		t.Errorf("Expected auto_proto_0x03BB, got %s", protocolID)
+	}
+	if res, _, err := dispatcher.Ingest([]byte{0x03, 0xBB, 0x07}); err != nil || res["value"] != 7 {
+		t.Errorf("Expected streamed parser to work, got %v (%v)", res, err)
+	}
+}
+
+func TestReadOllamaStream_Incomplete(t *testing.T) {
+	stream := `{"response":"package dyn"}` + "\n" + `{"response":"amic"}` + "\n"
+	if _, err := readOllamaStream(strings.NewReader(stream), nil); err == nil {
+		t.Error("Expected error for a stream without done")
+	}
+	if _, err := readOllamaStream(strings.NewReader(`{"error":"model not found"}`), nil); err == nil || !strings.Contains(err.Error(), "model not found") {
+		t.Errorf("Expected stream error to surface, got %v", err)
+	}
+}
+
 func TestDiscoveryService_DiscoverNewProtocol_Gemini(t *testing.T) {
 	// 1. Setup mock Gemini server
 	mockResponse := struct {
@@ -150,9 +233,6 @@ func Parse(data []byte) map[string]interface{} {
 	}))
 	defer server.Close()
 
-	_ = os.Setenv("GEMINI_API_KEY", "test-key")
-	defer func() { _ = os.Unsetenv("GEMINI_API_KEY") }()
-
 	// Setup agents
 	if err := os.MkdirAll("agents", 0755); err != nil {
 		t.Fatalf("Failed to create agents dir: %v", err)
@@ -174,6 +254,7 @@ func Parse(data []byte) map[string]interface{} {
 		Provider: "gemini",
 		Endpoint: server.URL,
 		Model:    "gemini-pro",
+		ApiKey:   "test-key",
 	}
 	service := NewDiscoveryService(dispatcher, manager, cfg)
 
@@ -202,6 +283,147 @@ func Parse(data []byte) map[string]interface{} {
 	}
 }
 
+func TestDiscoveryService_DiscoverNewProtocol_OpenAI(t *testing.T) {
+	// 1. Setup mock chat completions server
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			t.Errorf("Expected path /chat/completions, got %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Expected bearer auth header, got %q", got)
+		}
+
+		var req struct {
+			Model       string  `json:"model"`
+			Temperature float64 `json:"temperature"`
+			MaxTokens   int     `json:"max_tokens"`
+			Messages    []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		if req.Model != "local-vllm" {
+			t.Errorf("Expected model local-vllm, got %s", req.Model)
+		}
+		if len(req.Messages) != 1 || req.Messages[0].Role != "user" {
+			t.Errorf("Expected a single user message, got %+v", req.Messages)
+		}
+		if req.Temperature != llmTemperature || req.MaxTokens != llmMaxOutputTokens {
+			t.Errorf("Unexpected generation config: temperature=%v max_tokens=%d", req.Temperature, req.MaxTokens)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"// Signature: 04DD\npackage dynamic\nfunc Parse(data []byte) map[string]interface{} {\n\treturn map[string]interface{}{\"status\": \"openai_mock\"}\n}"}}]}`)
+	}))
+	defer server.Close()
+
+	_ = os.Setenv("OPENAI_API_KEY", "test-key")
+	defer func() { _ = os.Unsetenv("OPENAI_API_KEY") }()
+
+	service, dispatcher, _ := newTestDiscovery(t, DiscoveryConfig{
+		Provider: "openai",
+		Endpoint: server.URL,
+		Model:    "local-vllm",
+	})
+
+	// 3. Test Discovery
+	rawSample := []byte{0x04, 0xDD, 0x01}
+
+	protocolID, err := service.DiscoverNewProtocol(rawSample, nil, "test hint")
+	if err != nil {
+		t.Fatalf("DiscoverNewProtocol failed: %v", err)
+	}
+
+	expectedID := "auto_proto_0x04DD"
+	if protocolID != expectedID {
+		t.Errorf("Expected protocol ID %s, got %s", expectedID, protocolID)
+	}
+
+	// 4. Verify binding
+	result, _, err := dispatcher.Ingest(rawSample)
+	if err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+
+	if result["status"] != "openai_mock" {
+		t.Errorf("Expected status openai_mock, got %v", result["status"])
+	}
+}
+
+func TestDiscoveryService_DiscoverNewProtocol_Anthropic(t *testing.T) {
+	// 1. Setup mock Messages API server
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("Expected path /v1/messages, got %s", r.URL.Path)
+		}
+		if got := r.Header.Get("x-api-key"); got != "test-key" {
+			t.Errorf("Expected x-api-key header, got %q", got)
+		}
+		if got := r.Header.Get("anthropic-version"); got != anthropicVersion {
+			t.Errorf("Expected anthropic-version %s, got %q", anthropicVersion, got)
+		}
+
+		var req struct {
+			Model     string `json:"model"`
+			MaxTokens int    `json:"max_tokens"`
+			Messages  []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		if req.Model != "claude-test" {
+			t.Errorf("Expected model claude-test, got %s", req.Model)
+		}
+		if len(req.Messages) != 1 || req.Messages[0].Role != "user" || !strings.Contains(req.Messages[0].Content, "System prompt context") {
+			t.Errorf("Expected a single user message with the prompt, got %+v", req.Messages)
+		}
+		if req.MaxTokens != llmMaxOutputTokens {
+			t.Errorf("Expected max_tokens %d, got %d", llmMaxOutputTokens, req.MaxTokens)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"// Signature: 05EE\npackage dynamic\nfunc Parse(data []byte) map[string]interface{} {\n\treturn map[string]interface{}{\"status\": \"anthropic_mock\"}\n}"}],"stop_reason":"end_turn"}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+
+	service, dispatcher, _ := newTestDiscovery(t, DiscoveryConfig{
+		Provider: "anthropic",
+		Endpoint: server.URL + "/v1",
+		Model:    "claude-test",
+	})
+
+	// 3. Test Discovery
+	rawSample := []byte{0x05, 0xEE, 0x01}
+
+	protocolID, err := service.DiscoverNewProtocol(rawSample, nil, "test hint")
+	if err != nil {
+		t.Fatalf("DiscoverNewProtocol failed: %v", err)
+	}
+
+	expectedID := "auto_proto_0x05EE"
+	if protocolID != expectedID {
+		t.Errorf("Expected protocol ID %s, got %s", expectedID, protocolID)
+	}
+
+	// 4. Verify binding
+	result, _, err := dispatcher.Ingest(rawSample)
+	if err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+
+	if result["status"] != "anthropic_mock" {
+		t.Errorf("Expected status anthropic_mock, got %v", result["status"])
+	}
+}
+
 func TestDiscoveryService_RetryLogic(t *testing.T) {
 	attempts := 0
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -265,3 +487,1138 @@ func Parse(data []byte) map[string]interface{} {
 		t.Errorf("Expected protocol ID auto_proto_0x03CC, got %s", protocolID)
 	}
 }
+
+func TestDiscoveryService_DiscoverNewProtocol_Exec(t *testing.T) {
+	// Fake model CLI: consumes the prompt from stdin, records it, prints a parser
+	tempDir := t.TempDir()
+	promptFile := filepath.Join(tempDir, "prompt.txt")
+	script := filepath.Join(tempDir, "fake-model.sh")
+	scriptBody := `#!/bin/sh
+cat > "$1"
+cat <<'CODE'
+// Signature: 05EE
+package dynamic
+func Parse(data []byte) map[string]interface{} {
+	return map[string]interface{}{"status": "exec_mock"}
+}
+CODE
+`
+	if err := os.WriteFile(script, []byte(scriptBody), 0755); err != nil {
+		t.Fatalf("Failed to write fake model script: %v", err)
+	}
+
+	service, dispatcher, _ := newTestDiscovery(t, DiscoveryConfig{
+		Provider: "exec",
+		Command:  []string{script, promptFile},
+	})
+
+	rawSample := []byte{0x05, 0xEE, 0x01}
+	protocolID, err := service.DiscoverNewProtocol(rawSample, nil, "exec hint")
+	if err != nil {
+		t.Fatalf("DiscoverNewProtocol failed: %v", err)
+	}
+	if protocolID != "auto_proto_0x05EE" {
+		t.Errorf("Expected protocol ID auto_proto_0x05EE, got %s", protocolID)
+	}
+
+	prompt, _ := os.ReadFile(promptFile)
+	if !strings.Contains(string(prompt), "System prompt context") || !strings.Contains(string(prompt), "exec hint") {
+		t.Errorf("Expected prompt on stdin, got %q", prompt)
+	}
+
+	result, _, err := dispatcher.Ingest(rawSample)
+	if err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	if result["status"] != "exec_mock" {
+		t.Errorf("Expected status exec_mock, got %v", result["status"])
+	}
+}
+
+func TestDiscoveryService_Exec_Timeout(t *testing.T) {
+	cfg := DiscoveryConfig{
+		Provider:       "exec",
+		Command:        []string{"sleep", "5"},
+		CommandTimeout: 50 * time.Millisecond,
+	}
+	service := NewDiscoveryService(nil, nil, cfg)
+
+	start := time.Now()
+	_, err := service.callExec(context.Background(), "prompt")
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected timeout error, got: %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Command was not killed at the timeout")
+	}
+}
+
+func TestDiscoveryService_CoalescesManifestWrites(t *testing.T) {
+	var calls int
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		n := calls
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: fmt.Sprintf(`// Signature: A%d
+package dynamic
+func Parse(data []byte) map[string]interface{} { return nil }`, n)})
+	}))
+	defer server.Close()
+
+	service, dispatcher, manager := newTestDiscovery(t, DiscoveryConfig{Provider: "ollama", Endpoint: server.URL})
+	manager.SetManifestFlushDelay(time.Hour) // Only the explicit flush may write
+
+	const discoveries = 5
+	for i := 1; i <= discoveries; i++ {
+		if _, err := service.DiscoverNewProtocol([]byte{0xA0 + byte(i), 0x00}, nil, "burst"); err != nil {
+			t.Fatalf("DiscoverNewProtocol %d failed: %v", i, err)
+		}
+	}
+
+	if err := manager.FlushManifest(); err != nil {
+		t.Fatalf("FlushManifest failed: %v", err)
+	}
+
+	manager.manifest.mu.Lock()
+	writes := manager.manifest.writes
+	manager.manifest.mu.Unlock()
+	if writes >= discoveries {
+		t.Errorf("Expected fewer manifest writes than discoveries, got %d writes for %d discoveries", writes, discoveries)
+	}
+
+	manifest, err := manager.LoadManifest()
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	if !reflect.DeepEqual(manifest, dispatcher.GetBindings()) || len(manifest) != discoveries {
+		t.Errorf("Manifest out of sync with bindings: %v vs %v", manifest, dispatcher.GetBindings())
+	}
+}
+
+func TestInferSignature(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples [][]byte
+		want    []byte
+	}{
+		{"shared 2-byte header", [][]byte{{0x55, 0xAA, 0x01, 0x02}, {0x55, 0xAA, 0x03}, {0x55, 0xAA, 0x01, 0x09}}, []byte{0x55, 0xAA}},
+		{"no common prefix", [][]byte{{0x01, 0x02}, {0x02, 0x02}}, nil},
+		{"single sample", [][]byte{{0x55, 0xAA}}, nil},
+		{"one sample is the prefix", [][]byte{{0x7E}, {0x7E, 0x01}}, []byte{0x7E}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InferSignature(tt.samples); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("InferSignature() = %X, want %X", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscoveryService_DiscoverNewProtocol_InferredSignature(t *testing.T) {
+	var prompt string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		prompt = req.Prompt
+		// No "// Signature:" comment, so the inferred signature must be used
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"len": len(data)} }`})
+	}))
+	defer server.Close()
+
+	service, dispatcher, _ := newTestDiscovery(t, DiscoveryConfig{Provider: "ollama", Endpoint: server.URL})
+
+	protocolID, err := service.DiscoverNewProtocol([]byte{0x55, 0xAA, 0x03, 0xE8}, nil, "meter",
+		[]byte{0x55, 0xAA, 0x00, 0x10}, []byte{0x55, 0xAA, 0x7F})
+	if err != nil {
+		t.Fatalf("DiscoverNewProtocol failed: %v", err)
+	}
+	if protocolID != "auto_proto_0x55AA" {
+		t.Errorf("Expected protocol ID auto_proto_0x55AA, got %s", protocolID)
+	}
+	if !strings.Contains(prompt, "Additional Hex Sample: 55AA7F") {
+		t.Errorf("Expected extra samples in prompt, got %q", prompt)
+	}
+
+	if bindings := dispatcher.GetBindings(); bindings["55AA"] != protocolID || len(bindings) != 1 {
+		t.Errorf("Expected 55AA bound to %s, got %v", protocolID, bindings)
+	}
+	// A frame sharing only the first byte must not match the 2-byte signature
+	if _, proto, _ := dispatcher.Ingest([]byte{0x55, 0x00}); proto != "" {
+		t.Errorf("Expected 0x5500 to stay unknown, got %s", proto)
+	}
+	if _, proto, err := dispatcher.Ingest([]byte{0x55, 0xAA, 0x12}); proto != protocolID || err != nil {
+		t.Errorf("Expected 0x55AA12 to parse with %s, got %s (%v)", protocolID, proto, err)
+	}
+}
+
+func TestDiscoveryService_SharedRetryBudget(t *testing.T) {
+	// Every other request fails; the rest return code that doesn't compile
+	var calls int
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		mu.Lock()
+		calls++
+		n := calls
+		mu.Unlock()
+		if n%2 == 1 {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: "package dynamic\nfunc Parse(data []byte) map[string]interface{} { return missing }"})
+	}))
+	defer server.Close()
+
+	service, _, _ := newTestDiscovery(t, DiscoveryConfig{
+		Provider: "ollama", Endpoint: server.URL, MaxRetries: 3, RetryDelay: time.Millisecond,
+	})
+
+	if _, err := service.DiscoverNewProtocol([]byte{0x0C, 0x01}, []byte{0x0C}, "hint"); err == nil {
+		t.Fatal("Expected error when generated code never compiles")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 3 {
+		t.Errorf("Expected MaxRetries (3) LLM calls in total, got %d", calls)
+	}
+}
+
+func TestDiscoveryService_RepairsCompileErrorsBeforeRegistering(t *testing.T) {
+	var prompts []string
+	var mu sync.Mutex
+	responses := []string{
+		// Broken: undefined variable
+		`// Signature: 0BAD
+package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"val": missing} }`,
+		`package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"val": int(data[2])} }`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		n := len(prompts)
+		prompts = append(prompts, req.Prompt)
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: responses[min(n, len(responses)-1)]})
+	}))
+	defer server.Close()
+
+	service, dispatcher, manager := newTestDiscovery(t, DiscoveryConfig{
+		Provider:   "ollama",
+		Endpoint:   server.URL,
+		MaxRetries: 2,
+		RetryDelay: time.Millisecond,
+	})
+
+	protocolID, err := service.DiscoverNewProtocol([]byte{0x0B, 0xAD, 0x2A}, nil, "hint")
+	if err != nil {
+		t.Fatalf("DiscoverNewProtocol failed: %v", err)
+	}
+	if len(prompts) != 2 {
+		t.Fatalf("Expected 2 LLM calls, got %d", len(prompts))
+	}
+	if !strings.Contains(prompts[1], "ERROR TO FIX") || !strings.Contains(prompts[1], "missing") {
+		t.Errorf("Expected compile error fed back in second prompt, got %q", prompts[1])
+	}
+	// The signature declared by the broken attempt is kept
+	if protocolID != "auto_proto_0x0BAD" {
+		t.Errorf("Expected auto_proto_0x0BAD, got %s", protocolID)
+	}
+	if versions, _ := manager.ListVersions(protocolID); len(versions) != 1 {
+		t.Errorf("Expected only the compiling version registered, got %v", versions)
+	}
+	if res, _, err := dispatcher.Ingest([]byte{0x0B, 0xAD, 0x2A}); err != nil || res["val"] != 42 {
+		t.Errorf("Expected parse with fixed code, got %v (%v)", res, err)
+	}
+
+	// When every attempt is broken nothing is registered or bound
+	responses = responses[:1]
+	prompts = nil
+	if _, err := service.DiscoverNewProtocol([]byte{0x0C, 0x01}, []byte{0x0C}, "hint"); err == nil {
+		t.Error("Expected error when generated code never compiles")
+	}
+	if _, proto, _ := dispatcher.Ingest([]byte{0x0C, 0x01}); proto != "" {
+		t.Errorf("Broken parser must not be bound, got %s", proto)
+	}
+}
+
+func TestDiscoveryService_RepairKeepsBindings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		// No signature comment: nothing new to bind
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"val": int(data[2])} }`})
+	}))
+	defer server.Close()
+
+	service, dispatcher, manager := newTestDiscovery(t, DiscoveryConfig{Provider: "ollama", Endpoint: server.URL})
+	const broken = `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"val": int(data[9])} }`
+	for _, id := range []string{"frame_55AA", "frame_55"} {
+		if err := manager.RegisterParser(id, broken); err != nil {
+			t.Fatalf("RegisterParser failed: %v", err)
+		}
+	}
+	dispatcher.Bind([]byte{0x55, 0xAA}, "frame_55AA")
+	dispatcher.Bind([]byte{0x55}, "frame_55")
+	before := dispatcher.GetBindings()
+
+	if err := service.RequestRepair(context.Background(), "frame_55AA", broken, "index out of range", []byte{0x55, 0xAA, 0x2A}); err != nil {
+		t.Fatalf("RequestRepair failed: %v", err)
+	}
+	if after := dispatcher.GetBindings(); !reflect.DeepEqual(after, before) {
+		t.Errorf("repair changed the bindings from %v to %v", before, after)
+	}
+	if res, proto, err := dispatcher.Ingest([]byte{0x55, 0xAA, 0x2A}); err != nil || proto != "frame_55AA" || res["val"] != 42 {
+		t.Errorf("expected the repaired parser on its own binding, got %v, %s, %v", res, proto, err)
+	}
+}
+
+func TestDiscoveryService_RejectsNonDeterministicParsers(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: `// Signature: 0D
+package dynamic
+import "time"
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"ts": time.Now().UnixNano()} }`})
+	}))
+	defer server.Close()
+
+	service, dispatcher, _ := newTestDiscovery(t, DiscoveryConfig{
+		Provider:         "ollama",
+		Endpoint:         server.URL,
+		MaxRetries:       2,
+		RetryDelay:       time.Millisecond,
+		CheckDeterminism: true,
+	})
+
+	_, err := service.DiscoverNewProtocol([]byte{0x0D, 0x01}, nil, "hint")
+	if !errors.Is(err, ErrNonDeterministic) {
+		t.Fatalf("Expected non-deterministic rejection, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 LLM calls, got %d", calls)
+	}
+	if _, proto, _ := dispatcher.Ingest([]byte{0x0D, 0x01}); proto != "" {
+		t.Errorf("Non-deterministic parser must not be bound, got %s", proto)
+	}
+}
+
+func TestDiscoveryService_CustomSystemPromptPath(t *testing.T) {
+	promptDir := t.TempDir()
+	promptPath := filepath.Join(promptDir, "prompt.md")
+	if err := os.WriteFile(promptPath, []byte("CUSTOM PROMPT v1"), 0o644); err != nil {
+		t.Fatalf("Failed to write prompt: %v", err)
+	}
+
+	var prompts []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		prompts = append(prompts, req.Prompt)
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": 1} }`})
+	}))
+	defer server.Close()
+
+	manager := NewParserManager(t.TempDir(), "")
+	service := NewDiscoveryService(NewDispatcher(manager), manager, DiscoveryConfig{
+		Provider:         "ollama",
+		Endpoint:         server.URL,
+		SystemPromptPath: promptPath,
+	})
+	if err := service.LoadSystemPrompt(); err != nil {
+		t.Fatalf("LoadSystemPrompt failed: %v", err)
+	}
+
+	// The prompt is cached, so later edits don't apply until it is reloaded
+	if err := os.WriteFile(promptPath, []byte("CUSTOM PROMPT v2"), 0o644); err != nil {
+		t.Fatalf("Failed to rewrite prompt: %v", err)
+	}
+	if _, err := service.DiscoverNewProtocol([]byte{0x7A, 0x01}, nil, "test"); err != nil {
+		t.Fatalf("DiscoverNewProtocol failed: %v", err)
+	}
+	if err := service.LoadSystemPrompt(); err != nil {
+		t.Fatalf("LoadSystemPrompt failed: %v", err)
+	}
+	if _, err := service.DiscoverNewProtocol([]byte{0x7B, 0x01}, nil, "test"); err != nil {
+		t.Fatalf("DiscoverNewProtocol failed: %v", err)
+	}
+
+	if len(prompts) != 2 || !strings.HasPrefix(prompts[0], "CUSTOM PROMPT v1") || !strings.HasPrefix(prompts[1], "CUSTOM PROMPT v2") {
+		t.Errorf("unexpected prompts: %q", prompts)
+	}
+
+	missing := NewDiscoveryService(NewDispatcher(manager), manager, DiscoveryConfig{SystemPromptPath: filepath.Join(promptDir, "missing.md")})
+	if err := missing.LoadSystemPrompt(); err == nil {
+		t.Error("expected an error for a missing prompt file")
+	}
+}
+
+func TestDiscoveryService_MultipleSignatures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: `// Signature: 55AA
+// Signature: 55ab
+package dynamic
+
+func Parse(data []byte) map[string]interface{} {
+	return map[string]interface{}{"kind": int(data[1]), "value": int(data[2])}
+}`})
+	}))
+	defer server.Close()
+
+	service, dispatcher, manager := newTestDiscovery(t, DiscoveryConfig{Provider: "ollama", Endpoint: server.URL})
+
+	protocolID, err := service.DiscoverNewProtocol([]byte{0x55, 0xAA, 0x07}, nil, "meter family")
+	if err != nil {
+		t.Fatalf("DiscoverNewProtocol failed: %v", err)
+	}
+	if protocolID != "auto_proto_0x55AA" {
+		t.Errorf("expected the first signature to name the protocol, got %s", protocolID)
+	}
+
+	for _, frame := range [][]byte{{0x55, 0xAA, 0x07}, {0x55, 0xAB, 0x09}} {
+		res, proto, err := dispatcher.Ingest(frame)
+		if err != nil || proto != protocolID {
+			t.Errorf("frame %X routed to %q (%v), want %s", frame, proto, err, protocolID)
+			continue
+		}
+		if res["value"] != int(frame[2]) {
+			t.Errorf("frame %X parsed as %v", frame, res)
+		}
+	}
+
+	// Bindings are persisted for both signatures
+	_ = manager.FlushManifest()
+	manifest, _ := manager.LoadManifest()
+	if manifest["55AA"] != protocolID || manifest["55AB"] != protocolID {
+		t.Errorf("manifest = %v", manifest)
+	}
+}
+
+func TestDiscoveryService_Summarize(t *testing.T) {
+	var calls int
+	var lastPrompt string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		calls++
+		lastPrompt = req.Prompt
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: fmt.Sprintf("\n  Engine RPM from bytes 2-3, divided by 4 (call %d).\n", calls)})
+	}))
+	defer server.Close()
+
+	storagePath, _ := os.MkdirTemp("", "omnibridge_summary")
+	defer func() { _ = os.RemoveAll(storagePath) }()
+
+	manager := NewParserManager(storagePath, "")
+	service := NewDiscoveryService(NewDispatcher(manager), manager, DiscoveryConfig{Provider: "ollama", Endpoint: server.URL})
+
+	if _, err := service.Summarize("rpm"); err == nil {
+		t.Fatal("expected error for unknown protocol")
+	}
+
+	code := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"rpm": (int(data[2])*256 + int(data[3])) / 4} }`
+	if err := manager.RegisterParser("rpm", code); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+
+	summary, err := service.Summarize("rpm")
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if summary != "Engine RPM from bytes 2-3, divided by 4 (call 1)." {
+		t.Errorf("unexpected summary %q", summary)
+	}
+	if !strings.Contains(lastPrompt, code) {
+		t.Error("prompt does not contain the parser source")
+	}
+
+	// Cached: no second LLM call, and it survives a restart
+	if again, _ := service.Summarize("rpm"); again != summary || calls != 1 {
+		t.Errorf("expected cached summary, got %q after %d calls", again, calls)
+	}
+	reloaded := NewParserManager(storagePath, "")
+	if _, err := reloaded.LoadSavedParsers(); err != nil {
+		t.Fatalf("LoadSavedParsers failed: %v", err)
+	}
+	if got, ok := reloaded.GetSummary("rpm"); !ok || got != summary {
+		t.Errorf("stored summary = %q, %v", got, ok)
+	}
+
+	// A new parser version makes the summary stale
+	if err := manager.RegisterParser("rpm", code+"\n// v2"); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	if _, ok := manager.GetSummary("rpm"); ok {
+		t.Error("summary of the old code returned for the new version")
+	}
+	if fresh, _ := service.Summarize("rpm"); calls != 2 || !strings.Contains(fresh, "call 2") {
+		t.Errorf("expected regenerated summary, got %q after %d calls", fresh, calls)
+	}
+}
+
+func TestDiscoveryService_DescribeFrame(t *testing.T) {
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		prompts = append(prompts, req.Prompt)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: "\nByte 0 (0x41) is the OBD-II mode 01 response; byte 1 (0x0C) the engine speed PID.\n"})
+	}))
+	defer server.Close()
+
+	manager := NewParserManager(t.TempDir(), "")
+	dispatcher := NewDispatcher(manager)
+	service := NewDiscoveryService(dispatcher, manager, DiscoveryConfig{Provider: "ollama", Endpoint: server.URL})
+
+	description, err := service.DescribeFrame(context.Background(), []byte{0x41, 0x0C, 0x1A, 0xF8}, "car diagnostics port")
+	if err != nil {
+		t.Fatalf("DescribeFrame failed: %v", err)
+	}
+	if description != "Byte 0 (0x41) is the OBD-II mode 01 response; byte 1 (0x0C) the engine speed PID." {
+		t.Errorf("unexpected description %q", description)
+	}
+	if len(prompts) != 1 || !strings.Contains(prompts[0], "FRAME (Hex): 410C1AF8") || !strings.Contains(prompts[0], "car diagnostics port") {
+		t.Errorf("unexpected prompts %q", prompts)
+	}
+
+	// Describing is read-only
+	if len(manager.Parsers()) != 0 || len(dispatcher.GetBindings()) != 0 {
+		t.Errorf("DescribeFrame registered something: parsers %v, bindings %v", manager.Parsers(), dispatcher.GetBindings())
+	}
+
+	if _, err := service.DescribeFrame(context.Background(), nil, ""); err == nil {
+		t.Error("expected an error for an empty frame")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := service.DescribeFrame(ctx, []byte{0x41}, ""); err == nil || len(prompts) != 1 {
+		t.Errorf("expected a cancelled describe to send nothing, got %v after %d prompts", err, len(prompts))
+	}
+}
+
+func TestDiscoveryService_RateLimited(t *testing.T) {
+	var calls int
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: `package dynamic
+func Parse(data []byte) map[string]interface{} { return nil }`})
+	}))
+	defer server.Close()
+
+	newService := func(cfg DiscoveryConfig) (*DiscoveryService, *time.Time) {
+		cfg.Provider, cfg.Endpoint = "ollama", server.URL
+		service, _, _ := newTestDiscovery(t, cfg)
+		now := time.Now()
+		service.limiter.now = func() time.Time { return now }
+		return service, &now
+	}
+	callCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+
+	t.Run("per minute", func(t *testing.T) {
+		service, now := newService(DiscoveryConfig{MaxDiscoveriesPerMinute: 2})
+		before := callCount()
+		for i := 0; i < 2; i++ {
+			if _, err := service.DiscoverNewProtocol([]byte{0xB0 + byte(i), 0x00}, nil, "burst"); err != nil {
+				t.Fatalf("discovery %d within budget failed: %v", i, err)
+			}
+		}
+		if _, err := service.DiscoverNewProtocol([]byte{0xB2, 0x00}, nil, "burst"); !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("expected ErrRateLimited, got %v", err)
+		}
+		if got := callCount() - before; got != 2 {
+			t.Errorf("expected 2 LLM calls, got %d", got)
+		}
+
+		// Half a minute refills one token
+		*now = now.Add(30 * time.Second)
+		if _, err := service.DiscoverNewProtocol([]byte{0xB2, 0x00}, nil, "burst"); err != nil {
+			t.Errorf("expected discovery after refill, got %v", err)
+		}
+		if _, err := service.DiscoverNewProtocol([]byte{0xB3, 0x00}, nil, "burst"); !errors.Is(err, ErrRateLimited) {
+			t.Errorf("expected ErrRateLimited after spending the refill, got %v", err)
+		}
+	})
+
+	t.Run("distinct signatures per hour", func(t *testing.T) {
+		service, now := newService(DiscoveryConfig{MaxSignaturesPerHour: 2})
+		for _, sig := range []byte{0xC0, 0xC1, 0xC0} {
+			if _, err := service.DiscoverNewProtocol([]byte{sig, 0x00}, nil, "hourly"); err != nil {
+				t.Fatalf("discovery of 0x%X failed: %v", sig, err)
+			}
+		}
+		before := callCount()
+		if _, err := service.DiscoverNewProtocol([]byte{0xC2, 0x00}, nil, "hourly"); !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("expected ErrRateLimited for a third signature, got %v", err)
+		}
+		if callCount() != before {
+			t.Error("rate-limited discovery reached the LLM")
+		}
+
+		*now = now.Add(time.Hour)
+		if _, err := service.DiscoverNewProtocol([]byte{0xC2, 0x00}, nil, "hourly"); err != nil {
+			t.Errorf("expected discovery once the hour passed, got %v", err)
+		}
+	})
+}
+
+func TestDiscoveryService_ConcurrentDiscoveriesShareOneRequest(t *testing.T) {
+	var calls int
+	var mu sync.Mutex
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		<-release
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: `// Signature: D0
+package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": int(data[1])} }`})
+	}))
+	defer server.Close()
+
+	service, _, _ := newTestDiscovery(t, DiscoveryConfig{Provider: "ollama", Endpoint: server.URL})
+
+	sig := []byte{0xD0}
+	if service.IsDiscovering(sig) {
+		t.Fatal("IsDiscovering before any discovery")
+	}
+
+	const callers = 8
+	names := make([]string, callers)
+	errs := make([]error, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			names[i], errs[i] = service.DiscoverNewProtocol([]byte{0xD0, byte(i)}, sig, "concurrent")
+		}(i)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !service.IsDiscovering(sig) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !service.IsDiscovering(sig) {
+		t.Fatal("IsDiscovering = false while the LLM request is pending")
+	}
+	if service.IsDiscovering([]byte{0xD1}) {
+		t.Error("IsDiscovering = true for another signature")
+	}
+	time.Sleep(100 * time.Millisecond) // Let the other callers join
+	close(release)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 1 {
+		t.Errorf("expected 1 LLM request for %d concurrent callers, got %d", callers, calls)
+	}
+	for i := range names {
+		if errs[i] != nil || names[i] != names[0] || names[0] == "" {
+			t.Errorf("caller %d: %q, %v; want %q", i, names[i], errs[i], names[0])
+		}
+	}
+	if service.IsDiscovering(sig) {
+		t.Error("IsDiscovering still true after the discovery finished")
+	}
+}
+
+func TestDiscoveryService_SharedDiscoveryOutlivesCancelledCaller(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		<-release
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: `// Signature: D2
+package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": int(data[1])} }`})
+	}))
+	defer server.Close()
+
+	service, _, _ := newTestDiscovery(t, DiscoveryConfig{Provider: "ollama", Endpoint: server.URL})
+	sig := []byte{0xD2}
+
+	// The first caller starts the discovery, then hangs up while another waits on it
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := service.DiscoverNewProtocolContext(ctx, []byte{0xD2, 0x01}, sig, "shared")
+		first <- err
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for !service.IsDiscovering(sig) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	type outcome struct {
+		name string
+		err  error
+	}
+	second := make(chan outcome, 1)
+	go func() {
+		name, err := service.DiscoverNewProtocolContext(context.Background(), []byte{0xD2, 0x02}, sig, "shared")
+		second <- outcome{name, err}
+	}()
+	time.Sleep(50 * time.Millisecond) // Let the second caller join
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled caller: %v, want context.Canceled", err)
+	}
+	close(release)
+	if got := <-second; got.err != nil || got.name != "auto_proto_0xD2" {
+		t.Errorf("waiting caller: %q, %v; want the shared discovery's protocol", got.name, got.err)
+	}
+}
+
+func TestDiscoveryService_ModelOverride(t *testing.T) {
+	var mu sync.Mutex
+	var models []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		models = append(models, req.Model)
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": int(data[1])} }`})
+	}))
+	defer server.Close()
+
+	service, _, manager := newTestDiscovery(t, DiscoveryConfig{
+		Provider: "ollama", Endpoint: server.URL, Model: "small-model",
+	})
+
+	if _, err := service.DiscoverNewProtocol([]byte{0xE0, 0x01}, nil, "simple"); err != nil {
+		t.Fatalf("DiscoverNewProtocol failed: %v", err)
+	}
+	ctx := WithDiscoveryModel(context.Background(), "big-model")
+	name, err := service.DiscoverNewProtocolContext(ctx, []byte{0xE1, 0x01}, nil, "complex")
+	if err != nil {
+		t.Fatalf("DiscoverNewProtocolContext failed: %v", err)
+	}
+	if _, err := service.RepairParserContext(WithDiscoveryModel(context.Background(), "repair-model"), name, "package dynamic", "boom", []byte{0xE1, 0x01}, nil); err != nil {
+		t.Fatalf("RepairParserContext failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"small-model", "big-model", "repair-model"}; !reflect.DeepEqual(models, want) {
+		t.Errorf("requested models = %v, want %v", models, want)
+	}
+	meta, _ := manager.GetParserMeta(name)
+	if meta.Model != "big-model" || meta.Author != "ollama/big-model" {
+		t.Errorf("metadata should record the overriding model as the origin, got %+v", meta)
+	}
+	if !meta.Repaired || meta.RepairedBy != "ollama/repair-model" {
+		t.Errorf("metadata should record the repairing model separately, got %+v", meta)
+	}
+}
+
+func TestDiscoveryService_OverallTimeout(t *testing.T) {
+	// Every attempt hangs until the client gives up; retries would go on for minutes
+	var mu sync.Mutex
+	var attempts int
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		mu.Unlock()
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	service, _, _ := newTestDiscovery(t, DiscoveryConfig{
+		Provider: "ollama", Endpoint: server.URL,
+		MaxRetries: 5, RetryDelay: time.Minute, Timeout: 200 * time.Millisecond,
+	})
+
+	start := time.Now()
+	_, err := service.DiscoverNewProtocol([]byte{0xF0, 0x01}, nil, "slow")
+	elapsed := time.Since(start)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the overall deadline to abort discovery, got %v", err)
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("discovery returned after %v, well past its 200ms deadline", elapsed)
+	}
+
+	// The same bound applies to repairs
+	start = time.Now()
+	if _, err := service.RepairParser("slow", "package dynamic", "boom", []byte{0xF0, 0x01}, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the overall deadline to abort the repair, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("repair returned after %v", elapsed)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 2 {
+		t.Errorf("expected one attempt each before the deadline, got %d", attempts)
+	}
+}
+
+func TestDiscoveryService_ContextCancelMidRequest(t *testing.T) {
+	inFlight := make(chan struct{}, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body) // The server only notices a dropped client once the body is read
+		inFlight <- struct{}{}
+		<-r.Context().Done() // Hang until the client goes away
+	}))
+	defer server.Close()
+
+	service, _, _ := newTestDiscovery(t, DiscoveryConfig{
+		Provider: "ollama", Endpoint: server.URL,
+		MaxRetries: 3, RetryDelay: time.Minute, Timeout: 500 * time.Millisecond,
+	})
+	if service.httpClient.Timeout != DefaultRequestTimeout {
+		t.Errorf("expected the default request timeout, got %v", service.httpClient.Timeout)
+	}
+
+	// Discovery: the caller stops waiting once the request reaches the provider, while
+	// the shared discovery runs on until the service deadline
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-inFlight
+		cancel()
+	}()
+	start := time.Now()
+	_, err := service.DiscoverNewProtocolContext(ctx, []byte{0xF1, 0x01}, nil, "hung")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation to end the wait, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("discovery returned %v after cancellation", elapsed)
+	}
+	if !service.IsDiscovering([]byte{0xF1}) {
+		t.Error("cancelling the caller abandoned the shared discovery")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for service.IsDiscovering([]byte{0xF1}) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if service.IsDiscovering([]byte{0xF1}) {
+		t.Fatal("shared discovery outlived the service deadline")
+	}
+
+	// Repair honors its context the same way
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		<-inFlight
+		cancel()
+	}()
+	start = time.Now()
+	_, err = service.RepairParserContext(ctx, "hung", "package dynamic", "boom", []byte{0xF1, 0x01}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation to abort the repair, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("repair returned %v after cancellation", elapsed)
+	}
+}
+
+func TestDiscoveryService_RequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	service, _, _ := newTestDiscovery(t, DiscoveryConfig{
+		Provider: "ollama", Endpoint: server.URL,
+		RequestTimeout: 100 * time.Millisecond,
+	})
+
+	start := time.Now()
+	if _, err := service.DiscoverNewProtocol([]byte{0xF2, 0x01}, nil, "hung"); err == nil {
+		t.Error("expected a hung provider to fail discovery")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("request was not cut off by its 100ms timeout, took %v", elapsed)
+	}
+}
+
+func TestSanitizeAiCode(t *testing.T) {
+	const want = "//go:build ignore\n\npackage dynamic\n\nfunc Parse(data []byte) map[string]interface{} {\n\treturn map[string]interface{}{\"v\": int(data[0])}\n}"
+	const code = "package dynamic\n\nfunc Parse(data []byte) map[string]interface{} {\n\treturn map[string]interface{}{\"v\": int(data[0])}\n}"
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"bare code", code},
+		{"bare code with preamble", "Here is your parser:\n" + code},
+		{"fenced go block", "```go\n" + code + "\n```"},
+		{"fenced golang block with prose", "Sure! Here it is:\n\n```golang\n" + code + "\n```\n\nThe value is read from byte 0."},
+		{"trailing prose with braces", "```go\n" + code + "\n```\n\nExample output: `{\"v\": 1}` for input {0x01}."},
+		{"unfenced code with trailing markdown", code + "\n```\n\nCall it like `Parse([]byte{0x01})` to get {\"v\": 1}."},
+		{"prefers the go block over other fences", "Input:\n```\n55 AA 01\n```\nOutput:\n```json\n{\"v\": 1}\n```\nCode:\n```go\n" + code + "\n```\nUsage: `x := Parse(d) // {\"v\": 1}`"},
+		{"untagged fence", "```\n" + code + "\n```\nDone."},
+		{"unterminated fence", "```go\n" + code + "\n"},
+		{"renamed package and function", "```go\npackage main\n\nfunc ParseHex(data []byte) map[string]interface{} {\n\treturn map[string]interface{}{\"v\": int(data[0])}\n}\n```"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeAiCode(tt.input); got != want {
+				t.Errorf("sanitizeAiCode() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestDiscoveryService_CustomSanitizer(t *testing.T) {
+	// This "model" wraps its code in XML tags that the default sanitizer doesn't understand
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: `<think>byte 2 is a counter</think><code>
+package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"count": int(data[2])} }
+</code><note>returns {count}</note>`})
+	}))
+	defer server.Close()
+
+	var seen []string
+	sanitizer := func(response string) string {
+		seen = append(seen, response)
+		_, code, _ := strings.Cut(response, "<code>")
+		code, _, _ = strings.Cut(code, "</code>")
+		return DefaultSanitizer(code)
+	}
+
+	service, dispatcher, manager := newTestDiscovery(t, DiscoveryConfig{
+		Provider: "ollama", Endpoint: server.URL, Sanitizer: sanitizer,
+	})
+
+	sample := []byte{0xC0, 0xDE, 0x07}
+	protoName, err := service.DiscoverNewProtocol(sample, []byte{0xC0, 0xDE}, "counter")
+	if err != nil {
+		t.Fatalf("DiscoverNewProtocol failed: %v", err)
+	}
+	if len(seen) != 1 || !strings.Contains(seen[0], "<think>") {
+		t.Errorf("expected the custom sanitizer to receive the raw response, got %q", seen)
+	}
+
+	code, _ := manager.GetParserCode(protoName)
+	if strings.Contains(code, "<code>") || strings.Contains(code, "think") {
+		t.Errorf("stored parser still contains the model's wrapping:\n%s", code)
+	}
+	result, _, err := dispatcher.Ingest(sample)
+	if err != nil || result["count"] != 7 {
+		t.Errorf("Ingest() = %v, %v; want count 7", result, err)
+	}
+}
+
+func TestDiscoveryService_TruncatedResponseRetried(t *testing.T) {
+	const full = "package dynamic\nfunc Parse(data []byte) map[string]interface{} {\n\tif len(data) < 3 {\n\t\treturn nil\n\t}\n\treturn map[string]interface{}{\"level\": int(data[2])}\n}"
+	// Cut off after the if block: the last-brace heuristic alone would keep "{ ... }" of the if
+	truncated := full[:strings.Index(full, "\treturn map")]
+
+	tests := []struct {
+		name     string
+		provider string
+		// respond writes attempt n's reply (1-based) given the token budget the request asked for
+		respond func(w http.ResponseWriter, n, budget int)
+	}{
+		{"ollama code stops mid-function", "ollama", func(w http.ResponseWriter, n, budget int) {
+			code := full
+			if n == 1 {
+				code = truncated
+			}
+			_ = json.NewEncoder(w).Encode(OllamaResponse{Response: code, Done: true})
+		}},
+		{"ollama reports the token limit", "ollama", func(w http.ResponseWriter, n, budget int) {
+			if n == 1 {
+				_ = json.NewEncoder(w).Encode(OllamaResponse{Response: truncated + "\n}", Done: true, DoneReason: "length"})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(OllamaResponse{Response: full, Done: true})
+		}},
+		{"openai finish_reason length", "openai", func(w http.ResponseWriter, n, budget int) {
+			reason, code := "stop", full
+			if n == 1 {
+				reason, code = "length", truncated+"\n}"
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"choices": []map[string]interface{}{{"message": map[string]string{"content": code}, "finish_reason": reason}},
+			})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var budgets []int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req struct {
+					MaxTokens int            `json:"max_tokens"`
+					Options   *OllamaOptions `json:"options"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&req)
+				budget := req.MaxTokens
+				if req.Options != nil {
+					budget = req.Options.NumPredict
+				}
+				budgets = append(budgets, budget)
+				tt.respond(w, len(budgets), budget)
+			}))
+			defer server.Close()
+
+			service, dispatcher, _ := newTestDiscovery(t, DiscoveryConfig{
+				Provider: tt.provider, Endpoint: server.URL,
+				MaxRetries: 3, RetryDelay: time.Millisecond,
+			})
+
+			sample := []byte{0xE1, 0x01, 0x2A}
+			if _, err := service.DiscoverNewProtocol(sample, []byte{0xE1}, "level sensor"); err != nil {
+				t.Fatalf("DiscoverNewProtocol failed: %v", err)
+			}
+			if len(budgets) != 2 {
+				t.Fatalf("expected the truncated response to be retried once, got %d request(s)", len(budgets))
+			}
+			if budgets[1] != 2*llmMaxOutputTokens {
+				t.Errorf("expected the retry to double the token budget to %d, got %v", 2*llmMaxOutputTokens, budgets)
+			}
+			if result, _, err := dispatcher.Ingest(sample); err != nil || result["level"] != 42 {
+				t.Errorf("Ingest() = %v, %v; want the complete parser", result, err)
+			}
+		})
+	}
+
+	// Truncated on every attempt: fail with ErrTruncated rather than register broken code
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: truncated, Done: true})
+	}))
+	defer server.Close()
+	service, _, manager := newTestDiscovery(t, DiscoveryConfig{
+		Provider: "ollama", Endpoint: server.URL, MaxRetries: 2,
+	})
+	if _, err := service.DiscoverNewProtocol([]byte{0xE1, 0x01, 0x2A}, []byte{0xE1}, "level sensor"); !errors.Is(err, ErrTruncated) {
+		t.Errorf("expected ErrTruncated, got %v", err)
+	}
+	if parsers := manager.Parsers(); len(parsers) != 0 {
+		t.Errorf("expected nothing registered, got %v", parsers)
+	}
+}
+
+func TestUnbalancedBraces(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want bool
+	}{
+		{"balanced", "func Parse() { if x { y() } }", false},
+		{"missing close", "func Parse() { if x { y() }", true},
+		{"braces in strings and runes", "func Parse() { s := \"{{\"; r := '}'; t := `{`; _ = s }", false},
+		{"braces in comments", "func Parse() { // {\n /* } { */ }", false},
+		{"cut inside a string", "func Parse() { s := \"abc", true},
+		{"cut inside a comment", "func Parse() { /* note", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unbalancedBraces(tt.code); got != tt.want {
+				t.Errorf("unbalancedBraces(%q) = %v, want %v", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscoveryService_ProviderOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		options  map[string]string
+		response string
+		check    func(t *testing.T, r *http.Request, body map[string]interface{})
+	}{
+		{
+			name:     "ollama keep_alive",
+			provider: "ollama",
+			options:  map[string]string{OptionOllamaKeepAlive: "30m"},
+			response: `{"response":"ok","done":true}`,
+			check: func(t *testing.T, r *http.Request, body map[string]interface{}) {
+				if body["keep_alive"] != "30m" {
+					t.Errorf("keep_alive = %v", body["keep_alive"])
+				}
+			},
+		},
+		{
+			name:     "anthropic headers",
+			provider: "anthropic",
+			options:  map[string]string{OptionAnthropicVersion: "2024-01-01", OptionAnthropicBeta: "prompt-caching-2024-07-31"},
+			response: `{"content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`,
+			check: func(t *testing.T, r *http.Request, _ map[string]interface{}) {
+				if got := r.Header.Get("anthropic-version"); got != "2024-01-01" {
+					t.Errorf("anthropic-version = %q", got)
+				}
+				if got := r.Header.Get("anthropic-beta"); got != "prompt-caching-2024-07-31" {
+					t.Errorf("anthropic-beta = %q", got)
+				}
+			},
+		},
+		{
+			name:     "azure openai deployment",
+			provider: "openai",
+			options:  map[string]string{OptionAzureDeployment: "parser-gen", OptionOpenAIOrganization: "org-1"},
+			response: `{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}]}`,
+			check: func(t *testing.T, r *http.Request, _ map[string]interface{}) {
+				if r.URL.Path != "/openai/deployments/parser-gen/chat/completions" || r.URL.Query().Get("api-version") != azureAPIVersion {
+					t.Errorf("unexpected Azure URL %s", r.URL)
+				}
+				if r.Header.Get("api-key") != "test-key" || r.Header.Get("Authorization") != "" {
+					t.Errorf("expected the key in api-key, got headers %v", r.Header)
+				}
+				if got := r.Header.Get("OpenAI-Organization"); got != "org-1" {
+					t.Errorf("OpenAI-Organization = %q", got)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]interface{}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				tt.check(t, r, body)
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = fmt.Fprint(w, tt.response)
+			}))
+			defer server.Close()
+
+			s := NewDiscoveryService(nil, NewParserManager(t.TempDir(), ""), DiscoveryConfig{
+				Provider:        tt.provider,
+				Endpoint:        server.URL,
+				Model:           "test-model",
+				ApiKey:          "test-key",
+				ProviderOptions: tt.options,
+			})
+			if got, err := s.callLLM(context.Background(), "prompt", 1); err != nil || got != "ok" {
+				t.Errorf("callLLM = %q, %v", got, err)
+			}
+		})
+	}
+}
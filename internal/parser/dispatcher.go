@@ -1,21 +1,73 @@
 package parser
 
 import (
+	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"slices"
+	"sort"
 	"sync"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"github.com/chuanjin/OmniBridge/internal/metrics"
+	"go.uber.org/zap"
 )
 
+// ErrUnknownProtocol is returned by Ingest when no binding matches the frame
+var ErrUnknownProtocol = errors.New("unknown protocol signature")
+
+// ErrProtocolDisabled is returned by Ingest when the matched protocol has been disabled
+var ErrProtocolDisabled = errors.New("protocol disabled")
+
+// ErrChecksum is returned by Ingest when a frame fails its protocol's FrameValidator
+var ErrChecksum = errors.New("checksum mismatch")
+
+// ErrSignatureConflict is returned by BindStrict when the signature already routes to another protocol
+var ErrSignatureConflict = errors.New("signature already bound to another protocol")
+
+// ErrOutOfRange is returned by Ingest when a parsed result fails its family's ResultValidator
+var ErrOutOfRange = errors.New("result out of range")
+
+// FrameValidator checks a whole frame (e.g. its trailing CRC) before it is parsed.
+// See the checksum package for common ones.
+type FrameValidator func(frame []byte) error
+
+// ResultValidator checks a parser's output for a frame against what the protocol can
+// legitimately produce (e.g. the obd2 package's PID ranges).
+type ResultValidator func(frame []byte, result map[string]interface{}) error
+
 type trieNode struct {
 	children   map[byte]*trieNode
 	protocolID string
+	byLength   map[int]string // Total frame length -> ProtocolID, preferred over protocolID
+	family     string         // Family label inherited by every protocol bound at or below this prefix
+}
+
+// LengthBinding routes frames that start with Signature and are exactly Length bytes long
+type LengthBinding struct {
+	Signature string `json:"signature"` // Hex prefix
+	Length    int    `json:"length"`
+	Protocol  string `json:"protocol"`
 }
 
 type Dispatcher struct {
 	manager *ParserManager
 	// Map of Hex Signature Prefix -> ProtocolID (e.g., "01" -> "VolvoEngine", "012A" -> "SpecialSensor")
-	routes map[string]string
-	root   *trieNode
-	mu     sync.RWMutex
+	routes      map[string]string
+	root        *trieNode
+	families    map[string]string // ProtocolID -> explicit family label, overrides prefix families
+	disabled    map[string]bool   // ProtocolIDs that stay bound but are not parsed
+	validators  map[string]FrameValidator
+	checks      map[string]ResultValidator // Family -> result validator
+	masked      []maskedBinding            // In registration order
+	maskPolicy  MaskPolicy
+	ambiguous   sync.Map // Overlapping masked binding sets already logged
+	deadLetters *DeadLetterMonitor
+	outputs     *OutputRouter
+	fallback    bool                // Unknown frames get a raw passthrough result instead of an error
+	conflicts   map[string][]string // Hex signature -> every protocol that claimed it, once more than one has
+	mu          sync.RWMutex
 }
 
 // GetBindings returns a copy of the current signature-to-parser mappings.
@@ -36,23 +88,268 @@ func (d *Dispatcher) GetManager() *ParserManager {
 }
 
 func NewDispatcher(mgr *ParserManager) *Dispatcher {
-	return &Dispatcher{
-		manager: mgr,
-		routes:  make(map[string]string),
-		root:    &trieNode{children: make(map[byte]*trieNode)},
+	d := &Dispatcher{
+		manager:    mgr,
+		routes:     make(map[string]string),
+		root:       &trieNode{children: make(map[byte]*trieNode)},
+		families:   make(map[string]string),
+		disabled:   make(map[string]bool),
+		validators: make(map[string]FrameValidator),
+		checks:     make(map[string]ResultValidator),
+		conflicts:  make(map[string][]string),
+		maskPolicy: MaskFirstRegistered,
+	}
+	// Keep bindings in sync when a parser is rolled back or reloaded from disk. Another
+	// dispatcher on the same manager keeps being notified too.
+	mgr.mu.Lock()
+	if prev := mgr.onCodeChange; prev != nil {
+		mgr.onCodeChange = func(protocolID, oldCode, newCode string) {
+			prev(protocolID, oldCode, newCode)
+			d.rebindFromCode(protocolID, oldCode, newCode)
+		}
+	} else {
+		mgr.onCodeChange = d.rebindFromCode
+	}
+	mgr.mu.Unlock()
+	return d
+}
+
+// bindFromCode binds a protocol to every signature declared in its source
+func (d *Dispatcher) bindFromCode(protocolID, code string) {
+	for _, sig := range declaredSignatures(code) {
+		d.Bind(sig, protocolID)
 	}
 }
 
+// rebindFromCode moves a protocol's code-declared bindings from oldCode's signatures to
+// newCode's. Bindings that weren't declared in oldCode (manual, length or masked) are kept.
+func (d *Dispatcher) rebindFromCode(protocolID, oldCode, newCode string) {
+	declared := make(map[string]bool)
+	for _, sig := range declaredSignatures(newCode) {
+		declared[fmt.Sprintf("%X", sig)] = true
+	}
+	d.mu.Lock()
+	for _, sig := range declaredSignatures(oldCode) {
+		hexSig := fmt.Sprintf("%X", sig)
+		if !declared[hexSig] && d.routes[hexSig] == protocolID {
+			d.unbindSignatureLocked(hexSig)
+		}
+	}
+	d.mu.Unlock()
+	d.bindFromCode(protocolID, newCode)
+}
+
+// SetDeadLetterMonitor makes Ingest report every frame outcome to the monitor
+func (d *Dispatcher) SetDeadLetterMonitor(m *DeadLetterMonitor) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deadLetters = m
+}
+
+// SetOutputRouter sends every ingested frame to the router's sinks for its outcome.
+// Frames for disabled protocols are dropped on purpose and not routed.
+func (d *Dispatcher) SetOutputRouter(r *OutputRouter) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.outputs = r
+}
+
 // Bind links a specific byte slice (signature) to a parser
 func (d *Dispatcher) Bind(signature []byte, protocolID string) {
 	hexSig := fmt.Sprintf("%X", signature)
 	d.mu.Lock()
 	defer d.mu.Unlock()
+	if previous, ok := d.routes[hexSig]; ok && previous != protocolID {
+		d.recordConflictLocked(hexSig, previous, protocolID)
+		logger.Warn("Signature rebound to a different protocol",
+			zap.String("signature", hexSig), zap.String("previous", previous), zap.String("protocol", protocolID))
+	}
+	d.bindLocked(hexSig, signature, protocolID)
+}
+
+// BindStrict binds like Bind but refuses to take a signature from another protocol,
+// returning ErrSignatureConflict. Re-binding the same protocol is allowed.
+func (d *Dispatcher) BindStrict(signature []byte, protocolID string) error {
+	hexSig := fmt.Sprintf("%X", signature)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if previous, ok := d.routes[hexSig]; ok && previous != protocolID {
+		d.recordConflictLocked(hexSig, previous, protocolID)
+		return fmt.Errorf("%w: %s is bound to %s, not binding %s", ErrSignatureConflict, hexSig, previous, protocolID)
+	}
+	d.bindLocked(hexSig, signature, protocolID)
+	return nil
+}
+
+// Conflicts returns, for every signature that more than one protocol has tried to bind,
+// the protocols in the order they claimed it (the last one only won if Bind was used).
+func (d *Dispatcher) Conflicts() map[string][]string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	conflicts := make(map[string][]string, len(d.conflicts))
+	for sig, ids := range d.conflicts {
+		conflicts[sig] = append([]string(nil), ids...)
+	}
+	return conflicts
+}
+
+func (d *Dispatcher) recordConflictLocked(hexSig, previous, protocolID string) {
+	ids := d.conflicts[hexSig]
+	for _, id := range []string{previous, protocolID} {
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	d.conflicts[hexSig] = ids
+}
+
+func (d *Dispatcher) bindLocked(hexSig string, signature []byte, protocolID string) {
 	d.routes[hexSig] = protocolID
 
 	// Insert into Trie
+	d.nodeLocked(signature).protocolID = protocolID
+}
+
+// BindWithValidator binds a signature like Bind and makes every frame routed to protocolID
+// pass v before it is parsed; frames that fail are rejected with ErrChecksum instead of
+// being misparsed. A nil v removes the protocol's validator.
+func (d *Dispatcher) BindWithValidator(signature []byte, protocolID string, v FrameValidator) {
+	d.Bind(signature, protocolID)
+	d.setValidator(protocolID, v)
+}
+
+// setValidator sets or, if v is nil, removes the FrameValidator of a protocol
+func (d *Dispatcher) setValidator(protocolID string, v FrameValidator) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if v == nil {
+		delete(d.validators, protocolID)
+		return
+	}
+	d.validators[protocolID] = v
+}
+
+// BindWithLength links a signature to a parser for frames of exactly length bytes.
+// Protocols sharing a prefix (e.g. a 3-byte status and a 5-byte extended status both
+// starting 0x41 0x05) are told apart this way; a length-matched binding wins over a
+// plain prefix match, and frames of any other length fall back to Bind routing.
+// A non-positive length is the same as Bind.
+func (d *Dispatcher) BindWithLength(signature []byte, length int, protocolID string) {
+	if length <= 0 {
+		d.Bind(signature, protocolID)
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	node := d.nodeLocked(signature)
+	if node.byLength == nil {
+		node.byLength = make(map[int]string)
+	}
+	node.byLength[length] = protocolID
+}
+
+// GetLengthBindings returns the length-constrained bindings sorted by signature and length
+func (d *Dispatcher) GetLengthBindings() []LengthBinding {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var bindings []LengthBinding
+	d.walkLocked(func(prefix []byte, n *trieNode) {
+		for length, id := range n.byLength {
+			bindings = append(bindings, LengthBinding{Signature: fmt.Sprintf("%X", prefix), Length: length, Protocol: id})
+		}
+	})
+	sort.Slice(bindings, func(i, j int) bool {
+		if bindings[i].Signature != bindings[j].Signature {
+			return bindings[i].Signature < bindings[j].Signature
+		}
+		return bindings[i].Length < bindings[j].Length
+	})
+	return bindings
+}
+
+// bindLengthBindings binds every length binding, skipping (and logging) invalid ones from source
+func (d *Dispatcher) bindLengthBindings(bindings []LengthBinding, source string) {
+	for _, lb := range bindings {
+		sig, err := hex.DecodeString(lb.Signature)
+		if err != nil || len(sig) == 0 {
+			logger.Warn("Skipping invalid length binding in "+source, zap.String("signature", lb.Signature), zap.String("protocol", lb.Protocol))
+			continue
+		}
+		d.BindWithLength(sig, lb.Length, lb.Protocol)
+	}
+}
+
+// manifest returns every binding of d as a manifest
+func (d *Dispatcher) manifest() Manifest {
+	disabled, _, _ := d.routingState()
+	return Manifest{
+		Bindings:       d.GetBindings(),
+		LengthBindings: d.GetLengthBindings(),
+		MaskedBindings: d.GetMaskedBindings(),
+		Disabled:       disabled,
+	}
+}
+
+// SaveManifest writes every binding, including length and masked ones, and the disabled
+// protocols to the manifest immediately
+func (d *Dispatcher) SaveManifest() error {
+	return d.manager.saveManifest(d.manifest())
+}
+
+// QueueManifest schedules every binding, including length and masked ones, and the disabled
+// protocols to be written to the manifest after its flush delay (see ParserManager.QueueManifest)
+func (d *Dispatcher) QueueManifest() {
+	d.manager.queueManifest(d.manifest())
+}
+
+// RestoreManifest binds everything recorded in the manager's manifest.json and disables
+// the protocols recorded as disabled
+func (d *Dispatcher) RestoreManifest() error {
+	bindings, err := d.manager.LoadManifest()
+	if err != nil {
+		return err
+	}
+	for sigHex, id := range bindings {
+		sig, _ := hex.DecodeString(sigHex) // LoadManifest only returns valid signatures
+		d.Bind(sig, id)
+	}
+	lengths, err := d.manager.LoadLengthBindings()
+	if err != nil {
+		return err
+	}
+	d.bindLengthBindings(lengths, "manifest")
+	masked, err := d.manager.LoadMaskedBindings()
+	if err != nil {
+		return err
+	}
+	d.bindMaskedBindings(masked, "manifest")
+	disabled, err := d.manager.LoadDisabled()
+	if err != nil {
+		return err
+	}
+	for _, id := range disabled {
+		d.SetEnabled(id, false)
+	}
+	return nil
+}
+
+// walkLocked visits every trie node with its prefix
+func (d *Dispatcher) walkLocked(visit func(prefix []byte, n *trieNode)) {
+	var walk func(n *trieNode, prefix []byte)
+	walk = func(n *trieNode, prefix []byte) {
+		visit(prefix, n)
+		for b, child := range n.children {
+			walk(child, append(prefix[:len(prefix):len(prefix)], b))
+		}
+	}
+	walk(d.root, nil)
+}
+
+// nodeLocked returns the trie node for prefix, creating it if needed
+func (d *Dispatcher) nodeLocked(prefix []byte) *trieNode {
 	curr := d.root
-	for _, b := range signature {
+	for _, b := range prefix {
 		if curr.children == nil {
 			curr.children = make(map[byte]*trieNode)
 		}
@@ -61,42 +358,423 @@ func (d *Dispatcher) Bind(signature []byte, protocolID string) {
 		}
 		curr = curr.children[b]
 	}
-	curr.protocolID = protocolID
+	return curr
+}
+
+// BindFamily labels every protocol whose signature starts with prefix (e.g. 0x41 -> "obd2").
+// The longest matching prefix wins; an empty family removes the label.
+func (d *Dispatcher) BindFamily(prefix []byte, family string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nodeLocked(prefix).family = family
+}
+
+// SetFamily sets an explicit family label for a protocol, overriding any prefix-derived one.
+// An empty family removes the override.
+func (d *Dispatcher) SetFamily(protocolID, family string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if family == "" {
+		delete(d.families, protocolID)
+		return
+	}
+	d.families[protocolID] = family
+}
+
+// SetResultValidator checks every successful parse of a protocol in family with v.
+// A result that fails is rejected with ErrOutOfRange, which like any parse error
+// triggers a repair. A nil v removes the family's validator.
+func (d *Dispatcher) SetResultValidator(family string, v ResultValidator) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if v == nil {
+		delete(d.checks, family)
+		return
+	}
+	d.checks[family] = v
+}
+
+// SetEnabled toggles parsing for a protocol without touching its bindings or history.
+// Frames matching a disabled protocol fail with ErrProtocolDisabled.
+func (d *Dispatcher) SetEnabled(protocolID string, enabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if enabled {
+		delete(d.disabled, protocolID)
+		return
+	}
+	d.disabled[protocolID] = true
+}
+
+// IsEnabled reports whether frames for the protocol are parsed
+func (d *Dispatcher) IsEnabled(protocolID string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return !d.disabled[protocolID]
+}
+
+// routingState returns the disabled protocols plus the prefix and explicit family labels
+func (d *Dispatcher) routingState() (disabled []string, prefixFamilies, protocolFamilies map[string]string) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for id := range d.disabled {
+		disabled = append(disabled, id)
+	}
+	sort.Strings(disabled)
+
+	prefixFamilies = make(map[string]string)
+	d.walkLocked(func(prefix []byte, n *trieNode) {
+		if n.family != "" {
+			prefixFamilies[fmt.Sprintf("%X", prefix)] = n.family
+		}
+	})
+
+	protocolFamilies = make(map[string]string, len(d.families))
+	for id, family := range d.families {
+		protocolFamilies[id] = family
+	}
+	return disabled, prefixFamilies, protocolFamilies
 }
 
-// Ingest takes raw data, identifies the protocol, and parses it
+// Unbind removes every signature routed to protocolID and returns how many were removed
+func (d *Dispatcher) Unbind(protocolID string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	removed := 0
+	for hexSig, id := range d.routes {
+		if id != protocolID {
+			continue
+		}
+		d.unbindSignatureLocked(hexSig)
+		removed++
+	}
+
+	d.walkLocked(func(_ []byte, n *trieNode) {
+		for length, id := range n.byLength {
+			if id == protocolID {
+				delete(n.byLength, length)
+				removed++
+			}
+		}
+	})
+
+	delete(d.validators, protocolID)
+
+	kept := d.masked[:0]
+	for _, b := range d.masked {
+		if b.protocol == protocolID {
+			removed++
+			continue
+		}
+		kept = append(kept, b)
+	}
+	d.masked = kept
+	return removed
+}
+
+// UnbindSignature removes the plain binding of one signature, the inverse of Bind. Frames
+// starting with it fall back to a shorter bound prefix, if any, or become unknown.
+// Length and masked bindings are left alone; see Unbind to remove a whole protocol.
+func (d *Dispatcher) UnbindSignature(signature []byte) error {
+	hexSig := fmt.Sprintf("%X", signature)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.routes[hexSig]; !ok {
+		return fmt.Errorf("signature %s is not bound", hexSig)
+	}
+	d.unbindSignatureLocked(hexSig)
+	return nil
+}
+
+// unbindSignatureLocked removes the plain binding of one hex signature and prunes the trie
+// nodes left with nothing bound below them
+func (d *Dispatcher) unbindSignatureLocked(hexSig string) {
+	delete(d.routes, hexSig)
+	sig, _ := hex.DecodeString(hexSig)
+	path := []*trieNode{d.root}
+	for _, b := range sig {
+		next := path[len(path)-1].children[b]
+		if next == nil {
+			return
+		}
+		path = append(path, next)
+	}
+	path[len(path)-1].protocolID = ""
+
+	for i := len(path) - 1; i > 0; i-- {
+		n := path[i]
+		if len(n.children) > 0 || n.protocolID != "" || len(n.byLength) > 0 || n.family != "" {
+			return
+		}
+		delete(path[i-1].children, sig[i-1])
+	}
+}
+
+// Ingest takes raw data, identifies the protocol, and parses it.
+// For a multi-result parser only the first result is returned; see IngestMulti.
 func (d *Dispatcher) Ingest(data []byte) (map[string]interface{}, string, error) {
-	if len(data) == 0 {
-		return nil, "", fmt.Errorf("empty payload")
+	return d.ingest(data, data)
+}
+
+// IngestWithSignature routes using the supplied signature instead of the frame's own leading bytes,
+// then parses data with the matched parser. Useful when the routing byte is ambiguous or missing.
+func (d *Dispatcher) IngestWithSignature(signature, data []byte) (map[string]interface{}, string, error) {
+	if len(signature) == 0 {
+		return nil, "", fmt.Errorf("empty signature override")
+	}
+	return d.ingest(signature, data)
+}
+
+// Route reports which protocol a frame starting with signature would be parsed by, using the
+// same longest-prefix, length and mask matching as Ingest but without parsing anything.
+// Length-constrained bindings only apply when signature is a whole frame of that length.
+// Disabled protocols are still reported; the fallback is not.
+func (d *Dispatcher) Route(signature []byte) (protocolID string, matched bool) {
+	if len(signature) == 0 {
+		return "", false
 	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	protocolID, _ = d.matchLocked(signature, len(signature))
+	return protocolID, protocolID != ""
+}
 
+// known reports whether a frame routes to a bound protocol, without parsing it
+func (d *Dispatcher) known(data []byte) bool {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
+	proto, _ := d.matchLocked(data, len(data))
+	return proto != ""
+}
+
+// IngestResult is the outcome of one frame processed by IngestStream
+type IngestResult struct {
+	Frame    []byte
+	Protocol string
+	Alias    string // Friendly name of Protocol, if one is set
+	Result   map[string]interface{}
+	Err      error
+}
+
+// IngestStream parses frames as they arrive and emits one result per frame, in order.
+// The output channel is unbuffered, so a slow consumer throttles reading from frames.
+// It is closed once frames is closed or ctx is cancelled.
+func (d *Dispatcher) IngestStream(ctx context.Context, frames <-chan []byte) <-chan IngestResult {
+	out := make(chan IngestResult)
+	go func() {
+		defer close(out)
+		for {
+			var frame []byte
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case frame, ok = <-frames:
+				if !ok {
+					return
+				}
+			}
+
+			result, proto, err := d.Ingest(frame)
+			alias, _ := d.manager.GetAlias(proto)
+			select {
+			case <-ctx.Done():
+				return
+			case out <- IngestResult{Frame: frame, Protocol: proto, Alias: alias, Result: result, Err: err}:
+			}
+		}
+	}()
+	return out
+}
 
-	var matchedProto string
+// IngestBatch parses frames in order under a single routing lock and returns one result
+// per frame. Each protocol's compiled parser is looked up once for the whole batch.
+func (d *Dispatcher) IngestBatch(frames [][]byte) []IngestResult {
+	results := make([]IngestResult, len(frames))
+	families := make([]string, len(frames))
+	parsers := make(map[string]compiledParser)
+	parse := func(protocolID string, data []byte) (map[string]interface{}, error) {
+		fn, ok := parsers[protocolID]
+		if !ok {
+			var err error
+			if fn, err = d.manager.compiledParser(protocolID); err != nil {
+				return nil, err
+			}
+			parsers[protocolID] = fn
+		}
+		return d.manager.engine.executeCompiled(protocolID, fn, data)
+	}
+
+	d.mu.RLock()
+	for i, frame := range frames {
+		metrics.IncIngest()
+		r := &results[i]
+		r.Frame = frame
+		r.Result, r.Protocol, families[i], r.Err = d.ingestLocked(frame, frame, parse)
+	}
+	deadLetters, outputs := d.deadLetters, d.outputs
+	d.mu.RUnlock()
+
+	for i := range results {
+		r := &results[i]
+		r.Alias = d.finishIngest(r.Frame, r.Protocol, families[i], r.Result, r.Err, deadLetters, outputs)
+		r.Result = firstResult(r.Result)
+	}
+	return results
+}
+
+func (d *Dispatcher) ingest(key, data []byte) (map[string]interface{}, string, error) {
+	result, proto, err := d.ingestAll(key, data)
+	return firstResult(result), proto, err
+}
+
+// ingestAll parses a frame and feeds the sinks, returning a multi-result parser's output whole
+func (d *Dispatcher) ingestAll(key, data []byte) (map[string]interface{}, string, error) {
+	metrics.IncIngest()
+
+	d.mu.RLock()
+	result, proto, family, err := d.ingestLocked(key, data, d.manager.ParseData)
+	deadLetters, outputs := d.deadLetters, d.outputs
+	d.mu.RUnlock()
+
+	d.finishIngest(data, proto, family, result, err, deadLetters, outputs)
+	return result, proto, err
+}
+
+// redeliver parses a stored frame again, e.g. a dead letter after its parser was fixed.
+// Only a successful parse reaches the output sinks and the dead-letter monitor; a failure
+// is left to the caller so the frame isn't dead-lettered twice.
+func (d *Dispatcher) redeliver(data []byte) (map[string]interface{}, string, error) {
+	metrics.IncIngest()
+
+	d.mu.RLock()
+	result, proto, family, err := d.ingestLocked(data, data, d.manager.ParseData)
+	deadLetters, outputs := d.deadLetters, d.outputs
+	d.mu.RUnlock()
+
+	// An empty result the sinks would dead-letter again is no recovery
+	if err == nil && outputs != nil && emptyResult(result) && outputs.emptyResultPolicy() == EmptyDeadLetter {
+		err = fmt.Errorf("%s: %w", proto, ErrEmptyResult)
+	}
+	if err == nil && proto != FallbackProtocol {
+		d.finishIngest(data, proto, family, result, err, deadLetters, outputs)
+	}
+	return result, proto, err
+}
+
+// finishIngest logs a parsed frame and feeds the dead-letter monitor and output sinks.
+// It runs outside the lock so a slow sink doesn't block Bind, and returns the protocol's alias.
+func (d *Dispatcher) finishIngest(data []byte, proto, family string, result map[string]interface{}, err error,
+	deadLetters *DeadLetterMonitor, outputs *OutputRouter) string {
+	alias, _ := d.manager.GetAlias(proto)
+	logger.Debug("Frame ingested",
+		zap.String("protocol", proto), zap.String("alias", alias), zap.String("family", family), zap.Int("bytes", len(data)), zap.Error(err))
+	// Frames for a disabled protocol are dropped on purpose, not dead letters
+	if errors.Is(err, ErrProtocolDisabled) {
+		return alias
+	}
+	// A raw passthrough is still a frame nothing could parse
+	if deadLetters != nil {
+		deadLetters.Record(err != nil || proto == FallbackProtocol)
+	}
+	if outputs != nil {
+		outputs.Route(Output{Frame: data, Protocol: proto, Result: result, Err: err, Outcome: classifyOutcome(proto, err)})
+	}
+	return alias
+}
+
+func (d *Dispatcher) ingestLocked(key, data []byte, parse func(protocolID string, data []byte) (map[string]interface{}, error)) (map[string]interface{}, string, string, error) {
+	if len(data) == 0 {
+		return nil, "", "", fmt.Errorf("empty payload")
+	}
+
+	matchedProto, family := d.matchLocked(key, len(data))
+	if matchedProto == "" {
+		if d.fallback {
+			metrics.ObserveParse(FallbackProtocol, nil)
+			return rawResult(data), FallbackProtocol, family, nil
+		}
+		maxLen := 4
+		if len(key) < maxLen {
+			maxLen = len(key)
+		}
+		err := fmt.Errorf("%w: 0x%X", ErrUnknownProtocol, key[:maxLen])
+		metrics.ObserveParse("unknown", err)
+		return nil, "", family, err
+	}
+
+	result, err := d.parseMatchedLocked(matchedProto, family, data, parse)
+	return result, matchedProto, family, err
+}
+
+// parseMatchedLocked parses a frame already routed to matchedProto: the protocol must be
+// enabled and the frame pass its validators
+func (d *Dispatcher) parseMatchedLocked(matchedProto, family string, data []byte, parse func(protocolID string, data []byte) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	if d.disabled[matchedProto] {
+		return nil, fmt.Errorf("%s: %w", matchedProto, ErrProtocolDisabled)
+	}
+
+	if validate := d.validators[matchedProto]; validate != nil {
+		if err := validate(data); err != nil {
+			err = fmt.Errorf("%s: %w: %v", matchedProto, ErrChecksum, err)
+			metrics.ObserveParse(matchedProto, err)
+			return nil, err
+		}
+	}
+	return d.parseValidatedLocked(matchedProto, family, data, parse)
+}
+
+// parseValidatedLocked runs the parser of a protocol whose FrameValidator already accepted data,
+// then its family's ResultValidator
+func (d *Dispatcher) parseValidatedLocked(matchedProto, family string, data []byte, parse func(protocolID string, data []byte) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	// Run the cached parser
+	result, err := parse(matchedProto, data)
+	if check := d.checks[family]; check != nil && err == nil {
+		// Validators see the frame's leading record, which is the first of a multi-result parser
+		if checkErr := check(data, firstResult(result)); checkErr != nil {
+			result, err = nil, fmt.Errorf("%s: %w: %v", matchedProto, ErrOutOfRange, checkErr)
+		}
+	}
+	metrics.ObserveParse(matchedProto, err)
+	return result, err
+}
+
+// matchLocked performs a longest-prefix match of key against the trie and returns the
+// matched protocol with its family. A binding constrained to the frame length wins over
+// plain prefix bindings, and masked bindings are tried when neither matches. Unknown
+// frames still get the family of their prefix.
+func (d *Dispatcher) matchLocked(key []byte, length int) (string, string) {
+	var matchedProto, matchedFamily, family string
+	var lengthMatched bool
 	curr := d.root
 
-	// Longest prefix match using Trie
-	for _, b := range data {
+	for _, b := range key {
 		if next, ok := curr.children[b]; ok {
 			curr = next
-			if curr.protocolID != "" {
+			if curr.family != "" {
+				family = curr.family
+			}
+			if id := curr.byLength[length]; id != "" {
+				matchedProto, matchedFamily, lengthMatched = id, family, true
+			} else if curr.protocolID != "" && !lengthMatched {
 				matchedProto = curr.protocolID
+				matchedFamily = family
 			}
 		} else {
 			break
 		}
 	}
 
+	if matchedProto == "" && len(d.masked) > 0 {
+		matchedProto, matchedFamily = d.matchMaskedLocked(key), family
+	}
 	if matchedProto == "" {
-		maxLen := 4
-		if len(data) < maxLen {
-			maxLen = len(data)
-		}
-		return nil, "", fmt.Errorf("unknown protocol signature: 0x%X", data[:maxLen])
+		return "", family
 	}
-
-	// Use the manager to run the cached parser
-	result, err := d.manager.ParseData(matchedProto, data)
-	return result, matchedProto, err
+	if explicit, ok := d.families[matchedProto]; ok {
+		return matchedProto, explicit
+	}
+	return matchedProto, matchedFamily
 }
@@ -1,8 +1,24 @@
 package parser
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"github.com/chuanjin/OmniBridge/internal/parser/checksum"
+	"github.com/chuanjin/OmniBridge/internal/parser/obd2"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestDispatcher_BindAndIngest(t *testing.T) {
@@ -77,3 +93,873 @@ func TestDispatcher_GetBindings(t *testing.T) {
 		t.Errorf("Expected binding for AA to be ProtoA, got %v", bindings["AA"])
 	}
 }
+
+func TestDispatcher_IngestWithSignature(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	mgr := NewParserManager(tmpDir, "")
+	d := NewDispatcher(mgr)
+
+	code := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"first": int(data[0])} }`
+	if err := mgr.RegisterParser("ProtoA", code); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d.Bind([]byte{0xAA, 0x01}, "ProtoA")
+
+	// The frame's own leading bytes don't match any binding
+	frame := []byte{0x7F, 0x10, 0x20}
+	if _, proto, err := d.Ingest(frame); err == nil || proto != "" {
+		t.Fatalf("Expected plain Ingest to fail, got proto %q", proto)
+	}
+
+	result, proto, err := d.IngestWithSignature([]byte{0xAA, 0x01}, frame)
+	if err != nil {
+		t.Fatalf("IngestWithSignature failed: %v", err)
+	}
+	if proto != "ProtoA" {
+		t.Errorf("Expected ProtoA, got %s", proto)
+	}
+	// The parser must see the original frame, not the override
+	if result["first"] != 0x7F {
+		t.Errorf("Expected parser to receive original frame, got %v", result)
+	}
+
+	if _, _, err := d.IngestWithSignature([]byte{0xBB}, frame); err == nil {
+		t.Error("Expected unknown signature override to fail")
+	}
+}
+
+func TestDispatcher_Route(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	d := NewDispatcher(NewParserManager(tmpDir, ""))
+	d.Bind([]byte{0x41}, "OBDII_Service01")
+	d.Bind([]byte{0x41, 0x0C}, "OBD_RPM")
+	d.BindWithLength([]byte{0x55}, 3, "Short55")
+	d.SetFallback(true)
+
+	tests := []struct {
+		name      string
+		signature []byte
+		want      string
+		matched   bool
+	}{
+		{"longest prefix", []byte{0x41, 0x0C}, "OBD_RPM", true},
+		{"shorter prefix", []byte{0x41, 0x05}, "OBDII_Service01", true},
+		{"signature only", []byte{0x41}, "OBDII_Service01", true},
+		{"whole frame of a bound length", []byte{0x55, 0x01, 0x02}, "Short55", true},
+		{"length binding needs the whole frame", []byte{0x55}, "", false},
+		{"unbound, fallback not reported", []byte{0x99}, "", false},
+		{"empty", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, matched := d.Route(tt.signature)
+			if got != tt.want || matched != tt.matched {
+				t.Errorf("Route(%X) = %q, %v; want %q, %v", tt.signature, got, matched, tt.want, tt.matched)
+			}
+		})
+	}
+}
+
+func TestDispatcher_Unbind(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	d := NewDispatcher(NewParserManager(tmpDir, ""))
+	d.Bind([]byte{0x01}, "Proto1")
+	d.Bind([]byte{0x01, 0x02}, "Proto2")
+	d.Bind([]byte{0x03}, "Proto2")
+
+	if removed := d.Unbind("Proto2"); removed != 2 {
+		t.Errorf("Unbind removed %d signatures, want 2", removed)
+	}
+	if _, proto, _ := d.Ingest([]byte{0x01, 0x02, 0xFF}); proto != "Proto1" {
+		t.Errorf("expected fallback to shorter prefix Proto1, got %q", proto)
+	}
+	if _, proto, _ := d.Ingest([]byte{0x03}); proto != "" {
+		t.Errorf("expected 0x03 to be unbound, got %q", proto)
+	}
+	if bindings := d.GetBindings(); len(bindings) != 1 || bindings["01"] != "Proto1" {
+		t.Errorf("unexpected bindings after Unbind: %v", bindings)
+	}
+}
+
+func TestDispatcher_UnbindSignature(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	if err := mgr.RegisterParser("Proto1", fastParser); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d := NewDispatcher(mgr)
+	d.Bind([]byte{0x01}, "Proto1")
+	d.Bind([]byte{0x01, 0x02, 0x03}, "Proto1")
+	d.Bind([]byte{0x07, 0x08}, "Proto1")
+
+	tests := []struct {
+		name      string
+		signature []byte
+		frame     []byte
+		wantProto string // After unbinding
+	}{
+		{"falls back to a shorter prefix", []byte{0x01, 0x02, 0x03}, []byte{0x01, 0x02, 0x03}, "Proto1"},
+		{"last binding of its branch", []byte{0x07, 0x08}, []byte{0x07, 0x08}, ""},
+		{"root-level binding", []byte{0x01}, []byte{0x01, 0x02, 0x03}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := d.UnbindSignature(tt.signature); err != nil {
+				t.Fatalf("UnbindSignature failed: %v", err)
+			}
+			_, proto, err := d.Ingest(tt.frame)
+			if proto != tt.wantProto {
+				t.Errorf("Ingest(%X) routed to %q, want %q", tt.frame, proto, tt.wantProto)
+			}
+			if tt.wantProto == "" && !errors.Is(err, ErrUnknownProtocol) {
+				t.Errorf("expected ErrUnknownProtocol, got %v", err)
+			}
+			if err := d.UnbindSignature(tt.signature); err == nil {
+				t.Error("expected unbinding an unbound signature to fail")
+			}
+		})
+	}
+
+	// Nothing is left in the trie once every signature is gone
+	if len(d.root.children) != 0 || len(d.GetBindings()) != 0 {
+		t.Errorf("expected empty trie and routes, got %d root children and %v", len(d.root.children), d.GetBindings())
+	}
+}
+
+func TestDispatcher_BindStrict(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	prev := logger.Set(zap.New(core))
+	defer logger.Set(prev)
+
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	d := NewDispatcher(NewParserManager(tmpDir, ""))
+
+	if err := d.BindStrict([]byte{0xAA, 0x01}, "ProtoA"); err != nil {
+		t.Fatalf("BindStrict on a free signature failed: %v", err)
+	}
+	// Re-binding the same protocol is benign
+	if err := d.BindStrict([]byte{0xAA, 0x01}, "ProtoA"); err != nil {
+		t.Errorf("BindStrict re-bind of the same protocol failed: %v", err)
+	}
+	d.Bind([]byte{0xAA, 0x01}, "ProtoA")
+	if conflicts := d.Conflicts(); len(conflicts) != 0 || logs.Len() != 0 {
+		t.Errorf("expected no conflicts after same-protocol re-binds, got %v (%d warnings)", conflicts, logs.Len())
+	}
+
+	err := d.BindStrict([]byte{0xAA, 0x01}, "ProtoB")
+	if !errors.Is(err, ErrSignatureConflict) {
+		t.Fatalf("expected ErrSignatureConflict, got %v", err)
+	}
+	if bindings := d.GetBindings(); bindings["AA01"] != "ProtoA" {
+		t.Errorf("strict conflict changed the binding: %v", bindings)
+	}
+
+	// The lenient Bind still overwrites, and the collision stays visible
+	d.Bind([]byte{0xAA, 0x01}, "ProtoC")
+	if bindings := d.GetBindings(); bindings["AA01"] != "ProtoC" {
+		t.Errorf("Bind did not overwrite: %v", bindings)
+	}
+	if logs.FilterMessage("Signature rebound to a different protocol").Len() != 1 {
+		t.Errorf("expected one overwrite warning, got %v", logs.All())
+	}
+	want := map[string][]string{"AA01": {"ProtoA", "ProtoB", "ProtoC"}}
+	if conflicts := d.Conflicts(); !reflect.DeepEqual(conflicts, want) {
+		t.Errorf("Conflicts() = %v, want %v", conflicts, want)
+	}
+}
+
+func TestDispatcher_BindWithLength(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	for _, id := range []string{"status", "extended_status", "generic", "pid_0C"} {
+		code := fmt.Sprintf("package dynamic\nfunc Parse(data []byte) map[string]interface{} { return map[string]interface{}{\"id\": %q} }", id)
+		if err := mgr.RegisterParser(id, code); err != nil {
+			t.Fatalf("RegisterParser(%s) failed: %v", id, err)
+		}
+	}
+
+	d := NewDispatcher(mgr)
+	// Ambiguous: both start 0x41 0x05 and differ only in length
+	d.BindWithLength([]byte{0x41, 0x05}, 3, "status")
+	d.BindWithLength([]byte{0x41, 0x05}, 5, "extended_status")
+	// Unambiguous prefix bindings keep working alongside them
+	d.Bind([]byte{0x41}, "generic")
+	d.Bind([]byte{0x41, 0x0C}, "pid_0C")
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  string
+	}{
+		{"3-byte status", []byte{0x41, 0x05, 0x7B}, "status"},
+		{"5-byte extended status", []byte{0x41, 0x05, 0x7B, 0x01, 0x02}, "extended_status"},
+		{"other length falls back to prefix", []byte{0x41, 0x05, 0x7B, 0x01}, "generic"},
+		{"unrelated prefix", []byte{0x41, 0x0C, 0x1A, 0xF8}, "pid_0C"},
+	}
+	for _, tt := range tests {
+		res, proto, err := d.Ingest(tt.input)
+		if err != nil || proto != tt.want || res["id"] != tt.want {
+			t.Errorf("%s: got %q (%v, %v), want %q", tt.name, proto, res, err, tt.want)
+		}
+	}
+
+	want := []LengthBinding{
+		{Signature: "4105", Length: 3, Protocol: "status"},
+		{Signature: "4105", Length: 5, Protocol: "extended_status"},
+	}
+	if got := d.GetLengthBindings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetLengthBindings = %v, want %v", got, want)
+	}
+
+	if removed := d.Unbind("extended_status"); removed != 1 {
+		t.Errorf("Unbind removed %d bindings, want 1", removed)
+	}
+	if _, proto, _ := d.Ingest([]byte{0x41, 0x05, 0x7B, 0x01, 0x02}); proto != "generic" {
+		t.Errorf("expected fallback after Unbind, got %q", proto)
+	}
+}
+
+func TestDispatcher_ManifestKeepsConstrainedBindings(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	d := NewDispatcher(mgr)
+	d.Bind([]byte{0x41}, "generic")
+	d.BindWithLength([]byte{0x41, 0x05}, 3, "status")
+	d.BindWithLength([]byte{0x41, 0x05}, 5, "extended_status")
+	if err := d.BindMasked([]byte{0x40}, []byte{0xF0}, "nibble", 0); err != nil {
+		t.Fatalf("BindMasked failed: %v", err)
+	}
+	if err := d.BindMasked([]byte{0x42, 0x00}, []byte{0xFF, 0x0F}, "low_nibble", 5); err != nil {
+		t.Fatalf("BindMasked failed: %v", err)
+	}
+	if err := d.SaveManifest(); err != nil {
+		t.Fatalf("SaveManifest failed: %v", err)
+	}
+	// A plain-bindings save keeps the recorded length and masked bindings
+	if err := mgr.SaveManifest(d.GetBindings()); err != nil {
+		t.Fatalf("SaveManifest failed: %v", err)
+	}
+
+	restored := NewDispatcher(NewParserManager(tmpDir, ""))
+	if err := restored.RestoreManifest(); err != nil {
+		t.Fatalf("RestoreManifest failed: %v", err)
+	}
+	if got, want := restored.GetBindings(), d.GetBindings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Restored bindings = %v, want %v", got, want)
+	}
+	if got, want := restored.GetLengthBindings(), d.GetLengthBindings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Restored length bindings = %v, want %v", got, want)
+	}
+	if got, want := restored.GetMaskedBindings(), d.GetMaskedBindings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Restored masked bindings = %v, want %v", got, want)
+	}
+}
+
+func TestDispatcher_IngestLogsFamily(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	prev := logger.Set(zap.New(core))
+	defer logger.Set(prev)
+
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	code := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"pid": int(data[1])} }`
+	for _, id := range []string{"obd_rpm", "obd_speed", "meter"} {
+		if err := mgr.RegisterParser(id, code); err != nil {
+			t.Fatalf("RegisterParser failed: %v", err)
+		}
+	}
+
+	d := NewDispatcher(mgr)
+	d.Bind([]byte{0x41, 0x0C}, "obd_rpm")
+	d.Bind([]byte{0x41, 0x0D}, "obd_speed")
+	d.Bind([]byte{0x55, 0xAA}, "meter")
+	d.BindFamily([]byte{0x41}, "obd2")
+	d.SetFamily("meter", "metering")
+
+	frames := []struct {
+		data   []byte
+		family string
+	}{
+		{[]byte{0x41, 0x0C, 0x1A}, "obd2"},
+		{[]byte{0x41, 0x0D, 0x4B}, "obd2"},
+		{[]byte{0x41, 0x99}, "obd2"}, // Unknown PID, still in the family
+		{[]byte{0x55, 0xAA, 0x01}, "metering"},
+	}
+	for _, f := range frames {
+		_, _, _ = d.Ingest(f.data)
+	}
+
+	entries := logs.FilterMessage("Frame ingested").All()
+	if len(entries) != len(frames) {
+		t.Fatalf("Expected %d ingest log entries, got %d", len(frames), len(entries))
+	}
+	for i, entry := range entries {
+		if got := entry.ContextMap()["family"]; got != frames[i].family {
+			t.Errorf("frame %X logged family %v, want %s", frames[i].data, got, frames[i].family)
+		}
+	}
+}
+
+func TestDispatcher_SetEnabled(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	code := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"val": int(data[1])} }`
+	if err := mgr.RegisterParser("ProtoA", code); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d := NewDispatcher(mgr)
+	d.Bind([]byte{0x0A}, "ProtoA")
+	monitor := NewDeadLetterMonitor(DeadLetterConfig{})
+	d.SetDeadLetterMonitor(monitor)
+
+	d.SetEnabled("ProtoA", false)
+	if d.IsEnabled("ProtoA") {
+		t.Error("Expected ProtoA to be disabled")
+	}
+	res, proto, err := d.Ingest([]byte{0x0A, 0x07})
+	if !errors.Is(err, ErrProtocolDisabled) || res != nil {
+		t.Errorf("Expected ErrProtocolDisabled, got %v (%v)", err, res)
+	}
+	if proto != "ProtoA" {
+		t.Errorf("Expected the disabled protocol to still match, got %q", proto)
+	}
+	if bindings := d.GetBindings(); bindings["0A"] != "ProtoA" {
+		t.Errorf("Disabling must keep the binding, got %v", bindings)
+	}
+	if rate := monitor.Rate(); rate != 0 {
+		t.Errorf("Disabled frames should not count as dead letters, rate = %v", rate)
+	}
+
+	d.SetEnabled("ProtoA", true)
+	res, _, err = d.Ingest([]byte{0x0A, 0x07})
+	if err != nil || res["val"] != 7 {
+		t.Errorf("Expected parsing restored after re-enabling, got %v (%v)", res, err)
+	}
+}
+
+func TestDispatcher_IngestStream(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	code := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"seq": int(data[1])} }`
+	if err := mgr.RegisterParser("ProtoA", code); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d := NewDispatcher(mgr)
+	d.Bind([]byte{0x0A}, "ProtoA")
+
+	frames := make(chan []byte)
+	go func() {
+		defer close(frames)
+		for i := 0; i < 20; i++ {
+			frames <- []byte{0x0A, byte(i)}
+		}
+		frames <- []byte{0xFF} // Unknown protocol still yields a result
+	}()
+
+	i := 0
+	for res := range d.IngestStream(context.Background(), frames) {
+		if i == 20 {
+			if res.Err == nil || res.Protocol != "" {
+				t.Errorf("Expected unknown-protocol error for last frame, got %+v", res)
+			}
+		} else if res.Err != nil || res.Result["seq"] != i || res.Protocol != "ProtoA" {
+			t.Errorf("Result %d out of order or failed: %+v", i, res)
+		}
+		i++
+	}
+	if i != 21 {
+		t.Errorf("Expected 21 results, got %d", i)
+	}
+}
+
+func TestDispatcher_IngestStream_Cancel(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	d := NewDispatcher(NewParserManager(tmpDir, ""))
+	ctx, cancel := context.WithCancel(context.Background())
+	frames := make(chan []byte) // Never closed
+	out := d.IngestStream(ctx, frames)
+
+	cancel()
+	select {
+	case _, ok := <-out:
+		if ok {
+			t.Error("Expected no results after cancellation")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Output channel not closed after context cancellation")
+	}
+}
+
+// benchmarkRestartIngest simulates a restart with a library of stored parsers and
+// measures ingesting the first frame of every protocol.
+func benchmarkRestartIngest(b *testing.B, warm bool) {
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_bench")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	const protocols = 20
+	seed := NewParserManager(tmpDir, "")
+	for i := 0; i < protocols; i++ {
+		code := fmt.Sprintf(`// Signature: %02X
+package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"val": int(data[1])} }`, i)
+		if err := seed.RegisterParser(fmt.Sprintf("proto_%d", i), code); err != nil {
+			b.Fatalf("RegisterParser failed: %v", err)
+		}
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		mgr := NewParserManager(tmpDir, "")
+		if _, err := mgr.LoadSavedParsers(); err != nil {
+			b.Fatalf("LoadSavedParsers failed: %v", err)
+		}
+		d := NewDispatcher(mgr)
+		for i := 0; i < protocols; i++ {
+			d.Bind([]byte{byte(i)}, fmt.Sprintf("proto_%d", i))
+		}
+		if warm {
+			if err := mgr.GetEngine().WarmCache(mgr.Parsers()); err != nil {
+				b.Fatalf("WarmCache failed: %v", err)
+			}
+		}
+		b.StartTimer()
+
+		for i := 0; i < protocols; i++ {
+			if _, _, err := d.Ingest([]byte{byte(i), 0x2A}); err != nil {
+				b.Fatalf("Ingest failed: %v", err)
+			}
+		}
+	}
+}
+
+func BenchmarkIngest_ColdStart(b *testing.B) {
+	benchmarkRestartIngest(b, false)
+}
+
+func BenchmarkIngest_WarmedStart(b *testing.B) {
+	benchmarkRestartIngest(b, true)
+}
+
+func TestDispatcher_IngestBatch(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "batch_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	d := NewDispatcher(mgr)
+	_ = mgr.RegisterParser("a", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"a": int(data[1])} }`)
+	_ = mgr.RegisterParser("b", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"b": int(data[1])} }`)
+	d.Bind([]byte{0x01}, "a")
+	d.Bind([]byte{0x02}, "b")
+
+	frames := [][]byte{{0x01, 0x05}, {0x02, 0x06}, {0x99}, {}, {0x01, 0x07}}
+	results := d.IngestBatch(frames)
+	if len(results) != len(frames) {
+		t.Fatalf("expected %d results, got %d", len(frames), len(results))
+	}
+
+	// Every result must match what a single Ingest returns for the same frame
+	for i, frame := range frames {
+		wantRes, wantProto, wantErr := d.Ingest(frame)
+		got := results[i]
+		if !reflect.DeepEqual(got.Result, wantRes) || got.Protocol != wantProto || (got.Err == nil) != (wantErr == nil) {
+			t.Errorf("frame %X: batch = %v/%s/%v, single = %v/%s/%v", frame, got.Result, got.Protocol, got.Err, wantRes, wantProto, wantErr)
+		}
+		if !bytes.Equal(got.Frame, frame) {
+			t.Errorf("result %d carries frame %X, want %X", i, got.Frame, frame)
+		}
+	}
+}
+
+func benchmarkBatchDispatcher(b *testing.B) (*Dispatcher, [][]byte) {
+	tmpDir := b.TempDir()
+	mgr := NewParserManager(tmpDir, "")
+	d := NewDispatcher(mgr)
+	if err := mgr.RegisterParser("bench", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"val": int(data[1])} }`); err != nil {
+		b.Fatalf("RegisterParser failed: %v", err)
+	}
+	d.Bind([]byte{0x01}, "bench")
+
+	frames := make([][]byte, 256)
+	for i := range frames {
+		frames[i] = []byte{0x01, byte(i)}
+	}
+	if _, _, err := d.Ingest(frames[0]); err != nil {
+		b.Fatalf("Ingest failed: %v", err)
+	}
+	return d, frames
+}
+
+func BenchmarkIngest_Sequential(b *testing.B) {
+	d, frames := benchmarkBatchDispatcher(b)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, frame := range frames {
+			_, _, _ = d.Ingest(frame)
+		}
+	}
+}
+
+func BenchmarkIngest_Batch(b *testing.B) {
+	d, frames := benchmarkBatchDispatcher(b)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_ = d.IngestBatch(frames)
+	}
+}
+
+func TestDispatcher_MaskPolicy(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	prev := logger.Set(zap.New(core))
+	defer logger.Set(prev)
+
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	for _, id := range []string{"nibble", "sensor"} {
+		code := fmt.Sprintf("package dynamic\nfunc Parse(data []byte) map[string]interface{} { return map[string]interface{}{\"id\": %q} }", id)
+		if err := mgr.RegisterParser(id, code); err != nil {
+			t.Fatalf("RegisterParser(%s) failed: %v", id, err)
+		}
+	}
+
+	// Both match 0x4A 0x01: "nibble" fixes 4 bits, "sensor" fixes 12 but is registered later
+	frame := []byte{0x4A, 0x01, 0xFF}
+	tests := []struct {
+		policy MaskPolicy
+		want   string
+	}{
+		{MaskFirstRegistered, "nibble"},
+		{MaskMostSpecific, "sensor"},
+		{MaskHighestPriority, "nibble"},
+	}
+	for _, tt := range tests {
+		d := NewDispatcher(mgr)
+		d.SetMaskPolicy(tt.policy)
+		if err := d.BindMasked([]byte{0x40, 0x00}, []byte{0xF0, 0x00}, "nibble", 10); err != nil {
+			t.Fatalf("BindMasked failed: %v", err)
+		}
+		if err := d.BindMasked([]byte{0x0A, 0x01}, []byte{0x0F, 0xFF}, "sensor", 1); err != nil {
+			t.Fatalf("BindMasked failed: %v", err)
+		}
+
+		for i := 0; i < 5; i++ {
+			res, proto, err := d.Ingest(frame)
+			if err != nil || proto != tt.want || res["id"] != tt.want {
+				t.Fatalf("%s: got %q (%v, %v), want %q", tt.policy, proto, res, err, tt.want)
+			}
+		}
+		// Frames only one binding matches are unaffected by the policy
+		if _, proto, _ := d.Ingest([]byte{0x4A, 0x02}); proto != "nibble" {
+			t.Errorf("%s: non-overlapping frame routed to %q", tt.policy, proto)
+		}
+		// Exact prefix bindings still take precedence
+		d.Bind([]byte{0x4A}, "sensor")
+		if _, proto, _ := d.Ingest([]byte{0x4A, 0x02}); proto != "sensor" {
+			t.Errorf("%s: expected exact binding to win, got %q", tt.policy, proto)
+		}
+	}
+
+	if got := logs.FilterMessage("Frame matches several masked bindings").Len(); got != len(tests) {
+		t.Errorf("expected one ambiguity warning per dispatcher, got %d", got)
+	}
+
+	if err := NewDispatcher(mgr).BindMasked([]byte{0x40}, []byte{0xF0, 0x00}, "nibble", 0); err == nil {
+		t.Error("expected error for mask/signature length mismatch")
+	}
+}
+
+func TestDispatcher_BindPattern(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	for _, id := range []string{"meter", "master"} {
+		code := fmt.Sprintf("package dynamic\nfunc Parse(data []byte) map[string]interface{} { return map[string]interface{}{\"id\": %q, \"addr\": int(data[1])} }", id)
+		if err := mgr.RegisterParser(id, code); err != nil {
+			t.Fatalf("RegisterParser(%s) failed: %v", id, err)
+		}
+	}
+
+	d := NewDispatcher(mgr)
+	// AA <address> 10: any device address routes to the same parser
+	if err := d.BindPattern([]BytePattern{Exact(0xAA), AnyByte, Exact(0x10)}, "meter"); err != nil {
+		t.Fatalf("BindPattern failed: %v", err)
+	}
+	for _, addr := range []byte{0x00, 0x01, 0x7F, 0xFF} {
+		res, proto, err := d.Ingest([]byte{0xAA, addr, 0x10, 0x42})
+		if err != nil || proto != "meter" || res["addr"] != int(addr) {
+			t.Errorf("address 0x%02X: got %q (%v, %v), want meter", addr, proto, res, err)
+		}
+	}
+
+	// Exact bytes must still match, and the frame must cover the whole pattern
+	for _, frame := range [][]byte{{0xAA, 0x01, 0x11}, {0xAB, 0x01, 0x10}, {0xAA, 0x01}} {
+		if _, proto, err := d.Ingest(frame); !errors.Is(err, ErrUnknownProtocol) {
+			t.Errorf("frame % X: got %q, %v; want ErrUnknownProtocol", frame, proto, err)
+		}
+	}
+
+	// The trie is tried first: an exact binding for one address wins over the pattern
+	d.Bind([]byte{0xAA, 0x00}, "master")
+	if _, proto, _ := d.Ingest([]byte{0xAA, 0x00, 0x10}); proto != "master" {
+		t.Errorf("expected the exact binding to win, got %q", proto)
+	}
+	if _, proto, _ := d.Ingest([]byte{0xAA, 0x05, 0x10}); proto != "meter" {
+		t.Errorf("expected other addresses to keep matching the pattern, got %q", proto)
+	}
+
+	if err := d.BindPattern([]BytePattern{AnyByte, AnyByte}, "meter"); err == nil {
+		t.Error("expected error for a pattern of wildcards only")
+	}
+}
+
+func TestDispatcher_BindWithValidator(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	code := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"reg": int(data[3])*256 + int(data[4])} }`
+	if err := mgr.RegisterParser("modbus", code); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+
+	d := NewDispatcher(mgr)
+	d.BindWithValidator([]byte{0x01, 0x03}, "modbus", checksum.CRC16Modbus)
+
+	good := []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A, 0xC5, 0xCD}
+	if res, _, err := d.Ingest(good); err != nil || res["reg"] != 0 {
+		t.Fatalf("known-good frame: %v, %v", res, err)
+	}
+
+	bad := []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0B, 0xC5, 0xCD}
+	res, proto, err := d.Ingest(bad)
+	if !errors.Is(err, ErrChecksum) || proto != "modbus" || res != nil {
+		t.Fatalf("corrupt frame: got %v, %q, %v; want ErrChecksum", res, proto, err)
+	}
+
+	// A corrupt frame is not a parser fault, so the pipeline must not ask for a repair
+	if _, _, err := processFrame(context.Background(), d, nil, bad, tcpContextHint); !errors.Is(err, ErrChecksum) {
+		t.Errorf("processFrame = %v, want ErrChecksum", err)
+	}
+
+	d.Unbind("modbus")
+	d.Bind([]byte{0x01, 0x03}, "modbus")
+	if _, _, err := d.Ingest(bad); err != nil {
+		t.Errorf("validator outlived Unbind: %v", err)
+	}
+}
+
+func TestDispatcher_ResultValidatorFlagsWrongOBD2Formula(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	// Forgets the /4 of the standard RPM formula
+	wrong := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"rpm": int(data[2])*256 + int(data[3])} }`
+	if err := mgr.RegisterParser("obd_rpm", wrong); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+
+	d := NewDispatcher(mgr)
+	d.Bind([]byte{0x41, 0x0C}, "obd_rpm")
+	d.BindFamily([]byte{obd2.ResponseMode}, obd2.Family)
+
+	frame := []byte{0x41, 0x0C, 0xFF, 0xFF}
+	if _, _, err := d.Ingest(frame); err != nil {
+		t.Fatalf("without a validator the wrong value passes: %v", err)
+	}
+
+	d.SetResultValidator(obd2.Family, obd2.Validate)
+	res, proto, err := d.Ingest(frame)
+	if !errors.Is(err, ErrOutOfRange) || proto != "obd_rpm" || res != nil {
+		t.Fatalf("got %v, %q, %v; want ErrOutOfRange", res, proto, err)
+	}
+	if kind := ErrorKind(proto, err); kind != ErrorKindRange {
+		t.Errorf("ErrorKind = %q, want %q", kind, ErrorKindRange)
+	}
+
+	// Small readings stay in range even with the wrong formula, so they pass
+	if _, _, err := d.Ingest([]byte{0x41, 0x0C, 0x0B, 0xB8}); err != nil {
+		t.Errorf("in-range value flagged: %v", err)
+	}
+
+	// The fixed parser passes for the same frame
+	right := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"rpm": float64(int(data[2])*256+int(data[3])) / 4} }`
+	if err := mgr.RegisterParser("obd_rpm", right); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	if res, _, err := d.Ingest(frame); err != nil || res["rpm"] != 16383.75 {
+		t.Errorf("correct formula: %v, %v", res, err)
+	}
+}
+
+func TestDispatcher_Fallback(t *testing.T) {
+	tmpDir := t.TempDir()
+	mgr := NewParserManager(filepath.Join(tmpDir, "storage"), "")
+	code := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"val": int(data[1])} }`
+	if err := mgr.RegisterParser("ProtoA", code); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d := NewDispatcher(mgr)
+	d.Bind([]byte{0x0A}, "ProtoA")
+	unknown := []byte{0xB7, 0x00, 0x2A}
+
+	// Off (the default): unknown frames fail
+	res, proto, err := d.Ingest(unknown)
+	if !errors.Is(err, ErrUnknownProtocol) || proto != "" || res != nil {
+		t.Errorf("Ingest() without fallback = %v, %q, %v; want ErrUnknownProtocol", res, proto, err)
+	}
+
+	// On: unknown frames pass through raw, known ones are still parsed
+	d.SetFallback(true)
+	res, proto, err = d.Ingest(unknown)
+	want := map[string]interface{}{"raw": "b7002a", "length": 3, "first_byte": "0xB7"}
+	if err != nil || proto != FallbackProtocol || !reflect.DeepEqual(res, want) {
+		t.Errorf("Ingest() with fallback = %v, %q, %v; want %v", res, proto, err, want)
+	}
+	if res, proto, err := d.Ingest([]byte{0x0A, 0x07}); err != nil || proto != "ProtoA" || res["val"] != 7 {
+		t.Errorf("Ingest() of a known frame = %v, %q, %v", res, proto, err)
+	}
+
+	// The servers still try discovery first and only pass the frame through if it fails
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	disc := NewDiscoveryService(d, mgr, DiscoveryConfig{Provider: "ollama", Endpoint: server.URL, SystemPromptPath: testSystemPrompt(t)})
+	res, proto, err = processFrame(context.Background(), d, disc, unknown, tcpContextHint)
+	if err != nil || proto != FallbackProtocol || !reflect.DeepEqual(res, want) {
+		t.Errorf("processFrame() after failed discovery = %v, %q, %v; want the passthrough", res, proto, err)
+	}
+
+	d.SetFallback(false)
+	if _, _, err := d.Ingest(unknown); !errors.Is(err, ErrUnknownProtocol) {
+		t.Errorf("expected ErrUnknownProtocol after disabling the fallback, got %v", err)
+	}
+}
+
+func TestDispatcher_IngestMulti(t *testing.T) {
+	tmpDir := t.TempDir()
+	mgr := NewParserManager(tmpDir, "")
+	multi := `package dynamic
+func Parse(data []byte) []map[string]interface{} {
+	var results []map[string]interface{}
+	for i := 1; i < len(data); {
+		switch data[i] {
+		case 0x0C:
+			if i+2 >= len(data) { return results }
+			results = append(results, map[string]interface{}{"pid": "0C", "rpm": (int(data[i+1])*256 + int(data[i+2])) / 4})
+			i += 3
+		case 0x04:
+			if i+1 >= len(data) { return results }
+			results = append(results, map[string]interface{}{"pid": "04", "load": int(data[i+1]) * 100 / 255})
+			i += 2
+		default:
+			return results
+		}
+	}
+	return results
+}`
+	single := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": int(data[1])} }`
+	if err := mgr.RegisterParser("obd_multi", multi); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	if err := mgr.RegisterParser("single", single); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d := NewDispatcher(mgr)
+	d.Bind([]byte{0x41}, "obd_multi")
+	d.Bind([]byte{0x55}, "single")
+	sink := &captureSink{}
+	router := NewOutputRouter()
+	router.AddSink(sink, OutcomeSuccess)
+	d.SetOutputRouter(router)
+
+	frame := []byte{0x41, 0x0C, 0x1A, 0xF8, 0x04, 0x7F}
+	results, proto, err := d.IngestMulti(frame)
+	if err != nil || proto != "obd_multi" {
+		t.Fatalf("IngestMulti = %v, %q, %v", results, proto, err)
+	}
+	want := []map[string]interface{}{
+		{"pid": "0C", "rpm": 1726},
+		{"pid": "04", "load": 49},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("IngestMulti results = %v, want %v", results, want)
+	}
+
+	// Ingest keeps its single-result contract and returns the first PID
+	first, _, err := d.Ingest(frame)
+	if err != nil || !reflect.DeepEqual(first, want[0]) {
+		t.Errorf("Ingest = %v, %v; want %v", first, err, want[0])
+	}
+	// Sinks receive every result
+	if got := sink.outputs[0].Result[MultiResultsKey]; !reflect.DeepEqual(got, want) {
+		t.Errorf("sink got %v, want all results", sink.outputs[0].Result)
+	}
+
+	// Single-map parsers yield one result
+	results, _, err = d.IngestMulti([]byte{0x55, 0x07})
+	if err != nil || !reflect.DeepEqual(results, []map[string]interface{}{{"v": 7}}) {
+		t.Errorf("IngestMulti on a single-map parser = %v, %v", results, err)
+	}
+}
+
+func TestDispatcher_IngestPinnedValidatesOnce(t *testing.T) {
+	mgr := NewParserManager(t.TempDir(), "")
+	if err := mgr.RegisterParser("meter", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": int(data[1])} }`); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d := NewDispatcher(mgr)
+	var calls int
+	d.BindWithValidator([]byte{0x4D}, "meter", func(frame []byte) error {
+		calls++
+		return nil
+	})
+
+	if res, err := d.IngestPinned("meter", []byte{0x00, 0x07}); err != nil || res["v"] != 7 {
+		t.Fatalf("IngestPinned = %v, %v", res, err)
+	}
+	if calls != 1 {
+		t.Errorf("validator ran %d times for one pinned frame", calls)
+	}
+
+	d.SetEnabled("meter", false)
+	if _, err := d.IngestPinned("meter", []byte{0x00, 0x07}); !errors.Is(err, ErrProtocolDisabled) {
+		t.Errorf("IngestPinned on a disabled protocol = %v, want ErrProtocolDisabled", err)
+	}
+}
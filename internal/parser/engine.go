@@ -2,14 +2,25 @@ package parser
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"github.com/chuanjin/OmniBridge/internal/metrics"
+	"github.com/chuanjin/OmniBridge/internal/omni/exports"
 	"github.com/traefik/yaegi/interp"
 	"github.com/traefik/yaegi/stdlib"
+	"go.uber.org/zap"
 )
 
+// omniPackage is the key of the generated omni helper exports
+const omniPackage = "github.com/chuanjin/OmniBridge/internal/omni/omni"
+
 // symbols defines the restricted set of standard library symbols available to parsers
 var symbols = make(interp.Exports)
 
@@ -24,80 +35,335 @@ func init() {
 			symbols[pkg] = export
 		}
 	}
+
+	// OmniBridge's own types and helpers, importable from parsers as "omni"
+	omniSymbols := map[string]reflect.Value{
+		"Result": reflect.ValueOf((*Result)(nil)),
+		"Fields": reflect.ValueOf((*Fields)(nil)),
+	}
+	for name, value := range exports.Symbols[omniPackage] {
+		omniSymbols[name] = value
+	}
+	symbols["omni/omni"] = omniSymbols
 }
 
+// errExecutionTimeout is returned when a parser runs past its time limit
+var errExecutionTimeout = errors.New("EXECUTION_TIMEOUT: parser exceeded time limit")
+
+// DefaultCompileTimeout bounds how long yaegi may spend compiling a single parser.
+const DefaultCompileTimeout = 5 * time.Second
+
+// DefaultParseTimeout bounds a single parser execution unless SetParseTimeout changes it.
+const DefaultParseTimeout = 50 * time.Millisecond
+
+// ParserFunc is the default parser contract: func Parse(data []byte) map[string]interface{}
 type ParserFunc func([]byte) map[string]interface{}
 
+// ResultParserFunc is the structured parser contract: func Parse(data []byte) omni.Result
+type ResultParserFunc func([]byte) Result
+
+// MultiParserFunc is the multi-result contract: func Parse(data []byte) []map[string]interface{},
+// for frames packing several records (e.g. an OBD-II response with multiple PIDs)
+type MultiParserFunc func([]byte) []map[string]interface{}
+
+// FieldsParserFunc is the incremental contract: func Parse(data []byte, out *omni.Fields).
+// Fields set before a timeout are returned with the timeout error.
+type FieldsParserFunc func([]byte, *Fields)
+
+// compiledParser normalizes every supported contract into a map plus an optional error.
+// out receives the fields of incremental parsers as they are set; other contracts ignore it.
+type compiledParser func(data []byte, out *Fields) (map[string]interface{}, error)
+
+// cachedParser is a compiled parser with the time it was last loaded for execution
+type cachedParser struct {
+	fn       compiledParser
+	lastUsed atomic.Int64 // UnixNano; bumped under the read lock, hence atomic
+}
+
+func (c *cachedParser) touch() {
+	c.lastUsed.Store(time.Now().UnixNano())
+}
+
 type Engine struct {
-	cache map[string]ParserFunc
-	mu    sync.RWMutex
+	cache          map[string]*cachedParser
+	maxCached      int // Compiled parsers kept before evicting the least recently used, 0 for unlimited
+	compileTimeout time.Duration
+	parseTimeout   time.Duration
+	interpret      interpreter
+	stats          engineStats
+	bulkheads      map[string]*bulkhead // ProtocolID -> isolation state
+	bulkheadCfg    BulkheadConfig
+	results        *resultCache             // nil unless SetResultCache or Dispatcher.EnableResultCache enabled it
+	isolation      map[string]IsolationMode // ProtocolID -> mode, shared when absent
+	poolSize       int                      // Interpreters per pooled parser
+	usage          *symbolUsage             // nil unless TrackSymbolUsage enabled it
+	mu             sync.RWMutex
+}
+
+// engineStats holds the sandbox counters behind ResourceReport
+type engineStats struct {
+	compilations atomic.Int64
+	compileNanos atomic.Int64
+	executions   atomic.Int64
+	active       atomic.Int64
+	peakActive   atomic.Int64
+	timeouts     atomic.Int64
+	panics       atomic.Int64
+	cacheHits    atomic.Int64
+	evictions    atomic.Int64
+}
+
+// ResourceReport aggregates sandbox resource usage, useful for sizing the gateway
+type ResourceReport struct {
+	CachedParsers            int           `json:"cached_parsers"`             // Compiled parsers currently held in memory
+	Compilations             int64         `json:"compilations"`               // Successful compilations since start
+	CompileTime              time.Duration `json:"compile_time"`               // Cumulative time spent compiling (including failures)
+	Executions               int64         `json:"executions"`                 // Parser executions started
+	ActiveExecutions         int64         `json:"active_executions"`          // Executions still running, including runaway ones past their timeout
+	PeakConcurrentExecutions int64         `json:"peak_concurrent_executions"` // Highest number of simultaneous executions observed
+	Timeouts                 int64         `json:"timeouts"`                   // Executions that exceeded their time limit
+	Panics                   int64         `json:"panics"`                     // Executions that panicked
+	ResultCacheHits          int64         `json:"result_cache_hits"`          // Parses answered from the result cache
+	Evictions                int64         `json:"evictions"`                  // Compiled parsers dropped by the cache limit
 }
 
 func NewEngine() *Engine {
 	return &Engine{
-		cache: make(map[string]ParserFunc),
+		cache:          make(map[string]*cachedParser),
+		compileTimeout: DefaultCompileTimeout,
+		parseTimeout:   DefaultParseTimeout,
+		interpret:      interpret,
+		bulkheads:      make(map[string]*bulkhead),
+		bulkheadCfg:    DefaultBulkheadConfig(),
+		isolation:      make(map[string]IsolationMode),
+		poolSize:       DefaultInterpreterPoolSize,
+	}
+}
+
+// SetCompileTimeout changes the maximum time allowed for compiling a parser.
+// A non-positive value restores DefaultCompileTimeout.
+func (e *Engine) SetCompileTimeout(d time.Duration) {
+	if d <= 0 {
+		d = DefaultCompileTimeout
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.compileTimeout = d
+}
+
+// SetParseTimeout changes the time limit of every parser execution started by Execute.
+// A non-positive value restores DefaultParseTimeout.
+func (e *Engine) SetParseTimeout(d time.Duration) {
+	if d <= 0 {
+		d = DefaultParseTimeout
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.parseTimeout = d
+}
+
+// SetCacheLimit caps how many compiled parsers the engine keeps. Past the cap the least
+// recently executed parser is dropped and recompiled if it is needed again; 0 means unlimited.
+func (e *Engine) SetCacheLimit(n int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.maxCached = max(n, 0)
+	for e.maxCached > 0 && len(e.cache) > e.maxCached {
+		e.evictLocked()
 	}
 }
 
 // Execute takes raw bytes and a string of Go code (from AI) and runs it.
 // It uses a cache to avoid redundant compilation of the same code.
-// It executes with a default timeout of 50ms to prevent infinite loops.
+// It executes with a timeout (DefaultParseTimeout unless set) to prevent infinite loops;
+// the timer starts after compilation so a cold parser isn't penalized.
 func (e *Engine) Execute(id string, rawData []byte, goCode string) (map[string]interface{}, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	fn, err := e.load(id, goCode)
+	if err != nil {
+		return nil, err
+	}
+	return e.executeCompiled(id, fn, rawData)
+}
+
+// executeCompiled runs an already-loaded protocol parser like Execute, answering
+// repeated identical frames from the result cache when it is enabled
+func (e *Engine) executeCompiled(id string, fn compiledParser, rawData []byte) (map[string]interface{}, error) {
+	e.mu.RLock()
+	results, timeout := e.results, e.parseTimeout
+	e.mu.RUnlock()
+	var generation uint64
+	if results != nil {
+		res, gen, ok := results.get(id, rawData, time.Now())
+		if ok {
+			e.stats.cacheHits.Add(1)
+			return res, nil
+		}
+		generation = gen
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
-	return e.ExecuteWithContext(ctx, id, rawData, goCode)
+	res, err := e.runIsolated(ctx, id, fn, rawData)
+	if err == nil && results != nil {
+		results.put(id, rawData, res, time.Now(), generation)
+	}
+	return res, err
 }
 
 // ExecuteWithContext allows passing a custom context for execution.
 func (e *Engine) ExecuteWithContext(ctx context.Context, id string, rawData []byte, goCode string) (map[string]interface{}, error) {
+	fn, err := e.load(id, goCode)
+	if err != nil {
+		return nil, err
+	}
+	return e.runIsolated(ctx, id, fn, rawData)
+}
+
+// load returns the compiled parser for id, compiling and caching it on first use
+func (e *Engine) load(id string, goCode string) (compiledParser, error) {
 	// 1. Check if we already have a compiled version for this ID
 	e.mu.RLock()
-	fn, exists := e.cache[id]
+	entry, exists := e.cache[id]
+	if exists {
+		entry.touch()
+	}
 	e.mu.RUnlock()
 
 	if !exists {
 		// 2. Compile and cache
 		e.mu.Lock()
+		defer e.mu.Unlock()
 		// Double check after acquiring lock
-		var err error
-		if fn, exists = e.cache[id]; !exists {
-			fn, err = e.compile(goCode)
+		if entry, exists = e.cache[id]; !exists {
+			fn, err := e.compileIsolated(goCode, e.compileTimeout, e.interpretersFor(id), e.interpreterFor(id))
 			if err != nil {
-				e.mu.Unlock()
 				return nil, err
 			}
-			e.cache[id] = fn
+			entry = e.storeLocked(id, fn)
 		}
-		e.mu.Unlock()
 	}
+	return entry.fn, nil
+}
+
+// storeLocked caches a compiled parser, evicting the least recently used one if the cache is full.
+// Callers hold e.mu for writing.
+func (e *Engine) storeLocked(id string, fn compiledParser) *cachedParser {
+	if _, replacing := e.cache[id]; !replacing && e.maxCached > 0 && len(e.cache) >= e.maxCached {
+		e.evictLocked()
+	}
+	entry := &cachedParser{fn: fn}
+	entry.touch()
+	e.cache[id] = entry
+	return entry
+}
 
+// evictLocked drops the least recently used compiled parser. Callers hold e.mu for writing.
+func (e *Engine) evictLocked() {
+	var (
+		oldestID string
+		oldest   int64
+	)
+	for id, entry := range e.cache {
+		if used := entry.lastUsed.Load(); oldestID == "" || used < oldest {
+			oldestID, oldest = id, used
+		}
+	}
+	if oldestID == "" {
+		return
+	}
+	delete(e.cache, oldestID)
+	e.stats.evictions.Add(1)
+	logger.Debug("Evicted compiled parser", zap.String("protocol", oldestID))
+}
+
+// run executes a compiled parser with timeout and panic protection
+func (e *Engine) run(ctx context.Context, id string, fn compiledParser, rawData []byte) (map[string]interface{}, error) {
 	// 3. Execute with timeout protection
 	type result struct {
 		res map[string]interface{}
 		err error
 	}
 	resChan := make(chan result, 1)
+	partial := &Fields{}
+
+	start := time.Now()
+	defer func() { metrics.ObserveExecution(id, time.Since(start)) }()
+
+	e.stats.executions.Add(1)
+	e.trackActive(e.stats.active.Add(1))
 
 	go func() {
+		defer e.stats.active.Add(-1)
 		defer func() {
 			if r := recover(); r != nil {
+				e.stats.panics.Add(1)
 				resChan <- result{err: fmt.Errorf("PANIC: %v", r)}
 			}
 		}()
-		resChan <- result{res: fn(rawData)}
+		res, err := fn(rawData, partial)
+		resChan <- result{res: res, err: err}
 	}()
 
 	select {
 	case <-ctx.Done():
-		return nil, fmt.Errorf("EXECUTION_TIMEOUT: parser exceeded time limit")
+		e.stats.timeouts.Add(1)
+		// Best effort: whatever an incremental parser decoded before hanging
+		return partial.snapshot(), errExecutionTimeout
 	case r := <-resChan:
 		return r.res, r.err
 	}
 }
 
-func (e *Engine) compile(goCode string) (ParserFunc, error) {
+// compile runs the interpreter with a timeout so a pathological parser can't hang the caller.
+func (e *Engine) compile(goCode string, timeout time.Duration, run interpreter) (compiledParser, error) {
+	if err := checkUnboundedLoops(goCode); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	type result struct {
+		fn  compiledParser
+		err error
+	}
+	resChan := make(chan result, 1)
+
+	start := time.Now()
+	defer func() { e.stats.compileNanos.Add(int64(time.Since(start))) }()
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				resChan <- result{err: fmt.Errorf("COMPILE_ERROR: %v", r)}
+			}
+		}()
+		fn, err := run(goCode)
+		resChan <- result{fn: fn, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("COMPILE_TIMEOUT: compilation exceeded %v", timeout)
+	case r := <-resChan:
+		if r.err == nil {
+			e.stats.compilations.Add(1)
+		}
+		return r.fn, r.err
+	}
+}
+
+// interpreter compiles a parser's source into a compiledParser
+type interpreter func(goCode string) (compiledParser, error)
+
+func interpret(goCode string) (compiledParser, error) {
+	return interpretWith(goCode, symbols)
+}
+
+// interpretWith compiles a parser that can import exports
+func interpretWith(goCode string, exports interp.Exports) (compiledParser, error) {
 	i := interp.New(interp.Options{})
-	_ = i.Use(symbols)
+	_ = i.Use(exports)
 
 	_, err := i.Eval(goCode)
 	if err != nil {
@@ -109,29 +375,192 @@ func (e *Engine) compile(goCode string) (ParserFunc, error) {
 		return nil, fmt.Errorf("RECOVERY_ERROR: could not find Parse function: %v", err)
 	}
 
-	fn, ok := v.Interface().(func([]byte) map[string]interface{})
-	if !ok {
+	// Detect which contract the parser implements from its return type
+	switch fn := v.Interface().(type) {
+	case func([]byte) map[string]interface{}:
+		return func(data []byte, _ *Fields) (map[string]interface{}, error) {
+			return fn(data), nil
+		}, nil
+	case func([]byte) []map[string]interface{}:
+		return func(data []byte, _ *Fields) (map[string]interface{}, error) {
+			return map[string]interface{}{MultiResultsKey: fn(data)}, nil
+		}, nil
+	case func([]byte, *Fields):
+		return func(data []byte, out *Fields) (map[string]interface{}, error) {
+			fn(data, out)
+			res := out.snapshot()
+			if res == nil {
+				res = map[string]interface{}{}
+			}
+			return res, nil
+		}, nil
+	case func([]byte) Result:
+		return func(data []byte, _ *Fields) (map[string]interface{}, error) {
+			res := fn(data)
+			if res.Error != "" {
+				return res.Metrics, fmt.Errorf("PARSE_ERROR: %s", res.Error)
+			}
+			return res.Metrics, nil
+		}, nil
+	default:
 		return nil, fmt.Errorf("RECOVERY_ERROR: Parse function has wrong signature")
 	}
-
-	return fn, nil
 }
 
-// ClearCache removes cached parsers, useful if code changes
+// ClearCache removes a cached parser and its cached results, useful if code changes
 func (e *Engine) ClearCache(id string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	delete(e.cache, id)
+	if e.results != nil {
+		e.results.invalidate(id)
+	}
+}
+
+// Validate compiles code without caching it, returning the compile error if any
+func (e *Engine) Validate(goCode string) error {
+	_, err := e.compileUncached(goCode)
+	return err
+}
+
+// compileUncached compiles code with the configured timeout without touching the cache
+func (e *Engine) compileUncached(goCode string) (compiledParser, error) {
+	e.mu.RLock()
+	timeout := e.compileTimeout
+	e.mu.RUnlock()
+
+	return e.compile(goCode, timeout, e.interpret)
+}
+
+// runOnce executes a compiled parser with the configured execution timeout
+func (e *Engine) runOnce(id string, fn compiledParser, data []byte) (map[string]interface{}, error) {
+	e.mu.RLock()
+	timeout := e.parseTimeout
+	e.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return e.run(ctx, id, fn, data)
+}
+
+// ErrNonDeterministic is returned by CheckDeterminism when a parser's output changes between runs
+var ErrNonDeterministic = errors.New("non-deterministic parser output")
+
+// determinismCheckID labels the executions made by CheckDeterminism in metrics
+const determinismCheckID = "determinism_check"
+
+// CheckDeterminism compiles code and runs it twice on sample, returning ErrNonDeterministic if the
+// results differ (e.g. the parser reads time.Now() or a random source). Each run is bounded by
+// the parse timeout; a run that exceeds it fails the check.
+func (e *Engine) CheckDeterminism(goCode string, sample []byte) error {
+	fn, err := e.compileUncached(goCode)
+	if err != nil {
+		return err
+	}
+	return e.checkDeterminism(fn, sample)
+}
+
+// checkDeterminism is CheckDeterminism for an already compiled parser
+func (e *Engine) checkDeterminism(fn compiledParser, sample []byte) error {
+	first, firstErr := e.runOnce(determinismCheckID, fn, sample)
+	if errors.Is(firstErr, errExecutionTimeout) {
+		return firstErr
+	}
+	second, secondErr := e.runOnce(determinismCheckID, fn, sample)
+
+	if fmt.Sprint(firstErr) != fmt.Sprint(secondErr) {
+		return fmt.Errorf("%w: errors differ between runs (%v vs %v)", ErrNonDeterministic, firstErr, secondErr)
+	}
+	if !reflect.DeepEqual(first, second) {
+		return fmt.Errorf("%w: %v vs %v", ErrNonDeterministic, first, second)
+	}
+	return nil
 }
 
 // CompileAndCache pre-compiles code for an ID
 func (e *Engine) CompileAndCache(id string, goCode string) error {
-	fn, err := e.compile(goCode)
+	e.mu.RLock()
+	timeout := e.compileTimeout
+	instances := e.interpretersFor(id)
+	run := e.interpreterFor(id)
+	e.mu.RUnlock()
+
+	fn, err := e.compileIsolated(goCode, timeout, instances, run)
 	if err != nil {
 		return err
 	}
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	e.cache[id] = fn
+	e.storeLocked(id, fn)
 	return nil
 }
+
+// WarmCache compiles the given parsers (ProtocolID -> GoCode) concurrently with a bounded
+// worker pool so the first frame of each protocol doesn't pay for compilation.
+// A bad parser doesn't stop the others; all failures are returned joined together.
+func (e *Engine) WarmCache(parsers map[string]string) error {
+	type job struct{ id, code string }
+	jobs := make(chan job)
+	var (
+		wg       sync.WaitGroup
+		errsMu   sync.Mutex
+		errs     []error
+		compiled atomic.Int64
+	)
+
+	workers := min(runtime.GOMAXPROCS(0), len(parsers))
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if err := e.CompileAndCache(j.id, j.code); err != nil {
+					errsMu.Lock()
+					errs = append(errs, fmt.Errorf("%s: %w", j.id, err))
+					errsMu.Unlock()
+					continue
+				}
+				compiled.Add(1)
+			}
+		}()
+	}
+	for id, code := range parsers {
+		jobs <- job{id, code}
+	}
+	close(jobs)
+	wg.Wait()
+
+	logger.Info("Parser cache warmed",
+		zap.Int64("compiled", compiled.Load()), zap.Int("failed", len(errs)), zap.Int("workers", workers))
+	return errors.Join(errs...)
+}
+
+// ResourceReport returns a snapshot of the sandbox resource counters
+func (e *Engine) ResourceReport() ResourceReport {
+	e.mu.RLock()
+	cached := len(e.cache)
+	e.mu.RUnlock()
+
+	return ResourceReport{
+		CachedParsers:            cached,
+		Compilations:             e.stats.compilations.Load(),
+		CompileTime:              time.Duration(e.stats.compileNanos.Load()),
+		Executions:               e.stats.executions.Load(),
+		ActiveExecutions:         e.stats.active.Load(),
+		PeakConcurrentExecutions: e.stats.peakActive.Load(),
+		Timeouts:                 e.stats.timeouts.Load(),
+		Panics:                   e.stats.panics.Load(),
+		ResultCacheHits:          e.stats.cacheHits.Load(),
+		Evictions:                e.stats.evictions.Load(),
+	}
+}
+
+// trackActive raises the peak concurrency watermark if current exceeds it
+func (e *Engine) trackActive(current int64) {
+	for {
+		peak := e.stats.peakActive.Load()
+		if current <= peak || e.stats.peakActive.CompareAndSwap(peak, current) {
+			return
+		}
+	}
+}
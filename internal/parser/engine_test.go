@@ -1,9 +1,14 @@
 package parser
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestEngine_Execute_UniversalService01(t *testing.T) {
@@ -138,11 +143,12 @@ func Parse(data []byte) map[string]interface{} {
 
 func TestEngine_Execute_Timeout(t *testing.T) {
 	e := NewEngine()
-	// Infinite loop code
+	// Infinite loop the static check can't prove unbounded
 	code := `package dynamic
 func Parse(data []byte) map[string]interface{} {
-	for {
+	for len(data) > 0 {
 	}
+	return nil
 }`
 	_, err := e.Execute("timeout_test", []byte{0x00}, code)
 	if err == nil {
@@ -153,6 +159,85 @@ func Parse(data []byte) map[string]interface{} {
 	}
 }
 
+func TestEngine_Execute_ParseTimeout(t *testing.T) {
+	// Sleeps data[0] milliseconds
+	code := `package dynamic
+
+import "time"
+
+func Parse(data []byte) map[string]interface{} {
+	time.Sleep(time.Duration(data[0]) * time.Millisecond)
+	return map[string]interface{}{"slept": int(data[0])}
+}`
+	tests := []struct {
+		name        string
+		timeout     time.Duration
+		sleep       byte
+		wantTimeout bool
+	}{
+		{"default 50ms", 0, 100, true},
+		{"raised", time.Second, 100, false},
+		{"lowered", 5 * time.Millisecond, 45, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEngine()
+			if tt.timeout > 0 {
+				e.SetParseTimeout(tt.timeout)
+			}
+			res, err := e.Execute("parse_timeout_test", []byte{tt.sleep}, code)
+			if tt.wantTimeout {
+				if !errors.Is(err, errExecutionTimeout) {
+					t.Errorf("expected timeout error, got %v, %v", res, err)
+				}
+				return
+			}
+			if err != nil || res["slept"] != int(tt.sleep) {
+				t.Errorf("Execute = %v, %v", res, err)
+			}
+		})
+	}
+}
+
+func TestEngine_Execute_TimeoutKeepsPartialFields(t *testing.T) {
+	e := NewEngine()
+	// Decodes one field, then hangs before the second
+	code := `package dynamic
+
+import (
+	"omni"
+	"time"
+)
+
+func Parse(data []byte, out *omni.Fields) {
+	out.Set("rpm", int(data[0]))
+	if len(data) > 1 {
+		time.Sleep(300 * time.Millisecond)
+	}
+	out.Set("speed", 88)
+}`
+	res, err := e.Execute("partial_test", []byte{0x2A, 0xFF}, code)
+	if !errors.Is(err, errExecutionTimeout) {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if res["rpm"] != 42 {
+		t.Errorf("expected the partial rpm field alongside the timeout, got %v", res)
+	}
+	if _, ok := res["speed"]; ok {
+		t.Errorf("fields set after the timeout must not be returned, got %v", res)
+	}
+
+	// A run that finishes returns every field
+	res, err = e.Execute("partial_test", []byte{0x2A}, code)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(res, map[string]interface{}{"rpm": 42, "speed": 88}) {
+		t.Errorf("unexpected result: %v", res)
+	}
+}
+
 func TestEngine_Execute_Panic(t *testing.T) {
 	e := NewEngine()
 	// Code that panics
@@ -170,6 +255,145 @@ func Parse(data []byte) map[string]interface{} {
 	}
 }
 
+func TestEngine_Execute_CompileTimeout(t *testing.T) {
+	e := NewEngine()
+	e.SetCompileTimeout(20 * time.Millisecond)
+	// Simulate a pathological parser that makes the interpreter hang
+	e.interpret = func(goCode string) (compiledParser, error) {
+		time.Sleep(500 * time.Millisecond)
+		return nil, nil
+	}
+
+	start := time.Now()
+	_, err := e.Execute("slow_compile", []byte{0x00}, "package dynamic")
+	if err == nil {
+		t.Fatal("expected compile timeout error, got nil")
+	}
+	if !strings.HasPrefix(err.Error(), "COMPILE_TIMEOUT:") {
+		t.Errorf("expected compile timeout error message, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("compile timeout fired too late: %v", elapsed)
+	}
+
+	// A failed compilation must not be cached
+	e.interpret = interpret
+	if _, err := e.Execute("slow_compile", []byte{0x01}, `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"ok": true} }`); err != nil {
+		t.Errorf("expected successful compile after timeout, got: %v", err)
+	}
+}
+
+func TestEngine_Execute_ResultSignature(t *testing.T) {
+	e := NewEngine()
+
+	legacy := `package dynamic
+func Parse(data []byte) map[string]interface{} {
+	return map[string]interface{}{"rpm": int(data[1]) * 100}
+}`
+	structured := `package dynamic
+import "omni"
+func Parse(data []byte) omni.Result {
+	if len(data) < 2 {
+		return omni.Result{Error: "frame too short"}
+	}
+	return omni.Result{Metrics: map[string]interface{}{"rpm": int(data[1]) * 100, "unit": "rpm"}}
+}`
+
+	got, err := e.Execute("legacy", []byte{0x01, 0x10}, legacy)
+	if err != nil {
+		t.Fatalf("legacy signature failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, map[string]interface{}{"rpm": 1600}) {
+		t.Errorf("legacy signature = %v", got)
+	}
+
+	got, err = e.Execute("structured", []byte{0x01, 0x10}, structured)
+	if err != nil {
+		t.Fatalf("Result signature failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, map[string]interface{}{"rpm": 1600, "unit": "rpm"}) {
+		t.Errorf("Result signature = %v", got)
+	}
+
+	_, err = e.Execute("structured", []byte{0x01}, structured)
+	if err == nil || err.Error() != "PARSE_ERROR: frame too short" {
+		t.Errorf("expected Result.Error surfaced as error, got: %v", err)
+	}
+}
+
+func TestEngine_ResourceReport(t *testing.T) {
+	e := NewEngine()
+
+	ok := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"val": int(data[0])} }`
+	slow := `package dynamic
+import "time"
+func Parse(data []byte) map[string]interface{} {
+	time.Sleep(20 * time.Millisecond)
+	return nil
+}`
+	// Sleeps rather than spins, so its leftover goroutine doesn't starve the parsers of later tests
+	hang := `package dynamic
+import "time"
+func Parse(data []byte) map[string]interface{} {
+	time.Sleep(time.Second)
+	return nil
+}`
+	panics := `package dynamic
+func Parse(data []byte) map[string]interface{} {
+	var s []int
+	return map[string]interface{}{"val": s[0]}
+}`
+
+	for i := 0; i < 3; i++ {
+		if _, err := e.Execute("ok", []byte{0x01}, ok); err != nil {
+			t.Fatalf("ok parser failed: %v", err)
+		}
+	}
+
+	// Run the slow parser concurrently to raise the peak
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = e.Execute("slow", []byte{0x01}, slow)
+		}()
+	}
+	wg.Wait()
+
+	_, _ = e.Execute("hang", []byte{0x01}, hang)
+	_, _ = e.Execute("panics", []byte{0x01}, panics)
+
+	report := e.ResourceReport()
+	if report.CachedParsers != 4 {
+		t.Errorf("CachedParsers = %d, want 4", report.CachedParsers)
+	}
+	if report.Compilations != 4 {
+		t.Errorf("Compilations = %d, want 4", report.Compilations)
+	}
+	if report.CompileTime <= 0 {
+		t.Error("expected cumulative compile time to be recorded")
+	}
+	if report.Executions != 9 {
+		t.Errorf("Executions = %d, want 9", report.Executions)
+	}
+	if report.PeakConcurrentExecutions < 2 {
+		t.Errorf("PeakConcurrentExecutions = %d, want at least 2", report.PeakConcurrentExecutions)
+	}
+	if report.Timeouts != 1 {
+		t.Errorf("Timeouts = %d, want 1", report.Timeouts)
+	}
+	if report.Panics != 1 {
+		t.Errorf("Panics = %d, want 1", report.Panics)
+	}
+	// The runaway parser keeps its goroutine busy past the timeout
+	if report.ActiveExecutions < 1 {
+		t.Errorf("ActiveExecutions = %d, want the runaway parser counted", report.ActiveExecutions)
+	}
+}
+
 func BenchmarkExecute_Uncached(b *testing.B) {
 	e := NewEngine()
 	code := `package dynamic
@@ -198,3 +422,375 @@ func Parse(data []byte) map[string]interface{} {
 		_, _ = e.Execute("fixed_id", data, code)
 	}
 }
+
+func TestEngine_Execute_FlagGatedFields(t *testing.T) {
+	e := NewEngine()
+
+	// Frame: [0x7E] [flags] [temp] [humidity?]
+	// flags bit 0 gates the optional humidity byte, bits 4-5 carry the sensor mode
+	code := `package dynamic
+import "omni"
+func Parse(data []byte) map[string]interface{} {
+	if len(data) < 3 { return nil }
+	flags := uint64(data[1])
+	res := map[string]interface{}{
+		"temp": int(data[2]),
+		"mode": int(omni.Bits(flags, 4, 2)),
+	}
+	if omni.Bit(flags, 0) {
+		if len(data) < 4 { return nil }
+		res["humidity"] = int(data[3])
+	}
+	return res
+}`
+
+	got, err := e.Execute("flags", []byte{0x7E, 0x21, 0x19, 0x37}, code)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	want := map[string]interface{}{"temp": 25, "mode": 2, "humidity": 55}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("with flag set = %v, want %v", got, want)
+	}
+
+	got, err = e.Execute("flags", []byte{0x7E, 0x10, 0x19}, code)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	want = map[string]interface{}{"temp": 25, "mode": 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("with flag clear = %v, want %v", got, want)
+	}
+}
+
+func TestEngine_WarmCache(t *testing.T) {
+	e := NewEngine()
+	parsers := map[string]string{"broken": `package dynamic
+func Parse(data []byte) map[string]interface{} { return undefinedSymbol }`}
+	for i := 0; i < 8; i++ {
+		parsers[fmt.Sprintf("proto_%d", i)] = fmt.Sprintf(`package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"id": %d} }`, i)
+	}
+
+	err := e.WarmCache(parsers)
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("expected error naming the broken parser, got: %v", err)
+	}
+	if got := e.ResourceReport().CachedParsers; got != 8 {
+		t.Errorf("CachedParsers = %d, want 8", got)
+	}
+
+	// Warmed parsers must be served from cache, regardless of the code passed in
+	res, err := e.Execute("proto_3", []byte{0x00}, "")
+	if err != nil {
+		t.Fatalf("Execute on warmed parser failed: %v", err)
+	}
+	if res["id"] != 3 {
+		t.Errorf("proto_3 = %v", res)
+	}
+}
+
+func TestEngine_CacheLimitEvictsLeastRecentlyUsed(t *testing.T) {
+	e := NewEngine()
+	e.SetCacheLimit(2)
+	code := func(id int) string {
+		return fmt.Sprintf(`package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"id": %d} }`, id)
+	}
+	execute := func(id int) {
+		t.Helper()
+		res, err := e.Execute(fmt.Sprintf("proto_%d", id), []byte{0x00}, code(id))
+		if err != nil || res["id"] != id {
+			t.Fatalf("proto_%d = %v, %v", id, res, err)
+		}
+		time.Sleep(time.Millisecond) // Keep last-use timestamps distinct
+	}
+
+	execute(1)
+	execute(2)
+	execute(1) // proto_2 is now the least recently used
+	execute(3)
+
+	report := e.ResourceReport()
+	if report.CachedParsers != 2 || report.Evictions != 1 || report.Compilations != 3 {
+		t.Fatalf("after exceeding the cap: %+v", report)
+	}
+	e.mu.RLock()
+	_, cached := e.cache["proto_2"]
+	e.mu.RUnlock()
+	if cached {
+		t.Error("expected proto_2 to be evicted")
+	}
+
+	// An evicted parser is recompiled on its next use, pushing out the next oldest
+	execute(2)
+	if report = e.ResourceReport(); report.Compilations != 4 || report.Evictions != 2 {
+		t.Errorf("after reusing the evicted parser: %+v", report)
+	}
+	e.mu.RLock()
+	_, cached = e.cache["proto_1"]
+	e.mu.RUnlock()
+	if cached {
+		t.Error("expected proto_1 to be evicted next")
+	}
+}
+
+func TestEngine_Execute_OmniHelpers(t *testing.T) {
+	e := NewEngine()
+
+	// OBD-II RPM: ((A*256)+B)/4, decoded with the omni helpers instead of hand-rolled shifts
+	code := `package dynamic
+import "omni"
+func Parse(data []byte) map[string]interface{} {
+	return map[string]interface{}{
+		"rpm":     float64(omni.U16BE(data, 2)) / 4,
+		"missing": omni.Byte(data, 10),
+	}
+}`
+
+	got, err := e.Execute("omni_rpm", []byte{0x41, 0x0C, 0x1A, 0xF8}, code)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	want := map[string]interface{}{"rpm": 1726.0, "missing": 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("omni helpers = %v, want %v", got, want)
+	}
+}
+
+func TestEngine_Execute_BCDFields(t *testing.T) {
+	e := NewEngine()
+
+	// Meter reading 12 34 (packed), an M-Bus style little-endian counter and unpacked digits
+	code := `package dynamic
+import "omni"
+func Parse(data []byte) map[string]interface{} {
+	return map[string]interface{}{
+		"reading": omni.BCD(data, 1, 2),
+		"counter": omni.BCDLE(data, 3, 2),
+		"digits":  omni.UnpackedBCD(data, 5, 3),
+	}
+}`
+
+	got, err := e.Execute("omni_bcd", []byte{0x68, 0x12, 0x34, 0x78, 0x56, 0x01, 0x02, 0x03}, code)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	want := map[string]interface{}{"reading": 1234, "counter": 5678, "digits": 123}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BCD helpers = %v, want %v", got, want)
+	}
+}
+
+func TestEngine_Execute_SignedFields(t *testing.T) {
+	e := NewEngine()
+
+	// Coolant temperature 0xC8 (-56) and a 16-bit offset 0xFF38 (-200)
+	code := `package dynamic
+import "omni"
+func Parse(data []byte) map[string]interface{} {
+	return map[string]interface{}{
+		"temp_c": omni.I8(data, 1),
+		"offset": omni.I16BE(data, 2),
+		"raw":    omni.Byte(data, 1),
+	}
+}`
+
+	got, err := e.Execute("omni_signed", []byte{0x05, 0xC8, 0xFF, 0x38}, code)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	want := map[string]interface{}{"temp_c": -56, "offset": -200, "raw": 200}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("signed fields = %v, want %v", got, want)
+	}
+	if suspects := SuspectSignErrors(got); len(suspects) != 0 {
+		t.Errorf("Correctly signed result flagged: %v", suspects)
+	}
+}
+
+func TestEngine_CheckDeterminism(t *testing.T) {
+	e := NewEngine()
+
+	clockParser := `package dynamic
+import "time"
+func Parse(data []byte) map[string]interface{} {
+	return map[string]interface{}{"len": len(data), "ts": time.Now().UnixNano()}
+}`
+	err := e.CheckDeterminism(clockParser, []byte{0x01, 0x02})
+	if !errors.Is(err, ErrNonDeterministic) {
+		t.Errorf("Expected time.Now() parser to be flagged non-deterministic, got %v", err)
+	}
+
+	pureParser := `package dynamic
+func Parse(data []byte) map[string]interface{} {
+	return map[string]interface{}{"len": len(data), "first": int(data[0])}
+}`
+	if err := e.CheckDeterminism(pureParser, []byte{0x01, 0x02}); err != nil {
+		t.Errorf("Expected pure parser to pass, got %v", err)
+	}
+
+	if err := e.CheckDeterminism("package dynamic\nfunc Parse(", []byte{0x01}); err == nil || errors.Is(err, ErrNonDeterministic) {
+		t.Errorf("Expected compile error, got %v", err)
+	}
+
+	e.SetParseTimeout(20 * time.Millisecond)
+	sleepParser := `package dynamic
+import "time"
+func Parse(data []byte) map[string]interface{} {
+	time.Sleep(200 * time.Millisecond)
+	return map[string]interface{}{"len": len(data)}
+}`
+	if err := e.CheckDeterminism(sleepParser, []byte{0x01}); err == nil || errors.Is(err, ErrNonDeterministic) {
+		t.Errorf("Expected the parse timeout to bound the check, got %v", err)
+	}
+}
+
+func TestEngine_RejectsUnboundedLoop(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		wantErr bool
+	}{
+		{
+			name: "infinite for",
+			code: `package dynamic
+func Parse(data []byte) map[string]interface{} {
+	n := 0
+	for {
+		n++
+	}
+}`,
+			wantErr: true,
+		},
+		{
+			name: "break only leaves inner switch",
+			code: `package dynamic
+func Parse(data []byte) map[string]interface{} {
+	for {
+		switch len(data) {
+		case 0:
+			break
+		}
+	}
+}`,
+			wantErr: true,
+		},
+		{
+			name: "bounded range",
+			code: `package dynamic
+func Parse(data []byte) map[string]interface{} {
+	sum := 0
+	for i := range data {
+		sum += int(data[i])
+	}
+	return map[string]interface{}{"sum": sum}
+}`,
+		},
+		{
+			name: "conditional loop",
+			code: `package dynamic
+func Parse(data []byte) map[string]interface{} {
+	i := 0
+	for {
+		if i >= len(data) || data[i] == 0 {
+			break
+		}
+		i++
+	}
+	return map[string]interface{}{"len": i}
+}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEngine()
+			_, err := e.Execute(tt.name, []byte{0x01, 0x02, 0x00}, tt.code)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "infinite loop") {
+					t.Errorf("expected infinite loop error, got %v", err)
+				}
+				if report := e.ResourceReport(); report.Executions != 0 || report.Compilations != 0 {
+					t.Errorf("rejected parser reached the interpreter: %+v", report)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+// Run with -race: a stateful parser must never have its interpreter shared between goroutines
+func TestEngine_PooledIsolation_StatefulParser(t *testing.T) {
+	dir := t.TempDir()
+	mgr := NewParserManager(dir, "")
+	counter := `package dynamic
+var calls int
+var last []byte
+func Parse(data []byte) map[string]interface{} {
+	calls++
+	last = append(last[:0], data...)
+	return map[string]interface{}{"calls": calls, "last": int(last[0])}
+}`
+	if err := mgr.RegisterParserWithMeta("counter", counter, ParserMeta{Isolation: string(IsolationPooled)}); err != nil {
+		t.Fatalf("RegisterParserWithMeta failed: %v", err)
+	}
+	e := mgr.GetEngine()
+	e.SetInterpreterPoolSize(3)
+
+	const workers, perWorker = 8, 25
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		counts = make(map[int]int) // "calls" value -> times observed
+	)
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perWorker {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				res, err := e.ExecuteWithContext(ctx, "counter", []byte{byte(w)}, counter)
+				cancel()
+				if err != nil {
+					t.Errorf("execution failed: %v", err)
+					return
+				}
+				if res["last"] != w {
+					t.Errorf("worker %d saw another execution's state: %v", w, res)
+				}
+				mu.Lock()
+				counts[res["calls"].(int)]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Each interpreter counts its own calls, so no count can be seen more often than the pool size
+	total := 0
+	for calls, n := range counts {
+		if n > 3 {
+			t.Errorf("calls=%d observed %d times with 3 interpreters", calls, n)
+		}
+		total += n
+	}
+	if total != workers*perWorker {
+		t.Errorf("got %d results, want %d", total, workers*perWorker)
+	}
+
+	// The mode survives a restart through the metadata sidecar
+	reloaded := NewParserManager(dir, "")
+	if _, err := reloaded.LoadSavedParsers(); err != nil {
+		t.Fatalf("LoadSavedParsers failed: %v", err)
+	}
+	if n := reloaded.GetEngine().interpretersFor("counter"); n != DefaultInterpreterPoolSize {
+		t.Errorf("reloaded parser uses %d interpreters, want %d", n, DefaultInterpreterPoolSize)
+	}
+	if err := reloaded.SetParserIsolation("counter", "threaded"); err == nil {
+		t.Error("expected an unknown isolation mode to be rejected")
+	}
+}
@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Environment variables read by LoadConfigFromEnv
+const (
+	EnvProvider    = "OMNI_PROVIDER"
+	EnvModel       = "OMNI_MODEL"
+	EnvEndpoint    = "OMNI_ENDPOINT"
+	EnvAPIKey      = "OMNI_API_KEY"
+	EnvMaxRetries  = "OMNI_MAX_RETRIES"
+	EnvRetryDelay  = "OMNI_RETRY_DELAY" // A Go duration, e.g. "2s"
+	EnvPrivacyMode = "OMNI_PRIVACY_MODE"
+)
+
+// LoadConfigFromEnv builds a DiscoveryConfig from the OMNI_* environment variables.
+// Unset variables leave their field zero for flags or defaults to fill; invalid ones are
+// logged and ignored. Without OMNI_API_KEY the key comes from the provider's own
+// variable (see APIKeyEnv).
+func LoadConfigFromEnv() DiscoveryConfig {
+	cfg := DiscoveryConfig{
+		Provider: os.Getenv(EnvProvider),
+		Model:    os.Getenv(EnvModel),
+		Endpoint: os.Getenv(EnvEndpoint),
+		ApiKey:   os.Getenv(EnvAPIKey),
+	}
+	if cfg.ApiKey == "" {
+		cfg.ApiKey = ProviderAPIKey(cfg.Provider)
+	}
+
+	if v := os.Getenv(EnvMaxRetries); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MaxRetries = n
+		} else {
+			logger.Warn("Ignoring invalid environment variable", zap.String("name", EnvMaxRetries), zap.String("value", v))
+		}
+	}
+	if v := os.Getenv(EnvRetryDelay); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.RetryDelay = d
+		} else {
+			logger.Warn("Ignoring invalid environment variable", zap.String("name", EnvRetryDelay), zap.String("value", v))
+		}
+	}
+	if v := os.Getenv(EnvPrivacyMode); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.PrivacyMode = b
+		} else {
+			logger.Warn("Ignoring invalid environment variable", zap.String("name", EnvPrivacyMode), zap.String("value", v))
+		}
+	}
+	return cfg
+}
+
+// APIKeyEnv returns the conventional API key variable of a provider (GEMINI_API_KEY for
+// gemini, the default), or "" for providers that need none
+func APIKeyEnv(provider string) string {
+	switch provider {
+	case "ollama", "exec":
+		return ""
+	case "openai":
+		return "OPENAI_API_KEY"
+	case "anthropic":
+		return "ANTHROPIC_API_KEY"
+	default:
+		return "GEMINI_API_KEY"
+	}
+}
+
+// ProviderAPIKey reads the provider's conventional API key variable
+func ProviderAPIKey(provider string) string {
+	if name := APIKeyEnv(provider); name != "" {
+		return os.Getenv(name)
+	}
+	return ""
+}
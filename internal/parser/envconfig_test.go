@@ -0,0 +1,49 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	t.Setenv(EnvProvider, "openai")
+	t.Setenv(EnvModel, "local-vllm")
+	t.Setenv(EnvEndpoint, "http://vllm:8000/v1")
+	t.Setenv(EnvAPIKey, "")
+	t.Setenv("OPENAI_API_KEY", "sk-openai")
+	t.Setenv(EnvMaxRetries, "4")
+	t.Setenv(EnvRetryDelay, "250ms")
+	t.Setenv(EnvPrivacyMode, "true")
+
+	want := DiscoveryConfig{
+		Provider:    "openai",
+		Model:       "local-vllm",
+		Endpoint:    "http://vllm:8000/v1",
+		ApiKey:      "sk-openai",
+		MaxRetries:  4,
+		RetryDelay:  250 * time.Millisecond,
+		PrivacyMode: true,
+	}
+	if got := LoadConfigFromEnv(); !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadConfigFromEnv() = %+v, want %+v", got, want)
+	}
+
+	// OMNI_API_KEY wins over the provider's own variable
+	t.Setenv(EnvAPIKey, "omni-key")
+	if got := LoadConfigFromEnv(); got.ApiKey != "omni-key" {
+		t.Errorf("expected OMNI_API_KEY to be used, got %q", got.ApiKey)
+	}
+
+	// Without a provider the Gemini key applies; invalid values are ignored
+	t.Setenv(EnvProvider, "")
+	t.Setenv(EnvAPIKey, "")
+	t.Setenv("GEMINI_API_KEY", "gemini-key")
+	t.Setenv(EnvMaxRetries, "lots")
+	t.Setenv(EnvRetryDelay, "soon")
+	t.Setenv(EnvPrivacyMode, "maybe")
+	got := LoadConfigFromEnv()
+	if got.ApiKey != "gemini-key" || got.MaxRetries != 0 || got.RetryDelay != 0 || got.PrivacyMode {
+		t.Errorf("unexpected config from invalid variables: %+v", got)
+	}
+}
@@ -0,0 +1,49 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+)
+
+// Error kinds reported by ErrorKind, so callers (agents, APIs) can pick a remedy
+const (
+	ErrorKindUnknown    = "unknown"    // No protocol matches the signature; discover it
+	ErrorKindCompile    = "compile"    // The parser doesn't compile; repair it
+	ErrorKindTimeout    = "timeout"    // The parser ran past its time limit; repair it
+	ErrorKindPanic      = "panic"      // The parser panicked on this frame; repair it
+	ErrorKindDisabled   = "disabled"   // The protocol is switched off
+	ErrorKindChecksum   = "checksum"   // The frame is corrupt; the parser is fine
+	ErrorKindRange      = "range"      // The parser produced impossible values; repair it
+	ErrorKindOverloaded = "overloaded" // Bulkhead full or circuit open; retry later
+	ErrorKindParse      = "parse"      // Any other parser failure
+)
+
+// ErrorKind classifies an ingest error for the protocol it matched ("" if none)
+func ErrorKind(protocolID string, err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case protocolID == "":
+		return ErrorKindUnknown
+	case errors.Is(err, ErrProtocolDisabled):
+		return ErrorKindDisabled
+	case errors.Is(err, ErrChecksum):
+		return ErrorKindChecksum
+	case errors.Is(err, ErrOutOfRange):
+		return ErrorKindRange
+	case errors.Is(err, errExecutionTimeout):
+		return ErrorKindTimeout
+	case errors.Is(err, ErrCircuitOpen), errors.Is(err, ErrBulkheadFull):
+		return ErrorKindOverloaded
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "COMPILE_"):
+		return ErrorKindCompile
+	case strings.HasPrefix(msg, "PANIC:"):
+		return ErrorKindPanic
+	default:
+		return ErrorKindParse
+	}
+}
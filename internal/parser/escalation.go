@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"github.com/chuanjin/OmniBridge/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// Escalation describes a signature that could not be learned even after all LLM retries
+type Escalation struct {
+	Signature string    `json:"signature"` // Hex signature that failed discovery
+	Failures  int       `json:"failures"`  // Consecutive failed discoveries for this signature
+	Error     string    `json:"error"`     // Last discovery error
+	Time      time.Time `json:"time"`
+}
+
+// EscalationHook is invoked when fail-closed mode gives up on a signature
+type EscalationHook func(Escalation)
+
+// AddEscalationHook registers a hook fired on every escalation (only in fail-closed mode)
+func (s *DiscoveryService) AddEscalationHook(h EscalationHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.escalationHooks = append(s.escalationHooks, h)
+}
+
+// Escalated returns the signatures currently escalated, sorted
+func (s *DiscoveryService) Escalated() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sigs := make([]string, 0, len(s.escalated))
+	for sig := range s.escalated {
+		sigs = append(sigs, sig)
+	}
+	sort.Strings(sigs)
+	return sigs
+}
+
+// Healthy reports false while fail-closed mode has an outstanding escalation
+func (s *DiscoveryService) Healthy() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.Config.FailClosed || len(s.escalated) == 0
+}
+
+// recordDiscoveryOutcome tracks consecutive failures per signature and escalates once the
+// configured threshold is reached. A later successful discovery clears the escalation.
+func (s *DiscoveryService) recordDiscoveryOutcome(signature []byte, err error) {
+	key := fmt.Sprintf("%X", signature)
+
+	s.mu.Lock()
+	if err == nil {
+		delete(s.failures, key)
+		delete(s.escalated, key)
+		s.mu.Unlock()
+		return
+	}
+	// A cancelled discovery says nothing about the signature
+	if !s.Config.FailClosed || errors.Is(err, context.Canceled) {
+		s.mu.Unlock()
+		return
+	}
+
+	s.failures[key]++
+	threshold := s.Config.EscalateAfter
+	if threshold <= 0 {
+		threshold = 1
+	}
+	if s.failures[key] < threshold {
+		s.mu.Unlock()
+		return
+	}
+
+	esc := Escalation{Signature: key, Failures: s.failures[key], Error: err.Error(), Time: time.Now()}
+	s.escalated[key] = esc
+	hooks := append([]EscalationHook(nil), s.escalationHooks...)
+	s.mu.Unlock()
+
+	metrics.IncEscalation()
+	logger.Error("Discovery escalated (fail-closed)", zap.String("signature", key), zap.Int("failures", esc.Failures), zap.Error(err))
+	for _, h := range hooks {
+		h(esc)
+	}
+}
+
+// WebhookEscalation returns a hook that POSTs each escalation as JSON to url
+func WebhookEscalation(url string, client *http.Client) EscalationHook {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return func(esc Escalation) {
+		body, _ := json.Marshal(esc)
+		resp, err := client.Post(url, "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			logger.Error("Escalation webhook failed", zap.String("url", url), zap.Error(err))
+			return
+		}
+		if err := resp.Body.Close(); err != nil {
+			logger.Error("Failed to close response body", zap.Error(err))
+		}
+		if resp.StatusCode >= 300 {
+			logger.Error("Escalation webhook rejected", zap.String("url", url), zap.Int("status", resp.StatusCode))
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDiscoveryService_FailClosedEscalation(t *testing.T) {
+	// LLM that never succeeds
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer llm.Close()
+
+	// Webhook receiver
+	received := make(chan Escalation, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var esc Escalation
+		if err := json.NewDecoder(r.Body).Decode(&esc); err != nil {
+			t.Errorf("Failed to decode escalation: %v", err)
+		}
+		received <- esc
+	}))
+	defer webhook.Close()
+
+	service, _, _ := newTestDiscovery(t, DiscoveryConfig{
+		Provider:      "ollama",
+		Endpoint:      llm.URL,
+		MaxRetries:    2,
+		RetryDelay:    time.Millisecond,
+		FailClosed:    true,
+		EscalateAfter: 2,
+	})
+
+	var hookCalls []Escalation
+	service.AddEscalationHook(func(esc Escalation) { hookCalls = append(hookCalls, esc) })
+	service.AddEscalationHook(WebhookEscalation(webhook.URL, nil))
+
+	sample := []byte{0x7E, 0x01, 0x02}
+
+	// First exhausted discovery stays below the threshold
+	if _, err := service.DiscoverNewProtocol(sample, nil, "test"); err == nil {
+		t.Fatal("Expected discovery to fail")
+	}
+	if len(hookCalls) != 0 || !service.Healthy() {
+		t.Fatal("Expected no escalation before EscalateAfter failures")
+	}
+
+	// Second exhausted discovery escalates
+	if _, err := service.DiscoverNewProtocol(sample, nil, "test"); err == nil {
+		t.Fatal("Expected discovery to fail")
+	}
+	if len(hookCalls) != 1 {
+		t.Fatalf("Expected escalation hook to fire once, got %d", len(hookCalls))
+	}
+	if hookCalls[0].Signature != "7E" || hookCalls[0].Failures != 2 {
+		t.Errorf("Unexpected escalation: %+v", hookCalls[0])
+	}
+	if service.Healthy() {
+		t.Error("Expected service to report unhealthy after escalation")
+	}
+	if got := service.Escalated(); len(got) != 1 || got[0] != "7E" {
+		t.Errorf("Expected 7E escalated, got %v", got)
+	}
+
+	select {
+	case esc := <-received:
+		if esc.Signature != "7E" {
+			t.Errorf("Webhook received wrong signature: %s", esc.Signature)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Expected webhook to be called")
+	}
+}
+
+func TestDiscoveryService_FailOpenByDefault(t *testing.T) {
+	tempDir, _ := os.MkdirTemp("", "omnibridge_failopen_test")
+	defer func() { _ = os.RemoveAll(tempDir) }()
+
+	manager := NewParserManager(tempDir, "")
+	service := NewDiscoveryService(NewDispatcher(manager), manager, DiscoveryConfig{Provider: "ollama"})
+
+	fired := false
+	service.AddEscalationHook(func(Escalation) { fired = true })
+	service.recordDiscoveryOutcome([]byte{0x01}, os.ErrDeadlineExceeded)
+
+	if fired || !service.Healthy() {
+		t.Error("Expected no escalation when fail-closed is disabled")
+	}
+}
@@ -0,0 +1,27 @@
+package parser
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// FallbackProtocol is the protocol reported for frames handled by the raw passthrough
+const FallbackProtocol = "raw_hex"
+
+// SetFallback toggles the raw passthrough: when enabled, frames no binding matches are
+// returned as {"raw": "<hex>", "length": N, "first_byte": "0xNN"} under FallbackProtocol
+// instead of failing with ErrUnknownProtocol, so downstream systems still get them.
+func (d *Dispatcher) SetFallback(enabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.fallback = enabled
+}
+
+// rawResult is the passthrough result for a frame no parser understands
+func rawResult(data []byte) map[string]interface{} {
+	return map[string]interface{}{
+		"raw":        hex.EncodeToString(data),
+		"length":     len(data),
+		"first_byte": fmt.Sprintf("0x%02X", data[0]),
+	}
+}
@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// ResultRecord is one persisted frame as written by FileSink
+type ResultRecord struct {
+	Time     time.Time              `json:"time"`
+	Protocol string                 `json:"protocol"`
+	Frame    string                 `json:"frame"` // Hex
+	Result   map[string]interface{} `json:"result,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+// FileSink appends every output it receives to a JSONL file, one record per frame, for
+// replay and audit. Each record is a single write, so a crash never leaves half a frame.
+// Register it for OutcomeSuccess to persist every successful parse.
+type FileSink struct {
+	f  *os.File
+	mu sync.Mutex
+}
+
+// NewFileSink opens (or creates) path for appending
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open result log: %v", err)
+	}
+	return &FileSink{f: f}, nil
+}
+
+// newResultRecord converts an output to its persisted form
+func newResultRecord(out Output) ResultRecord {
+	record := ResultRecord{
+		Time:     time.Now().UTC(),
+		Protocol: out.Protocol,
+		Frame:    fmt.Sprintf("%X", out.Frame),
+		Result:   out.Result,
+	}
+	if out.Err != nil {
+		record.Error = out.Err.Error()
+	}
+	return record
+}
+
+// encodeRecord marshals a record as one JSON line
+func encodeRecord(record ResultRecord) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Emit appends out as one JSON line
+func (s *FileSink) Emit(out Output) error {
+	data, err := encodeRecord(newResultRecord(out))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.f.Write(data)
+	return err
+}
+
+// Close flushes and closes the file
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.f.Sync(); err != nil {
+		_ = s.f.Close()
+		return err
+	}
+	return s.f.Close()
+}
+
+// WriterSink writes every output it receives as a JSON line in the FileSink record format,
+// e.g. to os.Stdout for piping parsed results into another program
+type WriterSink struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// Emit writes out as one JSON line
+func (s *WriterSink) Emit(out Output) error {
+	data, err := encodeRecord(newResultRecord(out))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(data)
+	return err
+}
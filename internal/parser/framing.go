@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	DefaultReadBufferSize = 1024    // Bytes requested per read from a TCP connection
+	DefaultMaxFrameSize   = 1 << 20 // Largest frame assembled from consecutive full reads
+)
+
+// frameContinuationWait is how long a read that filled the buffer waits for the rest of its frame
+const frameContinuationWait = 20 * time.Millisecond
+
+// ErrFrameTooLarge is reported to a client whose frame exceeds the maximum frame size
+var ErrFrameTooLarge = errors.New("frame exceeds the maximum frame size")
+
+// FramingMode selects how a TCP connection's byte stream is cut into frames
+type FramingMode string
+
+const (
+	// FramingRead treats each read as one frame, joining reads that fill the buffer. It relies
+	// on the client's writes arriving whole, which holds on loopback but not across networks
+	// that split a write into several TCP segments.
+	FramingRead FramingMode = "read"
+	// FramingLengthPrefix reads frames each prefixed with their length as a big-endian uint16,
+	// the format replay files use, so frames survive any segmentation
+	FramingLengthPrefix FramingMode = "length-prefix"
+)
+
+// ParseFramingMode validates a framing name; the empty string means FramingRead
+func ParseFramingMode(s string) (FramingMode, error) {
+	switch FramingMode(s) {
+	case "", FramingRead:
+		return FramingRead, nil
+	case FramingLengthPrefix:
+		return FramingLengthPrefix, nil
+	default:
+		return "", fmt.Errorf("unknown framing %q (want %q or %q)", s, FramingRead, FramingLengthPrefix)
+	}
+}
+
+// SetFraming selects how frames are delimited on every connection. Call before Serve.
+func (s *TCPServer) SetFraming(mode FramingMode) {
+	if mode == "" {
+		mode = FramingRead
+	}
+	s.framing = mode
+}
+
+// SetReadBufferSize changes how many bytes each read from a connection requests. A non-positive
+// value restores DefaultReadBufferSize. Call before Serve.
+func (s *TCPServer) SetReadBufferSize(n int) {
+	if n <= 0 {
+		n = DefaultReadBufferSize
+	}
+	s.readBufferSize = n
+}
+
+// SetMaxFrameSize bounds the frames assembled from several reads; a connection sending a larger
+// one gets an error and is closed, since the rest of its stream can't be resynchronized. A
+// non-positive value restores DefaultMaxFrameSize. Call before Serve.
+func (s *TCPServer) SetMaxFrameSize(n int) {
+	if n <= 0 {
+		n = DefaultMaxFrameSize
+	}
+	s.maxFrameSize = n
+}
+
+// readFrame reads one frame from conn into buffer, under the read deadline already set. With
+// FramingRead, a read that fills the whole buffer means the client's write was larger, so
+// reading continues, each read waiting at most frameContinuationWait, until one comes back
+// short. The frame is only valid until the next call. Errors after the first read end the
+// frame; the next call reports them.
+func (s *TCPServer) readFrame(conn net.Conn, buffer []byte) ([]byte, error) {
+	if s.framing == FramingLengthPrefix {
+		return s.readPrefixedFrame(conn, buffer)
+	}
+	n, err := conn.Read(buffer)
+	if err != nil {
+		return nil, err
+	}
+	if n < len(buffer) {
+		return s.checkFrameSize(buffer[:n])
+	}
+
+	frame := append([]byte(nil), buffer...)
+	for n == len(buffer) && len(frame) <= s.maxFrameSize {
+		if err := conn.SetReadDeadline(time.Now().Add(frameContinuationWait)); err != nil {
+			break
+		}
+		if n, err = conn.Read(buffer); err != nil {
+			break
+		}
+		frame = append(frame, buffer[:n]...)
+	}
+	return s.checkFrameSize(frame)
+}
+
+// readPrefixedFrame reads one length-prefixed frame, skipping empty ones. A connection that
+// ends mid-frame reports io.ErrUnexpectedEOF.
+func (s *TCPServer) readPrefixedFrame(conn net.Conn, buffer []byte) ([]byte, error) {
+	var header [2]byte
+	n := 0
+	for n == 0 {
+		if _, err := io.ReadFull(conn, header[:]); err != nil {
+			return nil, err
+		}
+		n = int(binary.BigEndian.Uint16(header[:]))
+	}
+	if n > s.maxFrameSize {
+		return nil, fmt.Errorf("%w of %d bytes", ErrFrameTooLarge, s.maxFrameSize)
+	}
+	frame := buffer
+	if n > len(buffer) {
+		frame = make([]byte, n)
+	}
+	if _, err := io.ReadFull(conn, frame[:n]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return frame[:n], nil
+}
+
+func (s *TCPServer) checkFrameSize(frame []byte) ([]byte, error) {
+	if len(frame) > s.maxFrameSize {
+		return nil, fmt.Errorf("%w of %d bytes", ErrFrameTooLarge, s.maxFrameSize)
+	}
+	return frame, nil
+}
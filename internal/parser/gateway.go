@@ -0,0 +1,34 @@
+package parser
+
+// Gateway bundles the components of a running OmniBridge instance for
+// operations that span all of them, such as snapshots.
+type Gateway struct {
+	dispatcher  *Dispatcher
+	manager     *ParserManager
+	discovery   *DiscoveryService
+	deadLetters *DeadLetterStore // nil unless dead letters are stored
+}
+
+// NewGateway wires a gateway around an existing dispatcher; disc may be nil
+// when no AI discovery is configured.
+func NewGateway(d *Dispatcher, disc *DiscoveryService) *Gateway {
+	return &Gateway{
+		dispatcher: d,
+		manager:    d.GetManager(),
+		discovery:  disc,
+	}
+}
+
+func (g *Gateway) GetDispatcher() *Dispatcher {
+	return g.dispatcher
+}
+
+func (g *Gateway) GetManager() *ParserManager {
+	return g.manager
+}
+
+// SetDeadLetterStore sets the store ReplayDeadLetters works on. The store must also be
+// registered as a sink on the dispatcher's OutputRouter to receive frames.
+func (g *Gateway) SetDeadLetterStore(s *DeadLetterStore) {
+	g.deadLetters = s
+}
@@ -0,0 +1,147 @@
+package parser
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// goldenCheckID labels the executions of candidate parsers against golden cases in metrics
+const goldenCheckID = "golden_check"
+
+// TestCase is a recorded frame together with the output its parser must produce
+type TestCase struct {
+	Name     string                 `json:"name,omitempty"`
+	Input    string                 `json:"input"` // Hex-encoded frame
+	Expected map[string]interface{} `json:"expected"`
+}
+
+// TestResult is the outcome of running one TestCase
+type TestResult struct {
+	Case   TestCase
+	Got    map[string]interface{}
+	Err    error
+	Passed bool
+}
+
+// TestParser runs the protocol's current parser against cases. Results are compared
+// after a JSON round trip, so an int in the parser output matches 42.0 loaded from a golden file.
+func (m *ParserManager) TestParser(protocolID string, cases []TestCase) ([]TestResult, error) {
+	code, ok := m.GetParserCode(protocolID)
+	if !ok {
+		return nil, fmt.Errorf("no parser found for %s", protocolID)
+	}
+	return runTestCases(cases, func(data []byte) (map[string]interface{}, error) {
+		return m.engine.Execute(protocolID, data, code)
+	})
+}
+
+// ValidateStored runs the protocol's current parser against every stored vector: its golden
+// cases and, when it has a schema, the schema samples, which pass if the output conforms.
+func (m *ParserManager) ValidateStored(protocolID string) ([]TestResult, error) {
+	code, ok := m.GetParserCode(protocolID)
+	if !ok {
+		return nil, fmt.Errorf("no parser found for %s", protocolID)
+	}
+	exec := func(data []byte) (map[string]interface{}, error) {
+		return m.engine.Execute(protocolID, data, code)
+	}
+
+	cases, err := m.LoadGoldenCases(protocolID)
+	if err != nil {
+		return nil, err
+	}
+	results, err := runTestCases(cases, exec)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := m.LoadSchema(protocolID)
+	if err != nil || schema == nil {
+		return results, err
+	}
+	schemaResults, err := runSchemaSamples(schema, exec)
+	if err != nil {
+		return nil, err
+	}
+	return append(results, schemaResults...), nil
+}
+
+// testCode runs candidate code that has not been registered yet against cases
+func (m *ParserManager) testCode(code string, cases []TestCase) ([]TestResult, error) {
+	fn, err := m.engine.compileUncached(code)
+	if err != nil {
+		return nil, err
+	}
+	return runTestCases(cases, func(data []byte) (map[string]interface{}, error) {
+		return m.engine.runOnce(goldenCheckID, fn, data)
+	})
+}
+
+// LoadGoldenCases reads the recorded cases in storage/<id>.golden.json.
+// It returns no cases and no error when the protocol has no golden file.
+func (m *ParserManager) LoadGoldenCases(protocolID string) ([]TestCase, error) {
+	data, err := os.ReadFile(m.goldenPath(protocolID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []TestCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		return nil, fmt.Errorf("invalid golden file for %s: %v", protocolID, err)
+	}
+	return cases, nil
+}
+
+func (m *ParserManager) goldenPath(protocolID string) string {
+	return filepath.Join(m.storagePath, protocolID+".golden.json")
+}
+
+func runTestCases(cases []TestCase, exec func(data []byte) (map[string]interface{}, error)) ([]TestResult, error) {
+	results := make([]TestResult, 0, len(cases))
+	for i, tc := range cases {
+		data, err := hex.DecodeString(strings.Join(strings.Fields(tc.Input), ""))
+		if err != nil {
+			return nil, fmt.Errorf("case %d: invalid hex input: %v", i, err)
+		}
+
+		got, err := exec(data)
+		res := TestResult{Case: tc, Got: got, Err: err}
+		if err == nil {
+			res.Passed = reflect.DeepEqual(normalizeResult(got), normalizeResult(tc.Expected))
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// normalizeResult converts a parse result to its JSON representation so numeric types compare equal
+func normalizeResult(v map[string]interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+// failedCases counts the results that did not pass
+func failedCases(results []TestResult) int {
+	failed := 0
+	for _, r := range results {
+		if !r.Passed {
+			failed++
+		}
+	}
+	return failed
+}
@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSeededManager loads the OBD-II seed parsers into a temporary storage directory
+func newSeededManager(t *testing.T) (*ParserManager, string) {
+	t.Helper()
+	tmpDir, _ := os.MkdirTemp("", "golden_test")
+	t.Cleanup(func() { _ = os.RemoveAll(tmpDir) })
+
+	mgr := NewParserManager(tmpDir, filepath.Join("..", "..", "seeds"))
+	if err := mgr.SeedParsers(); err != nil {
+		t.Fatalf("SeedParsers failed: %v", err)
+	}
+	if _, err := mgr.LoadSavedParsers(); err != nil {
+		t.Fatalf("LoadSavedParsers failed: %v", err)
+	}
+	return mgr, tmpDir
+}
+
+func TestParserManager_TestParser_OBDII(t *testing.T) {
+	mgr, _ := newSeededManager(t)
+
+	cases := []TestCase{
+		{
+			Name:     "engine speed",
+			Input:    "41 0C 1A F8",
+			Expected: map[string]interface{}{"pid": "0C", "name": "Engine speed", "value": 1726.0, "unit": "rpm"},
+		},
+		{
+			Name:     "coolant temperature",
+			Input:    "41057B",
+			Expected: map[string]interface{}{"pid": "05", "name": "Engine coolant temperature", "value": 83, "unit": "°C"},
+		},
+		{
+			Name:     "wrong expectation",
+			Input:    "410C1AF8",
+			Expected: map[string]interface{}{"pid": "0C", "name": "Engine speed", "value": 6904, "unit": "rpm"},
+		},
+	}
+
+	results, err := mgr.TestParser("OBDII_Service01", cases)
+	if err != nil {
+		t.Fatalf("TestParser failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	for i, want := range []bool{true, true, false} {
+		if results[i].Passed != want {
+			t.Errorf("case %q: passed = %v, want %v (got %v, err %v)", cases[i].Name, results[i].Passed, want, results[i].Got, results[i].Err)
+		}
+	}
+
+	if _, err := mgr.TestParser("missing", cases); err == nil {
+		t.Error("Expected error for an unknown protocol")
+	}
+	if _, err := mgr.TestParser("OBDII_Service01", []TestCase{{Input: "zz"}}); err == nil {
+		t.Error("Expected error for invalid hex input")
+	}
+}
+
+func TestParserManager_LoadGoldenCases(t *testing.T) {
+	mgr, dir := newSeededManager(t)
+
+	if cases, err := mgr.LoadGoldenCases("OBDII_Service01"); err != nil || cases != nil {
+		t.Fatalf("Expected no cases without a golden file, got %v (%v)", cases, err)
+	}
+
+	golden := `[
+  {"name": "rpm", "input": "410C1AF8", "expected": {"pid": "0C", "name": "Engine speed", "value": 1726, "unit": "rpm"}},
+  {"name": "load", "input": "410480", "expected": {"pid": "04", "name": "Calculated engine load", "value": 50, "unit": "%"}}
+]`
+	if err := os.WriteFile(filepath.Join(dir, "OBDII_Service01.golden.json"), []byte(golden), 0o644); err != nil {
+		t.Fatalf("Failed to write golden file: %v", err)
+	}
+
+	cases, err := mgr.LoadGoldenCases("OBDII_Service01")
+	if err != nil || len(cases) != 2 {
+		t.Fatalf("Expected 2 golden cases, got %v (%v)", cases, err)
+	}
+	results, err := mgr.TestParser("OBDII_Service01", cases)
+	if err != nil {
+		t.Fatalf("TestParser failed: %v", err)
+	}
+	if failed := failedCases(results); failed != 0 {
+		t.Errorf("Expected seed parser to pass its golden cases, %d failed: %+v", failed, results)
+	}
+
+	// Golden files are not mistaken for parsers
+	if _, exists := mgr.GetParserCode("OBDII_Service01.golden"); exists {
+		t.Error("Golden file loaded as a parser")
+	}
+}
+
+func TestDiscoveryService_RepairGatedByGoldenCases(t *testing.T) {
+	// The "fixed" parser forgets the /4 scaling and regresses on the recorded RPM case
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: `// Signature: 41
+package dynamic
+func Parse(data []byte) map[string]interface{} {
+	return map[string]interface{}{"value": float64(int(data[2])*256 + int(data[3]))}
+}`})
+	}))
+	defer server.Close()
+
+	service, _, manager := newTestDiscovery(t, DiscoveryConfig{
+		Provider:   "ollama",
+		Endpoint:   server.URL,
+		RetryDelay: time.Millisecond,
+	})
+	golden := `[{"input": "410C1AF8", "expected": {"value": 1726}}]`
+	if err := os.WriteFile(manager.goldenPath("auto_proto_0x41"), []byte(golden), 0o644); err != nil {
+		t.Fatalf("Failed to write golden file: %v", err)
+	}
+
+	_, err := service.RepairParser("auto_proto_0x41", "package dynamic", "index out of range", []byte{0x41, 0x0C, 0x1A, 0xF8}, nil)
+	if err == nil || !strings.Contains(err.Error(), "golden") {
+		t.Fatalf("Expected repair to be rejected by golden cases, got %v", err)
+	}
+	if _, exists := manager.GetParserCode("auto_proto_0x41"); exists {
+		t.Error("Regressing repair must not be registered")
+	}
+}
@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"go.uber.org/zap"
+)
+
+// DefaultGraceFrames is how many unknown frames a connection may buffer during its grace period
+const DefaultGraceFrames = 3
+
+// graceBuffer holds the unknown frames a new connection sends during its discovery grace period.
+// A nil buffer means the grace period is over (or disabled).
+type graceBuffer struct {
+	frames   [][]byte
+	deadline time.Time // Set when the first frame is buffered
+	max      int
+}
+
+func (g *graceBuffer) pending() bool {
+	return g != nil && len(g.frames) > 0
+}
+
+// add buffers a frame and reports whether the buffer is full. deadline applies to the first frame only.
+func (g *graceBuffer) add(frame []byte, deadline time.Time) bool {
+	if len(g.frames) == 0 {
+		g.deadline = deadline
+	}
+	g.frames = append(g.frames, frame)
+	return len(g.frames) >= g.max
+}
+
+// SetDiscoveryGrace makes each new connection buffer up to frames unknown frames for at most
+// window before deciding which of them to discover, so a leading junk or handshake frame
+// doesn't trigger a discovery of its own. A non-positive window disables it. Call before Serve.
+func (s *TCPServer) SetDiscoveryGrace(window time.Duration, frames int) {
+	if frames <= 0 {
+		frames = DefaultGraceFrames
+	}
+	s.graceWindow, s.graceFrames = window, frames
+}
+
+// flushGrace discovers the most plausible protocol among the buffered frames, then answers
+// every frame in order. Frames outside the chosen group are not discovered. Frames still
+// buffered when the client disconnects are dropped.
+func (s *TCPServer) flushGrace(ctx context.Context, conn net.Conn, frames [][]byte) {
+	chosen := discoveryCandidates(frames)
+	logger.Info("Discovery grace period over",
+		zap.Int("buffered", len(frames)), zap.Int("candidates", len(chosen)), zap.String("remote_addr", conn.RemoteAddr().String()))
+
+	isCandidate := make(map[int]bool, len(chosen))
+	for _, i := range chosen {
+		isCandidate[i] = true
+	}
+
+	// Several frames share the signature: let discovery infer it across all of them
+	if len(chosen) > 1 && !s.dispatcher.known(frames[chosen[0]]) {
+		var extras [][]byte
+		for _, i := range chosen[1:] {
+			extras = append(extras, frames[i])
+		}
+		if _, err := s.discovery.DiscoverNewProtocolContext(ctx, frames[chosen[0]], nil, tcpContextHint, extras...); err != nil {
+			logger.Error("Discovery failed", zap.Error(err))
+			isCandidate = nil
+		}
+	}
+	for i, frame := range frames {
+		s.respond(ctx, conn, frame, isCandidate[i])
+	}
+}
+
+// discoveryCandidates groups frames by leading byte and returns the indexes of the group most
+// likely to be the real protocol: the largest one, ties going to the group with the longest frame.
+func discoveryCandidates(frames [][]byte) []int {
+	groups := make(map[byte][]int)
+	longest := make(map[byte]int)
+	for i, frame := range frames {
+		if len(frame) == 0 {
+			continue
+		}
+		b := frame[0]
+		groups[b] = append(groups[b], i)
+		if len(frame) > longest[b] {
+			longest[b] = len(frame)
+		}
+	}
+
+	var best []int
+	var bestKey byte
+	for b, idx := range groups {
+		if best == nil || len(idx) > len(best) ||
+			(len(idx) == len(best) && (longest[b] > longest[bestKey] || (longest[b] == longest[bestKey] && idx[0] < best[0]))) {
+			best, bestKey = idx, b
+		}
+	}
+	return best
+}
@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// maxInspectBytes bounds how many leading frame bytes Inspect traces, one parser run each
+const maxInspectBytes = 256
+
+// Inspection is the decoded interpretation of one frame, for debugging a parser
+type Inspection struct {
+	Frame    []byte
+	Protocol string
+	Alias    string // Friendly name of Protocol, if one is set
+	Family   string
+	Fields   []FieldInspection // Sorted by name
+}
+
+// FieldInspection is one result field and where in the frame it came from
+type FieldInspection struct {
+	Name  string
+	Value interface{}
+	Unit  string // From a "<name>_unit" field, or "unit" for the "value" field
+	Bytes []int  // Frame offsets the value depends on; empty if none were found
+}
+
+// Inspect parses frame like Ingest, without sinks, metrics or caches, and traces which bytes
+// each field depends on: every byte is inverted in turn and the parser re-run, and a byte
+// contributes to the fields whose value changes. Fields that disappear are not attributed,
+// so a selector byte such as an OBD-II PID shows up only under the fields echoing it.
+func (d *Dispatcher) Inspect(frame []byte) (*Inspection, error) {
+	d.mu.RLock()
+	proto, family := d.matchLocked(frame, len(frame))
+	d.mu.RUnlock()
+	if proto == "" {
+		return nil, ErrUnknownProtocol
+	}
+
+	result, err := d.manager.ParseData(proto, frame)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", proto, err)
+	}
+	in := &Inspection{Frame: append([]byte(nil), frame...), Protocol: proto, Family: family}
+	in.Alias, _ = d.manager.GetAlias(proto)
+	for _, name := range sortedKeys(result) {
+		in.Fields = append(in.Fields, FieldInspection{Name: name, Value: result[name], Unit: fieldUnit(result, name)})
+	}
+
+	mutated := make([]byte, len(frame))
+	for i := range min(len(frame), maxInspectBytes) {
+		copy(mutated, frame)
+		mutated[i] ^= 0xFF
+		changed, err := d.manager.ParseData(proto, mutated)
+		if err != nil {
+			continue
+		}
+		for f := range in.Fields {
+			field := &in.Fields[f]
+			if v, ok := changed[field.Name]; ok && !reflect.DeepEqual(v, field.Value) {
+				field.Bytes = append(field.Bytes, i)
+			}
+		}
+	}
+	return in, nil
+}
+
+// fieldUnit returns the unit a result declares for a field, if any
+func fieldUnit(result map[string]interface{}, name string) string {
+	if unit, ok := result[name+"_unit"].(string); ok {
+		return unit
+	}
+	if name == "value" {
+		unit, _ := result["unit"].(string)
+		return unit
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDispatcher_Inspect(t *testing.T) {
+	mgr := NewParserManager(t.TempDir(), "")
+	code := `package dynamic
+func Parse(data []byte) map[string]interface{} {
+	return map[string]interface{}{
+		"temp":      int(data[1]) - 40,
+		"temp_unit": "°C",
+		"volts":     float64(int(data[2])<<8|int(data[3])) / 1000,
+		"kind":      "sensor",
+	}
+}`
+	if err := mgr.RegisterParser("sensor", code); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d := NewDispatcher(mgr)
+	d.Bind([]byte{0x5A}, "sensor")
+	d.SetFamily("sensor", "env")
+
+	in, err := d.Inspect([]byte{0x5A, 0x41, 0x30, 0x39})
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if in.Protocol != "sensor" || in.Family != "env" {
+		t.Errorf("expected sensor/env, got %s/%s", in.Protocol, in.Family)
+	}
+
+	want := []FieldInspection{
+		{Name: "kind", Value: "sensor"},
+		{Name: "temp", Value: 25, Unit: "°C", Bytes: []int{1}},
+		{Name: "temp_unit", Value: "°C"},
+		{Name: "volts", Value: 12.345, Bytes: []int{2, 3}},
+	}
+	if !reflect.DeepEqual(in.Fields, want) {
+		t.Errorf("fields:\n got  %+v\n want %+v", in.Fields, want)
+	}
+
+	if _, err := d.Inspect([]byte{0x99, 0x00}); !errors.Is(err, ErrUnknownProtocol) {
+		t.Errorf("expected ErrUnknownProtocol for an unbound frame, got %v", err)
+	}
+}
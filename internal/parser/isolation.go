@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"fmt"
+	"time"
+)
+
+// IsolationMode selects how a protocol's compiled parser is shared between goroutines
+type IsolationMode string
+
+const (
+	// IsolationShared runs every execution on one interpreter; fine for stateless parsers
+	IsolationShared IsolationMode = "shared"
+	// IsolationPooled compiles the parser into a pool of interpreters and hands each one to a
+	// single execution at a time, so package-level state in the parser is never shared
+	IsolationPooled IsolationMode = "pooled"
+)
+
+// DefaultInterpreterPoolSize is how many interpreters a pooled parser is compiled into
+const DefaultInterpreterPoolSize = 4
+
+// ParseIsolationMode validates a mode name; the empty string means IsolationShared
+func ParseIsolationMode(s string) (IsolationMode, error) {
+	switch IsolationMode(s) {
+	case "", IsolationShared:
+		return IsolationShared, nil
+	case IsolationPooled:
+		return IsolationPooled, nil
+	default:
+		return "", fmt.Errorf("unknown isolation mode %q (want %q or %q)", s, IsolationShared, IsolationPooled)
+	}
+}
+
+// SetIsolation selects the isolation mode of a protocol's parser. A changed mode drops the
+// compiled parser so the next execution recompiles it accordingly.
+func (e *Engine) SetIsolation(id string, mode IsolationMode) error {
+	mode, err := ParseIsolationMode(string(mode))
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	current, ok := e.isolation[id]
+	if !ok {
+		current = IsolationShared
+	}
+	if current == mode {
+		return nil
+	}
+	e.isolation[id] = mode
+	delete(e.cache, id)
+	if e.results != nil {
+		e.results.invalidate(id)
+	}
+	return nil
+}
+
+// SetInterpreterPoolSize changes how many interpreters pooled parsers compile into. It applies
+// to parsers compiled afterwards; a non-positive value restores DefaultInterpreterPoolSize.
+func (e *Engine) SetInterpreterPoolSize(n int) {
+	if n <= 0 {
+		n = DefaultInterpreterPoolSize
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.poolSize = n
+}
+
+// interpretersFor returns how many interpreters id's parser needs, 0 for a shared one.
+// Callers hold e.mu.
+func (e *Engine) interpretersFor(id string) int {
+	if e.isolation[id] == IsolationPooled {
+		return e.poolSize
+	}
+	return 0
+}
+
+// compileIsolated compiles a shared parser, or a pool of instances behind one compiledParser
+// when instances is positive. Executions beyond the pool size wait for a free interpreter.
+func (e *Engine) compileIsolated(goCode string, timeout time.Duration, instances int, run interpreter) (compiledParser, error) {
+	if instances == 0 {
+		return e.compile(goCode, timeout, run)
+	}
+
+	free := make(chan compiledParser, instances)
+	for range instances {
+		fn, err := e.compile(goCode, timeout, run)
+		if err != nil {
+			return nil, err
+		}
+		free <- fn
+	}
+	return func(data []byte, out *Fields) (map[string]interface{}, error) {
+		fn := <-free
+		defer func() { free <- fn }()
+		return fn(data, out)
+	}, nil
+}
@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"fmt"
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
+)
+
+// checkUnboundedLoops rejects parsers containing a `for {}` loop that nothing can leave:
+// no condition, and no break, return, goto or panic in its body. It only catches the
+// obvious cases; anything subtler is still stopped by the execution timeout. Code that
+// doesn't parse is left for the interpreter to report.
+func checkUnboundedLoops(goCode string) error {
+	fset := token.NewFileSet()
+	file, err := goparser.ParseFile(fset, "parser.go", goCode, 0)
+	if err != nil {
+		return nil
+	}
+
+	var loopErr error
+	ast.Inspect(file, func(n ast.Node) bool {
+		if loopErr != nil {
+			return false
+		}
+		loop, ok := n.(*ast.ForStmt)
+		if ok && loop.Cond == nil && !loopCanExit(loop.Body) {
+			loopErr = fmt.Errorf("COMPILE_ERROR: infinite loop at line %d: for loop has no condition and no break, return or panic",
+				fset.Position(loop.Pos()).Line)
+		}
+		return true
+	})
+	return loopErr
+}
+
+// loopCanExit reports whether a loop body contains a statement that leaves the loop
+func loopCanExit(body *ast.BlockStmt) bool {
+	// Labels declared inside the body; breaking to them doesn't leave this loop
+	inner := map[string]bool{}
+	ast.Inspect(body, func(n ast.Node) bool {
+		if l, ok := n.(*ast.LabeledStmt); ok {
+			inner[l.Label.Name] = true
+		}
+		return true
+	})
+
+	exits := false
+	var walk func(n ast.Node, nested bool)
+	walk = func(n ast.Node, nested bool) {
+		ast.Inspect(n, func(c ast.Node) bool {
+			if exits || c == nil {
+				return false
+			}
+			switch s := c.(type) {
+			case *ast.FuncLit:
+				// Returns inside closures don't leave the loop
+				return false
+			case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+				// An unlabeled break in here targets the inner statement
+				if c != n {
+					walk(c, true)
+					return false
+				}
+			case *ast.ReturnStmt:
+				exits = true
+			case *ast.BranchStmt:
+				switch s.Tok {
+				case token.GOTO:
+					exits = true
+				case token.BREAK:
+					if s.Label == nil {
+						exits = !nested
+					} else {
+						exits = !inner[s.Label.Name]
+					}
+				}
+			case *ast.CallExpr:
+				if id, ok := s.Fun.(*ast.Ident); ok && id.Name == "panic" {
+					exits = true
+				}
+			}
+			return true
+		})
+	}
+	walk(body, false)
+	return exits
+}
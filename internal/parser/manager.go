@@ -1,50 +1,110 @@
 package parser
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	goparser "go/parser"
+	"go/token"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"github.com/pmezard/go-difflib/difflib"
+	"go.uber.org/zap"
 )
 
+// signatureRe matches the "// Signature: <HEX>" comment that parsers use to declare their prefix.
+// Only spaces and tabs may precede the hex, which may carry a 0x prefix.
+var signatureRe = regexp.MustCompile(`// Signature:[ \t]*(?:0[xX])?([0-9A-Fa-f]+)`)
+
+// versionFileRe matches versioned parser files inside a protocol directory (e.g. v3.go)
+var versionFileRe = regexp.MustCompile(`^v(\d+)\.go$`)
+
+// currentPointerFile holds the active version number inside a protocol directory
+const currentPointerFile = "current"
+
+// DefaultManifestFlushDelay is how long queued manifest updates are coalesced before being written
+const DefaultManifestFlushDelay = 500 * time.Millisecond
+
 type ParserManager struct {
 	engine      *Engine
 	storagePath string
-	seedPath    string
+	seeds       fs.FS             // Seed parsers copied into storage by SeedParsers; nil for none
 	cache       map[string]string // ProtocolID -> GoCode
-	mu          sync.RWMutex
+	aliases     map[string]string // ProtocolID -> human-friendly name
+	// Called when a rollback or reload swaps the code
+	onCodeChange func(protocolID, oldCode, newCode string)
+	manifest     manifestQueue
+	mu           sync.RWMutex
+}
+
+// manifestQueue coalesces bursts of manifest updates into a single write
+type manifestQueue struct {
+	pending *Manifest // Latest queued bindings, nil when nothing is dirty
+	last    *Manifest // Bindings in the last written manifest
+	timer   *time.Timer
+	delay   time.Duration
+	writes  int // Manifest files written, for tests
+	mu      sync.Mutex
 }
 
 func NewParserManager(storagePath string, seedPath string) *ParserManager {
+	var seeds fs.FS
+	if seedPath != "" {
+		seeds = os.DirFS(seedPath)
+	}
+	return NewParserManagerWithFS(storagePath, seeds)
+}
+
+// NewParserManagerWithFS is NewParserManager with seeds read from seedFS, such as an
+// embed.FS compiled into the binary, instead of a directory. seedFS may be nil.
+func NewParserManagerWithFS(storagePath string, seedFS fs.FS) *ParserManager {
 	if _, err := os.Stat(storagePath); os.IsNotExist(err) {
 		_ = os.MkdirAll(storagePath, 0o755)
 	}
 	return &ParserManager{
 		engine:      NewEngine(),
 		storagePath: storagePath,
-		seedPath:    seedPath,
+		seeds:       seedFS,
 		cache:       make(map[string]string),
+		aliases:     make(map[string]string),
+		manifest:    manifestQueue{delay: DefaultManifestFlushDelay},
 	}
 }
 
-// SeedParsers copies files from seedPath to storagePath if they don't exist
+// GetEngine returns the engine used to execute parsers
+func (m *ParserManager) GetEngine() *Engine {
+	return m.engine
+}
+
+// SeedParsers copies the seed files to storagePath if they don't exist there yet
 func (m *ParserManager) SeedParsers() error {
-	if m.seedPath == "" {
+	if m.seeds == nil {
 		return nil
 	}
 
-	files, err := os.ReadDir(m.seedPath)
+	files, err := fs.ReadDir(m.seeds, ".")
 	if err != nil {
 		return nil // Ignore if seed path doesn't exist
 	}
 
 	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
 		destPath := filepath.Join(m.storagePath, file.Name())
 		if _, err := os.Stat(destPath); os.IsNotExist(err) {
-			content, err := os.ReadFile(filepath.Join(m.seedPath, file.Name()))
-			if err == nil {
+			content, err := fs.ReadFile(m.seeds, file.Name())
+			// Skip Go files that aren't parsers, such as the seeds package's own embed.go
+			if err == nil && isParserPackage(content) {
 				if err := os.WriteFile(destPath, content, 0o644); err != nil {
 					fmt.Printf("Failed to write seed file %s: %v\n", file.Name(), err)
 				} else {
@@ -56,6 +116,13 @@ func (m *ParserManager) SeedParsers() error {
 	return nil
 }
 
+// isParserPackage reports whether a seed file declares package dynamic, as every parser does.
+// Files whose package clause doesn't parse are left for compilation to reject.
+func isParserPackage(content []byte) bool {
+	file, err := goparser.ParseFile(token.NewFileSet(), "", content, goparser.PackageClauseOnly)
+	return err != nil || file.Name.Name == "dynamic"
+}
+
 // LoadSavedParsers reads all .go files from the storage folder on startup
 // Returns a map of ProtocolID -> SignatureHex
 func (m *ParserManager) LoadSavedParsers() (map[string]string, error) {
@@ -65,39 +132,346 @@ func (m *ParserManager) LoadSavedParsers() (map[string]string, error) {
 	}
 
 	bindings := make(map[string]string)
-	reSig := regexp.MustCompile(`// Signature:\s*([0-9A-Fa-f]+)`)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
 	for _, file := range files {
-		if filepath.Ext(file.Name()) == ".go" {
-			protocolID := file.Name()[:len(file.Name())-3]
+		var protocolID, code string
+		switch {
+		case file.IsDir():
+			// Versioned layout: <id>/vN.go plus a "current" pointer
+			version, err := m.currentVersion(file.Name())
+			if err != nil {
+				continue
+			}
+			content, err := os.ReadFile(m.versionPath(file.Name(), version))
+			if err != nil {
+				continue
+			}
+			protocolID, code = file.Name(), string(content)
+		case filepath.Ext(file.Name()) == ".go":
+			// Legacy flat layout: <id>.go. A versioned directory takes precedence.
+			protocolID = strings.TrimSuffix(file.Name(), ".go")
+			if info, err := os.Stat(m.protocolDir(protocolID)); err == nil && info.IsDir() {
+				continue
+			}
 			content, _ := os.ReadFile(filepath.Join(m.storagePath, file.Name()))
-			code := string(content)
-			m.cache[protocolID] = code
+			code = string(content)
+		default:
+			continue
+		}
 
-			// Extract signature from code comments
-			matches := reSig.FindStringSubmatch(code)
-			if len(matches) > 1 {
-				bindings[protocolID] = matches[1]
-			}
+		m.cache[protocolID] = code
+		if meta, err := m.readMetaLocked(protocolID); err == nil && meta != nil {
+			m.applyIsolation(protocolID, meta)
+		}
 
-			fmt.Printf("📦 Loaded cached parser for: %s\n", protocolID)
+		// Extract signature from code comments
+		if sig := extractSignature(code); sig != "" {
+			bindings[protocolID] = sig
 		}
+
+		fmt.Printf("📦 Loaded cached parser for: %s\n", protocolID)
 	}
 	return bindings, nil
 }
 
-// RegisterParser saves a new AI-generated parser to disk and cache
+// RegisterParser saves a new AI-generated parser to disk and cache.
+// Each call writes a new version (storage/<id>/vN.go) and moves the "current" pointer to it,
+// so a bad repair can be undone with RollbackParser. Code that doesn't satisfy the
+// protocol's stored schema (see RegisterParserWithSchema) is rejected. The protocol's
+// metadata sidecar (see ParserMeta) is created on first registration and updated after.
 func (m *ParserManager) RegisterParser(protocolID, code string) error {
+	return m.RegisterParserWithMeta(protocolID, code, ParserMeta{})
+}
+
+// checkStoredSchema rejects code that doesn't satisfy the protocol's stored schema, if any
+func (m *ParserManager) checkStoredSchema(protocolID, code string) error {
+	schema, err := m.LoadSchema(protocolID)
+	if err != nil {
+		return err
+	}
+	if schema != nil {
+		if err := m.checkSchema(code, schema); err != nil {
+			return fmt.Errorf("parser for %s: %w", protocolID, err)
+		}
+	}
+	return nil
+}
+
+func (m *ParserManager) registerParser(protocolID, code string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	filename := filepath.Join(m.storagePath, protocolID+".go")
-	err := os.WriteFile(filename, []byte(code), 0o644)
+	if err := m.migrateFlatParser(protocolID); err != nil {
+		return err
+	}
+
+	versions, err := m.listVersions(protocolID)
+	if err != nil {
+		return err
+	}
+	next := 1
+	if len(versions) > 0 {
+		next = versions[len(versions)-1] + 1
+	}
+
+	if err := os.MkdirAll(m.protocolDir(protocolID), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(m.versionPath(protocolID, next), []byte(code), 0o644); err != nil {
+		return err
+	}
+	if err := m.setCurrentVersion(protocolID, next); err != nil {
+		return err
+	}
+
+	// Record what changed so repairs and rediscoveries can be reviewed
+	if next > 1 {
+		if diff, err := m.diffLocked(protocolID, versions[len(versions)-1], next); err == nil {
+			logger.Info("Parser updated", zap.String("protocol", protocolID), zap.Int("version", next), zap.String("diff", diff))
+		}
+	}
+
+	m.cache[protocolID] = code
+	// Drop any compiled version of the previous code so the next ingest uses the new one
+	m.engine.ClearCache(protocolID)
+	return nil
+}
+
+// RollbackParser reverts a protocol to the version preceding the current one
+func (m *ParserManager) RollbackParser(protocolID string) error {
+	m.mu.Lock()
+
+	current, err := m.currentVersion(protocolID)
+	if err != nil {
+		m.mu.Unlock()
+		return fmt.Errorf("no version history for %s: %v", protocolID, err)
+	}
+	versions, err := m.listVersions(protocolID)
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+
+	previous := 0
+	for _, v := range versions {
+		if v < current {
+			previous = v
+		}
+	}
+	if previous == 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("no version before v%d for %s", current, protocolID)
+	}
+
+	content, err := os.ReadFile(m.versionPath(protocolID, previous))
 	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	if err := m.setCurrentVersion(protocolID, previous); err != nil {
+		m.mu.Unlock()
 		return err
 	}
 
+	code, oldCode := string(content), m.cache[protocolID]
 	m.cache[protocolID] = code
+	m.engine.ClearCache(protocolID)
+	onCodeChange := m.onCodeChange
+	m.mu.Unlock()
+
+	// Re-bind outside the lock since the dispatcher may call back into the manager
+	if onCodeChange != nil {
+		onCodeChange(protocolID, oldCode, code)
+	}
+	return nil
+}
+
+// DeleteParser removes a parser, including its version history, from memory and disk
+func (m *ParserManager) DeleteParser(protocolID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.cache[protocolID]; !ok {
+		return fmt.Errorf("no parser found for %s", protocolID)
+	}
+	if err := os.RemoveAll(m.protocolDir(protocolID)); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(m.storagePath, protocolID+".go")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(m.metaPath(protocolID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	delete(m.cache, protocolID)
+	delete(m.aliases, protocolID)
+	m.engine.ClearCache(protocolID)
+	return nil
+}
+
+// ListVersions returns the stored version numbers for a protocol in ascending order.
+// Parsers stored in the legacy flat layout have no history and return an empty list.
+func (m *ParserManager) ListVersions(protocolID string) ([]int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.cache[protocolID]; !ok {
+		return nil, fmt.Errorf("no parser found for %s", protocolID)
+	}
+	return m.listVersions(protocolID)
+}
+
+// Diff returns a unified diff between two stored versions of a protocol's parser.
+// Parsers without version history (legacy flat layout) cannot be diffed.
+func (m *ParserManager) Diff(protocolID string, versionA, versionB int) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.cache[protocolID]; !ok {
+		return "", fmt.Errorf("no parser found for %s", protocolID)
+	}
+	return m.diffLocked(protocolID, versionA, versionB)
+}
+
+func (m *ParserManager) diffLocked(protocolID string, versionA, versionB int) (string, error) {
+	a, err := os.ReadFile(m.versionPath(protocolID, versionA))
+	if err != nil {
+		return "", fmt.Errorf("version %d of %s not found", versionA, protocolID)
+	}
+	b, err := os.ReadFile(m.versionPath(protocolID, versionB))
+	if err != nil {
+		return "", fmt.Errorf("version %d of %s not found", versionB, protocolID)
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(a)),
+		B:        difflib.SplitLines(string(b)),
+		FromFile: fmt.Sprintf("%s/v%d.go", protocolID, versionA),
+		ToFile:   fmt.Sprintf("%s/v%d.go", protocolID, versionB),
+		Context:  3,
+	})
+}
+
+func (m *ParserManager) listVersions(protocolID string) ([]int, error) {
+	versions := []int{}
+	entries, err := os.ReadDir(m.protocolDir(protocolID))
+	if os.IsNotExist(err) {
+		return versions, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if matches := versionFileRe.FindStringSubmatch(e.Name()); matches != nil {
+			v, _ := strconv.Atoi(matches[1])
+			versions = append(versions, v)
+		}
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+// migrateFlatParser moves a legacy <id>.go file into the versioned layout as v1
+func (m *ParserManager) migrateFlatParser(protocolID string) error {
+	flatPath := filepath.Join(m.storagePath, protocolID+".go")
+	content, err := os.ReadFile(flatPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(m.protocolDir(protocolID)); err == nil {
+		// Already versioned; the flat file is stale
+		return os.Remove(flatPath)
+	}
+
+	if err := os.MkdirAll(m.protocolDir(protocolID), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(m.versionPath(protocolID, 1), content, 0o644); err != nil {
+		return err
+	}
+	if err := m.setCurrentVersion(protocolID, 1); err != nil {
+		return err
+	}
+	return os.Remove(flatPath)
+}
+
+func (m *ParserManager) protocolDir(protocolID string) string {
+	return filepath.Join(m.storagePath, protocolID)
+}
+
+func (m *ParserManager) versionPath(protocolID string, version int) string {
+	return filepath.Join(m.protocolDir(protocolID), fmt.Sprintf("v%d.go", version))
+}
+
+func (m *ParserManager) currentVersion(protocolID string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(m.protocolDir(protocolID), currentPointerFile))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+func (m *ParserManager) setCurrentVersion(protocolID string, version int) error {
+	path := filepath.Join(m.protocolDir(protocolID), currentPointerFile)
+	return os.WriteFile(path, []byte(strconv.Itoa(version)), 0o644)
+}
+
+// extractSignature returns the hex signature declared in a parser's first "// Signature:" comment,
+// if any, in the canonical form used as a binding key
+func extractSignature(code string) string {
+	matches := signatureRe.FindStringSubmatch(code)
+	if len(matches) > 1 {
+		return normalizeSignature(matches[1])
+	}
+	return ""
+}
+
+// normalizeSignature canonicalizes a hex signature to the form Bind uses as its key: upper-case,
+// even length, without whitespace or a 0x prefix. It returns "" if s isn't valid hex.
+func normalizeSignature(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	s = strings.ToUpper(s)
+	if len(s)%2 != 0 {
+		s = "0" + s
+	}
+	if _, err := hex.DecodeString(s); err != nil || s == "" {
+		return ""
+	}
+	return s
+}
+
+// declaredSignatures decodes every "// Signature:" comment in a parser, in order and without
+// duplicates. A parser covering a protocol family may declare several.
+func declaredSignatures(code string) [][]byte {
+	var sigs [][]byte
+	seen := make(map[string]bool)
+	for _, m := range signatureRe.FindAllStringSubmatch(code, -1) {
+		hexStr := normalizeSignature(m[1])
+		sig, err := hex.DecodeString(hexStr)
+		if err != nil || len(sig) == 0 || seen[hexStr] {
+			continue
+		}
+		seen[hexStr] = true
+		sigs = append(sigs, sig)
+	}
+	return sigs
+}
+
+// DeclaredSignature returns the first signature a parser declares in a "// Signature:"
+// comment, the one it is auto-bound to on load, or nil if it declares none
+func DeclaredSignature(code string) []byte {
+	if sigs := declaredSignatures(code); len(sigs) > 0 {
+		return sigs[0]
+	}
 	return nil
 }
 
@@ -109,6 +483,17 @@ func (m *ParserManager) GetParserCode(protocolID string) (string, bool) {
 	return code, exists
 }
 
+// Parsers returns a snapshot of every loaded parser (ProtocolID -> GoCode)
+func (m *ParserManager) Parsers() map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	parsers := make(map[string]string, len(m.cache))
+	for id, code := range m.cache {
+		parsers[id] = code
+	}
+	return parsers
+}
+
 // ParseData executes the parser at native speed from cache
 func (m *ParserManager) ParseData(protocolID string, data []byte) (map[string]interface{}, error) {
 	m.mu.RLock()
@@ -123,30 +508,274 @@ func (m *ParserManager) ParseData(protocolID string, data []byte) (map[string]in
 	return m.engine.Execute(protocolID, data, code)
 }
 
+// compiledParser returns the compiled form of a protocol's current parser, compiling it if needed
+func (m *ParserManager) compiledParser(protocolID string) (compiledParser, error) {
+	m.mu.RLock()
+	code, exists := m.cache[protocolID]
+	m.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("no parser found for %s. Please trigger AI generation", protocolID)
+	}
+	return m.engine.load(protocolID, code)
+}
+
 // Manifest represents the persistent mapping of signatures to parser IDs
 type Manifest struct {
-	Bindings map[string]string `json:"bindings"`
+	Bindings       map[string]string `json:"bindings"`
+	LengthBindings []LengthBinding   `json:"length_bindings,omitempty"` // Prefix bindings constrained to a frame length
+	MaskedBindings []MaskedBinding   `json:"masked_bindings,omitempty"` // Bit-masked bindings in registration order
+	Disabled       []string          `json:"disabled,omitempty"`        // Protocols bound but not parsed, e.g. quarantined
+	Aliases        map[string]string `json:"aliases,omitempty"`         // ProtocolID -> human-friendly name
 }
 
-// SaveManifest writes the current dispatcher bindings to a JSON file
+// SetAlias gives a protocol a human-friendly name and persists it in the manifest.
+// An empty alias removes it.
+func (m *ParserManager) SetAlias(protocolID, alias string) error {
+	alias = strings.TrimSpace(alias)
+	m.mu.Lock()
+	if _, ok := m.cache[protocolID]; !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("no parser found for %s", protocolID)
+	}
+	if alias == "" {
+		delete(m.aliases, protocolID)
+	} else {
+		m.aliases[protocolID] = alias
+	}
+	m.mu.Unlock()
+
+	m.manifest.mu.Lock()
+	defer m.manifest.mu.Unlock()
+
+	// Keep whatever bindings are (or are about to be) on disk
+	manifest, err := m.currentManifestLocked()
+	if err != nil {
+		return err
+	}
+	m.manifest.stopLocked()
+	return m.writeManifestLocked(manifest)
+}
+
+// GetAlias returns the friendly name of a protocol, if one is set
+func (m *ParserManager) GetAlias(protocolID string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	alias, ok := m.aliases[protocolID]
+	return alias, ok
+}
+
+// Aliases returns a snapshot of all protocol aliases
+func (m *ParserManager) Aliases() map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	aliases := make(map[string]string, len(m.aliases))
+	for id, alias := range m.aliases {
+		aliases[id] = alias
+	}
+	return aliases
+}
+
+// SaveManifest writes the signature bindings to a JSON file immediately, superseding any
+// queued update. The length and masked bindings and disabled protocols already recorded are
+// kept; Dispatcher.SaveManifest writes the dispatcher's whole state.
 func (m *ParserManager) SaveManifest(bindings map[string]string) error {
-	manifest := Manifest{Bindings: bindings}
+	m.manifest.mu.Lock()
+	defer m.manifest.mu.Unlock()
+	manifest, err := m.currentManifestLocked()
+	if err != nil {
+		return err
+	}
+	manifest.Bindings = bindings
+	return m.saveManifestLocked(manifest)
+}
+
+// saveManifest writes the bindings of manifest immediately, superseding any queued update
+func (m *ParserManager) saveManifest(manifest Manifest) error {
+	m.manifest.mu.Lock()
+	defer m.manifest.mu.Unlock()
+	return m.saveManifestLocked(manifest)
+}
+
+func (m *ParserManager) saveManifestLocked(manifest Manifest) error {
+	m.manifest.stopLocked()
+	return m.writeManifestLocked(manifest)
+}
+
+// QueueManifest schedules the signature bindings to be written after the flush delay,
+// keeping the length and masked bindings and disabled protocols already recorded. Updates queued before the write happens
+// are coalesced; only the latest is written.
+func (m *ParserManager) QueueManifest(bindings map[string]string) {
+	m.manifest.mu.Lock()
+	defer m.manifest.mu.Unlock()
+	manifest, err := m.currentManifestLocked()
+	if err != nil {
+		logger.Warn("Failed to read manifest, queuing signature bindings only", zap.Error(err))
+	}
+	manifest.Bindings = bindings
+	m.queueManifestLocked(manifest)
+}
+
+// queueManifest schedules the bindings of manifest to be written after the flush delay
+func (m *ParserManager) queueManifest(manifest Manifest) {
+	m.manifest.mu.Lock()
+	defer m.manifest.mu.Unlock()
+	m.queueManifestLocked(manifest)
+}
+
+func (m *ParserManager) queueManifestLocked(manifest Manifest) {
+	m.manifest.pending = &manifest
+	if m.manifest.timer == nil {
+		m.manifest.timer = time.AfterFunc(m.manifest.delay, func() {
+			if err := m.FlushManifest(); err != nil {
+				logger.Error("Failed to save manifest", zap.Error(err))
+			}
+		})
+	}
+}
+
+// FlushManifest writes any queued manifest update right away. Call it before exiting.
+func (m *ParserManager) FlushManifest() error {
+	m.manifest.mu.Lock()
+	defer m.manifest.mu.Unlock()
+
+	manifest := m.manifest.pending
+	m.manifest.stopLocked()
+	if manifest == nil {
+		return nil
+	}
+	return m.writeManifestLocked(*manifest)
+}
+
+// SetManifestFlushDelay changes how long queued manifest updates are coalesced.
+// A non-positive value restores DefaultManifestFlushDelay.
+func (m *ParserManager) SetManifestFlushDelay(d time.Duration) {
+	if d <= 0 {
+		d = DefaultManifestFlushDelay
+	}
+	m.manifest.mu.Lock()
+	defer m.manifest.mu.Unlock()
+	m.manifest.delay = d
+}
+
+// stopLocked cancels the pending flush and drops the queued bindings
+func (q *manifestQueue) stopLocked() {
+	if q.timer != nil {
+		q.timer.Stop()
+		q.timer = nil
+	}
+	q.pending = nil
+}
+
+// currentManifestLocked returns the bindings queued, last written or else on disk
+func (m *ParserManager) currentManifestLocked() (Manifest, error) {
+	switch {
+	case m.manifest.pending != nil:
+		return *m.manifest.pending, nil
+	case m.manifest.last != nil:
+		return *m.manifest.last, nil
+	}
+	manifest, err := m.readManifest()
+	if err != nil {
+		return Manifest{}, err
+	}
+	return *manifest, nil
+}
+
+func (m *ParserManager) writeManifestLocked(manifest Manifest) error {
+	m.manifest.writes++
+	manifest.Aliases = m.Aliases()
 	data, err := json.MarshalIndent(manifest, "", "  ")
 	if err != nil {
 		return err
 	}
 
 	path := filepath.Join(m.storagePath, "manifest.json")
-	return os.WriteFile(path, data, 0o644)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	m.manifest.last = &manifest
+	return nil
 }
 
-// LoadManifest reads the manifest.json and returns the bindings
+// LoadManifest reads the manifest.json and returns the bindings. Hand-edited signatures
+// (lower-case, padded, 0x-prefixed) are normalized so they key the same as code-declared ones.
 func (m *ParserManager) LoadManifest() (map[string]string, error) {
+	manifest, err := m.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	bindings := make(map[string]string, len(manifest.Bindings))
+	for sig, id := range manifest.Bindings {
+		canonical := normalizeSignature(sig)
+		if canonical == "" {
+			logger.Warn("Skipping invalid signature in manifest", zap.String("signature", sig), zap.String("protocol", id))
+			continue
+		}
+		bindings[canonical] = id
+	}
+	return bindings, nil
+}
+
+// LoadLengthBindings reads the length bindings recorded in manifest.json, normalizing
+// their signatures like LoadManifest
+func (m *ParserManager) LoadLengthBindings() ([]LengthBinding, error) {
+	manifest, err := m.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	bindings := make([]LengthBinding, 0, len(manifest.LengthBindings))
+	for _, lb := range manifest.LengthBindings {
+		canonical := normalizeSignature(lb.Signature)
+		if canonical == "" {
+			logger.Warn("Skipping invalid length binding in manifest", zap.String("signature", lb.Signature), zap.String("protocol", lb.Protocol))
+			continue
+		}
+		lb.Signature = canonical
+		bindings = append(bindings, lb)
+	}
+	return bindings, nil
+}
+
+// LoadMaskedBindings reads the masked bindings recorded in manifest.json in registration order
+func (m *ParserManager) LoadMaskedBindings() ([]MaskedBinding, error) {
+	manifest, err := m.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	return manifest.MaskedBindings, nil
+}
+
+// LoadDisabled reads the protocols recorded as disabled in manifest.json
+func (m *ParserManager) LoadDisabled() ([]string, error) {
+	manifest, err := m.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Disabled, nil
+}
+
+// LoadAliases restores the protocol aliases recorded in manifest.json
+func (m *ParserManager) LoadAliases() error {
+	manifest, err := m.readManifest()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, alias := range manifest.Aliases {
+		m.aliases[id] = alias
+	}
+	return nil
+}
+
+func (m *ParserManager) readManifest() (*Manifest, error) {
 	path := filepath.Join(m.storagePath, "manifest.json")
 
 	// If file doesn't exist, return empty map (common on first run)
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return make(map[string]string), nil
+		return &Manifest{Bindings: make(map[string]string)}, nil
 	}
 
 	data, err := os.ReadFile(path)
@@ -158,6 +787,5 @@ func (m *ParserManager) LoadManifest() (map[string]string, error) {
 	if err := json.Unmarshal(data, &manifest); err != nil {
 		return nil, err
 	}
-
-	return manifest.Bindings, nil
+	return &manifest, nil
 }
@@ -1,9 +1,16 @@
 package parser
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 func TestParserManager_RegisterAndLoad(t *testing.T) {
@@ -30,7 +37,7 @@ func Parse(data []byte) map[string]interface{} {
 	}
 
 	// 2. Verify file exists
-	expectedPath := filepath.Join(tmpDir, protoID+".go")
+	expectedPath := filepath.Join(tmpDir, protoID, "v1.go")
 	if _, err := os.Stat(expectedPath); os.IsNotExist(err) {
 		t.Errorf("Parser file was not created at %s", expectedPath)
 	}
@@ -59,6 +66,58 @@ func Parse(data []byte) map[string]interface{} {
 	}
 }
 
+func TestParserManager_SeedFromFS(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "manager_seed_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	seedFS := fstest.MapFS{
+		"Demo.go": {Data: []byte(`// Signature: 7E
+package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": int(data[1])} }`)},
+		"Edited.go":       {Data: []byte("// Signature: 7F\npackage dynamic\n// seed version\n")},
+		"docs/README.txt": {Data: []byte("not a parser")},
+		"embed.go":        {Data: []byte("package seeds\n\nimport \"embed\"\n")},
+	}
+	edited := "// Signature: 7F\npackage dynamic\n// edited by the operator\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "Edited.go"), []byte(edited), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	mgr := NewParserManagerWithFS(tmpDir, seedFS)
+	if err := mgr.SeedParsers(); err != nil {
+		t.Fatalf("SeedParsers failed: %v", err)
+	}
+	bindings, err := mgr.LoadSavedParsers()
+	if err != nil {
+		t.Fatalf("LoadSavedParsers failed: %v", err)
+	}
+	if bindings["Demo"] != "7E" {
+		t.Errorf("expected the embedded seed to be copied and bound, got %v", bindings)
+	}
+	if res, err := mgr.ParseData("Demo", []byte{0x7E, 0x05}); err != nil || res["v"] != 5 {
+		t.Errorf("ParseData = %v, %v", res, err)
+	}
+
+	// Files already in storage are left alone; seed subdirectories and non-parser files are skipped
+	if code, _ := os.ReadFile(filepath.Join(tmpDir, "Edited.go")); string(code) != edited {
+		t.Errorf("seeding overwrote an existing parser: %q", code)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "docs")); !os.IsNotExist(err) {
+		t.Errorf("expected seed subdirectories to be skipped, got %v", err)
+	}
+	if _, exists := bindings["embed"]; exists {
+		t.Error("expected a seed file outside package dynamic to be skipped")
+	}
+
+	// A manager without seeds seeds nothing
+	if err := NewParserManagerWithFS(tmpDir, nil).SeedParsers(); err != nil {
+		t.Errorf("SeedParsers without seeds failed: %v", err)
+	}
+}
+
 func TestParserManager_Manifest(t *testing.T) {
 	tmpDir, _ := os.MkdirTemp("", "manifest_test")
 	defer func() { _ = os.RemoveAll(tmpDir) }()
@@ -90,6 +149,64 @@ func TestParserManager_Manifest(t *testing.T) {
 	}
 }
 
+func TestParserManager_Aliases(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "alias_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	code := "package dynamic\nfunc Parse(data []byte) map[string]interface{} { return nil }"
+	if err := mgr.RegisterParser("auto_proto_0x55AA", code); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	bindings := map[string]string{"55AA": "auto_proto_0x55AA"}
+	if err := mgr.SaveManifest(bindings); err != nil {
+		t.Fatalf("SaveManifest failed: %v", err)
+	}
+
+	if err := mgr.SetAlias("auto_proto_0x55AA", "Smart Meter"); err != nil {
+		t.Fatalf("SetAlias failed: %v", err)
+	}
+	if err := mgr.SetAlias("missing", "Nope"); err == nil {
+		t.Error("Expected error aliasing an unknown protocol")
+	}
+	if alias, ok := mgr.GetAlias("auto_proto_0x55AA"); !ok || alias != "Smart Meter" {
+		t.Errorf("GetAlias = %q, %v", alias, ok)
+	}
+
+	// Bindings stay intact and later writes keep the alias
+	if err := mgr.SaveManifest(bindings); err != nil {
+		t.Fatalf("SaveManifest failed: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(tmpDir, "manifest.json"))
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Invalid manifest: %v", err)
+	}
+	if manifest.Bindings["55AA"] != "auto_proto_0x55AA" || manifest.Aliases["auto_proto_0x55AA"] != "Smart Meter" {
+		t.Errorf("Unexpected manifest: %+v", manifest)
+	}
+
+	// A fresh manager picks the alias up again
+	reloaded := NewParserManager(tmpDir, "")
+	if err := reloaded.LoadAliases(); err != nil {
+		t.Fatalf("LoadAliases failed: %v", err)
+	}
+	if alias, _ := reloaded.GetAlias("auto_proto_0x55AA"); alias != "Smart Meter" {
+		t.Errorf("Expected alias after reload, got %q", alias)
+	}
+	if loaded, _ := reloaded.LoadManifest(); !reflect.DeepEqual(loaded, bindings) {
+		t.Errorf("Bindings changed: %v", loaded)
+	}
+
+	// Clearing the alias removes it from the manifest
+	if err := mgr.SetAlias("auto_proto_0x55AA", ""); err != nil {
+		t.Fatalf("SetAlias failed: %v", err)
+	}
+	if _, ok := mgr.GetAlias("auto_proto_0x55AA"); ok {
+		t.Error("Expected alias to be cleared")
+	}
+}
+
 func TestParserManager_Manifest_Empty(t *testing.T) {
 	tmpDir, _ := os.MkdirTemp("", "manifest_empty_test")
 	defer func() { _ = os.RemoveAll(tmpDir) }()
@@ -106,3 +223,355 @@ func TestParserManager_Manifest_Empty(t *testing.T) {
 		t.Errorf("Expected empty bindings, got %d", len(loadedBindings))
 	}
 }
+
+func TestParserManager_Versioning(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "versioning_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	d := NewDispatcher(mgr)
+
+	v1 := `package dynamic
+// Signature: 0A
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"version": 1} }`
+	v2 := `package dynamic
+// Signature: 0B
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"version": 2} }`
+
+	if err := mgr.RegisterParser("proto", v1); err != nil {
+		t.Fatalf("RegisterParser v1 failed: %v", err)
+	}
+	d.Bind([]byte{0x0A}, "proto")
+	if res, _, err := d.Ingest([]byte{0x0A}); err != nil || res["version"] != 1 {
+		t.Fatalf("Expected version 1 result, got %v (err: %v)", res, err)
+	}
+
+	// A repair registers a new version without losing the old one
+	if err := mgr.RegisterParser("proto", v2); err != nil {
+		t.Fatalf("RegisterParser v2 failed: %v", err)
+	}
+	versions, err := mgr.ListVersions("proto")
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if len(versions) != 2 || versions[0] != 1 || versions[1] != 2 {
+		t.Errorf("Expected versions [1 2], got %v", versions)
+	}
+	if code, _ := mgr.GetParserCode("proto"); code != v2 {
+		t.Error("Expected GetParserCode to return the current version")
+	}
+	if res, _, _ := d.Ingest([]byte{0x0A}); res["version"] != 2 {
+		t.Errorf("Expected recompiled version 2 result, got %v", res)
+	}
+
+	// Roll back to v1 and confirm it is re-bound and served
+	if err := mgr.RollbackParser("proto"); err != nil {
+		t.Fatalf("RollbackParser failed: %v", err)
+	}
+	if code, _ := mgr.GetParserCode("proto"); code != v1 {
+		t.Error("Expected GetParserCode to return v1 after rollback")
+	}
+	if res, proto, err := d.Ingest([]byte{0x0A}); err != nil || proto != "proto" || res["version"] != 1 {
+		t.Errorf("Expected version 1 after rollback, got %v from %s (err: %v)", res, proto, err)
+	}
+
+	// Nothing before v1
+	if err := mgr.RollbackParser("proto"); err == nil {
+		t.Error("Expected error rolling back past the first version")
+	}
+
+	// A restart picks up the rolled-back version
+	mgr2 := NewParserManager(tmpDir, "")
+	bindings, err := mgr2.LoadSavedParsers()
+	if err != nil {
+		t.Fatalf("LoadSavedParsers failed: %v", err)
+	}
+	if bindings["proto"] != "0A" {
+		t.Errorf("Expected signature 0A after reload, got %s", bindings["proto"])
+	}
+}
+
+func TestParserManager_LoadFlatAndMigrate(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "flat_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	flat := `package dynamic
+// Signature: 41
+func Parse(data []byte) map[string]interface{} { return nil }`
+	if err := os.WriteFile(filepath.Join(tmpDir, "legacy.go"), []byte(flat), 0o644); err != nil {
+		t.Fatalf("Failed to write flat parser: %v", err)
+	}
+
+	mgr := NewParserManager(tmpDir, "")
+	bindings, err := mgr.LoadSavedParsers()
+	if err != nil {
+		t.Fatalf("LoadSavedParsers failed: %v", err)
+	}
+	if bindings["legacy"] != "41" {
+		t.Errorf("Expected flat parser to load with signature 41, got %v", bindings)
+	}
+	if versions, err := mgr.ListVersions("legacy"); err != nil || len(versions) != 0 {
+		t.Errorf("Expected no version history for flat parser, got %v (err: %v)", versions, err)
+	}
+
+	// Registering over a flat parser keeps it as v1
+	if err := mgr.RegisterParser("legacy", flat+"\n"); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	versions, _ := mgr.ListVersions("legacy")
+	if len(versions) != 2 {
+		t.Errorf("Expected flat parser migrated to v1 plus new v2, got %v", versions)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "legacy.go")); !os.IsNotExist(err) {
+		t.Error("Expected flat parser file to be removed after migration")
+	}
+}
+
+func TestParserManager_QueueManifestDebounce(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "manifest_queue_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	mgr.SetManifestFlushDelay(20 * time.Millisecond)
+
+	mgr.QueueManifest(map[string]string{"01": "a"})
+	mgr.QueueManifest(map[string]string{"01": "a", "02": "b"})
+	mgr.QueueManifest(map[string]string{"01": "a", "02": "b", "03": "c"})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		// The file may be mid-write; just poll again
+		manifest, err := mgr.LoadManifest()
+		if err == nil && len(manifest) == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Queued manifest was never flushed, got %v", manifest)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	mgr.manifest.mu.Lock()
+	defer mgr.manifest.mu.Unlock()
+	if mgr.manifest.writes != 1 {
+		t.Errorf("Expected a single coalesced write, got %d", mgr.manifest.writes)
+	}
+}
+
+func TestParserManager_Diff(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "diff_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	v1 := "package dynamic\nfunc Parse(data []byte) map[string]interface{} {\n\treturn map[string]interface{}{\"rpm\": int(data[2])}\n}\n"
+	v2 := "package dynamic\nfunc Parse(data []byte) map[string]interface{} {\n\treturn map[string]interface{}{\"rpm\": (int(data[2])*256 + int(data[3])) / 4}\n}\n"
+	if err := mgr.RegisterParser("rpm", v1); err != nil {
+		t.Fatalf("RegisterParser v1 failed: %v", err)
+	}
+	if err := mgr.RegisterParser("rpm", v2); err != nil {
+		t.Fatalf("RegisterParser v2 failed: %v", err)
+	}
+
+	diff, err := mgr.Diff("rpm", 1, 2)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	for _, want := range []string{
+		"--- rpm/v1.go",
+		"+++ rpm/v2.go",
+		"-\treturn map[string]interface{}{\"rpm\": int(data[2])}",
+		"+\treturn map[string]interface{}{\"rpm\": (int(data[2])*256 + int(data[3])) / 4}",
+	} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("Diff missing %q:\n%s", want, diff)
+		}
+	}
+	if strings.Contains(diff, "-package dynamic") {
+		t.Errorf("Unchanged lines should only appear as context:\n%s", diff)
+	}
+
+	if _, err := mgr.Diff("rpm", 1, 3); err == nil {
+		t.Error("Expected error for a missing version")
+	}
+	if _, err := mgr.Diff("unknown", 1, 2); err == nil {
+		t.Error("Expected error for an unknown protocol")
+	}
+}
+
+func TestParserManager_WatchParsers(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_watch")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	d := NewDispatcher(mgr)
+	v1 := `package dynamic
+// Signature: 7A
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": 1} }`
+	if err := mgr.RegisterParser("watched", v1); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d.bindFromCode("watched", v1)
+	if res, _, err := d.Ingest([]byte{0x7A, 0x00}); err != nil || res["v"] != 1 {
+		t.Fatalf("Ingest = %v, %v", res, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := mgr.WatchParsers(ctx); err != nil {
+		t.Fatalf("WatchParsers failed: %v", err)
+	}
+
+	// Edit the current version by hand, in two quick writes, and move the signature
+	v2 := `package dynamic
+// Signature: 7B
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": 2} }`
+	path := mgr.versionPath("watched", 1)
+	if err := os.WriteFile(path, []byte(v2[:20]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(v2), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		res, proto, err := d.Ingest([]byte{0x7B, 0x00})
+		if err == nil && proto == "watched" && res["v"] == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("edited parser never took effect: %v, %q, %v", res, proto, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if _, proto, _ := d.Ingest([]byte{0x7A, 0x00}); proto != "" {
+		t.Errorf("old signature still routed to %q after reload", proto)
+	}
+}
+
+func TestParserManager_SignatureWhitespaceNormalized(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "manager_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	sloppy := "package dynamic\n// Signature: 0x1aB \t\r\nfunc Parse(data []byte) map[string]interface{} { return nil }\n"
+	canonical := "package dynamic\n// Signature: 01AB\nfunc Parse(data []byte) map[string]interface{} { return nil }\n"
+	if err := mgr.RegisterParser("sloppy", sloppy); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	if err := mgr.RegisterParser("canonical", canonical); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	// A hand-edited manifest entry for the same signature
+	manifest := `{"bindings": {" 0x01ab ": "sloppy", "zz": "broken"}}`
+	if err := os.WriteFile(filepath.Join(tmpDir, "manifest.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := NewParserManager(tmpDir, "").LoadSavedParsers()
+	if err != nil {
+		t.Fatalf("LoadSavedParsers failed: %v", err)
+	}
+	if loaded["sloppy"] != "01AB" || loaded["sloppy"] != loaded["canonical"] {
+		t.Errorf("signatures differ: sloppy=%q canonical=%q", loaded["sloppy"], loaded["canonical"])
+	}
+	if got := declaredSignatures(sloppy); len(got) != 1 || !bytes.Equal(got[0], []byte{0x01, 0xAB}) {
+		t.Errorf("declaredSignatures = %X, want [01AB]", got)
+	}
+
+	bindings, err := mgr.LoadManifest()
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	if !reflect.DeepEqual(bindings, map[string]string{"01AB": "sloppy"}) {
+		t.Errorf("LoadManifest = %v, want the entry keyed by 01AB and the invalid one dropped", bindings)
+	}
+}
+
+func TestParserManager_ParserMeta(t *testing.T) {
+	tmpDir := t.TempDir()
+	mgr := NewParserManager(tmpDir, "")
+	code := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": int(data[1])} }`
+
+	discovered := ParserMeta{ContextHint: "Door sensor", Provider: "ollama", Model: "llama3", Author: "ollama/llama3"}
+	if err := mgr.RegisterParserWithMeta("door", code, discovered); err != nil {
+		t.Fatalf("RegisterParserWithMeta failed: %v", err)
+	}
+	first, ok := mgr.GetParserMeta("door")
+	if !ok || first.CreatedAt.IsZero() || first.Repaired {
+		t.Fatalf("unexpected metadata after discovery: %+v, %v", first, ok)
+	}
+
+	// A repair keeps the origin and creation time but is recorded
+	if err := mgr.RegisterParserWithMeta("door", code+"\n", ParserMeta{Repaired: true}); err != nil {
+		t.Fatalf("RegisterParserWithMeta failed: %v", err)
+	}
+
+	// Round trip through disk
+	reloaded := NewParserManager(tmpDir, "")
+	if _, err := reloaded.LoadSavedParsers(); err != nil {
+		t.Fatalf("LoadSavedParsers failed: %v", err)
+	}
+	meta, ok := reloaded.GetParserMeta("door")
+	if !ok {
+		t.Fatal("metadata not found after reload")
+	}
+	if meta.ContextHint != "Door sensor" || meta.Author != "ollama/llama3" || meta.Model != "llama3" || !meta.Repaired {
+		t.Errorf("unexpected metadata after repair: %+v", meta)
+	}
+	if !meta.CreatedAt.Equal(first.CreatedAt) || meta.UpdatedAt.Before(first.UpdatedAt) {
+		t.Errorf("timestamps: created %v -> %v, updated %v -> %v", first.CreatedAt, meta.CreatedAt, first.UpdatedAt, meta.UpdatedAt)
+	}
+
+	// Parsers dropped in by hand have no metadata and still load
+	if err := os.WriteFile(filepath.Join(tmpDir, "manual.go"), []byte(code), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fresh := NewParserManager(tmpDir, "")
+	if _, err := fresh.LoadSavedParsers(); err != nil {
+		t.Fatalf("LoadSavedParsers failed: %v", err)
+	}
+	if _, ok := fresh.GetParserCode("manual"); !ok {
+		t.Fatal("manual parser not loaded")
+	}
+	if _, ok := fresh.GetParserMeta("manual"); ok {
+		t.Error("expected no metadata for a hand-written parser")
+	}
+
+	if err := reloaded.DeleteParser("door"); err != nil {
+		t.Fatalf("DeleteParser failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "door"+metaFileSuffix)); !os.IsNotExist(err) {
+		t.Error("metadata sidecar left behind after DeleteParser")
+	}
+}
+
+func TestParserManager_RollbackRebindsEveryDispatcher(t *testing.T) {
+	mgr := NewParserManager(t.TempDir(), "")
+	first, second := NewDispatcher(mgr), NewDispatcher(mgr)
+
+	v1 := "package dynamic\n// Signature: 0A\nfunc Parse(data []byte) map[string]interface{} { return map[string]interface{}{\"version\": 1} }"
+	v2 := "package dynamic\n// Signature: 0B\nfunc Parse(data []byte) map[string]interface{} { return map[string]interface{}{\"version\": 2} }"
+	for _, code := range []string{v1, v2} {
+		if err := mgr.RegisterParser("proto", code); err != nil {
+			t.Fatalf("RegisterParser failed: %v", err)
+		}
+	}
+	for _, d := range []*Dispatcher{first, second} {
+		d.bindFromCode("proto", v2)
+	}
+
+	if err := mgr.RollbackParser("proto"); err != nil {
+		t.Fatalf("RollbackParser failed: %v", err)
+	}
+	for i, d := range []*Dispatcher{first, second} {
+		if _, proto, err := d.Ingest([]byte{0x0A}); err != nil || proto != "proto" {
+			t.Errorf("dispatcher %d: expected 0A rebound after rollback, got %q (err: %v)", i, proto, err)
+		}
+		if _, _, err := d.Ingest([]byte{0x0B}); err == nil {
+			t.Errorf("dispatcher %d: expected 0B unbound after rollback", i)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package parser
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"go.uber.org/zap"
+)
+
+// MaskPolicy decides which masked binding wins when a frame matches several
+type MaskPolicy string
+
+const (
+	// MaskFirstRegistered picks the binding that was bound first
+	MaskFirstRegistered MaskPolicy = "first-registered"
+	// MaskMostSpecific picks the binding whose mask fixes the most bits
+	MaskMostSpecific MaskPolicy = "most-specific"
+	// MaskHighestPriority picks the binding with the highest priority
+	MaskHighestPriority MaskPolicy = "highest-priority"
+)
+
+// ParseMaskPolicy validates a policy name; an empty name is MaskFirstRegistered
+func ParseMaskPolicy(s string) (MaskPolicy, error) {
+	switch p := MaskPolicy(s); p {
+	case "":
+		return MaskFirstRegistered, nil
+	case MaskFirstRegistered, MaskMostSpecific, MaskHighestPriority:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown mask policy %q (want %s, %s or %s)", s, MaskFirstRegistered, MaskMostSpecific, MaskHighestPriority)
+}
+
+// MaskedBinding routes frames whose leading bytes equal Signature in every bit set in Mask
+type MaskedBinding struct {
+	Signature string `json:"signature"` // Hex
+	Mask      string `json:"mask"`      // Hex, same length as Signature
+	Protocol  string `json:"protocol"`
+	Priority  int    `json:"priority,omitempty"` // Used by MaskHighestPriority
+}
+
+type maskedBinding struct {
+	signature []byte
+	mask      []byte
+	protocol  string
+	priority  int
+	fixedBits int // Bits set in mask
+}
+
+func (b *maskedBinding) matches(key []byte) bool {
+	if len(key) < len(b.signature) {
+		return false
+	}
+	for i, m := range b.mask {
+		if key[i]&m != b.signature[i]&m {
+			return false
+		}
+	}
+	return true
+}
+
+// BindMasked links a signature to a parser, comparing only the bits set in mask
+// (e.g. signature 0x40 with mask 0xF0 matches any leading byte 0x40-0x4F). Masked
+// bindings are consulted only when no exact prefix binding matches; when several
+// qualify, the dispatcher's MaskPolicy picks the winner. Binding the same signature
+// and mask again replaces the earlier binding in place.
+func (d *Dispatcher) BindMasked(signature, mask []byte, protocolID string, priority int) error {
+	if len(signature) == 0 || len(signature) != len(mask) {
+		return fmt.Errorf("masked binding needs a signature and a mask of the same non-zero length")
+	}
+	b := maskedBinding{
+		signature: append([]byte(nil), signature...),
+		mask:      append([]byte(nil), mask...),
+		protocol:  protocolID,
+		priority:  priority,
+	}
+	for _, m := range mask {
+		b.fixedBits += bits.OnesCount8(m)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, existing := range d.masked {
+		if string(existing.signature) == string(b.signature) && string(existing.mask) == string(b.mask) {
+			d.masked[i] = b
+			return nil
+		}
+	}
+	d.masked = append(d.masked, b)
+	return nil
+}
+
+// BytePattern is one position of a pattern binding: an exact byte, or any byte when Any is set
+type BytePattern struct {
+	Value byte
+	Any   bool
+}
+
+// Exact returns a BytePattern matching only b
+func Exact(b byte) BytePattern { return BytePattern{Value: b} }
+
+// AnyByte matches every byte value, e.g. a device address in the middle of a header
+var AnyByte = BytePattern{Any: true}
+
+// BindPattern links a byte pattern to a parser, so frames differing only in wildcard
+// positions share one protocol: {Exact(0xAA), AnyByte, Exact(0x10)} matches AA 01 10,
+// AA 02 10, ... It is a masked binding with each position fully fixed or fully free, so
+// the trie is still tried first and MaskPolicy resolves overlaps.
+func (d *Dispatcher) BindPattern(pattern []BytePattern, protocolID string) error {
+	signature := make([]byte, len(pattern))
+	mask := make([]byte, len(pattern))
+	fixed := false
+	for i, p := range pattern {
+		if !p.Any {
+			signature[i], mask[i], fixed = p.Value, 0xFF, true
+		}
+	}
+	if !fixed {
+		return fmt.Errorf("pattern for %s needs at least one exact byte", protocolID)
+	}
+	return d.BindMasked(signature, mask, protocolID, 0)
+}
+
+// SetMaskPolicy sets how a frame matching several masked bindings is resolved
+func (d *Dispatcher) SetMaskPolicy(p MaskPolicy) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.maskPolicy = p
+}
+
+// bindMaskedBindings binds every masked binding in order, skipping (and logging) invalid ones from source
+func (d *Dispatcher) bindMaskedBindings(bindings []MaskedBinding, source string) {
+	for _, mb := range bindings {
+		sig, sigErr := hex.DecodeString(mb.Signature)
+		mask, maskErr := hex.DecodeString(mb.Mask)
+		if sigErr != nil || maskErr != nil || d.BindMasked(sig, mask, mb.Protocol, mb.Priority) != nil {
+			logger.Warn("Skipping invalid masked binding in "+source, zap.String("signature", mb.Signature), zap.String("protocol", mb.Protocol))
+		}
+	}
+}
+
+// GetMaskedBindings returns the masked bindings in registration order
+func (d *Dispatcher) GetMaskedBindings() []MaskedBinding {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	bindings := make([]MaskedBinding, 0, len(d.masked))
+	for _, b := range d.masked {
+		bindings = append(bindings, MaskedBinding{
+			Signature: fmt.Sprintf("%X", b.signature),
+			Mask:      fmt.Sprintf("%X", b.mask),
+			Protocol:  b.protocol,
+			Priority:  b.priority,
+		})
+	}
+	return bindings
+}
+
+// matchMaskedLocked returns the protocol of the masked binding that wins for key, if any.
+// Ties under every policy go to the binding registered first, so the winner is deterministic.
+func (d *Dispatcher) matchMaskedLocked(key []byte) string {
+	var winner *maskedBinding
+	var candidates []string
+	for i := range d.masked {
+		b := &d.masked[i]
+		if !b.matches(key) {
+			continue
+		}
+		candidates = append(candidates, b.protocol)
+		if winner == nil || d.maskBeats(b, winner) {
+			winner = b
+		}
+	}
+	if winner == nil {
+		return ""
+	}
+	if len(candidates) > 1 {
+		d.logAmbiguous(key, winner.protocol, candidates)
+	}
+	return winner.protocol
+}
+
+// maskBeats reports whether b wins over the current (earlier registered) winner
+func (d *Dispatcher) maskBeats(b, current *maskedBinding) bool {
+	switch d.maskPolicy {
+	case MaskMostSpecific:
+		return b.fixedBits > current.fixedBits
+	case MaskHighestPriority:
+		return b.priority > current.priority
+	default:
+		return false
+	}
+}
+
+// logAmbiguous warns once per set of overlapping bindings rather than on every frame
+func (d *Dispatcher) logAmbiguous(key []byte, winner string, candidates []string) {
+	set := strings.Join(candidates, ",")
+	if _, seen := d.ambiguous.LoadOrStore(set, true); seen {
+		return
+	}
+	maxLen := 4
+	if len(key) < maxLen {
+		maxLen = len(key)
+	}
+	logger.Warn("Frame matches several masked bindings",
+		zap.String("signature", fmt.Sprintf("%X", key[:maxLen])), zap.Strings("candidates", candidates),
+		zap.String("policy", string(d.maskPolicy)), zap.String("winner", winner))
+}
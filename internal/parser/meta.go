@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"go.uber.org/zap"
+)
+
+// metaFileSuffix names a parser's metadata sidecar: storage/<id>.meta.json
+const metaFileSuffix = ".meta.json"
+
+// ParserMeta records where a parser came from. It is optional: parsers added by hand
+// have none until they are registered through the manager.
+type ParserMeta struct {
+	Description string    `json:"description,omitempty"`
+	Author      string    `json:"author,omitempty"`       // Who wrote it; "provider/model" for generated parsers
+	ContextHint string    `json:"context_hint,omitempty"` // Protocol hint the parser was discovered with
+	Provider    string    `json:"provider,omitempty"`     // LLM provider that generated it
+	Model       string    `json:"model,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Repaired    bool      `json:"repaired"`              // Set once any repair has replaced the generated code
+	RepairedBy  string    `json:"repaired_by,omitempty"` // "provider/model" of the latest LLM repair
+	Isolation   string    `json:"isolation,omitempty"`   // Interpreter isolation, see IsolationMode
+}
+
+// RegisterParserWithMeta is RegisterParser that also records meta. Fields left empty keep
+// their stored values, CreatedAt is kept from the first registration, and Repaired stays
+// set once a repair has been recorded.
+func (m *ParserManager) RegisterParserWithMeta(protocolID, code string, meta ParserMeta) error {
+	if _, err := ParseIsolationMode(meta.Isolation); err != nil {
+		return err
+	}
+	if err := m.checkStoredSchema(protocolID, code); err != nil {
+		return err
+	}
+	if err := m.registerParser(protocolID, code); err != nil {
+		return err
+	}
+	return m.updateMeta(protocolID, meta)
+}
+
+// GetParserMeta returns a protocol's metadata; false if it has none or it can't be read
+func (m *ParserManager) GetParserMeta(protocolID string) (ParserMeta, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	meta, err := m.readMetaLocked(protocolID)
+	if err != nil || meta == nil {
+		return ParserMeta{}, false
+	}
+	return *meta, true
+}
+
+// updateMeta merges meta into the stored sidecar and stamps the update time
+func (m *ParserManager) updateMeta(protocolID string, meta ParserMeta) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	stored, err := m.readMetaLocked(protocolID)
+	if err != nil || stored == nil {
+		// Unreadable sidecars are replaced rather than blocking registration
+		stored = &ParserMeta{CreatedAt: now}
+	}
+	merge := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	merge(&stored.Description, meta.Description)
+	merge(&stored.Author, meta.Author)
+	merge(&stored.ContextHint, meta.ContextHint)
+	merge(&stored.Provider, meta.Provider)
+	merge(&stored.Model, meta.Model)
+	merge(&stored.RepairedBy, meta.RepairedBy)
+	merge(&stored.Isolation, meta.Isolation)
+	stored.Repaired = stored.Repaired || meta.Repaired
+	stored.UpdatedAt = now
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(m.metaPath(protocolID), data, 0o644); err != nil {
+		return err
+	}
+	m.applyIsolation(protocolID, stored)
+	return nil
+}
+
+// SetParserIsolation records the interpreter isolation mode of a protocol's parser and
+// recompiles it on next use. Stateful parsers (package-level variables) need IsolationPooled.
+func (m *ParserManager) SetParserIsolation(protocolID string, mode IsolationMode) error {
+	if _, err := ParseIsolationMode(string(mode)); err != nil {
+		return err
+	}
+	if _, ok := m.GetParserCode(protocolID); !ok {
+		return fmt.Errorf("no parser found for %s", protocolID)
+	}
+	return m.updateMeta(protocolID, ParserMeta{Isolation: string(mode)})
+}
+
+// applyIsolation hands the isolation mode recorded in meta to the engine
+func (m *ParserManager) applyIsolation(protocolID string, meta *ParserMeta) {
+	mode, err := ParseIsolationMode(meta.Isolation)
+	if err == nil {
+		err = m.engine.SetIsolation(protocolID, mode)
+	}
+	if err != nil {
+		logger.Warn("Ignoring parser isolation", zap.String("protocol", protocolID), zap.Error(err))
+	}
+}
+
+// readMetaLocked returns nil without error when the protocol has no sidecar
+func (m *ParserManager) readMetaLocked(protocolID string) (*ParserMeta, error) {
+	data, err := os.ReadFile(m.metaPath(protocolID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var meta ParserMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("invalid metadata file for %s: %v", protocolID, err)
+	}
+	return &meta, nil
+}
+
+func (m *ParserManager) metaPath(protocolID string) string {
+	return filepath.Join(m.storagePath, protocolID+metaFileSuffix)
+}
@@ -0,0 +1,167 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"github.com/chuanjin/OmniBridge/internal/mqtt"
+	"go.uber.org/zap"
+)
+
+// DefaultMQTTResultTopic prefixes the topics MQTTBridge publishes parsed results to
+const DefaultMQTTResultTopic = "omnibridge/parsed"
+
+const (
+	mqttQueueSize      = 256             // Messages waiting for the pipeline before new ones are dropped
+	mqttReconnectDelay = 2 * time.Second // Pause between reconnection attempts
+)
+
+// MQTTConfig configures an MQTTBridge
+type MQTTConfig struct {
+	Broker      string // host:port
+	Topic       string // Subscription filter, may use '+' and '#' wildcards
+	ResultTopic string // Prefix of result topics (default DefaultMQTTResultTopic)
+	ClientID    string
+	Username    string
+	Password    string
+}
+
+// mqttMessage is a received message waiting for the pipeline
+type mqttMessage struct {
+	topic   string
+	payload []byte
+}
+
+// MQTTBridge subscribes to sensor topics and runs each message payload through the ingest
+// pipeline as one frame, with discovery and repair. A parsed frame from topic T is published
+// as a JSON ResultRecord to "<ResultTopic>/T"; frames that fail are only logged. Messages
+// under ResultTopic are ignored, so a wide filter such as "#" doesn't feed results back in.
+type MQTTBridge struct {
+	dispatcher *Dispatcher
+	discovery  *DiscoveryService
+	cfg        MQTTConfig
+}
+
+func NewMQTTBridge(d *Dispatcher, disc *DiscoveryService, cfg MQTTConfig) *MQTTBridge {
+	if cfg.ResultTopic == "" {
+		cfg.ResultTopic = DefaultMQTTResultTopic
+	}
+	cfg.ResultTopic = strings.TrimSuffix(cfg.ResultTopic, "/")
+	return &MQTTBridge{dispatcher: d, discovery: disc, cfg: cfg}
+}
+
+// Run bridges until ctx is cancelled. A failed first connection or a refused subscription is
+// returned as an error; once subscribed, a lost connection is retried every few seconds.
+func (b *MQTTBridge) Run(ctx context.Context) error {
+	client, err := b.connect(ctx)
+	if err != nil {
+		return err
+	}
+	for {
+		err := b.session(ctx, client)
+		_ = client.Close()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		logger.Warn("MQTT connection lost, reconnecting", zap.String("broker", b.cfg.Broker), zap.Error(client.Err()))
+
+		client = nil
+		for client == nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(mqttReconnectDelay):
+			}
+			if client, err = b.connect(ctx); err != nil {
+				logger.Warn("MQTT reconnect failed", zap.String("broker", b.cfg.Broker), zap.Error(err))
+			}
+		}
+	}
+}
+
+// connect dials the broker with the configured credentials
+func (b *MQTTBridge) connect(ctx context.Context) (*mqtt.Client, error) {
+	client, err := mqtt.Dial(ctx, b.cfg.Broker, mqtt.Options{
+		ClientID: b.cfg.ClientID,
+		Username: b.cfg.Username,
+		Password: b.cfg.Password,
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Connected to MQTT broker", zap.String("broker", b.cfg.Broker), zap.String("topic", b.cfg.Topic))
+	return client, nil
+}
+
+// session processes messages one at a time, in arrival order, until the connection ends.
+// It fails only if the subscription does, unless the connection was lost meanwhile.
+func (b *MQTTBridge) session(ctx context.Context, client *mqtt.Client) error {
+	queue := make(chan mqttMessage, mqttQueueSize)
+	err := client.Subscribe(ctx, b.cfg.Topic, func(topic string, payload []byte) {
+		if b.isResultTopic(topic) {
+			return
+		}
+		select {
+		case queue <- mqttMessage{topic: topic, payload: payload}:
+		default:
+			logger.Warn("MQTT queue full, dropping message", zap.String("topic", topic))
+		}
+	})
+	if err != nil {
+		select {
+		case <-client.Done():
+			return nil // Lost the connection, not refused: reconnect
+		default:
+			return fmt.Errorf("mqtt subscribe to %q failed: %w", b.cfg.Topic, err)
+		}
+	}
+
+	for {
+		select {
+		case msg := <-queue:
+			b.handle(ctx, client, msg)
+		case <-client.Done():
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// isResultTopic reports whether topic is one the bridge publishes results to
+func (b *MQTTBridge) isResultTopic(topic string) bool {
+	return topic == b.cfg.ResultTopic || strings.HasPrefix(topic, b.cfg.ResultTopic+"/")
+}
+
+// handle runs one message through the pipeline and publishes the result
+func (b *MQTTBridge) handle(ctx context.Context, client *mqtt.Client, msg mqttMessage) {
+	if len(msg.payload) == 0 {
+		return
+	}
+	result, proto, err := processFrame(ctx, b.dispatcher, b.discovery, msg.payload, mqttContextHint(msg.topic))
+	if err != nil {
+		logger.Warn("MQTT frame not parsed", zap.String("topic", msg.topic), zap.String("protocol", proto), zap.Error(err))
+		return
+	}
+
+	record, err := json.Marshal(newResultRecord(Output{Frame: msg.payload, Protocol: proto, Result: result}))
+	if err != nil {
+		logger.Error("Failed to encode MQTT result", zap.String("topic", msg.topic), zap.Error(err))
+		return
+	}
+	if err := client.Publish(b.cfg.ResultTopic+"/"+msg.topic, record); err != nil {
+		logger.Warn("Failed to publish MQTT result", zap.String("topic", msg.topic), zap.Error(err))
+	}
+}
+
+// mqttContextHint tells the LLM where a frame came from; topic names often name the device
+func mqttContextHint(topic string) string {
+	return fmt.Sprintf("Binary payload received on MQTT topic %q.", topic)
+}
@@ -0,0 +1,171 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/mqtt/mqtttest"
+)
+
+func TestMQTTBridge_ParsesAndPublishes(t *testing.T) {
+	broker, err := mqtttest.NewBroker()
+	if err != nil {
+		t.Fatalf("NewBroker failed: %v", err)
+	}
+	defer broker.Close()
+
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_mqtt_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	mgr := NewParserManager(tmpDir, "")
+	if err := mgr.RegisterParser("rpm", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"rpm": (int(data[2])*256 + int(data[3])) / 4} }`); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d := NewDispatcher(mgr)
+	d.Bind([]byte{0x41, 0x0C}, "rpm")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bridge := NewMQTTBridge(d, NewDiscoveryService(d, mgr, DiscoveryConfig{Provider: "ollama"}), MQTTConfig{
+		Broker: broker.Addr, Topic: "cars/+/obd", ClientID: "bridge-test",
+	})
+	runErr := make(chan error, 1)
+	go func() { runErr <- bridge.Run(ctx) }()
+	defer func() {
+		cancel()
+		if err := <-runErr; err != nil {
+			t.Errorf("Run returned %v", err)
+		}
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !broker.Subscribed("cars/+/obd") {
+		if time.Now().After(deadline) {
+			t.Fatal("bridge never subscribed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Both cars match the wildcard; each result lands under its own topic
+	for _, car := range []string{"car1", "car2"} {
+		received := broker.Received()
+		broker.Publish("cars/"+car+"/obd", []byte{0x41, 0x0C, 0x1A, 0xF8})
+		select {
+		case <-received:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no result published for %s", car)
+		}
+	}
+
+	msgs := broker.Messages()
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 results, got %+v", msgs)
+	}
+	for i, car := range []string{"car1", "car2"} {
+		if want := DefaultMQTTResultTopic + "/cars/" + car + "/obd"; msgs[i].Topic != want {
+			t.Errorf("result topic = %q, want %q", msgs[i].Topic, want)
+		}
+		var record ResultRecord
+		if err := json.Unmarshal(msgs[i].Payload, &record); err != nil {
+			t.Fatalf("invalid result %q: %v", msgs[i].Payload, err)
+		}
+		if record.Protocol != "rpm" || record.Frame != "410C1AF8" || record.Result["rpm"] != float64(1726) {
+			t.Errorf("unexpected result %+v", record)
+		}
+	}
+
+	// The bridge reconnects and resubscribes after losing the broker
+	broker.DropClients()
+	deadline = time.Now().Add(3 * mqttReconnectDelay)
+	for !broker.Subscribed("cars/+/obd") {
+		if time.Now().After(deadline) {
+			t.Fatal("bridge did not resubscribe after the connection dropped")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestMQTTBridge_IgnoresItsOwnResults(t *testing.T) {
+	broker, err := mqtttest.NewBroker()
+	if err != nil {
+		t.Fatalf("NewBroker failed: %v", err)
+	}
+	defer broker.Close()
+
+	mgr := NewParserManager(t.TempDir(), "")
+	if err := mgr.RegisterParser("length", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"length": len(data)} }`); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d := NewDispatcher(mgr)
+	d.Bind([]byte{0x41}, "length")
+	d.Bind([]byte("{"), "length") // Would parse the bridge's own JSON results
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bridge := NewMQTTBridge(d, nil, MQTTConfig{Broker: broker.Addr, Topic: "#", ClientID: "bridge-test"})
+	go func() { _ = bridge.Run(ctx) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !broker.Subscribed("#") {
+		if time.Now().After(deadline) {
+			t.Fatal("bridge never subscribed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	received := broker.Received()
+	broker.Publish("cars/car1/obd", []byte{0x41, 0x0C})
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no result published")
+	}
+	// The result comes back through "#"; it must not be parsed and published again
+	time.Sleep(200 * time.Millisecond)
+	if msgs := broker.Messages(); len(msgs) != 1 || msgs[0].Topic != DefaultMQTTResultTopic+"/cars/car1/obd" {
+		t.Errorf("expected the single result of the device frame, got %+v", msgs)
+	}
+}
+
+func TestMQTTBridge_ReturnsRefusedSubscription(t *testing.T) {
+	broker, err := mqtttest.NewBroker()
+	if err != nil {
+		t.Fatalf("NewBroker failed: %v", err)
+	}
+	defer broker.Close()
+	broker.Refuse("cars/#")
+
+	d := NewDispatcher(NewParserManager(t.TempDir(), ""))
+	bridge := NewMQTTBridge(d, nil, MQTTConfig{Broker: broker.Addr, Topic: "cars/#"})
+	runErr := make(chan error, 1)
+	go func() { runErr <- bridge.Run(context.Background()) }()
+	select {
+	case err := <-runErr:
+		if err == nil {
+			t.Error("expected the refused subscription to be returned")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run kept reconnecting after the broker refused the subscription")
+	}
+}
+
+func TestMQTTBridge_FailsWithoutBroker(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_mqtt_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	mgr := NewParserManager(tmpDir, "")
+	d := NewDispatcher(mgr)
+
+	bridge := NewMQTTBridge(d, nil, MQTTConfig{Broker: "127.0.0.1:1", Topic: "#"})
+	if err := bridge.Run(context.Background()); err == nil {
+		t.Error("expected an error when the broker is unreachable")
+	}
+}
+
+func TestMQTTContextHint(t *testing.T) {
+	if hint := mqttContextHint("plant/boiler3/modbus"); hint != `Binary payload received on MQTT topic "plant/boiler3/modbus".` {
+		t.Errorf("unexpected hint %q", hint)
+	}
+}
@@ -0,0 +1,44 @@
+package parser
+
+// MultiResultsKey holds the results of a MultiParserFunc parser. Engine.Execute, ParseData and
+// the output sinks see them as map{"results": []map[string]interface{}{...}}.
+const MultiResultsKey = "results"
+
+// IngestMulti is Ingest for frames that carry several records, such as an OBD-II response
+// packing multiple PIDs (41 0C 1A F8 04 7F). It returns every result of a parser implementing
+// MultiParserFunc, in order; other parsers yield their single result.
+func (d *Dispatcher) IngestMulti(data []byte) ([]map[string]interface{}, string, error) {
+	result, proto, err := d.ingestAll(data, data)
+	return splitResults(result), proto, err
+}
+
+// splitResults unpacks a parser's output into its individual results
+func splitResults(result map[string]interface{}) []map[string]interface{} {
+	if results, ok := multiResults(result); ok {
+		return results
+	}
+	if result == nil {
+		return nil
+	}
+	return []map[string]interface{}{result}
+}
+
+// firstResult returns the first result of a multi-result output, or the output itself
+func firstResult(result map[string]interface{}) map[string]interface{} {
+	results, ok := multiResults(result)
+	if !ok {
+		return result
+	}
+	if len(results) == 0 {
+		return map[string]interface{}{}
+	}
+	return results[0]
+}
+
+func multiResults(result map[string]interface{}) ([]map[string]interface{}, bool) {
+	if len(result) != 1 {
+		return nil, false
+	}
+	results, ok := result[MultiResultsKey].([]map[string]interface{})
+	return results, ok
+}
@@ -0,0 +1,148 @@
+// Package obd2 knows the standard OBD-II (SAE J1979) mode 01 PIDs and checks parser
+// output against the range each PID's formula can produce. Its Validate function has
+// the parser.ResultValidator signature and is meant for the "obd2" family (0x41 replies).
+package obd2
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Family is the family label Validate is registered for
+const Family = "obd2"
+
+// ResponseMode is the first byte of a mode 01 reply (0x40 + 0x01)
+const ResponseMode = 0x41
+
+// PID describes one standard mode 01 parameter and the range its formula can produce
+type PID struct {
+	Name     string
+	Min, Max float64
+	Keywords []string // Substrings of a result field name that identify this value
+}
+
+// PIDs is the built-in ruleset, keyed by PID
+var PIDs = map[byte]PID{
+	0x04: {"engine_load", 0, 100, []string{"load"}},
+	0x05: {"coolant_temp", -40, 215, []string{"coolant", "temp"}},
+	0x06: {"short_term_fuel_trim_bank1", -100, 99.21875, []string{"trim"}},
+	0x07: {"long_term_fuel_trim_bank1", -100, 99.21875, []string{"trim"}},
+	0x08: {"short_term_fuel_trim_bank2", -100, 99.21875, []string{"trim"}},
+	0x09: {"long_term_fuel_trim_bank2", -100, 99.21875, []string{"trim"}},
+	0x0A: {"fuel_pressure", 0, 765, []string{"pressure"}},
+	0x0B: {"intake_manifold_pressure", 0, 255, []string{"pressure", "map"}},
+	0x0C: {"engine_rpm", 0, 16383.75, []string{"rpm"}},
+	0x0D: {"vehicle_speed", 0, 255, []string{"speed"}},
+	0x0E: {"timing_advance", -64, 63.5, []string{"timing", "advance"}},
+	0x0F: {"intake_air_temp", -40, 215, []string{"temp"}},
+	0x10: {"maf_rate", 0, 655.35, []string{"maf", "airflow", "air_flow"}},
+	0x11: {"throttle_position", 0, 100, []string{"throttle"}},
+	0x1F: {"run_time", 0, 65535, []string{"runtime", "run_time"}},
+	0x2F: {"fuel_level", 0, 100, []string{"fuel", "level"}},
+	0x46: {"ambient_air_temp", -40, 215, []string{"temp"}},
+	0x5C: {"oil_temp", -40, 210, []string{"oil", "temp"}},
+}
+
+// Validate checks the values a parser produced for a mode 01 reply against the range of
+// the reply's PID. Values are found by field name (e.g. "rpm" for 0x0C); if none match,
+// a lone numeric field is checked instead. Frames that aren't mode 01 replies, unknown
+// PIDs and results with no identifiable value pass.
+func Validate(frame []byte, result map[string]interface{}) error {
+	if len(frame) < 2 || frame[0] != ResponseMode {
+		return nil
+	}
+	pid, ok := PIDs[frame[1]]
+	if !ok {
+		return nil
+	}
+
+	values := matchFields(result, pid.Keywords)
+	if len(values) == 0 {
+		values = loneValue(result)
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if v := values[k]; v < pid.Min || v > pid.Max {
+			return fmt.Errorf("PID 0x%02X (%s): %s = %g outside [%g, %g]", frame[1], pid.Name, k, v, pid.Min, pid.Max)
+		}
+	}
+	return nil
+}
+
+// matchFields returns the numeric fields whose name contains one of keywords
+func matchFields(result map[string]interface{}, keywords []string) map[string]float64 {
+	values := make(map[string]float64)
+	for k, raw := range result {
+		v, ok := number(raw)
+		if !ok {
+			continue
+		}
+		name := strings.ToLower(k)
+		for _, kw := range keywords {
+			if strings.Contains(name, kw) {
+				values[k] = v
+				break
+			}
+		}
+	}
+	return values
+}
+
+// loneValue returns the only numeric field that isn't PID/mode bookkeeping, if there is exactly one
+func loneValue(result map[string]interface{}) map[string]float64 {
+	var key string
+	var value float64
+	for k, raw := range result {
+		v, ok := number(raw)
+		if !ok {
+			continue
+		}
+		if name := strings.ToLower(k); strings.Contains(name, "pid") || strings.Contains(name, "mode") || strings.Contains(name, "service") {
+			continue
+		}
+		if key != "" {
+			return nil
+		}
+		key, value = k, v
+	}
+	if key == "" {
+		return nil
+	}
+	return map[string]float64{key: value}
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,38 @@
+package obd2
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		frame   []byte
+		result  map[string]interface{}
+		wantErr string
+	}{
+		{"rpm in range", []byte{0x41, 0x0C, 0xFF, 0xFF}, map[string]interface{}{"pid": 12, "engine_rpm": 16383.75}, ""},
+		{"rpm without the /4", []byte{0x41, 0x0C, 0xFF, 0xFF}, map[string]interface{}{"pid": 12, "RPM": 65535}, "RPM = 65535"},
+		{"speed by keyword", []byte{0x41, 0x0D, 0x50}, map[string]interface{}{"speed_kmh": 300}, "vehicle_speed"},
+		{"coolant without the -40", []byte{0x41, 0x05, 0x00}, map[string]interface{}{"mode": 0x41, "value": -80}, "value = -80"},
+		{"several unnamed values are not guessed", []byte{0x41, 0x05, 0x00}, map[string]interface{}{"a": -80, "b": 500}, ""},
+		{"unknown PID", []byte{0x41, 0xE0, 0x00}, map[string]interface{}{"rpm": 1e9}, ""},
+		{"not a mode 01 reply", []byte{0x55, 0x0C}, map[string]interface{}{"rpm": 1e9}, ""},
+		{"non-numeric fields ignored", []byte{0x41, 0x0C, 0x00, 0x00}, map[string]interface{}{"rpm_unit": "rev/min"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.frame, tt.result)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
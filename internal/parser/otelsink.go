@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// otelScope is the instrumentation scope of the records OTelSink emits
+const otelScope = "github.com/chuanjin/OmniBridge/internal/parser"
+
+// otelEventName names every parse result record
+const otelEventName = "omnibridge.parse"
+
+// OTelSink emits each output as an OpenTelemetry log record: the protocol, outcome and
+// hex frame are attributes, and every result field becomes a "result.<field>" attribute.
+// It is an ordinary Sink, so it can be registered next to FileSink or DeadLetterStore.
+type OTelSink struct {
+	logger   otellog.Logger
+	provider *sdklog.LoggerProvider // Owned by the sink when built by NewOTLPSink
+}
+
+// NewOTelSink emits through an existing logger provider, e.g. the application's global one.
+// The caller keeps ownership of provider; Close is a no-op.
+func NewOTelSink(provider otellog.LoggerProvider) *OTelSink {
+	return &OTelSink{logger: provider.Logger(otelScope)}
+}
+
+// NewOTLPSink exports records in batches to an OTLP/HTTP logs endpoint such as
+// http://localhost:4318/v1/logs. Close flushes pending records.
+func NewOTLPSink(ctx context.Context, endpointURL string) (*OTelSink, error) {
+	exporter, err := otlploghttp.New(ctx, otlploghttp.WithEndpointURL(endpointURL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP log exporter: %v", err)
+	}
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)))
+	return &OTelSink{logger: provider.Logger(otelScope), provider: provider}, nil
+}
+
+// Emit converts out into a log record
+func (s *OTelSink) Emit(out Output) error {
+	var record otellog.Record
+	now := time.Now()
+	record.SetTimestamp(now)
+	record.SetObservedTimestamp(now)
+	record.SetEventName(otelEventName)
+	record.SetBody(otellog.StringValue("frame " + out.Outcome.String()))
+	if out.Outcome == OutcomeSuccess {
+		record.SetSeverity(otellog.SeverityInfo)
+		record.SetSeverityText("INFO")
+	} else {
+		record.SetSeverity(otellog.SeverityWarn)
+		record.SetSeverityText("WARN")
+	}
+
+	record.AddAttributes(
+		otellog.String("protocol", out.Protocol),
+		otellog.String("outcome", out.Outcome.String()),
+		otellog.String("frame", fmt.Sprintf("%X", out.Frame)),
+	)
+	if out.Err != nil {
+		record.AddAttributes(otellog.String("error", out.Err.Error()))
+	}
+	for _, key := range sortedKeys(out.Result) {
+		record.AddAttributes(otellog.KeyValue{Key: "result." + key, Value: otelValue(out.Result[key])})
+	}
+
+	s.logger.Emit(context.Background(), record)
+	return nil
+}
+
+// Close flushes and shuts down the exporter of a sink built by NewOTLPSink
+func (s *OTelSink) Close() error {
+	if s.provider == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.provider.Shutdown(ctx)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// otelValue maps a parser result value to the closest OTel log value type
+func otelValue(v interface{}) otellog.Value {
+	switch v := v.(type) {
+	case string:
+		return otellog.StringValue(v)
+	case bool:
+		return otellog.BoolValue(v)
+	case int:
+		return otellog.IntValue(v)
+	case int8:
+		return otellog.Int64Value(int64(v))
+	case int16:
+		return otellog.Int64Value(int64(v))
+	case int32:
+		return otellog.Int64Value(int64(v))
+	case int64:
+		return otellog.Int64Value(v)
+	case uint8:
+		return otellog.Int64Value(int64(v))
+	case uint16:
+		return otellog.Int64Value(int64(v))
+	case uint32:
+		return otellog.Int64Value(int64(v))
+	case float32:
+		return otellog.Float64Value(float64(v))
+	case float64:
+		return otellog.Float64Value(v)
+	case []byte:
+		return otellog.BytesValue(v)
+	case map[string]interface{}:
+		kvs := make([]otellog.KeyValue, 0, len(v))
+		for _, key := range sortedKeys(v) {
+			kvs = append(kvs, otellog.KeyValue{Key: key, Value: otelValue(v[key])})
+		}
+		return otellog.MapValue(kvs...)
+	case []interface{}:
+		values := make([]otellog.Value, len(v))
+		for i, item := range v {
+			values[i] = otelValue(item)
+		}
+		return otellog.SliceValue(values...)
+	case nil:
+		return otellog.Value{}
+	default:
+		// uint, uint64 (may overflow int64) and anything exotic keep their printed form
+		return otellog.StringValue(fmt.Sprint(v))
+	}
+}
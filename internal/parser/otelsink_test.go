@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// memoryExporter keeps exported log records in memory
+type memoryExporter struct {
+	records []sdklog.Record
+	mu      sync.Mutex
+}
+
+func (e *memoryExporter) Export(_ context.Context, records []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range records {
+		e.records = append(e.records, r.Clone())
+	}
+	return nil
+}
+
+func (e *memoryExporter) Shutdown(context.Context) error   { return nil }
+func (e *memoryExporter) ForceFlush(context.Context) error { return nil }
+
+func TestOTelSink_EmitsLogRecord(t *testing.T) {
+	exporter := &memoryExporter{}
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(exporter)))
+	defer func() { _ = provider.Shutdown(context.Background()) }()
+
+	router := NewOutputRouter()
+	router.AddSink(NewOTelSink(provider), OutcomeSuccess, OutcomeParseError)
+	router.Route(Output{
+		Frame:    []byte{0x41, 0x0C, 0x1A, 0xF8},
+		Protocol: "obd_rpm",
+		Result:   map[string]interface{}{"rpm": 1726, "unit": "rpm", "valid": true, "ratio": 0.5},
+		Outcome:  OutcomeSuccess,
+	})
+	router.Route(Output{Frame: []byte{0x41, 0x0D}, Protocol: "obd_speed", Err: errors.New("PARSE_ERROR: short frame"), Outcome: OutcomeParseError})
+
+	if len(exporter.records) != 2 {
+		t.Fatalf("expected 2 log records, got %d", len(exporter.records))
+	}
+
+	success := exporter.records[0]
+	if success.EventName() != otelEventName || success.Severity() != otellog.SeverityInfo {
+		t.Errorf("unexpected record header: event=%q severity=%v", success.EventName(), success.Severity())
+	}
+	want := map[string]otellog.Value{
+		"protocol":     otellog.StringValue("obd_rpm"),
+		"outcome":      otellog.StringValue("success"),
+		"frame":        otellog.StringValue("410C1AF8"),
+		"result.rpm":   otellog.Int64Value(1726),
+		"result.unit":  otellog.StringValue("rpm"),
+		"result.valid": otellog.BoolValue(true),
+		"result.ratio": otellog.Float64Value(0.5),
+	}
+	got := recordAttributes(success)
+	if len(got) != len(want) {
+		t.Errorf("attributes = %v, want %v", got, want)
+	}
+	for key, value := range want {
+		if !got[key].Equal(value) {
+			t.Errorf("attribute %s = %v, want %v", key, got[key], value)
+		}
+	}
+
+	failure := recordAttributes(exporter.records[1])
+	if exporter.records[1].Severity() != otellog.SeverityWarn || failure["error"].AsString() != "PARSE_ERROR: short frame" {
+		t.Errorf("unexpected failure record: severity=%v attributes=%v", exporter.records[1].Severity(), failure)
+	}
+}
+
+func recordAttributes(r sdklog.Record) map[string]otellog.Value {
+	attrs := make(map[string]otellog.Value)
+	r.WalkAttributes(func(kv otellog.KeyValue) bool {
+		attrs[kv.Key] = kv.Value
+		return true
+	})
+	return attrs
+}
@@ -0,0 +1,166 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Outcome classifies how a frame was handled by the dispatcher
+type Outcome int
+
+const (
+	OutcomeSuccess         Outcome = iota // The frame was parsed
+	OutcomeParseError                     // A parser matched but failed (error, panic or timeout)
+	OutcomeUnknownProtocol                // No binding matched the frame
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeSuccess:
+		return "success"
+	case OutcomeParseError:
+		return "parse_error"
+	case OutcomeUnknownProtocol:
+		return "unknown_protocol"
+	}
+	return "unknown"
+}
+
+// Output is one processed frame as delivered to sinks
+type Output struct {
+	Frame    []byte
+	Protocol string // Empty for unknown protocols
+	Result   map[string]interface{}
+	Err      error
+	Outcome  Outcome
+}
+
+// Sink receives processed frames, e.g. to forward successes to Kafka and failures to alerting
+type Sink interface {
+	Emit(out Output) error
+}
+
+// SinkFunc adapts a function to the Sink interface
+type SinkFunc func(out Output) error
+
+func (f SinkFunc) Emit(out Output) error { return f(out) }
+
+// ErrEmptyResult is the Err of a successful but empty parse that EmptyDeadLetter reclassified
+var ErrEmptyResult = errors.New("parser returned an empty result")
+
+// EmptyResultPolicy selects what the sinks see when a parser succeeds with a nil or empty map,
+// which downstream often can't tell from a parser that silently gave up
+type EmptyResultPolicy string
+
+const (
+	EmptyEmit       EmptyResultPolicy = "emit"        // Deliver it as a success with an empty result
+	EmptyDrop       EmptyResultPolicy = "drop"        // Deliver it to no sink
+	EmptyDeadLetter EmptyResultPolicy = "dead-letter" // Deliver it as an OutcomeParseError with ErrEmptyResult
+)
+
+// ParseEmptyResultPolicy validates a policy name; the empty string means EmptyEmit
+func ParseEmptyResultPolicy(s string) (EmptyResultPolicy, error) {
+	switch EmptyResultPolicy(s) {
+	case "", EmptyEmit:
+		return EmptyEmit, nil
+	case EmptyDrop, EmptyDeadLetter:
+		return EmptyResultPolicy(s), nil
+	default:
+		return "", fmt.Errorf("unknown empty result policy %q (want %q, %q or %q)", s, EmptyEmit, EmptyDrop, EmptyDeadLetter)
+	}
+}
+
+// OutputRouter delivers each processed frame to the sinks registered for its outcome
+type OutputRouter struct {
+	sinks map[Outcome][]Sink
+	empty EmptyResultPolicy
+	mu    sync.RWMutex
+}
+
+func NewOutputRouter() *OutputRouter {
+	return &OutputRouter{sinks: make(map[Outcome][]Sink)}
+}
+
+// AddSink registers a sink for one or more outcomes
+func (r *OutputRouter) AddSink(sink Sink, outcomes ...Outcome) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, o := range outcomes {
+		r.sinks[o] = append(r.sinks[o], sink)
+	}
+}
+
+// SetEmptyResultPolicy changes how successful parses with an empty result are routed.
+// Errors are unaffected. The default is EmptyEmit.
+func (r *OutputRouter) SetEmptyResultPolicy(p EmptyResultPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.empty = p
+}
+
+// emptyResultPolicy returns the policy set with SetEmptyResultPolicy
+func (r *OutputRouter) emptyResultPolicy() EmptyResultPolicy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.empty
+}
+
+// Route emits out to every sink registered for its outcome. A failing (or panicking)
+// sink is logged and does not prevent delivery to the others or fail the ingest.
+func (r *OutputRouter) Route(out Output) {
+	if out.Outcome == OutcomeSuccess && emptyResult(out.Result) {
+		switch r.emptyResultPolicy() {
+		case EmptyDrop:
+			logger.Debug("Dropping empty parse result", zap.String("protocol", out.Protocol))
+			return
+		case EmptyDeadLetter:
+			out.Outcome, out.Err = OutcomeParseError, fmt.Errorf("%s: %w", out.Protocol, ErrEmptyResult)
+		}
+	}
+
+	r.mu.RLock()
+	sinks := r.sinks[out.Outcome]
+	r.mu.RUnlock()
+
+	for _, sink := range sinks {
+		if err := emit(sink, out); err != nil {
+			logger.Warn("Output sink failed",
+				zap.String("outcome", out.Outcome.String()), zap.String("protocol", out.Protocol), zap.Error(err))
+		}
+	}
+}
+
+func emit(sink Sink, out Output) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("sink panicked: %v", r)
+		}
+	}()
+	return sink.Emit(out)
+}
+
+// emptyResult reports whether a parser produced no fields, or a multi-result parser no records
+func emptyResult(result map[string]interface{}) bool {
+	if results, ok := multiResults(result); ok {
+		return len(results) == 0
+	}
+	return len(result) == 0
+}
+
+// classifyOutcome maps an ingest result to its outcome
+func classifyOutcome(protocol string, err error) Outcome {
+	switch {
+	case protocol == FallbackProtocol && err == nil:
+		return OutcomeUnknownProtocol // Raw passthrough, the sinks get its result
+	case err == nil:
+		return OutcomeSuccess
+	case protocol == "":
+		return OutcomeUnknownProtocol
+	default:
+		return OutcomeParseError
+	}
+}
@@ -0,0 +1,297 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// captureSink records every output it receives
+type captureSink struct {
+	outputs []Output
+	mu      sync.Mutex
+}
+
+func (c *captureSink) Emit(out Output) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.outputs = append(c.outputs, out)
+	return nil
+}
+
+func (c *captureSink) protocols() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var protos []string
+	for _, o := range c.outputs {
+		protos = append(protos, o.Protocol)
+	}
+	return protos
+}
+
+func TestDispatcher_OutputRouting(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_output_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	if err := mgr.RegisterParser("good", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": int(data[1])} }`); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	if err := mgr.RegisterParser("broken", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": int(data[10])} }`); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	if err := mgr.RegisterParser("paused", `package dynamic
+func Parse(data []byte) map[string]interface{} { return nil }`); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+
+	d := NewDispatcher(mgr)
+	d.Bind([]byte{0x01}, "good")
+	d.Bind([]byte{0x02}, "broken")
+	d.Bind([]byte{0x03}, "paused")
+	d.SetEnabled("paused", false)
+
+	success, failures, unknown := &captureSink{}, &captureSink{}, &captureSink{}
+	router := NewOutputRouter()
+	router.AddSink(success, OutcomeSuccess)
+	router.AddSink(failures, OutcomeParseError, OutcomeUnknownProtocol)
+	router.AddSink(unknown, OutcomeUnknownProtocol)
+	// A failing sink must not stop delivery to the others
+	router.AddSink(SinkFunc(func(Output) error { return errors.New("kafka down") }), OutcomeSuccess)
+	d.SetOutputRouter(router)
+
+	_, _, _ = d.Ingest([]byte{0x01, 0x2A})
+	_, _, _ = d.Ingest([]byte{0x02, 0x00})
+	_, _, _ = d.Ingest([]byte{0x99})
+	_, _, _ = d.Ingest([]byte{0x03, 0x00})
+
+	if got := success.protocols(); len(got) != 1 || got[0] != "good" {
+		t.Errorf("success sink got %v, want [good]", got)
+	}
+	if success.outputs[0].Result["v"] != 42 || success.outputs[0].Err != nil {
+		t.Errorf("unexpected success output: %+v", success.outputs[0])
+	}
+	if got := failures.protocols(); len(got) != 2 || got[0] != "broken" || got[1] != "" {
+		t.Errorf("failure sink got %v, want [broken, unknown]", got)
+	}
+	if failures.outputs[0].Outcome != OutcomeParseError || failures.outputs[0].Err == nil {
+		t.Errorf("expected parse error outcome, got %+v", failures.outputs[0])
+	}
+	if len(unknown.outputs) != 1 || unknown.outputs[0].Outcome != OutcomeUnknownProtocol {
+		t.Errorf("unknown sink got %+v", unknown.outputs)
+	}
+}
+
+func TestFileSink_PersistsEverySuccessfulParse(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_filesink_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	if err := mgr.RegisterParser("good", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": int(data[1])} }`); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d := NewDispatcher(mgr)
+	d.Bind([]byte{0x01}, "good")
+
+	path := filepath.Join(tmpDir, "results.jsonl")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+	router := NewOutputRouter()
+	// Broken hooks registered first must not stop the file sink or the ingest
+	router.AddSink(SinkFunc(func(Output) error { return errors.New("disk full") }), OutcomeSuccess)
+	router.AddSink(SinkFunc(func(Output) error { panic("hook bug") }), OutcomeSuccess)
+	router.AddSink(sink, OutcomeSuccess)
+	d.SetOutputRouter(router)
+
+	frames := [][]byte{{0x01, 0x0A}, {0x01, 0x0B}, {0x09}, {0x01, 0x0C}}
+	for _, frame := range frames {
+		res, _, err := d.Ingest(frame)
+		if frame[0] == 0x01 && (err != nil || res == nil) {
+			t.Fatalf("ingest of %X failed despite hook errors: %v, %v", frame, res, err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("result log not written: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected one record per successful parse, got %d: %s", len(lines), data)
+	}
+	var last ResultRecord
+	if err := json.Unmarshal([]byte(lines[2]), &last); err != nil {
+		t.Fatalf("invalid record %q: %v", lines[2], err)
+	}
+	if last.Protocol != "good" || last.Frame != "010C" || last.Result["v"] != float64(12) || last.Time.IsZero() {
+		t.Errorf("unexpected record %+v", last)
+	}
+}
+
+func TestOutputRouter_EmptyResultPolicy(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "omnibridge_empty_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	mgr := NewParserManager(tmpDir, "")
+	if err := mgr.RegisterParser("empty", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{} }`); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	if err := mgr.RegisterParser("good", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": int(data[1])} }`); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d := NewDispatcher(mgr)
+	d.Bind([]byte{0x01}, "empty")
+	d.Bind([]byte{0x02}, "good")
+
+	tests := []struct {
+		policy       string
+		wantSuccess  []string
+		wantFailures []string
+	}{
+		{"", []string{"empty", "good"}, nil},
+		{"emit", []string{"empty", "good"}, nil},
+		{"drop", []string{"good"}, nil},
+		{"dead-letter", []string{"good"}, []string{"empty"}},
+	}
+
+	for _, tt := range tests {
+		t.Run("policy "+tt.policy, func(t *testing.T) {
+			policy, err := ParseEmptyResultPolicy(tt.policy)
+			if err != nil {
+				t.Fatalf("ParseEmptyResultPolicy failed: %v", err)
+			}
+			success, failures := &captureSink{}, &captureSink{}
+			router := NewOutputRouter()
+			router.SetEmptyResultPolicy(policy)
+			router.AddSink(success, OutcomeSuccess)
+			router.AddSink(failures, OutcomeParseError)
+			d.SetOutputRouter(router)
+
+			// The policy only affects the sinks; the caller still gets the empty result
+			if res, _, err := d.Ingest([]byte{0x01, 0x00}); err != nil || res == nil || len(res) != 0 {
+				t.Fatalf("Ingest of empty result = %v, %v", res, err)
+			}
+			_, _, _ = d.Ingest([]byte{0x02, 0x07})
+
+			if got := success.protocols(); strings.Join(got, ",") != strings.Join(tt.wantSuccess, ",") {
+				t.Errorf("success sink got %v, want %v", got, tt.wantSuccess)
+			}
+			if got := failures.protocols(); strings.Join(got, ",") != strings.Join(tt.wantFailures, ",") {
+				t.Errorf("failure sink got %v, want %v", got, tt.wantFailures)
+			}
+			if len(failures.outputs) > 0 && !errors.Is(failures.outputs[0].Err, ErrEmptyResult) {
+				t.Errorf("dead-lettered output Err = %v, want ErrEmptyResult", failures.outputs[0].Err)
+			}
+		})
+	}
+
+	if _, err := ParseEmptyResultPolicy("ignore"); err == nil {
+		t.Error("expected an unknown policy to be rejected")
+	}
+}
+
+func TestWriterSink_WritesJSONLines(t *testing.T) {
+	var buf strings.Builder
+	sink := NewWriterSink(&buf)
+	for _, v := range []int{1, 2} {
+		if err := sink.Emit(Output{Frame: []byte{0x01, byte(v)}, Protocol: "good", Result: map[string]interface{}{"v": v}}); err != nil {
+			t.Fatalf("Emit failed: %v", err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one line per output, got %q", buf.String())
+	}
+	var record ResultRecord
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatalf("invalid record %q: %v", lines[1], err)
+	}
+	if record.Protocol != "good" || record.Frame != "0102" || record.Result["v"] != float64(2) {
+		t.Errorf("unexpected record %+v", record)
+	}
+}
+
+func TestWebhookSink_PostsRecords(t *testing.T) {
+	var received []ResultRecord
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var record ResultRecord
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&record) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received = append(received, record)
+		mu.Unlock()
+		if record.Protocol == "rejected" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL, nil)
+	for _, proto := range []string{"good", "rejected", "good"} {
+		if err := sink.Emit(Output{Frame: []byte{0x01}, Protocol: proto, Result: map[string]interface{}{"v": 1}}); err != nil {
+			t.Fatalf("Emit failed: %v", err)
+		}
+	}
+	// Close waits for the queue, and a rejected post doesn't stop the ones after it
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 3 || received[2].Protocol != "good" || received[2].Frame != "01" {
+		t.Errorf("expected 3 records delivered in order, got %+v", received)
+	}
+}
+
+func TestWebhookSink_CloseDropsWhatMissesTheDeadline(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	sink := NewWebhookSink(server.URL, nil)
+	for range 3 {
+		if err := sink.Emit(Output{Frame: []byte{0x01}, Protocol: "good"}); err != nil {
+			t.Fatalf("Emit failed: %v", err)
+		}
+	}
+	// The endpoint hangs: Close gives up at its deadline instead of waiting out every post
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := sink.CloseContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "3 records dropped") {
+		t.Errorf("expected the 3 records to be dropped at the deadline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("CloseContext took %v past its deadline", elapsed)
+	}
+}
@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/chuanjin/OmniBridge/internal/metrics"
+)
+
+// ErrPinMismatch is returned by IngestPinned for a frame its protocol's FrameValidator rejects
+var ErrPinMismatch = errors.New("frame does not match the pinned protocol")
+
+// SetPinProtocol makes each connection keep the protocol of its first parsed frame and parse
+// the frames that follow with it, without routing them by their leading bytes, for devices that
+// only send the signature once. A frame the protocol's FrameValidator rejects, or whose length
+// fits none of its BindWithLength lengths, unpins the connection and is routed normally, as does
+// any frame once the protocol is disabled. Pinned frames are otherwise repaired like routed ones.
+// Call before Serve.
+func (s *TCPServer) SetPinProtocol(enabled bool) {
+	s.pinProtocol = enabled
+}
+
+// connPin is the protocol a connection is pinned to
+type connPin struct {
+	protocol string
+	lengths  map[int]bool // Frame lengths the protocol accepts; nil accepts any
+}
+
+func (p *connPin) fits(length int) bool {
+	return p.lengths == nil || p.lengths[length]
+}
+
+// pin pins a connection to protocolID
+func (s *TCPServer) pin(protocolID string) *connPin {
+	return &connPin{protocol: protocolID, lengths: s.dispatcher.boundLengths(protocolID)}
+}
+
+// IngestPinned parses data with protocolID without matching its leading bytes, for streams that
+// identify their protocol on the first frame only. The protocol's FrameValidator still applies:
+// a frame it rejects isn't parsed and yields ErrPinMismatch, so the caller can route it again.
+// Neither that nor a disabled protocol (ErrProtocolDisabled) counts as an ingested frame.
+func (d *Dispatcher) IngestPinned(protocolID string, data []byte) (map[string]interface{}, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty payload")
+	}
+
+	d.mu.RLock()
+	if d.disabled[protocolID] {
+		d.mu.RUnlock()
+		return nil, fmt.Errorf("%s: %w", protocolID, ErrProtocolDisabled)
+	}
+	if validate := d.validators[protocolID]; validate != nil {
+		if err := validate(data); err != nil {
+			d.mu.RUnlock()
+			return nil, fmt.Errorf("%s: %w: %v", protocolID, ErrPinMismatch, err)
+		}
+	}
+	metrics.IncIngest()
+	family := d.familyLocked(protocolID)
+	result, err := d.parseValidatedLocked(protocolID, family, data, d.manager.ParseData)
+	deadLetters, outputs := d.deadLetters, d.outputs
+	d.mu.RUnlock()
+
+	d.finishIngest(data, protocolID, family, result, err, deadLetters, outputs)
+	return firstResult(result), err
+}
+
+// familyLocked returns protocolID's family as matchLocked would resolve it: the explicit
+// family first, then the family of a trie prefix the protocol is bound at or below.
+func (d *Dispatcher) familyLocked(protocolID string) string {
+	if explicit, ok := d.families[protocolID]; ok {
+		return explicit
+	}
+	var walk func(n *trieNode, family string) string
+	walk = func(n *trieNode, family string) string {
+		if n.family != "" {
+			family = n.family
+		}
+		if family != "" && n.boundTo(protocolID) {
+			return family
+		}
+		for _, child := range n.children {
+			if found := walk(child, family); found != "" {
+				return found
+			}
+		}
+		return ""
+	}
+	return walk(d.root, "")
+}
+
+// boundTo reports whether protocolID is bound at n, with or without a frame length
+func (n *trieNode) boundTo(protocolID string) bool {
+	if n.protocolID == protocolID {
+		return true
+	}
+	for _, id := range n.byLength {
+		if id == protocolID {
+			return true
+		}
+	}
+	return false
+}
+
+// boundLengths returns the frame lengths protocolID is bound to with BindWithLength, each also
+// less its signature since pinned frames may omit it. It returns nil if the protocol has none.
+func (d *Dispatcher) boundLengths(protocolID string) map[int]bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var lengths map[int]bool
+	d.walkLocked(func(prefix []byte, n *trieNode) {
+		for length, id := range n.byLength {
+			if id != protocolID {
+				continue
+			}
+			if lengths == nil {
+				lengths = make(map[int]bool)
+			}
+			lengths[length] = true
+			if length > len(prefix) {
+				lengths[length-len(prefix)] = true
+			}
+		}
+	})
+	return lengths
+}
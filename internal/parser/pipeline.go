@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"go.uber.org/zap"
+)
+
+// errDiscoveryFailed marks frames whose unknown signature could not be learned
+var errDiscoveryFailed = errors.New("discovery failed")
+
+// FrameOutcome is how ProcessFrame ended up handling a frame
+type FrameOutcome int
+
+const (
+	FrameParsed     FrameOutcome = iota // Parsed by an existing parser
+	FrameRepaired                       // Parsed after repairing the parser
+	FrameDiscovered                     // Parsed after discovering the protocol
+	FrameFailed                         // Not parsed, though a raw passthrough may still be returned
+)
+
+// processFrame runs the network ingest pipeline shared by the servers; see ProcessFrame
+func processFrame(ctx context.Context, d *Dispatcher, disc *DiscoveryService, raw []byte, contextHint string) (map[string]interface{}, string, error) {
+	result, proto, _, err := ProcessFrame(ctx, d, disc, raw, contextHint)
+	return result, proto, err
+}
+
+// ProcessFrame runs raw through the ingest pipeline shared by the servers: parse the frame,
+// repair a known parser that fails on it, or learn an unknown protocol and parse again.
+// contextHint is passed to the LLM when discovery is needed; cancelling ctx stops waiting
+// for a repair or discovery, which other frames may share. Offline tools use it to handle
+// frames exactly like the servers do.
+func ProcessFrame(ctx context.Context, d *Dispatcher, disc *DiscoveryService, raw []byte, contextHint string) (map[string]interface{}, string, FrameOutcome, error) {
+	return runPipeline(ctx, d, disc, raw, contextHint, d.Ingest)
+}
+
+// processPinned runs a frame of a connection pinned to protocolID through the pipeline,
+// parsing it with IngestPinned. A frame that doesn't fit the protocol (ErrPinMismatch) or
+// arrives while it is disabled is returned as is, for the caller to route again.
+func processPinned(ctx context.Context, d *Dispatcher, disc *DiscoveryService, protocolID string, raw []byte) (map[string]interface{}, error) {
+	result, _, _, err := runPipeline(ctx, d, disc, raw, "", func(data []byte) (map[string]interface{}, string, error) {
+		result, err := d.IngestPinned(protocolID, data)
+		return result, protocolID, err
+	})
+	return result, err
+}
+
+// runPipeline is ProcessFrame over any way of parsing a frame
+func runPipeline(ctx context.Context, d *Dispatcher, disc *DiscoveryService, raw []byte, contextHint string, ingest func([]byte) (map[string]interface{}, string, error)) (map[string]interface{}, string, FrameOutcome, error) {
+	// Attempt to parse using cached/known logic
+	result, proto, err := ingest(raw)
+	outcome := FrameParsed
+
+	// A raw passthrough still means the protocol is unknown: try to learn it first and
+	// only hand out the passthrough if that fails
+	var passthrough map[string]interface{}
+	if proto == FallbackProtocol && err == nil {
+		passthrough, result, proto, err = result, nil, "", ErrUnknownProtocol
+	}
+
+	// 1. SELF-HEALING: If ingest fails for a KNOWN protocol (e.g., compile error), try to repair it.
+	// A corrupt frame (ErrChecksum) says nothing about the parser, and an overloaded protocol
+	// (ErrBulkheadFull, ErrCircuitOpen) never ran it.
+	if err != nil && proto != "" && repairable(err) {
+		logger.Warn("Detected error in protocol", zap.String("protocol", proto), zap.Error(err))
+		logger.Info("Attempting repair...")
+
+		faultyCode, exists := d.GetManager().GetParserCode(proto)
+		if exists {
+			repairErr := disc.RequestRepair(ctx, proto, faultyCode, err.Error(), raw)
+			if repairErr != nil {
+				// A deferred repair was already logged and runs once the budget allows
+				if !errors.Is(repairErr, ErrRepairDeferred) {
+					logger.Error("Repair failed", zap.Error(repairErr))
+				}
+			} else {
+				// Re-attempt ingestion after repair
+				result, proto, err = ingest(raw)
+				if err == nil {
+					outcome = FrameRepaired
+					logger.Info("Protocol repaired successfully", zap.String("protocol", proto))
+				}
+			}
+		}
+	}
+
+	// 2. DISCOVERY: If protocol is entirely unknown
+	if err != nil && proto == "" {
+		// Extract a tentative signature (e.g. first byte) to key the discovery process
+		sig := []byte{raw[0]}
+		sigHex := fmt.Sprintf("0x%X", sig)
+
+		// Attempt to run discovery synchronously for this client
+		// This blocks this specific client but ensures the first packet is not dropped.
+		// Concurrent connections with the same unknown signature share one discovery
+		if disc.IsDiscovering(sig) {
+			logger.Info("Discovery already in progress, waiting for it", zap.String("signature", sigHex))
+		} else {
+			logger.Info("Unknown signature, starting BLOCKING AI discovery", zap.String("signature", sigHex))
+		}
+		newName, discErr := disc.DiscoverNewProtocolContext(ctx, raw, sig, contextHint)
+		if discErr != nil {
+			// Out of discovery budget is expected under load: a later frame can try again
+			if !errors.Is(discErr, ErrRateLimited) {
+				logger.Error("Discovery failed", zap.String("signature", sigHex), zap.Error(discErr))
+			}
+			if passthrough != nil {
+				return passthrough, FallbackProtocol, FrameFailed, nil
+			}
+			return nil, "", FrameFailed, fmt.Errorf("%w: %w", errDiscoveryFailed, discErr)
+		}
+		logger.Info("Discovery Success: New Protocol Learned", zap.String("protocol", newName))
+
+		// Re-attempt ingestion after discovery
+		result, proto, err = ingest(raw)
+		if err != nil {
+			// If it still fails, then we really can't handle it
+			logger.Error("Still unable to parse after discovery", zap.Error(err))
+		}
+		outcome = FrameDiscovered
+	}
+
+	if err != nil {
+		outcome = FrameFailed
+	}
+	return result, proto, outcome, err
+}
+
+// repairable reports whether an ingest error of a known protocol may be the parser's fault
+func repairable(err error) bool {
+	return !errors.Is(err, ErrProtocolDisabled) && !errors.Is(err, ErrChecksum) && !errors.Is(err, ErrPinMismatch) &&
+		!errors.Is(err, ErrBulkheadFull) && !errors.Is(err, ErrCircuitOpen)
+}
@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"regexp"
+	"sort"
+)
+
+// PII heuristics applied to printable ASCII runs of a sample in PrivacyMode:
+//   - email:  local@domain.tld
+//   - vin:    17 characters from the VIN alphabet (no I, O or Q) mixing letters and digits
+//   - phone:  optional +, then 9 or more digits, optionally separated by spaces, dots, dashes or parentheses
+//
+// Matches keep their length and punctuation; letters become 'X' and digits '0', so the
+// LLM still sees where a string field starts, how long it is and what shape it has.
+var piiPatterns = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{"email", regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)},
+	{"vin", regexp.MustCompile(`[A-HJ-NPR-Z0-9]{17}`)},
+	{"phone", regexp.MustCompile(`\+?[0-9][0-9 ().\-]{7,}[0-9]`)},
+}
+
+// minPIIRunLen is the shortest printable run worth scanning; shorter ones are almost always binary noise
+const minPIIRunLen = 6
+
+// MaskPII returns a copy of sample with likely personal data replaced by placeholder bytes,
+// along with the kinds of data that were masked. The first keep bytes (the signature) are
+// never modified.
+func MaskPII(sample []byte, keep int) ([]byte, []string) {
+	masked := append([]byte(nil), sample...)
+	found := make(map[string]bool)
+
+	for _, run := range printableRuns(masked, keep) {
+		text := masked[run[0]:run[1]]
+		for _, p := range piiPatterns {
+			for _, loc := range p.re.FindAllIndex(text, -1) {
+				if p.kind == "vin" && !hasLetterAndDigit(text[loc[0]:loc[1]]) {
+					continue
+				}
+				if p.kind == "phone" && countDigits(text[loc[0]:loc[1]]) < 9 {
+					continue
+				}
+				maskBytes(text[loc[0]:loc[1]])
+				found[p.kind] = true
+			}
+		}
+	}
+
+	kinds := make([]string, 0, len(found))
+	for k := range found {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return masked, kinds
+}
+
+// printableRuns returns the [start, end) ranges of printable ASCII runs at or after offset from
+func printableRuns(data []byte, from int) [][2]int {
+	var runs [][2]int
+	start := -1
+	for i := max(from, 0); i <= len(data); i++ {
+		if i < len(data) && data[i] >= 0x20 && data[i] <= 0x7E {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && i-start >= minPIIRunLen {
+			runs = append(runs, [2]int{start, i})
+		}
+		start = -1
+	}
+	return runs
+}
+
+func maskBytes(b []byte) {
+	for i, c := range b {
+		switch {
+		case c >= '0' && c <= '9':
+			b[i] = '0'
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+			b[i] = 'X'
+		}
+	}
+}
+
+func hasLetterAndDigit(b []byte) bool {
+	return countDigits(b) > 0 && countDigits(b) < len(b)
+}
+
+func countDigits(b []byte) int {
+	n := 0
+	for _, c := range b {
+		if c >= '0' && c <= '9' {
+			n++
+		}
+	}
+	return n
+}
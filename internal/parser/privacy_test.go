@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMaskPII(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample []byte
+		want   []byte
+		kinds  []string
+	}{
+		{
+			name:   "email",
+			sample: append([]byte{0xAA, 0x55, 0x10}, "jane.doe@example.com"...),
+			want:   append([]byte{0xAA, 0x55, 0x10}, "XXXX.XXX@XXXXXXX.XXX"...),
+			kinds:  []string{"email"},
+		},
+		{
+			name:   "vin",
+			sample: append([]byte{0x09, 0x02}, "1HGCM82633A004352"...),
+			want:   append([]byte{0x09, 0x02}, "0XXXX00000X000000"...),
+			kinds:  []string{"vin"},
+		},
+		{
+			name:   "phone",
+			sample: append(append([]byte{0x7E, 0x01}, "+46 70-123 45 67"...), 0x00, 0xFF),
+			want:   append(append([]byte{0x7E, 0x01}, "+00 00-000 00 00"...), 0x00, 0xFF),
+			kinds:  []string{"phone"},
+		},
+		{
+			name:   "plain text and binary are untouched",
+			sample: append([]byte{0x01, 0x02, 0x03}, "STATUS OK"...),
+			want:   append([]byte{0x01, 0x02, 0x03}, "STATUS OK"...),
+		},
+	}
+	for _, tt := range tests {
+		got, kinds := MaskPII(tt.sample, 1)
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: MaskPII = %q, want %q", tt.name, got, tt.want)
+		}
+		if len(kinds) != len(tt.kinds) || (len(kinds) > 0 && !reflect.DeepEqual(kinds, tt.kinds)) {
+			t.Errorf("%s: kinds = %v, want %v", tt.name, kinds, tt.kinds)
+		}
+		if len(got) != len(tt.sample) {
+			t.Errorf("%s: length changed from %d to %d", tt.name, len(tt.sample), len(got))
+		}
+	}
+
+	// The signature prefix is never masked, and the input is not modified
+	sample := []byte("a@b.se,x")
+	if got, _ := MaskPII(sample, len(sample)); !bytes.Equal(got, sample) {
+		t.Errorf("Expected bytes within the signature kept, got %q", got)
+	}
+	if string(sample) != "a@b.se,x" {
+		t.Error("MaskPII modified its input")
+	}
+}
+
+func TestDiscoveryService_PrivacyModeMasksEmail(t *testing.T) {
+	var prompts []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		prompts = append(prompts, req.Prompt)
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"user": string(data[2:])} }`})
+	}))
+	defer server.Close()
+
+	email := "alice@example.org"
+	sample := append([]byte{0xC0, 0x11}, email...)
+	masked := append([]byte{0xC0, 0x11}, "XXXXX@XXXXXXX.XXX"...)
+
+	for _, privacy := range []bool{false, true} {
+		prompts = nil
+		service, _, _ := newTestDiscovery(t, DiscoveryConfig{
+			Provider:    "ollama",
+			Endpoint:    server.URL,
+			RetryDelay:  time.Millisecond,
+			PrivacyMode: privacy,
+		})
+		if _, err := service.DiscoverNewProtocol(sample, []byte{0xC0}, "user record"); err != nil {
+			t.Fatalf("DiscoverNewProtocol failed: %v", err)
+		}
+
+		rawHex, maskedHex := fmt.Sprintf("%X", sample), fmt.Sprintf("%X", masked)
+		if privacy {
+			if strings.Contains(prompts[0], rawHex) || !strings.Contains(prompts[0], maskedHex) {
+				t.Errorf("Expected the email masked in the prompt, got %q", prompts[0])
+			}
+		} else if !strings.Contains(prompts[0], rawHex) {
+			t.Errorf("Expected the raw sample without privacy mode, got %q", prompts[0])
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"time"
+)
+
+const (
+	// DefaultProfileIterations is how many passes over the samples Profile makes when none are given
+	DefaultProfileIterations = 100
+	// MaxProfileIterations bounds the passes one Profile call may request
+	MaxProfileIterations = 10000
+)
+
+// profileID labels the executions made by Profile in metrics, keeping them out of the protocol's own
+const profileID = "profile"
+
+// ProfileStats summarizes the execution time and allocations of a parser over repeated runs.
+// Allocation counts are process-wide deltas, so concurrent traffic inflates them; they also
+// include the few allocations the sandbox makes per run.
+type ProfileStats struct {
+	Protocol    string        `json:"protocol"`
+	Executions  int           `json:"executions"`
+	Errors      int           `json:"errors"` // Runs the parser failed
+	Min         time.Duration `json:"min"`
+	Max         time.Duration `json:"max"`
+	Mean        time.Duration `json:"mean"`
+	P99         time.Duration `json:"p99"`
+	AllocsPerOp uint64        `json:"allocs_per_op"`
+	BytesPerOp  uint64        `json:"bytes_per_op"`
+}
+
+// Profile runs the compiled parser of id over every sample, iterations times (non-positive
+// means DefaultProfileIterations, at most MaxProfileIterations), and reports its latency
+// distribution and allocations. The parser must already be compiled, e.g. by a previous parse
+// or CompileAndCache; see ParserManager.ProfileParser to compile it from storage first. Runs
+// bypass the result cache and the bulkhead. A run that exceeds the parse timeout, or ctx being
+// cancelled between runs, ends profiling with an error.
+func (e *Engine) Profile(ctx context.Context, id string, samples [][]byte, iterations int) (ProfileStats, error) {
+	e.mu.RLock()
+	entry, ok := e.cache[id]
+	e.mu.RUnlock()
+	if !ok {
+		return ProfileStats{}, fmt.Errorf("parser %s is not compiled", id)
+	}
+	return e.profile(ctx, id, entry.fn, samples, iterations)
+}
+
+func (e *Engine) profile(ctx context.Context, id string, fn compiledParser, samples [][]byte, iterations int) (ProfileStats, error) {
+	if len(samples) == 0 {
+		return ProfileStats{}, fmt.Errorf("no samples to profile %s with", id)
+	}
+	if iterations <= 0 {
+		iterations = DefaultProfileIterations
+	}
+	if iterations > MaxProfileIterations {
+		return ProfileStats{}, fmt.Errorf("%d iterations exceed the limit of %d", iterations, MaxProfileIterations)
+	}
+
+	stats := ProfileStats{Protocol: id}
+	durations := make([]time.Duration, 0, iterations*len(samples))
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for range iterations {
+		for _, sample := range samples {
+			if err := ctx.Err(); err != nil {
+				return ProfileStats{}, fmt.Errorf("profiling %s: %w", id, err)
+			}
+			start := time.Now()
+			_, err := e.runOnce(profileID, fn, sample)
+			durations = append(durations, time.Since(start))
+			if errors.Is(err, errExecutionTimeout) {
+				return ProfileStats{}, fmt.Errorf("profiling %s: %w", id, err)
+			}
+			if err != nil {
+				stats.Errors++
+			}
+		}
+	}
+	runtime.ReadMemStats(&after)
+
+	slices.Sort(durations)
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	n := len(durations)
+	stats.Executions = n
+	stats.Min, stats.Max = durations[0], durations[n-1]
+	stats.Mean = total / time.Duration(n)
+	stats.P99 = durations[(n*99+99)/100-1]
+	stats.AllocsPerOp = (after.Mallocs - before.Mallocs) / uint64(n)
+	stats.BytesPerOp = (after.TotalAlloc - before.TotalAlloc) / uint64(n)
+	return stats, nil
+}
+
+// ProfileParser profiles a stored parser, compiling it if needed. Without samples, the
+// protocol's stored golden and schema samples are used.
+func (m *ParserManager) ProfileParser(ctx context.Context, protocolID string, samples [][]byte, iterations int) (ProfileStats, error) {
+	code, ok := m.GetParserCode(protocolID)
+	if !ok {
+		return ProfileStats{}, fmt.Errorf("no parser found for %s", protocolID)
+	}
+	if len(samples) == 0 {
+		stored, err := m.StoredSamples(protocolID)
+		if err != nil {
+			return ProfileStats{}, err
+		}
+		samples = stored
+	}
+	fn, err := m.engine.load(protocolID, code)
+	if err != nil {
+		return ProfileStats{}, err
+	}
+	return m.engine.profile(ctx, protocolID, fn, samples, iterations)
+}
@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestEngine_Profile(t *testing.T) {
+	e := NewEngine()
+	fast := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": int(data[1])} }`
+	slow := `package dynamic
+import "fmt"
+func Parse(data []byte) map[string]interface{} {
+	s := ""
+	for i := 0; i < 300; i++ {
+		s = fmt.Sprintf("%s%02X", s[:len(s)/2], data[1])
+	}
+	return map[string]interface{}{"v": s}
+}`
+	for id, code := range map[string]string{"fast": fast, "slow": slow} {
+		if err := e.CompileAndCache(id, code); err != nil {
+			t.Fatalf("CompileAndCache %s failed: %v", id, err)
+		}
+	}
+	samples := [][]byte{{0x01, 0x2A}, {0x01, 0x07}}
+
+	fastStats, err := e.Profile(context.Background(), "fast", samples, 20)
+	if err != nil {
+		t.Fatalf("Profile fast failed: %v", err)
+	}
+	slowStats, err := e.Profile(context.Background(), "slow", samples, 20)
+	if err != nil {
+		t.Fatalf("Profile slow failed: %v", err)
+	}
+
+	for _, stats := range []ProfileStats{fastStats, slowStats} {
+		if stats.Executions != 40 || stats.Errors != 0 {
+			t.Errorf("%s: expected 40 clean executions, got %+v", stats.Protocol, stats)
+		}
+		if stats.Min <= 0 || stats.Min > stats.Mean || stats.Mean > stats.Max || stats.P99 > stats.Max || stats.P99 < stats.Min {
+			t.Errorf("%s: inconsistent latencies %+v", stats.Protocol, stats)
+		}
+	}
+	if slowStats.Mean <= fastStats.Mean {
+		t.Errorf("expected the slow parser to have a higher mean, got fast %v and slow %v", fastStats.Mean, slowStats.Mean)
+	}
+	if slowStats.AllocsPerOp <= fastStats.AllocsPerOp {
+		t.Errorf("expected the slow parser to allocate more, got fast %d and slow %d", fastStats.AllocsPerOp, slowStats.AllocsPerOp)
+	}
+
+	if _, err := e.Profile(context.Background(), "missing", samples, 1); err == nil {
+		t.Error("expected an error for a parser that isn't compiled")
+	}
+	if _, err := e.Profile(context.Background(), "fast", nil, 1); err == nil {
+		t.Error("expected an error without samples")
+	}
+	if _, err := e.Profile(context.Background(), "fast", samples, MaxProfileIterations+1); err == nil {
+		t.Error("expected an error for iterations over the limit")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := e.Profile(ctx, "fast", samples, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancelled profile to stop, got %v", err)
+	}
+}
+
+func TestParserManager_ProfileParser(t *testing.T) {
+	mgr := NewParserManager(t.TempDir(), "")
+	code := `package dynamic
+func Parse(data []byte) map[string]interface{} {
+	if len(data) < 2 { return nil }
+	return map[string]interface{}{"v": int(data[1])}
+}`
+	if err := mgr.RegisterParserWithSchema("door", code, &Schema{Fields: map[string]string{"v": "integer"}, Samples: []string{"0D01", "0D00"}}); err != nil {
+		t.Fatalf("RegisterParserWithSchema failed: %v", err)
+	}
+
+	stats, err := mgr.ProfileParser(context.Background(), "door", nil, 5)
+	if err != nil {
+		t.Fatalf("ProfileParser failed: %v", err)
+	}
+	if stats.Protocol != "door" || stats.Executions != 10 {
+		t.Errorf("expected 5 passes over the 2 stored samples, got %+v", stats)
+	}
+	if _, err := mgr.ProfileParser(context.Background(), "unknown", nil, 1); err == nil {
+		t.Error("expected an error for an unknown protocol")
+	}
+}
@@ -0,0 +1,36 @@
+package parser
+
+import "context"
+
+// DiscoveryProgress reports how far a streamed LLM generation has got
+type DiscoveryProgress struct {
+	Chunks  int  // Streamed chunks received; Ollama sends roughly one token per chunk
+	Bytes   int  // Generated code received so far
+	Percent int  // Estimate against the output token budget; 100 only once Done
+	Done    bool // The generation is complete
+}
+
+// ProgressFunc receives discovery progress updates. It runs on the discovery
+// goroutine, so it should return quickly.
+type ProgressFunc func(p DiscoveryProgress)
+
+type progressKey struct{}
+
+// WithDiscoveryProgress returns a context that makes DiscoverNewProtocolContext and
+// RepairParserContext report streaming progress to fn. Progress is only reported when DiscoveryConfig.Stream is set.
+func WithDiscoveryProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+func progressFrom(ctx context.Context) ProgressFunc {
+	fn, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return fn
+}
+
+func newDiscoveryProgress(chunks, bytes int, done bool) DiscoveryProgress {
+	percent := 100
+	if !done {
+		percent = min(chunks*100/llmMaxOutputTokens, 99)
+	}
+	return DiscoveryProgress{Chunks: chunks, Bytes: bytes, Percent: percent, Done: done}
+}
@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// streamingServer streams the parser in chunks, then waits for release before sending done
+func streamingServer(t *testing.T, release <-chan struct{}) *httptest.Server {
+	t.Helper()
+	chunks := []string{
+		"// Signature: 0E\npackage dynamic\n",
+		"func Parse(data []byte) map[string]interface{} {\n",
+		"\treturn map[string]interface{}{\"v\": int(data[1])}\n}",
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc := json.NewEncoder(w)
+		for _, c := range chunks {
+			_ = enc.Encode(OllamaResponse{Response: c})
+			w.(http.Flusher).Flush()
+		}
+		select {
+		case <-release:
+			_ = enc.Encode(OllamaResponse{Done: true})
+		case <-r.Context().Done():
+		}
+	}))
+}
+
+func newStreamingService(t *testing.T, endpoint string) (*DiscoveryService, *Dispatcher) {
+	t.Helper()
+	service, dispatcher, _ := newTestDiscovery(t, DiscoveryConfig{
+		Provider:   "ollama",
+		Endpoint:   endpoint,
+		Stream:     true,
+		RetryDelay: time.Millisecond,
+	})
+	return service, dispatcher
+}
+
+func TestDiscoveryService_StreamingProgress(t *testing.T) {
+	release := make(chan struct{})
+	server := streamingServer(t, release)
+	defer server.Close()
+	service, dispatcher := newStreamingService(t, server.URL)
+
+	var updates []DiscoveryProgress
+	var releaseOnce sync.Once
+	ctx := WithDiscoveryProgress(context.Background(), func(p DiscoveryProgress) {
+		updates = append(updates, p)
+		// The server only completes once we've seen all chunks, so these arrive before completion
+		if p.Chunks == 3 {
+			releaseOnce.Do(func() { close(release) })
+		}
+	})
+
+	protocolID, err := service.DiscoverNewProtocolContext(ctx, []byte{0x0E, 0x05}, nil, "hint")
+	if err != nil {
+		t.Fatalf("DiscoverNewProtocolContext failed: %v", err)
+	}
+	if len(updates) != 4 {
+		t.Fatalf("Expected 4 progress updates, got %+v", updates)
+	}
+	for i, p := range updates[:3] {
+		if p.Done || p.Chunks != i+1 || p.Percent >= 100 || p.Bytes == 0 {
+			t.Errorf("update %d: unexpected in-flight progress %+v", i, p)
+		}
+	}
+	if last := updates[3]; !last.Done || last.Percent != 100 {
+		t.Errorf("Expected final update to be complete, got %+v", last)
+	}
+	if res, _, err := dispatcher.Ingest([]byte{0x0E, 0x05}); err != nil || res["v"] != 5 || protocolID != "auto_proto_0x0E" {
+		t.Errorf("Expected streamed parser to be registered, got %s %v (%v)", protocolID, res, err)
+	}
+}
+
+func TestDiscoveryService_StreamingCancel(t *testing.T) {
+	server := streamingServer(t, nil) // Never completes on its own
+	defer server.Close()
+	service, dispatcher, _ := newTestDiscovery(t, DiscoveryConfig{
+		Provider:   "ollama",
+		Endpoint:   server.URL,
+		Stream:     true,
+		RetryDelay: time.Millisecond,
+		Timeout:    300 * time.Millisecond, // Ends the discovery the caller stopped waiting for
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = WithDiscoveryProgress(ctx, func(p DiscoveryProgress) {
+		if p.Chunks == 1 {
+			cancel() // Operator gives up after the first progress report
+		}
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := service.DiscoverNewProtocolContext(ctx, []byte{0x0E, 0x05}, nil, "hint")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected cancellation error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Discovery did not stop after cancel")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for service.IsDiscovering([]byte{0x0E}) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, proto, _ := dispatcher.Ingest([]byte{0x0E, 0x05}); proto != "" {
+		t.Errorf("Discovery past its deadline must not bind a parser, got %s", proto)
+	}
+}
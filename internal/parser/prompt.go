@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/chuanjin/OmniBridge/agents"
+)
+
+// Names of the templates a PromptBuilder renders
+const (
+	PromptDiscovery = "discovery"
+	PromptRepair    = "repair"
+	PromptDescribe  = "describe" // Plain-English breakdown of a frame (DescribeFrame)
+)
+
+// promptNames are the templates a PromptBuilder knows
+var promptNames = []string{PromptDiscovery, PromptRepair, PromptDescribe}
+
+// PromptData holds the variables available to prompt templates. Hex samples are
+// uppercase hex strings without separators.
+type PromptData struct {
+	SystemPrompt string
+	HexSample    string
+	ExtraSamples []string // Further samples of the same signature (discovery only)
+	ContextHint  string   // Discovery and describe only
+	FaultyCode   string   // Repair only
+	ErrorMessage string   // Repair only
+}
+
+// PromptBuilder renders the discovery, repair and describe prompts from text/template files named
+// <name>.tmpl in its directory, so they can be customized without recompiling. A missing
+// file falls back to the one shipped in agents/, embedded in the binary. Files are read on
+// every render.
+type PromptBuilder struct {
+	dir string
+}
+
+func NewPromptBuilder(dir string) *PromptBuilder {
+	return &PromptBuilder{dir: dir}
+}
+
+// PromptBuilderFor returns the builder for the agents directory holding systemPromptPath
+// (DefaultSystemPromptPath if empty)
+func PromptBuilderFor(systemPromptPath string) *PromptBuilder {
+	if systemPromptPath == "" {
+		systemPromptPath = DefaultSystemPromptPath
+	}
+	return NewPromptBuilder(filepath.Dir(systemPromptPath))
+}
+
+// Render executes the named template with data
+func (b *PromptBuilder) Render(name string, data PromptData) (string, error) {
+	tmpl, err := b.load(name)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render %s prompt: %v", name, err)
+	}
+	return out.String(), nil
+}
+
+// Check parses every template, surfacing a broken customization before it is needed
+func (b *PromptBuilder) Check() error {
+	for _, name := range promptNames {
+		if _, err := b.load(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *PromptBuilder) load(name string) (*template.Template, error) {
+	if !slices.Contains(promptNames, name) {
+		return nil, fmt.Errorf("unknown prompt template %q", name)
+	}
+	path := filepath.Join(b.dir, name+".tmpl")
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		path = "agents/" + name + ".tmpl"
+		data, err = fs.ReadFile(agents.Templates, name+".tmpl")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompt template %s: %v", path, err)
+	}
+	// The prompt shouldn't end with the newline editors put at the end of files
+	tmpl, err := template.New(name).Parse(strings.TrimSuffix(string(data), "\n"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt template %s: %v", path, err)
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPromptBuilder_Render(t *testing.T) {
+	b := NewPromptBuilder(t.TempDir())
+	data := PromptData{
+		SystemPrompt: "SYSTEM",
+		HexSample:    "55AA01",
+		ExtraSamples: []string{"55AA02"},
+		ContextHint:  "door sensor",
+		FaultyCode:   "func Parse() {}",
+		ErrorMessage: "index out of range",
+	}
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{PromptDiscovery, []string{"SYSTEM\n\nINPUT:", "Hex Sample: 55AA01", "Protocol Hints: door sensor", "Additional Hex Sample: 55AA02"}},
+		{PromptRepair, []string{"SYSTEM\n\n### ERROR TO FIX", "```go\nfunc Parse() {}\n```", "ERROR MESSAGE:\nindex out of range", "INPUT DATA (Hex): 55AA01"}},
+		{PromptDescribe, []string{"unknown binary protocol frame", "FRAME (Hex): 55AA01", "CONTEXT: door sensor"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt, err := b.Render(tt.name, data)
+			if err != nil {
+				t.Fatalf("Render failed: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("prompt lacks %q:\n%s", want, prompt)
+				}
+			}
+		})
+	}
+
+	if _, err := b.Render("summary", data); err == nil {
+		t.Error("expected an error for an unknown template")
+	}
+}
+
+func TestPromptBuilder_CustomTemplates(t *testing.T) {
+	dir := t.TempDir()
+	b := NewPromptBuilder(dir)
+	custom := "Decode {{.HexSample}} ({{.ContextHint}})\n"
+	if err := os.WriteFile(filepath.Join(dir, "discovery.tmpl"), []byte(custom), 0o644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	prompt, err := b.Render(PromptDiscovery, PromptData{HexSample: "7A01", ContextHint: "CAN"})
+	if err != nil || prompt != "Decode 7A01 (CAN)" {
+		t.Errorf("Render = %q, %v", prompt, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "repair.tmpl"), []byte("{{.FaultyCode"), 0o644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	if err := b.Check(); err == nil {
+		t.Error("expected Check to reject a malformed template")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "repair.tmpl"), []byte("{{.Protocol}}"), 0o644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	if _, err := b.Render(PromptRepair, PromptData{}); err == nil {
+		t.Error("expected an error for an unknown variable")
+	}
+}
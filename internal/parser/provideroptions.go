@@ -0,0 +1,50 @@
+package parser
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Keys recognized in DiscoveryConfig.ProviderOptions
+const (
+	OptionOllamaKeepAlive    = "keep_alive"        // ollama: how long the model stays loaded, e.g. "10m" or "-1"
+	OptionAnthropicVersion   = "anthropic_version" // anthropic: anthropic-version header (default 2023-06-01)
+	OptionAnthropicBeta      = "anthropic_beta"    // anthropic: anthropic-beta header
+	OptionOpenAIOrganization = "organization"      // openai: OpenAI-Organization header
+	OptionAzureDeployment    = "azure_deployment"  // openai: call an Azure OpenAI deployment instead of /chat/completions
+	OptionAzureAPIVersion    = "api_version"       // openai: api-version of an Azure deployment (default 2024-06-01)
+)
+
+// providerOptionKeys lists the ProviderOptions keys each provider reads
+var providerOptionKeys = map[string][]string{
+	"ollama":    {OptionOllamaKeepAlive},
+	"anthropic": {OptionAnthropicVersion, OptionAnthropicBeta},
+	"openai":    {OptionOpenAIOrganization, OptionAzureDeployment, OptionAzureAPIVersion},
+}
+
+// option returns a provider-specific setting, or def if it isn't set
+func (s *DiscoveryService) option(key, def string) string {
+	if v := s.Config.ProviderOptions[key]; v != "" {
+		return v
+	}
+	return def
+}
+
+// warnUnknownOptions logs ProviderOptions keys the configured provider ignores, typically typos
+func warnUnknownOptions(provider string, options map[string]string) {
+	known := providerOptionKeys[provider]
+	var unknown []string
+	for key := range options {
+		if !slices.Contains(known, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		logger.Warn("Ignoring provider options not used by this provider",
+			zap.String("provider", provider), zap.Strings("options", unknown), zap.Strings("recognized", known))
+	}
+}
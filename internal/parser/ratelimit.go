@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned by DiscoverNewProtocol when the discovery budget is exhausted.
+// The frame should be dropped; the signature can be discovered once the budget refills.
+var ErrRateLimited = errors.New("discovery rate limited")
+
+// signatureWindow is the period MaxSignaturesPerHour counts distinct signatures over
+const signatureWindow = time.Hour
+
+// discoveryLimiter caps LLM discoveries with a token bucket refilled at perMinute per minute
+// (bursts up to perMinute) and a sliding one-hour cap on distinct signatures. Zero disables a limit.
+type discoveryLimiter struct {
+	perMinute  int
+	tokens     float64
+	refilled   time.Time
+	perHour    int
+	signatures map[string]time.Time // Signature -> first discovery attempt in the window
+	now        func() time.Time
+	mu         sync.Mutex
+}
+
+func newDiscoveryLimiter(perMinute, perHour int) *discoveryLimiter {
+	return &discoveryLimiter{
+		perMinute:  perMinute,
+		tokens:     float64(perMinute),
+		perHour:    perHour,
+		signatures: make(map[string]time.Time),
+		now:        time.Now,
+	}
+}
+
+// allow takes one discovery from the budget for signature, or reports why it can't
+func (l *discoveryLimiter) allow(signature string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+
+	if l.perHour > 0 {
+		for sig, first := range l.signatures {
+			if now.Sub(first) >= signatureWindow {
+				delete(l.signatures, sig)
+			}
+		}
+		if _, seen := l.signatures[signature]; !seen && len(l.signatures) >= l.perHour {
+			return fmt.Errorf("%w: %d distinct signatures in the last hour", ErrRateLimited, len(l.signatures))
+		}
+	}
+
+	if l.perMinute > 0 {
+		if !l.refilled.IsZero() {
+			l.tokens += now.Sub(l.refilled).Minutes() * float64(l.perMinute)
+			if l.tokens > float64(l.perMinute) {
+				l.tokens = float64(l.perMinute)
+			}
+		}
+		l.refilled = now
+		if l.tokens < 1 {
+			return fmt.Errorf("%w: more than %d discoveries per minute", ErrRateLimited, l.perMinute)
+		}
+		l.tokens--
+	}
+
+	if l.perHour > 0 {
+		if _, seen := l.signatures[signature]; !seen {
+			l.signatures[signature] = now
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"go.uber.org/zap"
+)
+
+// ReconcileReport summarises a ReconcileAll sweep. Each list holds protocol IDs in sorted order.
+type ReconcileReport struct {
+	Passed      []string          // Every stored vector still passes
+	Repaired    []string          // Failed, then passed after an LLM repair
+	Quarantined []string          // Still failing; disabled until an operator looks at them
+	Untested    []string          // No golden cases or schema samples to check against
+	Failures    map[string]string // Quarantined protocol -> why it failed
+}
+
+func (r ReconcileReport) String() string {
+	return fmt.Sprintf("%d passed, %d repaired, %d quarantined, %d untested",
+		len(r.Passed), len(r.Repaired), len(r.Quarantined), len(r.Untested))
+}
+
+// ReconcileAll re-runs every parser against its stored vectors (golden cases and schema
+// samples, see ParserManager.ValidateStored), which is worth doing after an interpreter
+// upgrade or a config change. A failing parser is sent for repair with the first failing
+// vector; if there is no discovery service or the repair doesn't make every vector pass,
+// the protocol is quarantined: disabled in the dispatcher, with its bindings kept, and
+// recorded as disabled in the manifest so it stays off after a restart.
+// Cancelling ctx aborts the repair in flight.
+func (g *Gateway) ReconcileAll(ctx context.Context) ReconcileReport {
+	report := ReconcileReport{Failures: make(map[string]string)}
+
+	parsers := g.manager.Parsers()
+	ids := make([]string, 0, len(parsers))
+	for id := range parsers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		// Recompile from source so nothing compiled by an older setup is reused
+		g.manager.engine.ClearCache(id)
+
+		failure, sample, tested := g.checkStored(id)
+		switch {
+		case !tested:
+			report.Untested = append(report.Untested, id)
+			continue
+		case failure == "":
+			report.Passed = append(report.Passed, id)
+			continue
+		}
+		logger.Warn("Parser fails its stored vectors", zap.String("protocol", id), zap.String("failure", failure))
+
+		if g.discovery != nil && sample != nil {
+			code, _ := g.manager.GetParserCode(id)
+			_, err := g.discovery.RepairParserContext(ctx, id, code, failure, sample, nil)
+			if err == nil {
+				failure, _, _ = g.checkStored(id)
+			} else {
+				failure = fmt.Sprintf("%s (repair failed: %v)", failure, err)
+			}
+			if failure == "" {
+				logger.Info("Parser repaired during reconcile", zap.String("protocol", id))
+				report.Repaired = append(report.Repaired, id)
+				continue
+			}
+		}
+
+		g.dispatcher.SetEnabled(id, false)
+		logger.Error("Parser quarantined", zap.String("protocol", id), zap.String("failure", failure))
+		report.Quarantined = append(report.Quarantined, id)
+		report.Failures[id] = failure
+	}
+
+	if len(report.Quarantined) > 0 {
+		if err := g.dispatcher.SaveManifest(); err != nil {
+			logger.Error("Failed to persist quarantined protocols", zap.Error(err))
+		}
+	}
+	logger.Info("Reconcile finished", zap.Stringer("summary", report))
+	return report
+}
+
+// checkStored validates a protocol's parser against its stored vectors. It returns a
+// description of the first failure ("" if all pass) with that vector's frame, and
+// whether the protocol had any vectors at all.
+func (g *Gateway) checkStored(protocolID string) (failure string, sample []byte, tested bool) {
+	results, err := g.manager.ValidateStored(protocolID)
+	if err != nil {
+		return err.Error(), nil, true
+	}
+	for _, r := range results {
+		if r.Passed {
+			continue
+		}
+		sample, _ = hex.DecodeString(strings.Join(strings.Fields(r.Case.Input), ""))
+		name := r.Case.Name
+		if name == "" {
+			name = r.Case.Input
+		}
+		if r.Err != nil {
+			return fmt.Sprintf("%s: %v", name, r.Err), sample, true
+		}
+		return fmt.Sprintf("%s: got %v, want %v", name, r.Got, r.Case.Expected), sample, true
+	}
+	return "", nil, len(results) > 0
+}
@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGateway_ReconcileAll(t *testing.T) {
+	// The LLM fixes "temp" but can't fix "lost"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		code := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": -1} }`
+		if strings.Contains(string(body), "celsius") {
+			code = `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"celsius": int(data[1]) - 40} }`
+		}
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: code})
+	}))
+	defer server.Close()
+
+	mgr := NewParserManager(t.TempDir(), "")
+	parsers := map[string]string{
+		"rpm": `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"rpm": (int(data[1])*256 + int(data[2])) / 4} }`,
+		// Regressed: lost the -40 offset
+		"temp": `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"celsius": int(data[1])} }`,
+		"lost": `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": int(data[1]) * 2} }`,
+		"adhoc": `package dynamic
+func Parse(data []byte) map[string]interface{} { return nil }`,
+	}
+	for id, code := range parsers {
+		if err := mgr.RegisterParser(id, code); err != nil {
+			t.Fatalf("RegisterParser(%s) failed: %v", id, err)
+		}
+	}
+	golden := map[string][]TestCase{
+		"rpm":  {{Input: "0C1AF8", Expected: map[string]interface{}{"rpm": 1726}}},
+		"temp": {{Name: "warm engine", Input: "055A", Expected: map[string]interface{}{"celsius": 50}}},
+		"lost": {{Input: "9902", Expected: map[string]interface{}{"v": 2}}},
+	}
+	for id, cases := range golden {
+		data, _ := json.Marshal(cases)
+		if err := os.WriteFile(mgr.goldenPath(id), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := NewDispatcher(mgr)
+	d.Bind([]byte{0x05}, "temp")
+	d.Bind([]byte{0x99}, "lost")
+	disc := NewDiscoveryService(d, mgr, DiscoveryConfig{Provider: "ollama", Endpoint: server.URL, SystemPromptPath: testSystemPrompt(t)})
+
+	report := NewGateway(d, disc).ReconcileAll(context.Background())
+
+	want := ReconcileReport{
+		Passed:      []string{"rpm"},
+		Repaired:    []string{"temp"},
+		Quarantined: []string{"lost"},
+		Untested:    []string{"adhoc"},
+	}
+	if !reflect.DeepEqual(report.Passed, want.Passed) || !reflect.DeepEqual(report.Repaired, want.Repaired) ||
+		!reflect.DeepEqual(report.Quarantined, want.Quarantined) || !reflect.DeepEqual(report.Untested, want.Untested) {
+		t.Errorf("report = %+v, want %+v", report, want)
+	}
+	if !strings.Contains(report.Failures["lost"], "repair failed") {
+		t.Errorf("expected the failed repair to be reported, got %q", report.Failures["lost"])
+	}
+
+	if res, _, err := d.Ingest([]byte{0x05, 0x5A}); err != nil || res["celsius"] != 50 {
+		t.Errorf("repaired parser: %v, %v", res, err)
+	}
+	if d.IsEnabled("lost") || !d.IsEnabled("temp") {
+		t.Error("expected only the unrepairable protocol to be quarantined")
+	}
+
+	// The quarantine survives a restart
+	restarted := NewDispatcher(NewParserManager(mgr.storagePath, ""))
+	if err := restarted.RestoreManifest(); err != nil {
+		t.Fatalf("RestoreManifest failed: %v", err)
+	}
+	if restarted.IsEnabled("lost") || !restarted.IsEnabled("temp") {
+		t.Error("expected the quarantine to be restored from the manifest")
+	}
+}
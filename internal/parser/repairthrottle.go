@@ -0,0 +1,227 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"go.uber.org/zap"
+)
+
+// ErrRepairDeferred is returned by RequestRepair when the repair budget is spent: the repair
+// is queued and runs once the budget allows, so the frame that triggered it fails for now.
+var ErrRepairDeferred = errors.New("repair deferred")
+
+// DefaultRepairWindow is the period DiscoveryConfig.RepairBudget counts repairs over
+const DefaultRepairWindow = time.Hour
+
+// maxDeferredRepairs bounds the repair queue; repairs beyond it are dropped
+const maxDeferredRepairs = 64
+
+// deferredRepair is a queued self-healing repair
+type deferredRepair struct {
+	protocolID string
+	faultyCode string
+	errorMsg   string
+	sample     []byte
+}
+
+// repairThrottle spreads self-healing repairs out with a token bucket of perMinute repairs
+// per minute and a budget of repairs in any sliding window. Repairs beyond either wait in a
+// FIFO queue, one per protocol, and run in the background as the budget refills.
+type repairThrottle struct {
+	perMinute int
+	tokens    float64
+	refilled  time.Time
+	budget    int
+	window    time.Duration
+	started   []time.Time // Start of each repair still inside the window
+
+	pending map[string]*deferredRepair
+	order   []string // Protocols in pending, oldest first
+	timer   *time.Timer
+
+	run func(deferredRepair)
+	now func() time.Time
+	mu  sync.Mutex
+}
+
+func newRepairThrottle(perMinute, budget int, window time.Duration, run func(deferredRepair)) *repairThrottle {
+	if window <= 0 {
+		window = DefaultRepairWindow
+	}
+	return &repairThrottle{
+		perMinute: perMinute,
+		tokens:    float64(perMinute),
+		budget:    budget,
+		window:    window,
+		pending:   make(map[string]*deferredRepair),
+		run:       run,
+		now:       time.Now,
+	}
+}
+
+// reserve takes one repair from the budget, or returns how long until one is available.
+// Callers hold t.mu.
+func (t *repairThrottle) reserve() time.Duration {
+	now := t.now()
+	var wait time.Duration
+
+	if t.perMinute > 0 {
+		if !t.refilled.IsZero() {
+			t.tokens = min(t.tokens+now.Sub(t.refilled).Minutes()*float64(t.perMinute), float64(t.perMinute))
+		}
+		t.refilled = now
+		if t.tokens < 1 {
+			wait = time.Duration((1 - t.tokens) / float64(t.perMinute) * float64(time.Minute))
+		}
+	}
+	if t.budget > 0 {
+		for len(t.started) > 0 && now.Sub(t.started[0]) >= t.window {
+			t.started = t.started[1:]
+		}
+		if len(t.started) >= t.budget {
+			wait = max(wait, t.started[0].Add(t.window).Sub(now))
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+
+	if t.perMinute > 0 {
+		t.tokens--
+	}
+	if t.budget > 0 {
+		t.started = append(t.started, now)
+	}
+	return 0
+}
+
+// submit reports whether r may run right away. Otherwise r is queued (replacing an earlier
+// repair of the same protocol) and ErrRepairDeferred returned, or dropped if the queue is full.
+func (t *repairThrottle) submit(r deferredRepair) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if queued, ok := t.pending[r.protocolID]; ok {
+		*queued = r
+		return fmt.Errorf("%w: %s already waiting for the repair budget", ErrRepairDeferred, r.protocolID)
+	}
+	// Queued repairs go first, so a new one may only run now if the queue is empty
+	if len(t.order) == 0 {
+		wait := t.reserve()
+		if wait == 0 {
+			return nil
+		}
+		t.schedule(wait)
+	}
+	if len(t.order) >= maxDeferredRepairs {
+		logger.Warn("Repair queue full, dropping repair", zap.String("protocol", r.protocolID), zap.Int("queued", len(t.order)))
+		return fmt.Errorf("repair of %s dropped: %d repairs already waiting for the budget", r.protocolID, len(t.order))
+	}
+
+	t.pending[r.protocolID] = &r
+	t.order = append(t.order, r.protocolID)
+	logger.Warn("Repair budget exhausted, deferring repair", zap.String("protocol", r.protocolID), zap.Int("queued", len(t.order)))
+	return fmt.Errorf("%w: %d repairs queued", ErrRepairDeferred, len(t.order))
+}
+
+// schedule arranges a drain after wait unless one is already scheduled. Callers hold t.mu.
+func (t *repairThrottle) schedule(wait time.Duration) {
+	if t.timer == nil {
+		t.timer = time.AfterFunc(wait, t.drain)
+	}
+}
+
+// drain runs the queued repairs the budget allows and schedules the next drain for the rest
+func (t *repairThrottle) drain() {
+	t.mu.Lock()
+	t.timer = nil
+	var ready []deferredRepair
+	for len(t.order) > 0 {
+		if wait := t.reserve(); wait > 0 {
+			t.schedule(wait)
+			break
+		}
+		id := t.order[0]
+		t.order = t.order[1:]
+		ready = append(ready, *t.pending[id])
+		delete(t.pending, id)
+	}
+	t.mu.Unlock()
+
+	for _, r := range ready {
+		t.run(r)
+	}
+}
+
+// queued returns the protocols waiting for the repair budget, oldest first
+func (t *repairThrottle) queued() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.order...)
+}
+
+// RequestRepair is RepairParserContext for the self-healing loop: with a repair budget
+// configured (DiscoveryConfig.MaxRepairsPerMinute, RepairBudget), repairs beyond it are
+// queued and ErrRepairDeferred returned instead of calling the LLM.
+//
+// Calls for a protocol that is already being repaired wait for that repair and share its
+// result, taking nothing from the budget. Like a discovery, the repair is detached from the
+// callers' cancellation and bounded by DiscoveryConfig.Timeout; cancelling ctx stops waiting.
+func (s *DiscoveryService) RequestRepair(ctx context.Context, protocolID, faultyCode, errorMsg string, rawSample []byte) error {
+	return s.shareRepair(ctx, protocolID, func(ctx context.Context) error {
+		if s.repairs != nil {
+			r := deferredRepair{protocolID: protocolID, faultyCode: faultyCode, errorMsg: errorMsg, sample: rawSample}
+			if err := s.repairs.submit(r); err != nil {
+				return err
+			}
+		}
+		_, err := s.RepairParserContext(ctx, protocolID, faultyCode, errorMsg, rawSample, nil)
+		return err
+	})
+}
+
+// shareRepair runs repair unless one of protocolID is already in flight, in which case it
+// waits for that one's result
+func (s *DiscoveryService) shareRepair(ctx context.Context, protocolID string, repair func(context.Context) error) error {
+	results := s.repairGroup.DoChan(protocolID, func() (interface{}, error) {
+		return nil, repair(context.WithoutCancel(ctx))
+	})
+	select {
+	case res := <-results:
+		if res.Shared {
+			logger.Debug("Shared in-flight repair", zap.String("protocol", protocolID))
+		}
+		return res.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// DeferredRepairs returns the protocols whose repair waits for the repair budget
+func (s *DiscoveryService) DeferredRepairs() []string {
+	if s.repairs == nil {
+		return nil
+	}
+	return s.repairs.queued()
+}
+
+// runDeferredRepair repairs a queued protocol, unless its parser changed while it waited
+func (s *DiscoveryService) runDeferredRepair(r deferredRepair) {
+	if code, ok := s.manager.GetParserCode(r.protocolID); !ok || code != r.faultyCode {
+		logger.Info("Dropping deferred repair, parser changed meanwhile", zap.String("protocol", r.protocolID))
+		return
+	}
+	logger.Info("Running deferred repair", zap.String("protocol", r.protocolID))
+	err := s.shareRepair(context.Background(), r.protocolID, func(ctx context.Context) error {
+		_, err := s.RepairParserContext(ctx, r.protocolID, r.faultyCode, r.errorMsg, r.sample, nil)
+		return err
+	})
+	if err != nil {
+		logger.Error("Deferred repair failed", zap.String("protocol", r.protocolID), zap.Error(err))
+	}
+}
@@ -0,0 +1,167 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDiscoveryService_RepairBudgetDefersRepairs(t *testing.T) {
+	var calls int
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"fixed": true} }`})
+	}))
+	defer server.Close()
+	callCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+
+	service, _, manager := newTestDiscovery(t, DiscoveryConfig{
+		Provider: "ollama", Endpoint: server.URL,
+		RepairBudget: 2, RepairWindow: time.Minute,
+	})
+	now := time.Now()
+	service.repairs.now = func() time.Time { return now }
+
+	const broken = `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": data[9]} }`
+	protocols := []string{"Proto_A", "Proto_B", "Proto_C", "Proto_D"}
+	for _, id := range protocols {
+		if err := manager.RegisterParser(id, broken); err != nil {
+			t.Fatalf("register %s: %v", id, err)
+		}
+	}
+	repair := func(id string) error {
+		code, _ := manager.GetParserCode(id)
+		return service.RequestRepair(context.Background(), id, code, "index out of range", []byte{0xA0, 0x01})
+	}
+
+	for _, id := range protocols[:2] {
+		if err := repair(id); err != nil {
+			t.Fatalf("repair of %s within budget failed: %v", id, err)
+		}
+	}
+	for _, id := range protocols[2:] {
+		if err := repair(id); !errors.Is(err, ErrRepairDeferred) {
+			t.Fatalf("expected ErrRepairDeferred for %s, got %v", id, err)
+		}
+	}
+	// A second failure of a queued protocol doesn't queue it twice
+	if err := repair("Proto_C"); !errors.Is(err, ErrRepairDeferred) {
+		t.Fatalf("expected ErrRepairDeferred for a queued protocol, got %v", err)
+	}
+	if got := callCount(); got != 2 {
+		t.Errorf("expected 2 LLM calls within the budget, got %d", got)
+	}
+	if got := service.DeferredRepairs(); !reflect.DeepEqual(got, []string{"Proto_C", "Proto_D"}) {
+		t.Errorf("expected Proto_C and Proto_D queued, got %v", got)
+	}
+
+	// Once the window passes, the queued repairs run in order
+	now = now.Add(time.Minute)
+	service.repairs.drain()
+	if got := callCount(); got != 4 {
+		t.Errorf("expected the 2 deferred repairs to reach the LLM, got %d calls", got)
+	}
+	if got := service.DeferredRepairs(); len(got) != 0 {
+		t.Errorf("expected an empty repair queue, got %v", got)
+	}
+	if code, _ := manager.GetParserCode("Proto_D"); code == broken {
+		t.Error("deferred repair of Proto_D was not registered")
+	}
+}
+
+func TestRepairThrottle_PerMinute(t *testing.T) {
+	var ran []string
+	throttle := newRepairThrottle(2, 0, 0, func(r deferredRepair) { ran = append(ran, r.protocolID) })
+	now := time.Now()
+	throttle.now = func() time.Time { return now }
+
+	for _, id := range []string{"a", "b"} {
+		if err := throttle.submit(deferredRepair{protocolID: id}); err != nil {
+			t.Fatalf("submit %s within budget: %v", id, err)
+		}
+	}
+	if err := throttle.submit(deferredRepair{protocolID: "c"}); !errors.Is(err, ErrRepairDeferred) {
+		t.Fatalf("expected ErrRepairDeferred, got %v", err)
+	}
+
+	// The queue goes first: a new repair is deferred even when a token has refilled
+	now = now.Add(30 * time.Second)
+	if err := throttle.submit(deferredRepair{protocolID: "d"}); !errors.Is(err, ErrRepairDeferred) {
+		t.Fatalf("expected ErrRepairDeferred while repairs are queued, got %v", err)
+	}
+	throttle.drain()
+	if !reflect.DeepEqual(ran, []string{"c"}) {
+		t.Errorf("expected only c to run after half a minute, got %v", ran)
+	}
+	if got := throttle.queued(); !reflect.DeepEqual(got, []string{"d"}) {
+		t.Errorf("expected d still queued, got %v", got)
+	}
+}
+
+func TestDiscoveryService_ConcurrentRepairsShareOneRequest(t *testing.T) {
+	var calls atomic.Int32
+	arrived, release := make(chan struct{}, 1), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		calls.Add(1)
+		arrived <- struct{}{}
+		<-release
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"fixed": true} }`})
+	}))
+	defer server.Close()
+
+	service, _, manager := newTestDiscovery(t, DiscoveryConfig{
+		Provider: "ollama", Endpoint: server.URL,
+		RepairBudget: 2, RepairWindow: time.Minute,
+	})
+	const broken = `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": data[9]} }`
+	if err := manager.RegisterParser("Proto_A", broken); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+
+	// Every failing frame of Proto_A asks for a repair while the first one is in flight
+	errs := make(chan error, 5)
+	for range cap(errs) {
+		go func() {
+			errs <- service.RequestRepair(context.Background(), "Proto_A", broken, "index out of range", []byte{0xA0, 0x01})
+		}()
+	}
+	<-arrived
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for range cap(errs) {
+		if err := <-errs; err != nil {
+			t.Errorf("shared repair failed: %v", err)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected one LLM repair for Proto_A, got %d", got)
+	}
+
+	// The shared repair took a single repair from the budget
+	if err := manager.RegisterParser("Proto_B", broken); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	if err := service.RequestRepair(context.Background(), "Proto_B", broken, "index out of range", []byte{0xB0, 0x01}); err != nil {
+		t.Errorf("expected Proto_B to be repaired within the budget, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package parser
+
+import (
+	"bytes"
+	"container/list"
+	"hash/fnv"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// DefaultResultCacheEntries bounds the result cache when no size is given
+const DefaultResultCacheEntries = 1024
+
+// resultKey identifies a payload parsed by a protocol
+type resultKey struct {
+	protocolID string
+	hash       uint64
+}
+
+type cachedResult struct {
+	key     resultKey
+	payload []byte // Kept to rule out hash collisions
+	result  map[string]interface{}
+	expires time.Time // Zero never expires
+}
+
+// resultCache remembers successful parses of byte-identical frames (e.g. heartbeats) so they
+// skip the interpreter, evicting the least recently used entry once full. Entries are dropped
+// whenever the parser changes.
+type resultCache struct {
+	ttl        time.Duration // 0 keeps entries until they are evicted or invalidated
+	maxEntries int
+	order      *list.List // Of *cachedResult, most recently used first
+	entries    map[resultKey]*list.Element
+	generation uint64 // Bumped by invalidate, so a parse that raced it isn't cached
+	mu         sync.Mutex
+}
+
+func newResultCache(ttl time.Duration, maxEntries int) *resultCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultResultCacheEntries
+	}
+	return &resultCache{ttl: ttl, maxEntries: maxEntries, order: list.New(), entries: make(map[resultKey]*list.Element)}
+}
+
+// SetResultCache enables caching of successful parse results for ttl, holding at most
+// maxEntries results (DefaultResultCacheEntries if non-positive). A non-positive ttl disables it.
+// Only enable it for parsers whose output depends on nothing but the frame.
+func (e *Engine) SetResultCache(ttl time.Duration, maxEntries int) {
+	var c *resultCache
+	if ttl > 0 {
+		c = newResultCache(ttl, maxEntries)
+	}
+	e.mu.Lock()
+	e.results = c
+	e.mu.Unlock()
+}
+
+// EnableResultCache memoizes successful parses of byte-identical frames in the engine's result
+// cache, keeping the size most recently used ones until their parser changes, so a heartbeat
+// arriving thousands of times a second is parsed once. Routing, validators and sinks still see
+// every frame. A non-positive size disables the cache; either way it replaces one set with
+// Engine.SetResultCache. Leave it off for parsers whose output depends on more than the frame,
+// such as the time of day.
+func (d *Dispatcher) EnableResultCache(size int) {
+	var c *resultCache
+	if size > 0 {
+		c = newResultCache(0, size)
+	}
+	e := d.manager.engine
+	e.mu.Lock()
+	e.results = c
+	e.mu.Unlock()
+}
+
+func payloadHash(data []byte) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	return h.Sum64()
+}
+
+// get returns a copy of the cached result for data, if it is still fresh, along with the
+// generation to hand to put after parsing it on a miss
+func (c *resultCache) get(protocolID string, data []byte, now time.Time) (map[string]interface{}, uint64, bool) {
+	key := resultKey{protocolID, payloadHash(data)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, c.generation, false
+	}
+	entry := elem.Value.(*cachedResult)
+	if !bytes.Equal(entry.payload, data) {
+		return nil, c.generation, false
+	}
+	if !entry.expires.IsZero() && now.After(entry.expires) {
+		c.removeLocked(elem)
+		return nil, c.generation, false
+	}
+	c.order.MoveToFront(elem)
+	return cloneResult(entry.result), c.generation, true
+}
+
+// put caches a result parsed under generation, unless the protocol was invalidated since
+func (c *resultCache) put(protocolID string, data []byte, result map[string]interface{}, now time.Time, generation uint64) {
+	key := resultKey{protocolID, payloadHash(data)}
+	entry := &cachedResult{key: key, payload: append([]byte(nil), data...), result: cloneResult(result)}
+	if c.ttl > 0 {
+		entry.expires = now.Add(c.ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	if c.order.Len() > c.maxEntries {
+		c.removeLocked(c.order.Back())
+	}
+}
+
+func (c *resultCache) removeLocked(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*cachedResult).key)
+}
+
+// invalidate drops every cached result of a protocol
+func (c *resultCache) invalidate(protocolID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for key, elem := range c.entries {
+		if key.protocolID == protocolID {
+			c.order.Remove(elem)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// cloneResult copies a result down to its nested maps and slices, so a caller mutating
+// what it was handed can't change the cached entry
+func cloneResult(result map[string]interface{}) map[string]interface{} {
+	if result == nil {
+		return nil
+	}
+	return deepCopy(reflect.ValueOf(result)).Interface().(map[string]interface{})
+}
+
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(deepCopy(v.Elem()))
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return out
+	}
+	return v
+}
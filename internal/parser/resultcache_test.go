@@ -0,0 +1,218 @@
+package parser
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestEngine_ResultCacheSkipsRepeatedFrame(t *testing.T) {
+	e := NewEngine()
+	e.SetResultCache(time.Minute, 0)
+
+	heartbeat := []byte{0x2A}
+	first, err := e.Execute("hb", heartbeat, fastParser)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	executions := e.ResourceReport().Executions
+
+	for i := 0; i < 10; i++ {
+		res, err := e.Execute("hb", heartbeat, fastParser)
+		if err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		if res["v"] != first["v"] {
+			t.Fatalf("cached result = %v, want %v", res, first)
+		}
+	}
+
+	report := e.ResourceReport()
+	if report.Executions != executions {
+		t.Errorf("repeated frame ran the interpreter: executions %d -> %d", executions, report.Executions)
+	}
+	if report.ResultCacheHits != 10 {
+		t.Errorf("ResultCacheHits = %d, want 10", report.ResultCacheHits)
+	}
+
+	// A different payload still runs the parser
+	if res, _ := e.Execute("hb", []byte{0x07}, fastParser); res["v"] != 7 {
+		t.Errorf("expected fresh parse of new payload, got %v", res)
+	}
+
+	// Mutating a returned result must not poison the cache
+	res, _ := e.Execute("hb", heartbeat, fastParser)
+	res["v"] = -1
+	if res, _ := e.Execute("hb", heartbeat, fastParser); res["v"] != 42 {
+		t.Errorf("cache entry was mutated through a returned result: %v", res)
+	}
+}
+
+func TestEngine_ResultCacheExpires(t *testing.T) {
+	e := NewEngine()
+	e.SetResultCache(20*time.Millisecond, 0)
+
+	_, _ = e.Execute("hb", []byte{0x2A}, fastParser)
+	time.Sleep(30 * time.Millisecond)
+	executions := e.ResourceReport().Executions
+	_, _ = e.Execute("hb", []byte{0x2A}, fastParser)
+	if got := e.ResourceReport().Executions; got != executions+1 {
+		t.Errorf("expected expired entry to re-run the parser, executions %d -> %d", executions, got)
+	}
+}
+
+func TestParserManager_ResultCacheInvalidatedOnUpdate(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "omnibridge_resultcache")
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	mgr := NewParserManager(dir, "")
+	mgr.GetEngine().SetResultCache(time.Minute, 0)
+	d := NewDispatcher(mgr)
+	if err := mgr.RegisterParser("hb", fastParser); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d.Bind([]byte{0x2A}, "hb")
+
+	if res, _, err := d.Ingest([]byte{0x2A}); err != nil || res["v"] != 42 {
+		t.Fatalf("Ingest = %v, %v", res, err)
+	}
+
+	updated := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": int(data[0]) + 1} }`
+	if err := mgr.RegisterParser("hb", updated); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	if res, _, err := d.Ingest([]byte{0x2A}); err != nil || res["v"] != 43 {
+		t.Errorf("expected updated parser result, got %v, %v", res, err)
+	}
+}
+
+func TestEngine_ResultCacheCopiesNestedValues(t *testing.T) {
+	e := NewEngine()
+	e.SetResultCache(time.Minute, 0)
+	nested := `package dynamic
+func Parse(data []byte) map[string]interface{} {
+	return map[string]interface{}{"raw": []int{int(data[0])}, "meta": map[string]interface{}{"len": len(data)}}
+}`
+
+	res, err := e.Execute("nested", []byte{0x2A}, nested)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	res["raw"].([]int)[0] = -1
+	res["meta"].(map[string]interface{})["len"] = -1
+
+	cached, _ := e.Execute("nested", []byte{0x2A}, nested)
+	if cached["raw"].([]int)[0] != 42 || cached["meta"].(map[string]interface{})["len"] != 1 {
+		t.Errorf("cache entry was mutated through a nested value: %v", cached)
+	}
+	if e.ResourceReport().ResultCacheHits != 1 {
+		t.Errorf("expected the second parse to come from the cache")
+	}
+}
+
+func newResultCacheDispatcher(t testing.TB, size int) *Dispatcher {
+	dir, _ := os.MkdirTemp("", "omnibridge_resultcache")
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	mgr := NewParserManager(dir, "")
+	if err := mgr.RegisterParser("hb", fastParser); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d := NewDispatcher(mgr)
+	d.Bind([]byte{0x01}, "hb")
+	d.EnableResultCache(size)
+	return d
+}
+
+func TestDispatcher_ResultCache(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int
+		frames     [][]byte
+		executions int64 // Parser runs expected for frames
+	}{
+		{"disabled", 0, [][]byte{{0x01, 0x64}, {0x01, 0x64}, {0x01, 0x64}}, 3},
+		{"repeated frame", 8, [][]byte{{0x01, 0x64}, {0x01, 0x64}, {0x01, 0x64}}, 1},
+		{"distinct frames", 8, [][]byte{{0x01, 0x64}, {0x01, 0x65}, {0x01, 0x64}}, 2},
+		{"least recently used evicted", 2, [][]byte{{0x01, 0x01}, {0x01, 0x02}, {0x01, 0x01}, {0x01, 0x03}, {0x01, 0x01}, {0x01, 0x02}}, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newResultCacheDispatcher(t, tt.size)
+			engine := d.GetManager().GetEngine()
+			before := engine.ResourceReport().Executions
+
+			for _, frame := range tt.frames {
+				res, proto, err := d.Ingest(frame)
+				if err != nil || proto != "hb" || res["v"] != 1 {
+					t.Fatalf("Ingest(%X) = %v, %q, %v", frame, res, proto, err)
+				}
+				// Mutating a returned result must not poison the cache
+				res["v"] = -1
+			}
+			if got := engine.ResourceReport().Executions - before; got != tt.executions {
+				t.Errorf("parser ran %d times, want %d", got, tt.executions)
+			}
+		})
+	}
+}
+
+func TestDispatcher_ResultCacheInvalidatedOnUpdate(t *testing.T) {
+	d := newResultCacheDispatcher(t, 8)
+	frame := []byte{0x01, 0x64}
+	if res, _, err := d.Ingest(frame); err != nil || res["v"] != 1 {
+		t.Fatalf("Ingest = %v, %v", res, err)
+	}
+
+	updated := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": int(data[1])} }`
+	if err := d.GetManager().RegisterParser("hb", updated); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	if res, _, err := d.Ingest(frame); err != nil || res["v"] != 100 {
+		t.Errorf("expected updated parser result, got %v, %v", res, err)
+	}
+
+	// Routing still applies to cached frames
+	d.SetEnabled("hb", false)
+	if _, _, err := d.Ingest(frame); err == nil {
+		t.Error("expected a disabled protocol to reject a cached frame")
+	}
+}
+
+func BenchmarkExecute_ResultCache(b *testing.B) {
+	for _, tc := range []struct {
+		name string
+		ttl  time.Duration
+	}{{"Uncached", 0}, {"Cached", time.Minute}} {
+		b.Run(tc.name, func(b *testing.B) {
+			e := NewEngine()
+			e.SetResultCache(tc.ttl, 0)
+			heartbeat := []byte{0x2A}
+			for i := 0; i < b.N; i++ {
+				if _, err := e.Execute("hb", heartbeat, fastParser); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkIngest_ResultCache(b *testing.B) {
+	for _, tc := range []struct {
+		name string
+		size int
+	}{{"Uncached", 0}, {"Cached", 64}} {
+		b.Run(tc.name, func(b *testing.B) {
+			d := newResultCacheDispatcher(b, tc.size)
+			heartbeat := []byte{0x01, 0x64}
+			for i := 0; i < b.N; i++ {
+				if _, _, err := d.Ingest(heartbeat); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
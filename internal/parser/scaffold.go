@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// protocolNameRe restricts scaffolded protocol names to something safe as a storage directory
+var protocolNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ParserSkeleton returns a hand-authoring template for a protocol: a compilable package dynamic
+// parser with the // Signature: comment, a length guard and a Parse stub returning an empty map.
+func ParserSkeleton(name string, signature []byte) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "// Signature: %X\n", signature)
+	fmt.Fprintf(&sb, "// %s parser, scaffolded for manual authoring.\n", name)
+	sb.WriteString("package dynamic\n\n")
+	fmt.Fprintf(&sb, "// Parse decodes one %s frame; data starts with the signature bytes.\n", name)
+	sb.WriteString("// Return a flat map of fields, e.g. {\"rpm\": 1726, \"unit\": \"rpm\"}.\n")
+	sb.WriteString("func Parse(data []byte) map[string]interface{} {\n")
+	sb.WriteString("\tresult := map[string]interface{}{}\n\n")
+	sb.WriteString("\t// Length guard: check the frame is long enough before indexing into it\n")
+	fmt.Fprintf(&sb, "\tif len(data) < %d {\n", max(len(signature), 1))
+	sb.WriteString("\t\treturn result\n")
+	sb.WriteString("\t}\n\n")
+	fmt.Fprintf(&sb, "\t// TODO: decode the payload after the %d signature byte(s), e.g.\n", len(signature))
+	fmt.Fprintf(&sb, "\t// result[\"value\"] = int(data[%d])\n\n", len(signature))
+	sb.WriteString("\treturn result\n")
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// Scaffold registers a ParserSkeleton for a new protocol and returns the path of its source
+// file, ready to edit. It refuses to overwrite an existing parser.
+func (m *ParserManager) Scaffold(name string, signature []byte) (string, error) {
+	if !protocolNameRe.MatchString(name) {
+		return "", fmt.Errorf("invalid protocol name %q (letters, digits, '_', '-' and '.' only)", name)
+	}
+	if len(signature) == 0 {
+		return "", fmt.Errorf("scaffolding %s requires a signature", name)
+	}
+	if _, exists := m.GetParserCode(name); exists {
+		return "", fmt.Errorf("parser %s already exists", name)
+	}
+
+	meta := ParserMeta{Author: "scaffold", Description: "Hand-written parser scaffolded from a template"}
+	if err := m.RegisterParserWithMeta(name, ParserSkeleton(name, signature), meta); err != nil {
+		return "", err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	version, err := m.currentVersion(name)
+	if err != nil {
+		return "", err
+	}
+	return m.versionPath(name, version), nil
+}
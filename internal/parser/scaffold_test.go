@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParserManager_Scaffold(t *testing.T) {
+	dir := t.TempDir()
+	mgr := NewParserManager(dir, "")
+
+	path, err := mgr.Scaffold("OBDII", []byte{0x41})
+	if err != nil {
+		t.Fatalf("Scaffold failed: %v", err)
+	}
+	code, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("scaffold file not written: %v", err)
+	}
+	if !strings.Contains(string(code), "// Signature: 41") {
+		t.Errorf("scaffold lacks the signature comment:\n%s", code)
+	}
+
+	// The skeleton compiles as is and returns an empty map, guarded against short frames
+	for _, frame := range [][]byte{{0x41, 0x0C, 0x1A}, {}} {
+		res, err := NewEngine().Execute("OBDII", frame, string(code))
+		if err != nil || !reflect.DeepEqual(res, map[string]interface{}{}) {
+			t.Errorf("skeleton on %X = %v, %v; want an empty map", frame, res, err)
+		}
+	}
+
+	// Reloading picks up the signature so the scaffold is routed without extra binding
+	bindings, err := NewParserManager(dir, "").LoadSavedParsers()
+	if err != nil || bindings["OBDII"] != "41" {
+		t.Errorf("reloaded bindings = %v, %v", bindings, err)
+	}
+
+	if _, err := mgr.Scaffold("OBDII", []byte{0x41}); err == nil {
+		t.Error("expected scaffolding an existing parser to fail")
+	}
+	if _, err := mgr.Scaffold("../escape", []byte{0x41}); err == nil {
+		t.Error("expected an unsafe name to be rejected")
+	}
+}
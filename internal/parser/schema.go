@@ -0,0 +1,262 @@
+package parser
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrSchemaViolation is returned when a parser's output doesn't match its declared schema
+var ErrSchemaViolation = errors.New("parser output violates schema")
+
+// schemaCheckID labels the executions of parsers against their schema samples in metrics
+const schemaCheckID = "schema_check"
+
+// schemaFile holds a protocol's declared schema inside its storage directory
+const schemaFile = "schema.json"
+
+// Field types a Schema may declare; they follow the JSON form of the parse result
+var schemaTypes = map[string]bool{
+	"number": true, "integer": true, "string": true, "bool": true, "object": true, "array": true, "any": true,
+}
+
+// Schema declares the fields a curated parser must produce and the sample frames it is
+// checked against. Every declared field must be present with its type in the result of every sample.
+type Schema struct {
+	Fields  map[string]string `json:"fields"`  // Field name -> number, integer, string, bool, object, array or any
+	Samples []string          `json:"samples"` // Hex-encoded frames
+}
+
+// validate checks that the schema itself is usable
+func (s *Schema) validate() error {
+	if len(s.Fields) == 0 {
+		return fmt.Errorf("schema declares no fields")
+	}
+	if len(s.Samples) == 0 {
+		return fmt.Errorf("schema has no sample vectors")
+	}
+	for name, typ := range s.Fields {
+		if !schemaTypes[typ] {
+			return fmt.Errorf("field %q has unknown type %q", name, typ)
+		}
+	}
+	return nil
+}
+
+// Check reports the first declared field that is missing from result or has the wrong type
+func (s *Schema) Check(result map[string]interface{}) error {
+	normalized, _ := normalizeResult(result).(map[string]interface{})
+
+	names := make([]string, 0, len(s.Fields))
+	for name := range s.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		v, ok := normalized[name]
+		if !ok {
+			return fmt.Errorf("%w: missing field %q", ErrSchemaViolation, name)
+		}
+		if typ := s.Fields[name]; !matchesType(v, typ) {
+			return fmt.Errorf("%w: field %q is %T, want %s", ErrSchemaViolation, name, v, typ)
+		}
+	}
+	return nil
+}
+
+func matchesType(v interface{}, typ string) bool {
+	switch typ {
+	case "any":
+		return true
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "bool":
+		_, ok := v.(bool)
+		return ok
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	}
+	return false
+}
+
+// RegisterParserWithSchema registers code after checking it against schema, and stores the
+// schema with the parser so later registrations (repairs, rediscoveries) are held to it too.
+func (m *ParserManager) RegisterParserWithSchema(protocolID, code string, schema *Schema) error {
+	if err := schema.validate(); err != nil {
+		return fmt.Errorf("invalid schema for %s: %v", protocolID, err)
+	}
+	if err := m.checkSchema(code, schema); err != nil {
+		return fmt.Errorf("parser for %s: %w", protocolID, err)
+	}
+	if err := m.registerParser(protocolID, code); err != nil {
+		return err
+	}
+	if err := m.updateMeta(protocolID, ParserMeta{}); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.schemaPath(protocolID), data, 0o644)
+}
+
+// LoadSchema returns the schema stored with a parser, or nil when it has none
+func (m *ParserManager) LoadSchema(protocolID string) (*Schema, error) {
+	data, err := os.ReadFile(m.schemaPath(protocolID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema file for %s: %v", protocolID, err)
+	}
+	return &schema, nil
+}
+
+func (m *ParserManager) schemaPath(protocolID string) string {
+	return filepath.Join(m.protocolDir(protocolID), schemaFile)
+}
+
+// checkSchema runs candidate code on every schema sample and reports the first failure
+func (m *ParserManager) checkSchema(code string, schema *Schema) error {
+	fn, err := m.engine.compileUncached(code)
+	if err != nil {
+		return err
+	}
+	results, err := runSchemaSamples(schema, func(data []byte) (map[string]interface{}, error) {
+		return m.engine.runOnce(schemaCheckID, fn, data)
+	})
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if !r.Passed {
+			return fmt.Errorf("%s: %w", r.Case.Name, r.Err)
+		}
+	}
+	return nil
+}
+
+// runSchemaSamples runs exec on every schema sample; a result passes when it conforms to the schema
+func runSchemaSamples(schema *Schema, exec func(data []byte) (map[string]interface{}, error)) ([]TestResult, error) {
+	results := make([]TestResult, 0, len(schema.Samples))
+	for i, sample := range schema.Samples {
+		data, err := hex.DecodeString(strings.Join(strings.Fields(sample), ""))
+		if err != nil {
+			return nil, fmt.Errorf("schema sample %d: invalid hex: %v", i, err)
+		}
+
+		got, err := exec(data)
+		if err == nil {
+			err = schema.Check(got)
+		}
+		results = append(results, TestResult{
+			Case:   TestCase{Name: fmt.Sprintf("schema sample %d", i), Input: sample},
+			Got:    got,
+			Err:    err,
+			Passed: err == nil,
+		})
+	}
+	return results, nil
+}
+
+// InferSchema runs a protocol's parser over samples and records the Go type observed for each
+// result field, e.g. {"value": "float64", "unit": "string"}. A field seen with several types
+// lists them sorted and joined by "|". Samples the parser fails on are skipped; it is an error
+// if none of them parses.
+func (m *ParserManager) InferSchema(protocolID string, samples [][]byte) (map[string]string, error) {
+	if _, ok := m.GetParserCode(protocolID); !ok {
+		return nil, fmt.Errorf("no parser found for %s", protocolID)
+	}
+
+	observed := make(map[string]map[string]bool)
+	parsed := 0
+	var lastErr error
+	for _, sample := range samples {
+		result, err := m.ParseData(protocolID, sample)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		parsed++
+		for _, record := range splitResults(result) {
+			for key, v := range record {
+				if observed[key] == nil {
+					observed[key] = make(map[string]bool)
+				}
+				observed[key][fmt.Sprintf("%T", v)] = true
+			}
+		}
+	}
+	if parsed == 0 {
+		if lastErr != nil {
+			return nil, fmt.Errorf("no sample parsed with %s: %w", protocolID, lastErr)
+		}
+		return nil, fmt.Errorf("no samples to infer the schema of %s from", protocolID)
+	}
+
+	inferred := make(map[string]string, len(observed))
+	for key, types := range observed {
+		names := make([]string, 0, len(types))
+		for name := range types {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		inferred[key] = strings.Join(names, "|")
+	}
+	return inferred, nil
+}
+
+// StoredSamples returns the frames recorded for a protocol: its golden case inputs followed by
+// its schema samples
+func (m *ParserManager) StoredSamples(protocolID string) ([][]byte, error) {
+	var inputs []string
+	cases, err := m.LoadGoldenCases(protocolID)
+	if err != nil {
+		return nil, err
+	}
+	for _, tc := range cases {
+		inputs = append(inputs, tc.Input)
+	}
+	schema, err := m.LoadSchema(protocolID)
+	if err != nil {
+		return nil, err
+	}
+	if schema != nil {
+		inputs = append(inputs, schema.Samples...)
+	}
+
+	samples := make([][]byte, 0, len(inputs))
+	for _, input := range inputs {
+		data, err := hex.DecodeString(strings.Join(strings.Fields(input), ""))
+		if err != nil {
+			return nil, fmt.Errorf("stored sample %q of %s: invalid hex: %v", input, protocolID, err)
+		}
+		samples = append(samples, data)
+	}
+	return samples, nil
+}
@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParserManager_RegisterParserWithSchema(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "schema_test")
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	mgr := NewParserManager(tmpDir, "")
+
+	schema := &Schema{
+		Fields:  map[string]string{"rpm": "integer", "unit": "string"},
+		Samples: []string{"410C1AF8", "410C0000"},
+	}
+	good := `package dynamic
+func Parse(data []byte) map[string]interface{} {
+	return map[string]interface{}{"rpm": (int(data[2])*256 + int(data[3])) / 4, "unit": "rpm"}
+}`
+	missingField := `package dynamic
+func Parse(data []byte) map[string]interface{} {
+	return map[string]interface{}{"rpm": (int(data[2])*256 + int(data[3])) / 4}
+}`
+	wrongType := `package dynamic
+func Parse(data []byte) map[string]interface{} {
+	return map[string]interface{}{"rpm": float64(int(data[2])*256 + int(data[3])) / 4.1, "unit": "rpm"}
+}`
+
+	// A parser missing a declared field is rejected and nothing is stored
+	err := mgr.RegisterParserWithSchema("rpm", missingField, schema)
+	if !errors.Is(err, ErrSchemaViolation) {
+		t.Fatalf("expected schema violation, got %v", err)
+	}
+	if _, ok := mgr.GetParserCode("rpm"); ok {
+		t.Error("rejected parser was registered")
+	}
+	if s, _ := mgr.LoadSchema("rpm"); s != nil {
+		t.Error("schema stored for a rejected parser")
+	}
+
+	if err := mgr.RegisterParserWithSchema("rpm", good, schema); err != nil {
+		t.Fatalf("RegisterParserWithSchema failed: %v", err)
+	}
+	stored, err := mgr.LoadSchema("rpm")
+	if err != nil || !reflect.DeepEqual(stored, schema) {
+		t.Errorf("LoadSchema = %+v, %v", stored, err)
+	}
+
+	// Later registrations, such as repairs, are held to the stored schema
+	for name, code := range map[string]string{"missing field": missingField, "wrong type": wrongType} {
+		if err := mgr.RegisterParser("rpm", code); !errors.Is(err, ErrSchemaViolation) {
+			t.Errorf("%s: expected schema violation, got %v", name, err)
+		}
+	}
+	if versions, _ := mgr.ListVersions("rpm"); !reflect.DeepEqual(versions, []int{1}) {
+		t.Errorf("rejected repairs created versions: %v", versions)
+	}
+	if err := mgr.RegisterParser("rpm", good+"\n// v2"); err != nil {
+		t.Errorf("conforming repair rejected: %v", err)
+	}
+
+	if err := mgr.RegisterParserWithSchema("bad", good, &Schema{Fields: map[string]string{"rpm": "float"}, Samples: []string{"41"}}); err == nil {
+		t.Error("expected an unknown field type to be rejected")
+	}
+}
+
+func TestParserManager_InferSchema_OBDII(t *testing.T) {
+	mgr, _ := newSeededManager(t)
+
+	// Engine speed, vehicle speed and coolant temperature
+	samples := [][]byte{{0x41, 0x0C, 0x1A, 0xF8}, {0x41, 0x0D, 0x3C}, {0x41, 0x05, 0x7B}}
+	schema, err := mgr.InferSchema("OBDII_Service01", samples)
+	if err != nil {
+		t.Fatalf("InferSchema failed: %v", err)
+	}
+	want := map[string]string{"pid": "string", "name": "string", "value": "float64", "unit": "string"}
+	if !reflect.DeepEqual(schema, want) {
+		t.Errorf("InferSchema = %v, want %v", schema, want)
+	}
+
+	// Engine load reports an int value, so value is seen with two types
+	schema, err = mgr.InferSchema("OBDII_Service01", append(samples, []byte{0x41, 0x04, 0x80}))
+	if err != nil {
+		t.Fatalf("InferSchema failed: %v", err)
+	}
+	if schema["value"] != "float64|int" || schema["unit"] != "string" {
+		t.Errorf("Expected value float64|int and unit string, got %v", schema)
+	}
+
+	if _, err := mgr.InferSchema("OBDII_Service01", nil); err == nil {
+		t.Error("Expected an error without samples")
+	}
+	if _, err := mgr.InferSchema("missing", samples); err == nil {
+		t.Error("Expected an error for an unknown protocol")
+	}
+}
@@ -1,28 +1,95 @@
 package parser
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt" // Keep fmt as it's used
 	"io"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/chuanjin/OmniBridge/internal/logger"
 	"go.uber.org/zap"
 )
 
+// ErrServerClosed is returned by Serve and ListenAndServe after Shutdown
+var ErrServerClosed = errors.New("tcp server closed")
+
+// tcpContextHint is the protocol hint given to discovery for frames from TCP clients
+const tcpContextHint = "Remote incoming binary data stream."
+
+const (
+	DefaultIdleTimeout    = 60 * time.Second // Connections silent for longer are closed
+	DefaultMaxConnections = 1024             // Concurrent connections before new ones are rejected
+)
+
 // TCPServer listens for incoming binary data streams
 type TCPServer struct {
 	addr       string
 	dispatcher *Dispatcher
 	discovery  *DiscoveryService
+
+	idleTimeout    time.Duration
+	readBufferSize int
+	maxFrameSize   int
+	framing        FramingMode
+	connSlots      chan struct{} // Semaphore bounding concurrent connections; nil means unlimited
+	graceWindow    time.Duration // Buffering of a new connection's unknown frames; 0 disables it
+	graceFrames    int
+	pinProtocol    bool // Parse a connection's frames with the protocol of its first one
+
+	authToken        []byte      // Shared secret clients send before their first frame; empty disables it
+	tlsConfig        *tls.Config // nil serves plain TCP
+	handshakeTimeout time.Duration
+
+	// Shutdown state
+	ctx       context.Context // Parent of every connection's context
+	cancel    context.CancelFunc
+	listener  net.Listener
+	conns     map[net.Conn]struct{}
+	wg        sync.WaitGroup
+	done      chan struct{}
+	closeOnce sync.Once
+	mu        sync.Mutex
 }
 
 func NewTCPServer(addr string, d *Dispatcher, disc *DiscoveryService) *TCPServer {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &TCPServer{
-		addr:       addr,
-		dispatcher: d,
-		discovery:  disc,
+		ctx:              ctx,
+		cancel:           cancel,
+		addr:             addr,
+		dispatcher:       d,
+		discovery:        disc,
+		idleTimeout:      DefaultIdleTimeout,
+		readBufferSize:   DefaultReadBufferSize,
+		maxFrameSize:     DefaultMaxFrameSize,
+		framing:          FramingRead,
+		handshakeTimeout: DefaultHandshakeTimeout,
+		connSlots:        make(chan struct{}, DefaultMaxConnections),
+		conns:            make(map[net.Conn]struct{}),
+		done:             make(chan struct{}),
+	}
+}
+
+// SetIdleTimeout changes how long a connection may go without sending data before it is closed.
+// A non-positive value restores DefaultIdleTimeout. Call before Serve.
+func (s *TCPServer) SetIdleTimeout(d time.Duration) {
+	if d <= 0 {
+		d = DefaultIdleTimeout
 	}
+	s.idleTimeout = d
+}
+
+// SetMaxConnections limits concurrent connections; 0 removes the limit. Call before Serve.
+func (s *TCPServer) SetMaxConnections(n int) {
+	if n <= 0 {
+		s.connSlots = nil
+		return
+	}
+	s.connSlots = make(chan struct{}, n)
 }
 
 func (s *TCPServer) ListenAndServe() error {
@@ -30,106 +97,268 @@ func (s *TCPServer) ListenAndServe() error {
 	if err != nil {
 		return fmt.Errorf("failed to listen on %s: %v", s.addr, err)
 	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on listener until Shutdown is called
+func (s *TCPServer) Serve(listener net.Listener) error {
+	if s.tlsConfig != nil {
+		listener = tls.NewListener(listener, s.tlsConfig)
+	}
+	// Shutdown reads the listener under the same lock, so it either sees and closes
+	// it or has already run, in which case Serve must not start accepting
+	s.mu.Lock()
+	if s.shuttingDown() {
+		s.mu.Unlock()
+		_ = listener.Close()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.mu.Unlock()
+
 	defer func() {
-		if err := listener.Close(); err != nil {
+		if err := listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
 			logger.Error("Failed to close listener", zap.Error(err))
 		}
 	}()
 
-	logger.Info("TCP Server listening", zap.String("address", s.addr))
+	logger.Info("TCP Server listening", zap.String("address", listener.Addr().String()))
 
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
+			if s.shuttingDown() {
+				return ErrServerClosed
+			}
 			logger.Error("Accept error", zap.Error(err))
 			continue
 		}
+		if !s.acquireSlot() {
+			logger.Warn("Connection limit reached, rejecting connection",
+				zap.String("remote_addr", conn.RemoteAddr().String()), zap.Int("max_connections", cap(s.connSlots)))
+			_ = conn.Close()
+			continue
+		}
+		if !s.trackConn(conn) {
+			s.releaseSlot()
+			_ = conn.Close()
+			return ErrServerClosed
+		}
 		go s.handleConnection(conn)
 	}
 }
 
+// Shutdown stops accepting new connections, asks active connections to finish their
+// current frame, and waits for them until ctx expires. Remaining connections are then
+// closed forcibly and ctx.Err() is returned.
+func (s *TCPServer) Shutdown(ctx context.Context) error {
+	s.closeOnce.Do(func() { close(s.done) })
+
+	s.mu.Lock()
+	if s.listener != nil {
+		if err := s.listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			logger.Error("Failed to close listener", zap.Error(err))
+		}
+	}
+	// Unblock idle reads so handlers notice the shutdown
+	for conn := range s.conns {
+		_ = conn.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		logger.Info("TCP Server shut down gracefully")
+		return nil
+	case <-ctx.Done():
+		// Abort repairs and discoveries still holding connections open
+		s.cancel()
+		s.mu.Lock()
+		for conn := range s.conns {
+			_ = conn.Close()
+		}
+		s.mu.Unlock()
+		logger.Warn("TCP Server shutdown deadline exceeded, closed remaining connections")
+		return ctx.Err()
+	}
+}
+
+func (s *TCPServer) shuttingDown() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// trackConn registers an active connection; it returns false once shutdown has begun
+func (s *TCPServer) trackConn(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shuttingDown() {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *TCPServer) untrackConn(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	s.releaseSlot()
+	s.wg.Done()
+}
+
+// acquireSlot reserves room for a connection without blocking; false means the server is saturated
+func (s *TCPServer) acquireSlot() bool {
+	if s.connSlots == nil {
+		return true
+	}
+	select {
+	case s.connSlots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *TCPServer) releaseSlot() {
+	if s.connSlots != nil {
+		<-s.connSlots
+	}
+}
+
+// respond runs a frame through the pipeline and writes the outcome to the client, returning
+// the protocol that parsed it, if any. Without discover, unknown frames are answered with an
+// error instead of learned. ctx is the connection's context; it bounds any repair and the wait
+// for a discovery.
+func (s *TCPServer) respond(ctx context.Context, conn net.Conn, raw []byte, discover bool) (string, bool) {
+	var result map[string]interface{}
+	var proto string
+	var err error
+	if discover {
+		result, proto, err = processFrame(ctx, s.dispatcher, s.discovery, raw, tcpContextHint)
+		if errors.Is(err, errDiscoveryFailed) {
+			return "", false
+		}
+	} else {
+		result, proto, err = s.dispatcher.Ingest(raw)
+	}
+
+	reply(conn, proto, result, err)
+	return proto, err == nil && proto != FallbackProtocol
+}
+
+// reply writes the outcome of parsing one frame to the client
+func reply(conn net.Conn, proto string, result map[string]interface{}, err error) {
+	if err == nil {
+		logger.Info("Success", zap.String("protocol", proto), zap.Any("data", result))
+		// Optionally send result back to client or log it
+		_, _ = fmt.Fprintf(conn, "Parsed (%s): %v\n", proto, result)
+	} else {
+		_, _ = fmt.Fprintf(conn, "Error: %v\n", err)
+	}
+}
+
 func (s *TCPServer) handleConnection(conn net.Conn) {
+	defer s.untrackConn(conn)
 	defer func() {
-		if err := conn.Close(); err != nil {
+		if err := conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
 			logger.Error("Failed to close connection", zap.Error(err))
 		}
 	}()
 	logger.Info("New connection", zap.String("remote_addr", conn.RemoteAddr().String()))
+	// Cancelled by a forced shutdown. A client hanging up is only noticed at the next read,
+	// so it doesn't cut short a repair or discovery already running for its frame.
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
 
-	buffer := make([]byte, 1024)
+	if err := s.authenticate(ctx, conn); err != nil {
+		logger.Warn("Rejecting unauthenticated connection", zap.String("remote_addr", conn.RemoteAddr().String()), zap.Error(err))
+		_, _ = fmt.Fprintf(conn, "Error: %v\n", errAuthFailed)
+		return
+	}
+	if len(s.authToken) > 0 {
+		_, _ = fmt.Fprintln(conn, "Authenticated")
+	}
+
+	var grace *graceBuffer
+	if s.graceWindow > 0 {
+		grace = &graceBuffer{max: s.graceFrames}
+	}
+	var pinned *connPin
+
+	buffer := make([]byte, s.readBufferSize)
 	for {
-		n, err := conn.Read(buffer)
+		// Set the idle deadline before checking for shutdown so it can't override Shutdown's own deadline
+		deadline := time.Now().Add(s.idleTimeout)
+		if grace.pending() && grace.deadline.Before(deadline) {
+			deadline = grace.deadline
+		}
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			logger.Error("Failed to set read deadline", zap.Error(err))
+			break
+		}
+		if s.shuttingDown() {
+			break
+		}
+		raw, err := s.readFrame(conn, buffer)
 		if err != nil {
-			if err != io.EOF {
+			var netErr net.Error
+			if errors.Is(err, ErrFrameTooLarge) {
+				logger.Warn("Closing connection after an oversized frame", zap.String("remote_addr", conn.RemoteAddr().String()), zap.Int("max_frame_size", s.maxFrameSize))
+				_, _ = fmt.Fprintf(conn, "Error: %v\n", err)
+			} else if s.shuttingDown() {
+				logger.Info("Closing connection for shutdown", zap.String("remote_addr", conn.RemoteAddr().String()))
+			} else if errors.As(err, &netErr) && netErr.Timeout() {
+				if grace.pending() && !time.Now().Before(grace.deadline) {
+					s.flushGrace(ctx, conn, grace.frames)
+					grace = nil
+					continue
+				}
+				logger.Info("Closing idle connection", zap.String("remote_addr", conn.RemoteAddr().String()), zap.Duration("idle_timeout", s.idleTimeout))
+			} else if err != io.EOF {
 				logger.Error("Read error", zap.Error(err))
 			}
 			break
 		}
 
-		raw := buffer[:n]
 		logger.Debug("Received raw data", zap.String("hex", fmt.Sprintf("0x%X", raw)), zap.String("remote_addr", conn.RemoteAddr().String()))
 
-		// Attempt to parse using cached/known logic
-		result, proto, err := s.dispatcher.Ingest(raw)
-
-		// 1. SELF-HEALING: If ingest fails for a KNOWN protocol (e.g., compile error), try to repair it
-		if err != nil && proto != "" {
-			logger.Warn("Detected error in protocol", zap.String("protocol", proto), zap.Error(err))
-			logger.Info("Attempting repair...")
-
-			faultyCode, exists := s.dispatcher.GetManager().GetParserCode(proto)
-			if exists {
-				_, repairErr := s.discovery.RepairParser(proto, faultyCode, err.Error(), raw, nil)
-				if repairErr != nil {
-					logger.Error("Repair failed", zap.Error(repairErr))
-				} else {
-					// Re-attempt ingestion after repair
-					result, proto, err = s.dispatcher.Ingest(raw)
-					if err == nil {
-						logger.Info("Protocol repaired successfully", zap.String("protocol", proto))
-					}
-				}
-			}
-		}
-
-		// 2. DISCOVERY: If protocol is entirely unknown
-		if err != nil && proto == "" {
-			// Extract a tentative signature (e.g. first byte) to key the discovery process
-			sig := []byte{raw[0]}
-			sigHex := fmt.Sprintf("0x%X", sig)
-
-			// Attempt to run discovery synchronously for this connection
-			// This blocks this specific client but ensures the first packet is not dropped.
-			if s.discovery.IsDiscovering(sig) {
-				logger.Info("Discovery already in progress, waiting...", zap.String("signature", sigHex))
-				// In a real implementation, we might want a condition variable or a loop here.
-				// For now, we'll just wait a bit and retry ingest, or drop if it takes too long.
-				time.Sleep(2 * time.Second)
-			} else {
-				logger.Info("Unknown signature, starting BLOCKING AI discovery", zap.String("signature", sigHex))
-				context := "Remote incoming binary data stream."
-				newName, discErr := s.discovery.DiscoverNewProtocol(raw, sig, context)
-				if discErr != nil {
-					logger.Error("Discovery failed", zap.String("signature", sigHex), zap.Error(discErr))
+		if pinned != nil {
+			if pinned.fits(len(raw)) {
+				result, err := processPinned(ctx, s.dispatcher, s.discovery, pinned.protocol, raw)
+				if !errors.Is(err, ErrPinMismatch) && !errors.Is(err, ErrProtocolDisabled) {
+					reply(conn, pinned.protocol, result, err)
 					continue
 				}
-				logger.Info("Discovery Success: New Protocol Learned", zap.String("protocol", newName))
 			}
+			logger.Info("Frame does not match the pinned protocol, routing it again",
+				zap.String("protocol", pinned.protocol), zap.Int("bytes", len(raw)), zap.String("remote_addr", conn.RemoteAddr().String()))
+			pinned = nil
+		}
 
-			// Re-attempt ingestion after discovery
-			result, proto, err = s.dispatcher.Ingest(raw)
-			if err != nil {
-				// If it still fails, then we really can't handle it
-				logger.Error("Still unable to parse after discovery", zap.Error(err))
+		// Hold back unknown frames at the start of a connection instead of discovering on a handshake byte
+		if grace != nil && !s.dispatcher.known(raw) {
+			if grace.add(append([]byte(nil), raw...), time.Now().Add(s.graceWindow)) {
+				s.flushGrace(ctx, conn, grace.frames)
+				grace = nil
 			}
+			continue
 		}
 
-		if err == nil {
-			logger.Info("Success", zap.String("protocol", proto), zap.Any("data", result))
-			// Optionally send result back to client or log it
-			_, _ = fmt.Fprintf(conn, "Parsed (%s): %v\n", proto, result)
-		} else {
-			_, _ = fmt.Fprintf(conn, "Error: %v\n", err)
+		if proto, ok := s.respond(ctx, conn, raw, true); ok && s.pinProtocol {
+			pinned = s.pin(proto)
+			logger.Debug("Connection pinned to protocol", zap.String("protocol", proto), zap.String("remote_addr", conn.RemoteAddr().String()))
 		}
 	}
 	logger.Info("Connection closed", zap.String("remote_addr", conn.RemoteAddr().String()))
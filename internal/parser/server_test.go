@@ -0,0 +1,751 @@
+package parser
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestTCPServer starts a TCPServer on a loopback listener with a single bound parser
+func newTestTCPServer(t *testing.T, configure ...func(*TCPServer)) (*TCPServer, string, chan error) {
+	t.Helper()
+
+	tmpDir, _ := os.MkdirTemp("", "server_test")
+	t.Cleanup(func() { _ = os.RemoveAll(tmpDir) })
+
+	mgr := NewParserManager(tmpDir, "")
+	d := NewDispatcher(mgr)
+	code := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"val": int(data[1])} }`
+	if err := mgr.RegisterParser("test_proto", code); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d.Bind([]byte{0x01}, "test_proto")
+	// Pre-compile so the first frame isn't charged for compilation
+	if err := mgr.GetEngine().CompileAndCache("test_proto", code); err != nil {
+		t.Fatalf("CompileAndCache failed: %v", err)
+	}
+
+	srv := NewTCPServer("127.0.0.1:0", d, NewDiscoveryService(d, mgr, DiscoveryConfig{Provider: "ollama", SystemPromptPath: testSystemPrompt(t)}))
+	for _, fn := range configure {
+		fn(srv)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(listener) }()
+	return srv, listener.Addr().String(), serveErr
+}
+
+func TestTCPServer_GracefulShutdown(t *testing.T) {
+	srv, addr, serveErr := newTestTCPServer(t)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	reader := bufio.NewReader(conn)
+	if _, err := conn.Write([]byte{0x01, 0x2A}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !strings.HasPrefix(line, "Parsed (test_proto)") {
+		t.Errorf("Unexpected response: %q", line)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	select {
+	case err := <-serveErr:
+		if err != ErrServerClosed {
+			t.Errorf("Expected ErrServerClosed, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve did not return after Shutdown")
+	}
+
+	// The idle connection was closed by the server
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := reader.ReadByte(); err == nil {
+		t.Error("Expected connection to be closed after shutdown")
+	}
+
+	// New connections are refused
+	if c, err := net.DialTimeout("tcp", addr, 200*time.Millisecond); err == nil {
+		_ = c.Close()
+		t.Error("Expected dial to fail after shutdown")
+	}
+}
+
+func TestTCPServer_ShutdownDeadline(t *testing.T) {
+	// An LLM that never answers keeps the connection busy in discovery
+	requested := make(chan struct{}, 1)
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The request context only notices a client hang-up once the body is consumed
+		_, _ = io.Copy(io.Discard, r.Body)
+		select {
+		case requested <- struct{}{}:
+		default:
+		}
+		<-r.Context().Done()
+	}))
+	defer llm.Close()
+	srv, addr, _ := newTestTCPServer(t, func(s *TCPServer) {
+		s.discovery.Config.Endpoint = llm.URL
+		s.discovery.Config.Timeout = 500 * time.Millisecond // Discovery outlives the connection until then
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err := conn.Write([]byte{0x7E, 0x01}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	select {
+	case <-requested:
+	case <-time.After(2 * time.Second):
+		t.Fatal("discovery never reached the LLM")
+	}
+
+	// The busy connection outlives the deadline: it is closed and the deadline reported
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := srv.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := bufio.NewReader(conn).ReadString('\n'); err == nil {
+		t.Error("Expected the busy connection to be closed")
+	}
+}
+
+func TestTCPServer_ShutdownBeforeServe(t *testing.T) {
+	mgr := NewParserManager(t.TempDir(), "")
+	d := NewDispatcher(mgr)
+	srv := NewTCPServer("127.0.0.1:0", d, NewDiscoveryService(d, mgr, DiscoveryConfig{}))
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(listener) }()
+	select {
+	case err := <-serveErr:
+		if err != ErrServerClosed {
+			t.Errorf("Expected ErrServerClosed, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve accepted connections after Shutdown")
+	}
+}
+
+func TestTCPServer_IdleTimeout(t *testing.T) {
+	_, addr, _ := newTestTCPServer(t, func(s *TCPServer) { s.SetIdleTimeout(100 * time.Millisecond) })
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	// Stay silent; the server should hang up once the idle timeout passes
+	start := time.Now()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("Expected the idle connection to be closed")
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Fatal("Server did not close the idle connection")
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Connection closed before the idle timeout (%v)", elapsed)
+	}
+}
+
+func TestTCPServer_MaxConnections(t *testing.T) {
+	_, addr, _ := newTestTCPServer(t, func(s *TCPServer) { s.SetMaxConnections(1) })
+
+	first, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	// Make sure the first connection holds the only slot
+	if _, err := first.Write([]byte{0x01, 0x2A}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := bufio.NewReader(first).ReadString('\n'); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	second, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = second.Close() }()
+	_ = second.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := second.Read(make([]byte, 1)); err == nil {
+		t.Error("Expected the connection over the limit to be rejected")
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Error("Connection over the limit was not closed")
+	}
+
+	// Freeing the slot lets new connections in again
+	_ = first.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		third, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		_, _ = third.Write([]byte{0x01, 0x07})
+		_ = third.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+		line, err := bufio.NewReader(third).ReadString('\n')
+		_ = third.Close()
+		if err == nil && strings.HasPrefix(line, "Parsed (test_proto)") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Slot was not released after the first connection closed")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestTCPServer_DiscoveryGraceSkipsJunkFrame(t *testing.T) {
+	var mu sync.Mutex
+	var prompts []string
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		prompts = append(prompts, req.Prompt)
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: `// Signature: 55AA
+package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": int(data[2])} }`})
+	}))
+	defer llm.Close()
+
+	_, addr, _ := newTestTCPServer(t, func(s *TCPServer) {
+		s.discovery.Config.Endpoint = llm.URL
+		s.SetDiscoveryGrace(200*time.Millisecond, 3)
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	// A handshake byte, then the real frame; the grace period ends before the buffer fills
+	if _, err := conn.Write([]byte{0xFF}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := conn.Write([]byte{0x55, 0xAA, 0x07}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	junkReply, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	realReply, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !strings.HasPrefix(junkReply, "Error: unknown protocol signature: 0xFF") {
+		t.Errorf("Unexpected reply to the junk frame: %q", junkReply)
+	}
+	if !strings.HasPrefix(realReply, "Parsed (auto_proto_0x55AA)") {
+		t.Errorf("Unexpected reply to the real frame: %q", realReply)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(prompts) != 1 || !strings.Contains(prompts[0], "Hex Sample: 55AA07") {
+		t.Errorf("expected one discovery keyed on the real frame, got %q", prompts)
+	}
+}
+
+func TestTCPServer_PinProtocol(t *testing.T) {
+	_, addr, _ := newTestTCPServer(t, func(s *TCPServer) {
+		s.SetPinProtocol(true)
+		mgr := s.dispatcher.GetManager()
+		other := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"other": len(data)} }`
+		for _, id := range []string{"other_proto", "len_proto"} {
+			if err := mgr.RegisterParser(id, other); err != nil {
+				t.Fatalf("RegisterParser failed: %v", err)
+			}
+		}
+		s.dispatcher.Bind([]byte{0x02}, "other_proto")
+		s.dispatcher.BindWithLength([]byte{0x03}, 3, "len_proto")
+		// test_proto frames are two bytes long
+		s.dispatcher.BindWithValidator([]byte{0x01}, "test_proto", func(frame []byte) error {
+			if len(frame) != 2 {
+				return fmt.Errorf("want 2 bytes, got %d", len(frame))
+			}
+			return nil
+		})
+	})
+
+	type step struct {
+		name  string
+		frame []byte
+		want  string
+	}
+	// Each sequence runs on its own connection
+	sequences := map[string][]step{
+		"validator": {
+			{"first frame carries the signature", []byte{0x01, 0x2A}, "Parsed (test_proto): map[val:42]"},
+			{"payload without signature stays pinned", []byte{0x7F, 0x05}, "Parsed (test_proto): map[val:5]"},
+			{"another bound signature is not looked up", []byte{0x02, 0x07}, "Parsed (test_proto): map[val:7]"},
+			{"validator rejects, re-detected", []byte{0x02, 0x07, 0x08}, "Parsed (other_proto): map[other:3]"},
+			{"pinned to the re-detected protocol", []byte{0x01, 0x01, 0x01, 0x01}, "Parsed (other_proto): map[other:4]"},
+		},
+		"length": {
+			{"length-bound protocol", []byte{0x03, 0x00, 0x00}, "Parsed (len_proto): map[other:3]"},
+			{"frame without its signature fits", []byte{0x10, 0x11}, "Parsed (len_proto): map[other:2]"},
+			{"no bound length fits, re-detected", []byte{0x01, 0x09, 0x00, 0x00, 0x00}, "Error: test_proto: checksum mismatch: want 2 bytes, got 5"},
+			{"a failed frame doesn't pin", []byte{0x01, 0x0B}, "Parsed (test_proto): map[val:11]"},
+		},
+	}
+	for name, steps := range sequences {
+		t.Run(name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatalf("Dial failed: %v", err)
+			}
+			defer func() { _ = conn.Close() }()
+			reader := bufio.NewReader(conn)
+
+			for _, step := range steps {
+				_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+				if _, err := conn.Write(step.frame); err != nil {
+					t.Fatalf("%s: Write failed: %v", step.name, err)
+				}
+				line, err := reader.ReadString('\n')
+				if err != nil || strings.TrimSpace(line) != step.want {
+					t.Fatalf("%s: got %q, %v; want %q", step.name, line, err, step.want)
+				}
+				// Keep frames from coalescing into one read
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestTCPServer_PinnedFramesUsePrefixFamily(t *testing.T) {
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer llm.Close()
+
+	_, addr, _ := newTestTCPServer(t, func(s *TCPServer) {
+		s.SetPinProtocol(true)
+		s.discovery.Config.Endpoint = llm.URL
+		// test_proto gets its family from the prefix it is bound under
+		s.dispatcher.BindFamily([]byte{0x01}, "sensors")
+		s.dispatcher.SetResultValidator("sensors", func(_ []byte, result map[string]interface{}) error {
+			if v, _ := result["val"].(int); v > 100 {
+				return fmt.Errorf("val %d above 100", v)
+			}
+			return nil
+		})
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+
+	for _, step := range []struct {
+		frame []byte
+		want  string
+	}{
+		{[]byte{0x01, 0x2A}, "Parsed (test_proto): map[val:42]"},
+		{[]byte{0x7F, 0x05}, "Parsed (test_proto): map[val:5]"},
+		{[]byte{0x7F, 0xC8}, "Error: test_proto: result out of range: val 200 above 100"},
+	} {
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err := conn.Write(step.frame); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil || strings.TrimSpace(line) != step.want {
+			t.Fatalf("frame %X: got %q, %v; want %q", step.frame, line, err, step.want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTCPServer_PinnedFramesShareThePipeline(t *testing.T) {
+	var repairs atomic.Int32
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		repairs.Add(1)
+		_ = json.NewEncoder(w).Encode(OllamaResponse{Response: `// Signature: 01
+package dynamic
+func Parse(data []byte) map[string]interface{} {
+	if len(data) < 2 {
+		return map[string]interface{}{"short": true}
+	}
+	return map[string]interface{}{"val": int(data[1])}
+}`})
+	}))
+	defer llm.Close()
+
+	srv, addr, _ := newTestTCPServer(t, func(s *TCPServer) {
+		s.SetPinProtocol(true)
+		s.discovery.Config.Endpoint = llm.URL
+		if err := s.dispatcher.GetManager().RegisterParser("other_proto", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"other": len(data)} }`); err != nil {
+			t.Fatalf("RegisterParser failed: %v", err)
+		}
+		s.dispatcher.Bind([]byte{0x02}, "other_proto")
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	send := func(frame []byte, want string) {
+		t.Helper()
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err := conn.Write(frame); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil || strings.TrimSpace(line) != want {
+			t.Fatalf("frame %X: got %q, %v; want %q", frame, line, err, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	send([]byte{0x01, 0x2A}, "Parsed (test_proto): map[val:42]")
+	// The pinned parser panics on a short frame and is repaired like a routed one
+	send([]byte{0x7F}, "Parsed (test_proto): map[short:true]")
+	if n := repairs.Load(); n != 1 {
+		t.Errorf("expected 1 repair of the pinned protocol, got %d", n)
+	}
+
+	// Once the pinned protocol is disabled, frames are routed again
+	srv.dispatcher.SetEnabled("test_proto", false)
+	send([]byte{0x02, 0x07}, "Parsed (other_proto): map[other:2]")
+}
+
+func TestTCPServer_OutputSinks(t *testing.T) {
+	capture := &captureSink{}
+	_, addr, _ := newTestTCPServer(t, func(s *TCPServer) {
+		router := NewOutputRouter()
+		router.AddSink(SinkFunc(func(Output) error { return errors.New("webhook down") }), OutcomeSuccess)
+		router.AddSink(SinkFunc(func(Output) error { panic("sink bug") }), OutcomeSuccess)
+		router.AddSink(capture, OutcomeSuccess)
+		s.dispatcher.SetOutputRouter(router)
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+
+	// Failing sinks don't affect the reply or the sinks after them
+	for _, v := range []byte{0x2A, 0x2B} {
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if _, err := conn.Write([]byte{0x01, v}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		line, err := reader.ReadString('\n')
+		if want := fmt.Sprintf("Parsed (test_proto): map[val:%d]", v); err != nil || strings.TrimSpace(line) != want {
+			t.Fatalf("got %q, %v; want %q", line, err, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+	if len(capture.outputs) != 2 || capture.outputs[1].Protocol != "test_proto" || capture.outputs[1].Result["val"] != 0x2B {
+		t.Errorf("expected both parses delivered to the sink, got %+v", capture.outputs)
+	}
+}
+
+func TestTCPServer_LargeFrames(t *testing.T) {
+	_, addr, _ := newTestTCPServer(t, func(s *TCPServer) {
+		s.SetMaxFrameSize(4096)
+		code := `package dynamic
+func Parse(data []byte) map[string]interface{} {
+	sum := 0
+	for _, b := range data {
+		sum += int(b)
+	}
+	return map[string]interface{}{"len": len(data), "sum": sum}
+}`
+		if err := s.dispatcher.GetManager().RegisterParser("bulk_proto", code); err != nil {
+			t.Fatalf("RegisterParser failed: %v", err)
+		}
+		s.dispatcher.Bind([]byte{0x02}, "bulk_proto")
+	})
+
+	frame := func(size int) []byte {
+		f := make([]byte, size)
+		f[0] = 0x02
+		for i := 1; i < size; i++ {
+			f[i] = byte(i)
+		}
+		return f
+	}
+	sum := func(f []byte) int {
+		total := 0
+		for _, b := range f {
+			total += int(b)
+		}
+		return total
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+
+	// Frames filling the read buffer exactly or spanning several reads arrive whole
+	for _, size := range []int{2048, DefaultReadBufferSize, 3000} {
+		f := frame(size)
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if _, err := conn.Write(f); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		line, err := reader.ReadString('\n')
+		if want := fmt.Sprintf("Parsed (bulk_proto): map[len:%d sum:%d]", size, sum(f)); err != nil || strings.TrimSpace(line) != want {
+			t.Fatalf("got %q, %v; want %q", line, err, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A frame over the limit is refused and the connection closed
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Write(frame(5000)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil || !strings.Contains(line, ErrFrameTooLarge.Error()) {
+		t.Fatalf("got %q, %v; want a frame size error", line, err)
+	}
+	if _, err := reader.ReadByte(); err == nil {
+		t.Error("expected the connection to be closed after an oversized frame")
+	}
+}
+
+func TestTCPServer_LengthPrefixedFrames(t *testing.T) {
+	_, addr, _ := newTestTCPServer(t, func(s *TCPServer) {
+		s.SetFraming(FramingLengthPrefix)
+		s.SetMaxFrameSize(4096)
+		if err := s.dispatcher.GetManager().RegisterParser("bulk_proto", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"len": len(data), "last": int(data[len(data)-1])} }`); err != nil {
+			t.Fatalf("RegisterParser failed: %v", err)
+		}
+		s.dispatcher.Bind([]byte{0x02}, "bulk_proto")
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+
+	// A 2 KB frame split mid-way, as a network may segment it, still arrives whole
+	frame := make([]byte, 2048)
+	frame[0], frame[len(frame)-1] = 0x02, 0x7F
+	prefixed := append(binary.BigEndian.AppendUint16(nil, uint16(len(frame))), frame...)
+	for _, part := range [][]byte{prefixed[:700], prefixed[700:]} {
+		if _, err := conn.Write(part); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if line, err := reader.ReadString('\n'); err != nil || strings.TrimSpace(line) != "Parsed (bulk_proto): map[last:127 len:2048]" {
+		t.Fatalf("got %q, %v; want the whole frame parsed", line, err)
+	}
+
+	// A declared length over the limit is refused without reading the frame
+	if _, err := conn.Write([]byte{0x13, 0x88}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if line, err := reader.ReadString('\n'); err != nil || !strings.Contains(line, ErrFrameTooLarge.Error()) {
+		t.Fatalf("got %q, %v; want a frame size error", line, err)
+	}
+}
+
+func TestTCPServer_AuthToken(t *testing.T) {
+	_, addr, _ := newTestTCPServer(t, func(s *TCPServer) {
+		s.SetAuthToken("s3cret")
+		s.handshakeTimeout = 200 * time.Millisecond
+	})
+
+	tests := []struct {
+		name      string
+		handshake []byte
+		wantLine  string
+	}{
+		{"wrong token", []byte("guess\n"), "Error: authentication failed\n"},
+		{"frame without token", []byte{0x01, 0x2A}, "Error: authentication failed\n"},
+		{"valid token", []byte("s3cret\r\n"), "Authenticated\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatalf("Dial failed: %v", err)
+			}
+			defer func() { _ = conn.Close() }()
+			_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			reader := bufio.NewReader(conn)
+
+			if _, err := conn.Write(tt.handshake); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			if line, err := reader.ReadString('\n'); err != nil || line != tt.wantLine {
+				t.Fatalf("handshake response = %q, %v; want %q", line, err, tt.wantLine)
+			}
+			if tt.wantLine != "Authenticated\n" {
+				if _, err := reader.ReadByte(); err == nil {
+					t.Error("expected the unauthenticated connection to be closed")
+				}
+				return
+			}
+
+			if _, err := conn.Write([]byte{0x01, 0x2A}); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			if line, err := reader.ReadString('\n'); err != nil || !strings.HasPrefix(line, "Parsed (test_proto)") {
+				t.Errorf("frame response = %q, %v", line, err)
+			}
+		})
+	}
+}
+
+func TestTCPServer_MutualTLS(t *testing.T) {
+	ca, caKey := newTestCert(t, "test-ca", nil, nil)
+	serverCert, serverKey := newTestCert(t, "127.0.0.1", ca, caKey)
+	clientCert, clientKey := newTestCert(t, "client", ca, caKey)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	_, addr, _ := newTestTCPServer(t, func(s *TCPServer) {
+		s.SetTLSConfig(&tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.Raw}, PrivateKey: serverKey}},
+			ClientCAs:    pool,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+		})
+	})
+
+	dial := func(certs []tls.Certificate) (*tls.Conn, error) {
+		return tls.DialWithDialer(&net.Dialer{Timeout: 2 * time.Second}, "tcp", addr,
+			&tls.Config{RootCAs: pool, Certificates: certs, ServerName: "127.0.0.1"})
+	}
+
+	// Without a client certificate the server aborts the handshake and never parses the frame
+	if conn, err := dial(nil); err == nil {
+		_, _ = conn.Write([]byte{0x01, 0x2A})
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if line, err := bufio.NewReader(conn).ReadString('\n'); err == nil {
+			t.Errorf("client without certificate got %q", line)
+		}
+		_ = conn.Close()
+	}
+
+	conn, err := dial([]tls.Certificate{{Certificate: [][]byte{clientCert.Raw}, PrivateKey: clientKey}})
+	if err != nil {
+		t.Fatalf("Dial with client certificate failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Write([]byte{0x01, 0x2A}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if line, err := bufio.NewReader(conn).ReadString('\n'); err != nil || !strings.HasPrefix(line, "Parsed (test_proto)") {
+		t.Errorf("frame response = %q, %v", line, err)
+	}
+}
+
+// newTestCert issues a certificate for name signed by parent, or a self-signed CA when parent is nil
+func newTestCert(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if ip := net.ParseIP(name); ip != nil {
+		tmpl.IPAddresses = []net.IP{ip}
+	}
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %v", err)
+	}
+	return cert, key
+}
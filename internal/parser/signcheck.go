@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// signedFieldHints are field-name fragments that usually denote signed quantities
+var signedFieldHints = []string{"temp", "offset", "delta", "diff", "bias", "trim", "correction", "angle", "accel"}
+
+// SuspectSignErrors returns the fields of a parse result that look like signed values
+// decoded as unsigned: a field named like a signed quantity (temperature, offset, delta...)
+// holding a whole number in the upper half of the 8, 16 or 32-bit range, e.g. 200 for -56.
+// Each entry names the field and the value it would have as a two's-complement number.
+func SuspectSignErrors(result map[string]interface{}) []string {
+	var suspects []string
+	collectSignSuspects("", result, &suspects)
+	sort.Strings(suspects)
+	return suspects
+}
+
+func collectSignSuspects(prefix string, fields map[string]interface{}, suspects *[]string) {
+	for key, value := range fields {
+		name := prefix + key
+		if nested, ok := value.(map[string]interface{}); ok {
+			collectSignSuspects(name+".", nested, suspects)
+			continue
+		}
+		if !looksSigned(key) {
+			continue
+		}
+		v, ok := wholeNumber(value)
+		if !ok {
+			continue
+		}
+		for _, bits := range []uint{8, 16, 32} {
+			lo, hi := int64(1)<<(bits-1), int64(1)<<bits
+			if v >= lo && v < hi {
+				*suspects = append(*suspects, fmt.Sprintf("%s=%d (as signed %d-bit: %d)", name, v, bits, v-hi))
+				break
+			}
+		}
+	}
+}
+
+func looksSigned(field string) bool {
+	field = strings.ToLower(field)
+	for _, hint := range signedFieldHints {
+		if strings.Contains(field, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// wholeNumber converts the numeric types parsers commonly return, rejecting fractional values
+func wholeNumber(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case int32:
+		return int64(n), true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		if n > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(n), true
+	case float64:
+		if n != math.Trunc(n) || math.Abs(n) > math.MaxInt64/2 {
+			return 0, false
+		}
+		return int64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,30 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSuspectSignErrors(t *testing.T) {
+	result := map[string]interface{}{
+		"coolant_temp": 200,                                       // 8-bit -56 read as unsigned
+		"Offset":       float64(65336),                            // 16-bit -200
+		"rpm":          1726.0,                                    // Not a signed quantity
+		"temp_ok":      -56,                                       // Already signed
+		"scaled_temp":  12.5,                                      // Fractional, can't tell
+		"imu":          map[string]interface{}{"accel_x": 0xFFFE}, // Nested 16-bit -2
+	}
+
+	want := []string{
+		"Offset=65336 (as signed 16-bit: -200)",
+		"coolant_temp=200 (as signed 8-bit: -56)",
+		"imu.accel_x=65534 (as signed 16-bit: -2)",
+	}
+	if got := SuspectSignErrors(result); !reflect.DeepEqual(got, want) {
+		t.Errorf("SuspectSignErrors = %v, want %v", got, want)
+	}
+
+	if got := SuspectSignErrors(map[string]interface{}{"temp": 25}); len(got) != 0 {
+		t.Errorf("Expected plausible temperature to pass, got %v", got)
+	}
+}
@@ -0,0 +1,230 @@
+package parser
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Snapshot archive layout: a state descriptor first, then the storage tree
+const (
+	snapshotVersion    = 1
+	snapshotStateFile  = "state.json"
+	snapshotStorageDir = "storage/"
+)
+
+// SnapshotState describes the in-memory gateway state that isn't fully captured by the storage files
+type SnapshotState struct {
+	Version          int               `json:"version"`
+	CreatedAt        time.Time         `json:"created_at"`
+	Parsers          []string          `json:"parsers"`
+	Bindings         map[string]string `json:"bindings"`                    // Hex signature -> ProtocolID
+	LengthBindings   []LengthBinding   `json:"length_bindings,omitempty"`   // Prefix bindings constrained to a frame length
+	MaskedBindings   []MaskedBinding   `json:"masked_bindings,omitempty"`   // Bit-masked bindings in registration order
+	Disabled         []string          `json:"disabled,omitempty"`          // Protocols bound but not parsed
+	Families         map[string]string `json:"families,omitempty"`          // Hex prefix -> family label
+	ProtocolFamilies map[string]string `json:"protocol_families,omitempty"` // ProtocolID -> explicit family label
+}
+
+// Snapshot writes every parser (with version history), the manifest and the routing
+// state to w as a tar.gz archive that Restore can load.
+func (g *Gateway) Snapshot(w io.Writer) error {
+	disabled, families, protocolFamilies := g.dispatcher.routingState()
+	parsers := g.manager.Parsers()
+
+	state := SnapshotState{
+		Version:          snapshotVersion,
+		CreatedAt:        time.Now().UTC(),
+		Bindings:         g.dispatcher.GetBindings(),
+		LengthBindings:   g.dispatcher.GetLengthBindings(),
+		MaskedBindings:   g.dispatcher.GetMaskedBindings(),
+		Disabled:         disabled,
+		Families:         families,
+		ProtocolFamilies: protocolFamilies,
+	}
+	for id := range parsers {
+		state.Parsers = append(state.Parsers, id)
+	}
+	sort.Strings(state.Parsers)
+
+	// Make sure the manifest on disk reflects the current bindings
+	if err := g.dispatcher.SaveManifest(); err != nil {
+		return fmt.Errorf("failed to save manifest: %v", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	stateData, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, snapshotStateFile, stateData, state.CreatedAt); err != nil {
+		return err
+	}
+
+	// Keep parsers from changing underneath the walk
+	g.manager.mu.RLock()
+	err = filepath.WalkDir(g.manager.storagePath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(g.manager.storagePath, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return writeTarFile(tw, snapshotStorageDir+filepath.ToSlash(rel), data, info.ModTime())
+	})
+	g.manager.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to archive storage: %v", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Restore loads an archive produced by Snapshot into this gateway. It is meant for a
+// fresh gateway: parsers with the same ID are overwritten, other existing state is kept.
+func (g *Gateway) Restore(r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("invalid snapshot: %v", err)
+	}
+	defer func() { _ = gz.Close() }()
+	tr := tar.NewReader(gz)
+
+	var state *SnapshotState
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid snapshot: %v", err)
+		}
+
+		if state == nil {
+			if hdr.Name != snapshotStateFile {
+				return fmt.Errorf("invalid snapshot: expected %s first, got %s", snapshotStateFile, hdr.Name)
+			}
+			state = &SnapshotState{}
+			if err := json.NewDecoder(tr).Decode(state); err != nil {
+				return fmt.Errorf("invalid snapshot state: %v", err)
+			}
+			if state.Version != snapshotVersion {
+				return fmt.Errorf("unsupported snapshot version %d", state.Version)
+			}
+			continue
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		rel, ok := strings.CutPrefix(hdr.Name, snapshotStorageDir)
+		if !ok {
+			continue
+		}
+		dest, err := g.restorePath(rel)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(dest, data, 0o644); err != nil {
+			return err
+		}
+	}
+	if state == nil {
+		return fmt.Errorf("invalid snapshot: missing %s", snapshotStateFile)
+	}
+
+	if _, err := g.manager.LoadSavedParsers(); err != nil {
+		return fmt.Errorf("failed to load restored parsers: %v", err)
+	}
+	for _, id := range state.Parsers {
+		g.manager.engine.ClearCache(id)
+	}
+	if err := g.manager.LoadAliases(); err != nil {
+		return fmt.Errorf("failed to load restored aliases: %v", err)
+	}
+
+	for sigHex, id := range state.Bindings {
+		sig, err := hex.DecodeString(sigHex)
+		if err != nil || len(sig) == 0 {
+			logger.Warn("Skipping invalid binding in snapshot", zap.String("signature", sigHex), zap.String("protocol", id))
+			continue
+		}
+		g.dispatcher.Bind(sig, id)
+	}
+	g.dispatcher.bindLengthBindings(state.LengthBindings, "snapshot")
+	g.dispatcher.bindMaskedBindings(state.MaskedBindings, "snapshot")
+	for _, id := range state.Disabled {
+		g.dispatcher.SetEnabled(id, false)
+	}
+	for prefixHex, family := range state.Families {
+		prefix, err := hex.DecodeString(prefixHex)
+		if err != nil {
+			continue
+		}
+		g.dispatcher.BindFamily(prefix, family)
+	}
+	for id, family := range state.ProtocolFamilies {
+		g.dispatcher.SetFamily(id, family)
+	}
+
+	logger.Info("Gateway restored from snapshot",
+		zap.Int("parsers", len(state.Parsers)), zap.Int("bindings", len(state.Bindings)), zap.Time("created_at", state.CreatedAt))
+	return g.dispatcher.SaveManifest()
+}
+
+// restorePath maps an archive path to a file inside storage, rejecting anything that escapes it
+func (g *Gateway) restorePath(rel string) (string, error) {
+	clean := path.Clean(rel)
+	if clean == "." || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("invalid snapshot: illegal path %q", rel)
+	}
+	return filepath.Join(g.manager.storagePath, filepath.FromSlash(clean)), nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGateway_SnapshotRestore(t *testing.T) {
+	srcDir, _ := os.MkdirTemp("", "omnibridge_snapshot_src")
+	defer func() { _ = os.RemoveAll(srcDir) }()
+	dstDir, _ := os.MkdirTemp("", "omnibridge_snapshot_dst")
+	defer func() { _ = os.RemoveAll(dstDir) }()
+
+	// Train a gateway: two parsers (one with history), bindings, a disabled protocol and families
+	srcMgr := NewParserManager(srcDir, "")
+	src := NewGateway(NewDispatcher(srcMgr), nil)
+	parsers := []struct{ id, code string }{
+		{"rpm", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"rpm": 0} }`},
+		{"rpm", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"rpm": (int(data[2])*256 + int(data[3])) / 4} }`},
+		{"meter", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"mv": int(data[2])} }`},
+		{"legacy", `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"v": 1} }`},
+	}
+	for _, p := range parsers {
+		if err := srcMgr.RegisterParser(p.id, p.code); err != nil {
+			t.Fatalf("RegisterParser(%s) failed: %v", p.id, err)
+		}
+	}
+	d := src.GetDispatcher()
+	d.Bind([]byte{0x41, 0x0C}, "rpm")
+	d.Bind([]byte{0x55, 0xAA}, "meter")
+	d.Bind([]byte{0x01}, "legacy")
+	d.BindWithLength([]byte{0x55, 0xAA}, 5, "legacy")
+	_ = d.BindMasked([]byte{0x60}, []byte{0xF0}, "meter", 0)
+	d.SetEnabled("legacy", false)
+	d.BindFamily([]byte{0x41}, "obd2")
+	d.SetFamily("meter", "metering")
+
+	var archive bytes.Buffer
+	if err := src.Snapshot(&archive); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	dstMgr := NewParserManager(dstDir, "")
+	dst := NewGateway(NewDispatcher(dstMgr), nil)
+	if err := dst.Restore(&archive); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	frames := [][]byte{
+		{0x41, 0x0C, 0x1A, 0xF8},
+		{0x55, 0xAA, 0x2A},
+		{0x01, 0x00},
+		{0x55, 0xAA, 0x2A, 0x00, 0x00},
+		{0x6B, 0x00, 0x2A},
+		{0x99},
+	}
+	for _, frame := range frames {
+		wantRes, wantProto, wantErr := d.Ingest(frame)
+		gotRes, gotProto, gotErr := dst.GetDispatcher().Ingest(frame)
+		if !reflect.DeepEqual(gotRes, wantRes) || gotProto != wantProto || (gotErr == nil) != (wantErr == nil) {
+			t.Errorf("frame %X: restored = %v/%s/%v, original = %v/%s/%v", frame, gotRes, gotProto, gotErr, wantRes, wantProto, wantErr)
+		}
+	}
+
+	if !reflect.DeepEqual(dst.GetDispatcher().GetBindings(), d.GetBindings()) {
+		t.Errorf("bindings differ: %v vs %v", dst.GetDispatcher().GetBindings(), d.GetBindings())
+	}
+	if !reflect.DeepEqual(dst.GetDispatcher().GetLengthBindings(), d.GetLengthBindings()) {
+		t.Errorf("length bindings differ: %v vs %v", dst.GetDispatcher().GetLengthBindings(), d.GetLengthBindings())
+	}
+	if !reflect.DeepEqual(dst.GetDispatcher().GetMaskedBindings(), d.GetMaskedBindings()) {
+		t.Errorf("masked bindings differ: %v vs %v", dst.GetDispatcher().GetMaskedBindings(), d.GetMaskedBindings())
+	}
+	if versions, _ := dstMgr.ListVersions("rpm"); !reflect.DeepEqual(versions, []int{1, 2}) {
+		t.Errorf("expected version history restored, got %v", versions)
+	}
+	wantDisabled, wantFamilies, wantProtoFamilies := d.routingState()
+	gotDisabled, gotFamilies, gotProtoFamilies := dst.GetDispatcher().routingState()
+	if !reflect.DeepEqual(gotDisabled, wantDisabled) || !reflect.DeepEqual(gotFamilies, wantFamilies) || !reflect.DeepEqual(gotProtoFamilies, wantProtoFamilies) {
+		t.Errorf("routing state differs: %v %v %v", gotDisabled, gotFamilies, gotProtoFamilies)
+	}
+	manifest, err := dstMgr.LoadManifest()
+	if err != nil || !reflect.DeepEqual(manifest, d.GetBindings()) {
+		t.Errorf("restored manifest = %v (%v)", manifest, err)
+	}
+}
+
+func TestGateway_RestoreRejectsPathTraversal(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "omnibridge_snapshot_evil")
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	_ = writeTarFile(tw, snapshotStateFile, []byte(`{"version":1}`), time.Time{})
+	_ = writeTarFile(tw, snapshotStorageDir+"../escaped.go", []byte("package dynamic"), time.Time{})
+	_ = tw.Close()
+	_ = gz.Close()
+
+	g := NewGateway(NewDispatcher(NewParserManager(dir+"/storage", "")), nil)
+	err := g.Restore(&archive)
+	if err == nil || !strings.Contains(err.Error(), "illegal path") {
+		t.Errorf("expected illegal path error, got %v", err)
+	}
+	if _, err := os.Stat(dir + "/escaped.go"); !os.IsNotExist(err) {
+		t.Error("file escaped the storage directory")
+	}
+}
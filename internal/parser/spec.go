@@ -0,0 +1,184 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chuanjin/OmniBridge/internal/parser/checksum"
+	"gopkg.in/yaml.v3"
+)
+
+// checksumValidators are the FrameValidators a spec can name
+var checksumValidators = map[string]FrameValidator{
+	"crc16-modbus": checksum.CRC16Modbus,
+	"crc16-ccitt":  checksum.CRC16CCITT,
+	"crc32":        checksum.CRC32,
+	"xor":          checksum.XOR,
+}
+
+// ProtocolSpec declares known protocols and their bindings, for operators who have the
+// specs and don't need discovery. It is read from JSON, or YAML for .yaml/.yml files.
+type ProtocolSpec struct {
+	Protocols []SpecProtocol `json:"protocols" yaml:"protocols"`
+}
+
+// SpecProtocol is one protocol of a ProtocolSpec
+type SpecProtocol struct {
+	Name        string        `json:"name" yaml:"name"`
+	Parser      string        `json:"parser,omitempty" yaml:"parser,omitempty"`     // Go source file, relative to the spec; optional if the parser is already stored
+	Checksum    string        `json:"checksum,omitempty" yaml:"checksum,omitempty"` // crc16-modbus, crc16-ccitt, crc32 or xor
+	Alias       string        `json:"alias,omitempty" yaml:"alias,omitempty"`
+	Family      string        `json:"family,omitempty" yaml:"family,omitempty"`
+	Description string        `json:"description,omitempty" yaml:"description,omitempty"`
+	Bindings    []SpecBinding `json:"bindings" yaml:"bindings"`
+}
+
+// SpecBinding routes frames to a SpecProtocol. A plain binding is a prefix, or a whole frame
+// when Length is set. With Offset or Mask it becomes a masked binding: the signature is
+// compared Offset bytes into the frame, only in the bits set in Mask (all of them by default).
+type SpecBinding struct {
+	Signature string `json:"signature" yaml:"signature"` // Hex
+	Offset    int    `json:"offset,omitempty" yaml:"offset,omitempty"`
+	Mask      string `json:"mask,omitempty" yaml:"mask,omitempty"` // Hex, same length as Signature
+	Length    int    `json:"length,omitempty" yaml:"length,omitempty"`
+	Priority  int    `json:"priority,omitempty" yaml:"priority,omitempty"` // Used by MaskHighestPriority
+}
+
+// LoadSpec reads a ProtocolSpec and resolves its parser paths relative to the file
+func LoadSpec(path string) (*ProtocolSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var spec ProtocolSpec
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &spec)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&spec)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid protocol spec %s: %v", path, err)
+	}
+	for i := range spec.Protocols {
+		if p := &spec.Protocols[i]; p.Parser != "" && !filepath.IsAbs(p.Parser) {
+			p.Parser = filepath.Join(filepath.Dir(path), p.Parser)
+		}
+	}
+	return &spec, nil
+}
+
+// ApplySpec registers the parser of every protocol in spec (unless the stored one is already
+// the same code) and applies its bindings, checksum, alias and family. The whole spec is
+// checked first, parser files included, which must compile, so a mistake in it changes nothing.
+func (d *Dispatcher) ApplySpec(spec *ProtocolSpec) error {
+	type binding struct {
+		signature, mask []byte
+		spec            SpecBinding
+	}
+	codes := make([]string, len(spec.Protocols))
+	bindings := make([][]binding, len(spec.Protocols))
+
+	var errs []error
+	for i, p := range spec.Protocols {
+		if !protocolNameRe.MatchString(p.Name) {
+			errs = append(errs, fmt.Errorf("invalid protocol name %q", p.Name))
+			continue
+		}
+		if p.Checksum != "" && checksumValidators[p.Checksum] == nil {
+			errs = append(errs, fmt.Errorf("%s: unknown checksum %q", p.Name, p.Checksum))
+		}
+		if p.Parser != "" {
+			code, err := os.ReadFile(p.Parser)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", p.Name, err))
+			} else if err := d.manager.engine.Validate(string(code)); err != nil {
+				// Caught now rather than at ingest, where it would send the operator's code for repair
+				errs = append(errs, fmt.Errorf("%s: parser does not compile: %v", p.Name, err))
+			}
+			codes[i] = string(code)
+		} else if _, ok := d.manager.GetParserCode(p.Name); !ok {
+			errs = append(errs, fmt.Errorf("%s: no parser file and no stored parser", p.Name))
+		}
+		if len(p.Bindings) == 0 {
+			errs = append(errs, fmt.Errorf("%s: no bindings", p.Name))
+		}
+		for _, b := range p.Bindings {
+			sig, mask, err := specSignature(b)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: binding %q: %v", p.Name, b.Signature, err))
+				continue
+			}
+			bindings[i] = append(bindings[i], binding{sig, mask, b})
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	for i, p := range spec.Protocols {
+		if current, _ := d.manager.GetParserCode(p.Name); codes[i] != "" && codes[i] != current {
+			meta := ParserMeta{Author: "spec", Description: p.Description}
+			if err := d.manager.RegisterParserWithMeta(p.Name, codes[i], meta); err != nil {
+				return fmt.Errorf("%s: %w", p.Name, err)
+			}
+		}
+		for _, b := range bindings[i] {
+			switch {
+			case b.mask != nil:
+				if err := d.BindMasked(b.signature, b.mask, p.Name, b.spec.Priority); err != nil {
+					return fmt.Errorf("%s: %w", p.Name, err)
+				}
+			default:
+				d.BindWithLength(b.signature, b.spec.Length, p.Name)
+			}
+		}
+		if p.Checksum != "" {
+			d.setValidator(p.Name, checksumValidators[p.Checksum])
+		}
+		if p.Family != "" {
+			d.SetFamily(p.Name, p.Family)
+		}
+		if p.Alias != "" {
+			if err := d.manager.SetAlias(p.Name, p.Alias); err != nil {
+				return fmt.Errorf("%s: %w", p.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// specSignature decodes a binding's signature, and builds its mask if it is a masked binding
+func specSignature(b SpecBinding) (signature, mask []byte, err error) {
+	signature, err = hex.DecodeString(strings.Join(strings.Fields(b.Signature), ""))
+	if err != nil || len(signature) == 0 {
+		return nil, nil, fmt.Errorf("signature must be non-empty hex")
+	}
+	if b.Offset < 0 || b.Length < 0 {
+		return nil, nil, fmt.Errorf("offset and length can't be negative")
+	}
+	if b.Offset == 0 && b.Mask == "" {
+		return signature, nil, nil
+	}
+
+	if b.Length > 0 {
+		return nil, nil, fmt.Errorf("length can't be combined with offset or mask")
+	}
+	mask = bytes.Repeat([]byte{0xFF}, len(signature))
+	if b.Mask != "" {
+		if mask, err = hex.DecodeString(strings.Join(strings.Fields(b.Mask), "")); err != nil || len(mask) != len(signature) {
+			return nil, nil, fmt.Errorf("mask must be hex as long as the signature")
+		}
+	}
+	// Bytes before the offset are don't-cares
+	pad := make([]byte, b.Offset)
+	return append(pad, signature...), append(pad, mask...), nil
+}
@@ -0,0 +1,142 @@
+package parser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const specMeterParser = `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"kwh": int(data[2])} }`
+
+const specSensorParser = `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"temp": int(data[3])} }`
+
+func TestDispatcher_ApplySpec(t *testing.T) {
+	dir := t.TempDir()
+	for name, code := range map[string]string{"meter.go": specMeterParser, "parsers/sensor.go": specSensorParser} {
+		_ = os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(code), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	specPath := filepath.Join(dir, "protocols.yaml")
+	spec := `protocols:
+  - name: meter
+    parser: meter.go
+    checksum: xor
+    alias: Power meter
+    bindings:
+      - signature: 55AA
+  - name: sensor
+    parser: parsers/sensor.go
+    family: climate
+    bindings:
+      - signature: "7E"
+        offset: 2
+`
+	if err := os.WriteFile(specPath, []byte(spec), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	mgr := NewParserManager(filepath.Join(dir, "storage"), "")
+	d := NewDispatcher(mgr)
+	loaded, err := LoadSpec(specPath)
+	if err != nil {
+		t.Fatalf("LoadSpec failed: %v", err)
+	}
+	if err := d.ApplySpec(loaded); err != nil {
+		t.Fatalf("ApplySpec failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		frame     []byte
+		wantProto string
+		wantField string
+		wantValue int
+		wantErr   error
+	}{
+		{"prefix binding", []byte{0x55, 0xAA, 0x10, 0x55 ^ 0xAA ^ 0x10}, "meter", "kwh", 16, nil},
+		{"checksum applied", []byte{0x55, 0xAA, 0x10, 0x00}, "meter", "", 0, ErrChecksum},
+		{"signature at an offset", []byte{0x01, 0x02, 0x7E, 0x15}, "sensor", "temp", 21, nil},
+		{"offset signature elsewhere", []byte{0x7E, 0x02, 0x03, 0x15}, "", "", 0, ErrUnknownProtocol},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, proto, err := d.Ingest(tt.frame)
+			if proto != tt.wantProto {
+				t.Errorf("routed to %q, want %q", proto, tt.wantProto)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || res[tt.wantField] != tt.wantValue {
+				t.Errorf("Ingest = %v, %v", res, err)
+			}
+		})
+	}
+
+	if alias, _ := mgr.GetAlias("meter"); alias != "Power meter" {
+		t.Errorf("alias = %q, want Power meter", alias)
+	}
+	if _, family := d.matchLocked([]byte{0x01, 0x02, 0x7E}, 3); family != "climate" {
+		t.Errorf("family = %q, want climate", family)
+	}
+
+	// Applying the same spec at the next startup doesn't store new versions
+	if err := d.ApplySpec(loaded); err != nil {
+		t.Fatalf("second ApplySpec failed: %v", err)
+	}
+	if versions, _ := mgr.ListVersions("meter"); len(versions) != 1 {
+		t.Errorf("expected one stored version, got %v", versions)
+	}
+}
+
+func TestDispatcher_ApplySpecRejects(t *testing.T) {
+	dir := t.TempDir()
+	parserPath := filepath.Join(dir, "p.go")
+	if err := os.WriteFile(parserPath, []byte(specMeterParser), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	brokenPath := filepath.Join(dir, "broken.go")
+	if err := os.WriteFile(brokenPath, []byte("package dynamic\nfunc Parse(data []byte) map[string]interface{} { return undefined }"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		protocol SpecProtocol
+	}{
+		{"invalid name", SpecProtocol{Name: "../x", Parser: parserPath, Bindings: []SpecBinding{{Signature: "01"}}}},
+		{"unknown checksum", SpecProtocol{Name: "p", Parser: parserPath, Checksum: "md5", Bindings: []SpecBinding{{Signature: "01"}}}},
+		{"missing parser", SpecProtocol{Name: "p", Bindings: []SpecBinding{{Signature: "01"}}}},
+		{"no bindings", SpecProtocol{Name: "p", Parser: parserPath}},
+		{"parser does not compile", SpecProtocol{Name: "p", Parser: brokenPath, Bindings: []SpecBinding{{Signature: "01"}}}},
+		{"bad signature", SpecProtocol{Name: "p", Parser: parserPath, Bindings: []SpecBinding{{Signature: "zz"}}}},
+		{"mask length", SpecProtocol{Name: "p", Parser: parserPath, Bindings: []SpecBinding{{Signature: "0102", Mask: "FF"}}}},
+		{"length with offset", SpecProtocol{Name: "p", Parser: parserPath, Bindings: []SpecBinding{{Signature: "01", Offset: 1, Length: 4}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr := NewParserManager(t.TempDir(), "")
+			d := NewDispatcher(mgr)
+			if err := d.ApplySpec(&ProtocolSpec{Protocols: []SpecProtocol{tt.protocol}}); err == nil {
+				t.Fatal("expected ApplySpec to fail")
+			}
+			if len(mgr.Parsers()) != 0 || len(d.GetBindings()) != 0 {
+				t.Error("a rejected spec must not register or bind anything")
+			}
+		})
+	}
+
+	badJSON := filepath.Join(dir, "spec.json")
+	_ = os.WriteFile(badJSON, []byte(`{"protocols": [{"name": "p", "signatures": ["01"]}]}`), 0o644)
+	if _, err := LoadSpec(badJSON); err == nil {
+		t.Error("expected unknown JSON fields to be rejected")
+	}
+}
@@ -0,0 +1,177 @@
+package parser
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"go.uber.org/zap"
+)
+
+// summariesFile holds the LLM-written protocol descriptions inside the storage directory
+const summariesFile = "summaries.json"
+
+// summaryPrompt asks for documentation rather than code, so the system prompt isn't used
+const summaryPrompt = `You are documenting a binary protocol parser written in Go for operators.
+Describe in plain English, in at most a short paragraph plus one line per output field,
+what the parser decodes: each field it returns, the bytes it comes from, its scaling and
+units if any. Do not return code.
+
+PARSER SOURCE:
+` + "```go\n%s\n```"
+
+// ProtocolSummary is a natural-language description of a parser, tied to the code it describes
+type ProtocolSummary struct {
+	Summary   string    `json:"summary"`
+	CodeHash  string    `json:"code_hash"` // SHA-256 of the parser source the summary was written for
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Summarize returns a concise description of what a protocol's parser decodes, asking the
+// LLM for one the first time and caching it in storage/summaries.json. A summary is
+// regenerated once the parser's code changes.
+func (s *DiscoveryService) Summarize(protocolID string) (string, error) {
+	code, ok := s.manager.GetParserCode(protocolID)
+	if !ok {
+		return "", fmt.Errorf("no parser found for %s", protocolID)
+	}
+	if summary, ok := s.manager.GetSummary(protocolID); ok {
+		return summary, nil
+	}
+
+	prompt := fmt.Sprintf(summaryPrompt, code)
+	maxRetries := s.Config.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = 1
+	}
+	response, err := s.callLLM(context.Background(), prompt, maxRetries)
+	entry := AuditEntry{Kind: "summary", ProtocolID: protocolID, Prompt: prompt, Response: response, Attempts: 1}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	s.audit(entry)
+	if err != nil {
+		return "", err
+	}
+
+	summary := strings.TrimSpace(response)
+	if summary == "" {
+		return "", fmt.Errorf("LLM returned an empty summary for %s", protocolID)
+	}
+	if err := s.manager.saveSummary(protocolID, code, summary); err != nil {
+		// Still useful to the caller; it will just be regenerated next time
+		logger.Warn("Failed to store protocol summary", zap.String("protocol", protocolID), zap.Error(err))
+	}
+	logger.Info("Protocol summarized", zap.String("protocol", protocolID))
+	return summary, nil
+}
+
+// DescribeFrame asks the LLM for a plain-English breakdown of a frame's byte layout, for
+// exploring a device before committing to a parser. Nothing is generated, compiled or
+// registered. The prompt comes from the describe template, and PrivacyMode masks the sample
+// as it does for discovery. Cancelling ctx abandons the request.
+func (s *DiscoveryService) DescribeFrame(ctx context.Context, rawSample []byte, contextHint string) (string, error) {
+	if len(rawSample) == 0 {
+		return "", fmt.Errorf("no frame to describe")
+	}
+	if contextHint == "" {
+		contextHint = "None"
+	}
+	ctx, cancel := s.withDeadline(ctx)
+	defer cancel()
+
+	prompt, err := s.prompts.Render(PromptDescribe, PromptData{
+		HexSample:   fmt.Sprintf("%X", s.promptSample(rawSample, 1)),
+		ContextHint: contextHint,
+	})
+	if err != nil {
+		return "", err
+	}
+	maxRetries := s.Config.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = 1
+	}
+	response, err := s.callLLM(ctx, prompt, maxRetries)
+	entry := AuditEntry{Kind: "describe", Prompt: prompt, Response: response, Attempts: 1}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	s.audit(entry)
+	if err != nil {
+		return "", err
+	}
+
+	description := strings.TrimSpace(response)
+	if description == "" {
+		return "", fmt.Errorf("LLM returned an empty description")
+	}
+	logger.Info("Frame described", zap.Int("bytes", len(rawSample)))
+	return description, nil
+}
+
+// GetSummary returns the stored summary of a protocol if it describes the current code
+func (m *ParserManager) GetSummary(protocolID string) (string, bool) {
+	summary, ok := m.Summaries()[protocolID]
+	return summary, ok
+}
+
+// Summaries returns the stored summary of every protocol whose code hasn't changed since
+func (m *ParserManager) Summaries() map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, err := m.readSummariesLocked()
+	if err != nil {
+		logger.Warn("Failed to read protocol summaries", zap.Error(err))
+	}
+	summaries := make(map[string]string, len(stored))
+	for id, s := range stored {
+		if code, ok := m.cache[id]; ok && s.CodeHash == codeHash(code) {
+			summaries[id] = s.Summary
+		}
+	}
+	return summaries
+}
+
+func (m *ParserManager) saveSummary(protocolID, code, summary string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, err := m.readSummariesLocked()
+	if err != nil {
+		return err
+	}
+	stored[protocolID] = ProtocolSummary{Summary: summary, CodeHash: codeHash(code), CreatedAt: time.Now().UTC()}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.storagePath, summariesFile), data, 0o644)
+}
+
+func (m *ParserManager) readSummariesLocked() (map[string]ProtocolSummary, error) {
+	summaries := make(map[string]ProtocolSummary)
+	data, err := os.ReadFile(filepath.Join(m.storagePath, summariesFile))
+	if os.IsNotExist(err) {
+		return summaries, nil
+	}
+	if err != nil {
+		return summaries, err
+	}
+	if err := json.Unmarshal(data, &summaries); err != nil {
+		return make(map[string]ProtocolSummary), fmt.Errorf("invalid %s: %v", summariesFile, err)
+	}
+	return summaries, nil
+}
+
+func codeHash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,165 @@
+package parser
+
+import (
+	goparser "go/parser"
+	"go/token"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+// SymbolUsageReport lists the allowlisted functions parsers called and the packages they
+// import, to help shrink the allowlist
+type SymbolUsageReport struct {
+	Protocols      map[string]map[string]int64 `json:"protocols"`       // ProtocolID -> "pkg.Func" -> calls
+	Imports        map[string][]string         `json:"imports"`         // ProtocolID -> allowlisted packages its parser imports
+	UsedPackages   []string                    `json:"used_packages"`   // Allowlisted packages at least one parser imported or called into
+	UnusedPackages []string                    `json:"unused_packages"` // Allowlisted packages no parser imported
+}
+
+// symbolUsage counts calls to allowlisted functions and records imported packages per protocol
+type symbolUsage struct {
+	calls   map[string]map[string]int64 // ProtocolID -> "pkg.Func" -> calls
+	imports map[string][]string         // ProtocolID -> import paths of its compiled parser
+	mu      sync.Mutex
+}
+
+// TrackSymbolUsage records, per protocol, every call a parser makes to an allowlisted function
+// (fmt.Sprintf, omni.U16BE, ...), aggregated across runs and reported by SymbolUsage, along with
+// the packages each compiled parser imports. Methods of allowlisted values, such as
+// binary.BigEndian.Uint16, aren't counted as calls, but their package still shows up as imported.
+// Calls go through reflection while it is on, so it is meant for profiling the allowlist rather
+// than production. Toggling it recompiles parsers.
+func (e *Engine) TrackSymbolUsage(enabled bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if enabled == (e.usage != nil) {
+		return
+	}
+	e.usage = nil
+	if enabled {
+		e.usage = &symbolUsage{calls: make(map[string]map[string]int64), imports: make(map[string][]string)}
+	}
+	clear(e.cache)
+}
+
+// SymbolUsage reports the calls recorded since TrackSymbolUsage was enabled
+func (e *Engine) SymbolUsage() SymbolUsageReport {
+	e.mu.RLock()
+	usage := e.usage
+	e.mu.RUnlock()
+
+	report := SymbolUsageReport{Protocols: make(map[string]map[string]int64), Imports: make(map[string][]string)}
+	used := make(map[string]bool)
+	if usage != nil {
+		usage.mu.Lock()
+		for id, calls := range usage.calls {
+			report.Protocols[id] = maps.Clone(calls)
+			for symbol := range calls {
+				used[symbol[:strings.LastIndex(symbol, ".")]] = true
+			}
+		}
+		for id, imports := range usage.imports {
+			report.Imports[id] = slices.Clone(imports)
+			for _, pkg := range imports {
+				used[pkg] = true
+			}
+		}
+		usage.mu.Unlock()
+	}
+
+	for key := range symbols {
+		if pkg := exportPackage(key); used[pkg] {
+			report.UsedPackages = append(report.UsedPackages, pkg)
+		} else {
+			report.UnusedPackages = append(report.UnusedPackages, pkg)
+		}
+	}
+	sort.Strings(report.UsedPackages)
+	sort.Strings(report.UnusedPackages)
+	return report
+}
+
+// interpreterFor returns how id's parser is compiled: instrumented while symbol usage is
+// tracked, otherwise with the plain allowlist. Callers hold e.mu.
+func (e *Engine) interpreterFor(id string) interpreter {
+	if e.usage == nil {
+		return e.interpret
+	}
+	usage := e.usage
+	exports := usage.instrument(id)
+	return func(goCode string) (compiledParser, error) {
+		fn, err := interpretWith(goCode, exports)
+		if err == nil {
+			usage.recordImports(id, goCode)
+		}
+		return fn, err
+	}
+}
+
+// recordImports remembers the packages a compiled parser imports, replacing those of the
+// code it was compiled from before. Code that compiled always parses.
+func (u *symbolUsage) recordImports(id, goCode string) {
+	file, err := goparser.ParseFile(token.NewFileSet(), "", goCode, goparser.ImportsOnly)
+	if err != nil {
+		return
+	}
+	imports := make([]string, 0, len(file.Imports))
+	for _, spec := range file.Imports {
+		if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+			imports = append(imports, path)
+		}
+	}
+	sort.Strings(imports)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.imports[id] = imports
+}
+
+func (u *symbolUsage) record(id, symbol string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	calls := u.calls[id]
+	if calls == nil {
+		calls = make(map[string]int64)
+		u.calls[id] = calls
+	}
+	calls[symbol]++
+}
+
+// instrument copies the allowlist with every function wrapped to record its calls under id
+func (u *symbolUsage) instrument(id string) interp.Exports {
+	exports := make(interp.Exports, len(symbols))
+	for key, pkgSymbols := range symbols {
+		pkg := exportPackage(key)
+		wrapped := make(map[string]reflect.Value, len(pkgSymbols))
+		for name, value := range pkgSymbols {
+			if value.Kind() != reflect.Func {
+				wrapped[name] = value // Types, constants and variables
+				continue
+			}
+			symbol := pkg + "." + name
+			wrapped[name] = reflect.MakeFunc(value.Type(), func(args []reflect.Value) []reflect.Value {
+				u.record(id, symbol)
+				if value.Type().IsVariadic() {
+					return value.CallSlice(args)
+				}
+				return value.Call(args)
+			})
+		}
+		exports[key] = wrapped
+	}
+	return exports
+}
+
+// exportPackage turns an exports key such as "encoding/binary/binary" into its import path
+func exportPackage(key string) string {
+	return key[:strings.LastIndex(key, "/")]
+}
@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestEngine_SymbolUsage(t *testing.T) {
+	fmtOnly := `package dynamic
+
+import "fmt"
+
+func Parse(data []byte) map[string]interface{} {
+	return map[string]interface{}{"hex": fmt.Sprintf("%X", data), "label": fmt.Sprint("frame ", len(data))}
+}`
+	e := NewEngine()
+
+	// Off by default: nothing is recorded
+	if _, err := e.Execute("fmt_only", []byte{0x01}, fmtOnly); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if report := e.SymbolUsage(); len(report.Protocols) != 0 || len(report.UsedPackages) != 0 {
+		t.Errorf("expected no usage while tracking is off, got %+v", report)
+	}
+
+	e.TrackSymbolUsage(true)
+	for range 3 {
+		res, err := e.Execute("fmt_only", []byte{0x01, 0xAB}, fmtOnly)
+		if err != nil || res["hex"] != "01AB" || res["label"] != "frame 2" {
+			t.Fatalf("instrumented parser returned %v, %v", res, err)
+		}
+	}
+
+	report := e.SymbolUsage()
+	want := map[string]int64{"fmt.Sprintf": 3, "fmt.Sprint": 3}
+	if !reflect.DeepEqual(report.Protocols["fmt_only"], want) {
+		t.Errorf("fmt_only calls = %v, want %v", report.Protocols["fmt_only"], want)
+	}
+	if !reflect.DeepEqual(report.UsedPackages, []string{"fmt"}) {
+		t.Errorf("UsedPackages = %v, want [fmt]", report.UsedPackages)
+	}
+	for _, pkg := range []string{"encoding/binary", "omni", "strconv"} {
+		if !slices.Contains(report.UnusedPackages, pkg) {
+			t.Errorf("expected %s among the unused packages, got %v", pkg, report.UnusedPackages)
+		}
+	}
+
+	if !reflect.DeepEqual(report.Imports["fmt_only"], []string{"fmt"}) {
+		t.Errorf("fmt_only imports = %v, want [fmt]", report.Imports["fmt_only"])
+	}
+}
+
+func TestEngine_SymbolUsageCountsImportedPackages(t *testing.T) {
+	// binary.BigEndian.Uint16 is a method on a variable, so it isn't counted as a call
+	bigEndian := `package dynamic
+
+import "encoding/binary"
+
+func Parse(data []byte) map[string]interface{} {
+	return map[string]interface{}{"value": int(binary.BigEndian.Uint16(data))}
+}`
+	e := NewEngine()
+	e.TrackSymbolUsage(true)
+	if res, err := e.Execute("big_endian", []byte{0x01, 0x02}, bigEndian); err != nil || res["value"] != 258 {
+		t.Fatalf("instrumented parser returned %v, %v", res, err)
+	}
+
+	report := e.SymbolUsage()
+	if !reflect.DeepEqual(report.UsedPackages, []string{"encoding/binary"}) {
+		t.Errorf("UsedPackages = %v, want [encoding/binary]", report.UsedPackages)
+	}
+	if slices.Contains(report.UnusedPackages, "encoding/binary") {
+		t.Errorf("encoding/binary is imported but listed as unused: %v", report.UnusedPackages)
+	}
+}
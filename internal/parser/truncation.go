@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrTruncated marks an LLM response cut off by the output token limit. callLLM retries
+// it with a doubled token budget instead of handing half a function to the compiler.
+var ErrTruncated = errors.New("LLM response truncated")
+
+// llmMaxOutputTokensLimit caps how far retries raise the output token budget
+const llmMaxOutputTokensLimit = 8 * llmMaxOutputTokens
+
+type maxTokensKey struct{}
+
+// withMaxTokens sets the output token budget of the requests made with ctx
+func withMaxTokens(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, maxTokensKey{}, n)
+}
+
+// maxTokens returns the output token budget for ctx, llmMaxOutputTokens unless raised
+func maxTokens(ctx context.Context) int {
+	if n, _ := ctx.Value(maxTokensKey{}).(int); n > 0 {
+		return n
+	}
+	return llmMaxOutputTokens
+}
+
+// unbalancedBraces reports whether Go source opens more blocks than it closes, or ends
+// inside a string or comment: the shape of code cut off mid-function. Braces inside
+// strings, runes and comments are ignored.
+func unbalancedBraces(code string) bool {
+	depth := 0
+	for i := 0; i < len(code); i++ {
+		switch c := code[i]; c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '/':
+			if i+1 >= len(code) {
+				continue
+			}
+			switch code[i+1] {
+			case '/':
+				for i < len(code) && code[i] != '\n' {
+					i++
+				}
+			case '*':
+				end := strings.Index(code[i+2:], "*/")
+				if end < 0 {
+					return true
+				}
+				i += end + 3
+			}
+		case '"', '\'', '`':
+			i++
+			for ; i < len(code) && code[i] != c; i++ {
+				if code[i] == '\\' && c != '`' {
+					i++
+				} else if code[i] == '\n' && c != '`' {
+					return true
+				}
+			}
+			if i >= len(code) {
+				return true
+			}
+		}
+	}
+	return depth != 0
+}
@@ -1,11 +1,55 @@
 package parser
 
-// Result is what the AI should always return
+import "sync"
+
+// Result is the structured alternative to returning a bare map.
+// Parsers can use it via `import "omni"` and `func Parse(data []byte) omni.Result`;
+// a non-empty Error is surfaced by the Engine as a Go error.
 type Result struct {
 	Metrics map[string]interface{} `json:"metrics"`
 	Error   string                 `json:"error,omitempty"`
 }
 
+// Fields collects a parser's result one field at a time. Parsers taking it, via
+// `func Parse(data []byte, out *omni.Fields)`, keep what they decoded before a timeout:
+// the Engine returns those fields alongside the timeout error.
+type Fields struct {
+	mu     sync.Mutex
+	values map[string]interface{}
+}
+
+// Set records a result field
+func (f *Fields) Set(key string, value interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.values == nil {
+		f.values = make(map[string]interface{})
+	}
+	f.values[key] = value
+}
+
+// Get returns a field set earlier
+func (f *Fields) Get(key string) (interface{}, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.values[key]
+	return v, ok
+}
+
+// snapshot copies the fields set so far; nil if there are none
+func (f *Fields) snapshot() map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.values) == 0 {
+		return nil
+	}
+	out := make(map[string]interface{}, len(f.values))
+	for k, v := range f.values {
+		out[k] = v
+	}
+	return out
+}
+
 // The AI-generated code will be expected to implement this logic
 const Template = `
 package dynamic
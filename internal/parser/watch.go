@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// DefaultWatchDebounce is how long a parser file must stay quiet before it is reloaded,
+// so an editor's burst of writes triggers a single reload
+const DefaultWatchDebounce = 200 * time.Millisecond
+
+// WatchParsers reloads parsers edited by hand in the storage folder until ctx is done.
+// A change to a protocol's current version (storage/<id>/vN.go or its "current" pointer)
+// or to a flat storage/<id>.go file replaces the cached code, drops the compiled parser
+// so the next ingest recompiles it, and re-binds the declared signatures.
+func (m *ParserManager) WatchParsers(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start parser watcher: %v", err)
+	}
+	if err := watcher.Add(m.storagePath); err != nil {
+		_ = watcher.Close()
+		return fmt.Errorf("failed to watch %s: %v", m.storagePath, err)
+	}
+	entries, _ := os.ReadDir(m.storagePath)
+	for _, e := range entries {
+		if e.IsDir() {
+			_ = watcher.Add(filepath.Join(m.storagePath, e.Name()))
+		}
+	}
+
+	go m.watchLoop(ctx, watcher, DefaultWatchDebounce)
+	logger.Info("Watching parsers for changes", zap.String("path", m.storagePath))
+	return nil
+}
+
+func (m *ParserManager) watchLoop(ctx context.Context, watcher *fsnotify.Watcher, debounce time.Duration) {
+	var mu sync.Mutex
+	timers := make(map[string]*time.Timer)
+	defer func() {
+		_ = watcher.Close()
+		mu.Lock()
+		for _, t := range timers {
+			t.Stop()
+		}
+		mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Warn("Parser watcher error", zap.Error(err))
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+			// New protocol directories need their own watch
+			if event.Has(fsnotify.Create) && filepath.Dir(event.Name) == filepath.Clean(m.storagePath) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					_ = watcher.Add(event.Name)
+				}
+			}
+			protocolID := m.watchedProtocol(event.Name)
+			if protocolID == "" {
+				continue
+			}
+
+			mu.Lock()
+			if t, ok := timers[protocolID]; ok {
+				t.Reset(debounce)
+			} else {
+				timers[protocolID] = time.AfterFunc(debounce, func() {
+					mu.Lock()
+					delete(timers, protocolID)
+					mu.Unlock()
+					if ctx.Err() == nil {
+						m.reloadParser(protocolID)
+					}
+				})
+			}
+			mu.Unlock()
+		}
+	}
+}
+
+// watchedProtocol maps a changed file to the protocol it belongs to, or "" if it isn't parser code
+func (m *ParserManager) watchedProtocol(path string) string {
+	dir, name := filepath.Split(path)
+	dir = filepath.Clean(dir)
+	storage := filepath.Clean(m.storagePath)
+
+	if dir == storage {
+		if filepath.Ext(name) == ".go" {
+			return strings.TrimSuffix(name, ".go")
+		}
+		return ""
+	}
+	if filepath.Dir(dir) == storage && (versionFileRe.MatchString(name) || name == currentPointerFile) {
+		return filepath.Base(dir)
+	}
+	return ""
+}
+
+// reloadParser picks up the on-disk code of a protocol if it differs from the cached code
+func (m *ParserManager) reloadParser(protocolID string) {
+	m.mu.Lock()
+	var content []byte
+	var err error
+	if version, verr := m.currentVersion(protocolID); verr == nil {
+		content, err = os.ReadFile(m.versionPath(protocolID, version))
+	} else if info, serr := os.Stat(m.protocolDir(protocolID)); serr == nil && info.IsDir() {
+		// Versioned directory without a readable pointer yet; nothing to load
+		m.mu.Unlock()
+		return
+	} else {
+		content, err = os.ReadFile(filepath.Join(m.storagePath, protocolID+".go"))
+	}
+	if err != nil {
+		m.mu.Unlock()
+		if !os.IsNotExist(err) {
+			logger.Warn("Failed to reload parser", zap.String("protocol", protocolID), zap.Error(err))
+		}
+		return
+	}
+
+	code, previous := string(content), m.cache[protocolID]
+	if code == previous {
+		// Our own write (RegisterParser, rollback) or a no-op save
+		m.mu.Unlock()
+		return
+	}
+	m.cache[protocolID] = code
+	m.engine.ClearCache(protocolID)
+	onCodeChange := m.onCodeChange
+	m.mu.Unlock()
+
+	if onCodeChange != nil {
+		onCodeChange(protocolID, previous, code)
+	}
+	logger.Info("Parser reloaded from disk", zap.String("protocol", protocolID))
+}
@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"go.uber.org/zap"
+)
+
+// DefaultWebhookTimeout bounds one webhook POST when NewWebhookSink gets no client
+const DefaultWebhookTimeout = 5 * time.Second
+
+// webhookQueueSize is how many records may wait for delivery before Emit drops new ones
+const webhookQueueSize = 256
+
+// WebhookSink POSTs every output it receives as a JSON ResultRecord to a URL. Delivery runs
+// in the background so a slow endpoint never stalls ingestion: Emit only queues the record,
+// and fails once webhookQueueSize records are waiting. Close delivers what is queued, for
+// as long as its deadline allows.
+type WebhookSink struct {
+	url     string
+	client  *http.Client
+	queue   chan []byte
+	ctx     context.Context // Cancelled to abandon delivery at close
+	cancel  context.CancelFunc
+	dropped int // Records abandoned at close, set before done is closed
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewWebhookSink starts delivering to url; a nil client gets DefaultWebhookTimeout
+func NewWebhookSink(url string, client *http.Client) *WebhookSink {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultWebhookTimeout}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &WebhookSink{
+		url:    url,
+		client: client,
+		queue:  make(chan []byte, webhookQueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go s.deliver()
+	return s
+}
+
+// Emit queues out for delivery
+func (s *WebhookSink) Emit(out Output) error {
+	data, err := encodeRecord(newResultRecord(out))
+	if err != nil {
+		return err
+	}
+	select {
+	case s.queue <- data:
+		return nil
+	default:
+		return fmt.Errorf("webhook %s is falling behind: %d records queued, dropping", s.url, webhookQueueSize)
+	}
+}
+
+// Close is CloseContext bounded by DefaultWebhookTimeout
+func (s *WebhookSink) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultWebhookTimeout)
+	defer cancel()
+	return s.CloseContext(ctx)
+}
+
+// CloseContext stops accepting records and waits until the queued ones are delivered or ctx
+// is done, in which case the post in progress is aborted, the rest are dropped and an error
+// reports how many. Emit must not be called after Close.
+func (s *WebhookSink) CloseContext(ctx context.Context) error {
+	s.once.Do(func() { close(s.queue) })
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		s.cancel()
+		<-s.done
+	}
+	s.cancel()
+	if s.dropped > 0 {
+		return fmt.Errorf("webhook %s: %d records dropped at close: %w", s.url, s.dropped, ctx.Err())
+	}
+	return nil
+}
+
+func (s *WebhookSink) deliver() {
+	defer close(s.done)
+	for body := range s.queue {
+		if s.ctx.Err() != nil {
+			s.dropped++
+			continue
+		}
+		if err := s.post(body); err != nil {
+			if s.ctx.Err() != nil {
+				s.dropped++
+				continue
+			}
+			logger.Warn("Result webhook failed", zap.String("url", s.url), zap.Error(err))
+		}
+	}
+}
+
+func (s *WebhookSink) post(body []byte) error {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if err := resp.Body.Close(); err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("rejected with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chuanjin/OmniBridge/internal/logger"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	maxWSMessageBytes = 1 << 20          // Frames are small; larger messages close the connection
+	wsWriteTimeout    = 10 * time.Second // Slow clients are dropped rather than stalling the pipeline
+)
+
+// WSMessage is the JSON reply pushed to a WebSocket client for every frame it sends
+type WSMessage struct {
+	Protocol string                 `json:"protocol"`
+	Result   map[string]interface{} `json:"result"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+// WSServer accepts WebSocket clients (typically browser dashboards) that send each
+// binary frame as one binary message and receive a WSMessage per frame in return.
+// It is an http.Handler; mount it on an http.Server.
+type WSServer struct {
+	dispatcher *Dispatcher
+	discovery  *DiscoveryService
+	upgrader   websocket.Upgrader
+
+	// Shutdown state
+	conns     map[*wsConn]struct{}
+	wg        sync.WaitGroup
+	done      chan struct{}
+	closeOnce sync.Once
+	mu        sync.Mutex
+}
+
+// wsConn serializes writes to a client; gorilla connections allow one concurrent writer
+type wsConn struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+}
+
+func NewWSServer(d *Dispatcher, disc *DiscoveryService) *WSServer {
+	return &WSServer{
+		dispatcher: d,
+		discovery:  disc,
+		conns:      make(map[*wsConn]struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// ServeHTTP upgrades the request and serves the client until it disconnects or the server shuts down
+func (s *WSServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.shuttingDown() {
+		http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written the HTTP error response
+		logger.Warn("WebSocket upgrade failed", zap.String("remote_addr", r.RemoteAddr), zap.Error(err))
+		return
+	}
+	c := &wsConn{conn: conn}
+	if !s.trackConn(c) {
+		_ = c.close(websocket.CloseGoingAway, "server shutting down")
+		_ = conn.Close()
+		return
+	}
+	s.handleConnection(c)
+}
+
+// Shutdown stops accepting clients, lets active ones finish their current frame and
+// sends them a close message, waiting until ctx expires. Remaining connections are
+// then closed forcibly and ctx.Err() is returned.
+func (s *WSServer) Shutdown(ctx context.Context) error {
+	s.closeOnce.Do(func() { close(s.done) })
+
+	s.mu.Lock()
+	// Unblock idle reads so handlers notice the shutdown
+	for c := range s.conns {
+		_ = c.conn.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		logger.Info("WebSocket Server shut down gracefully")
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for c := range s.conns {
+			_ = c.conn.Close()
+		}
+		s.mu.Unlock()
+		logger.Warn("WebSocket Server shutdown deadline exceeded, closed remaining connections")
+		return ctx.Err()
+	}
+}
+
+func (s *WSServer) shuttingDown() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// trackConn registers an active connection; it returns false once shutdown has begun
+func (s *WSServer) trackConn(c *wsConn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shuttingDown() {
+		return false
+	}
+	s.conns[c] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *WSServer) untrackConn(c *wsConn) {
+	s.mu.Lock()
+	delete(s.conns, c)
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+func (s *WSServer) handleConnection(c *wsConn) {
+	defer s.untrackConn(c)
+	defer func() { _ = c.conn.Close() }()
+	remote := c.conn.RemoteAddr().String()
+	logger.Info("New WebSocket connection", zap.String("remote_addr", remote))
+
+	c.conn.SetReadLimit(maxWSMessageBytes)
+	for {
+		if s.shuttingDown() {
+			_ = c.close(websocket.CloseGoingAway, "server shutting down")
+			break
+		}
+		msgType, raw, err := c.conn.ReadMessage()
+		if err != nil {
+			if s.shuttingDown() {
+				logger.Info("Closing WebSocket connection for shutdown", zap.String("remote_addr", remote))
+				_ = c.close(websocket.CloseGoingAway, "server shutting down")
+			} else if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logger.Error("WebSocket read error", zap.String("remote_addr", remote), zap.Error(err))
+			}
+			break
+		}
+
+		if msgType != websocket.BinaryMessage {
+			if err := c.send(WSMessage{Error: "expected a binary message"}); err != nil {
+				break
+			}
+			continue
+		}
+		if len(raw) == 0 {
+			if err := c.send(WSMessage{Error: "empty payload"}); err != nil {
+				break
+			}
+			continue
+		}
+		logger.Debug("Received raw data", zap.String("hex", fmt.Sprintf("0x%X", raw)), zap.String("remote_addr", remote))
+
+		msg := WSMessage{}
+		msg.Result, msg.Protocol, err = processFrame(context.Background(), s.dispatcher, s.discovery, raw, "Remote incoming binary data stream (WebSocket).")
+		if err != nil {
+			msg.Result = nil
+			msg.Error = err.Error()
+		} else {
+			logger.Info("Success", zap.String("protocol", msg.Protocol), zap.Any("data", msg.Result))
+		}
+		if err := c.send(msg); err != nil {
+			logger.Warn("Failed to send WebSocket reply", zap.String("remote_addr", remote), zap.Error(err))
+			break
+		}
+	}
+	logger.Info("WebSocket connection closed", zap.String("remote_addr", remote))
+}
+
+func (c *wsConn) send(msg WSMessage) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+		return err
+	}
+	return c.conn.WriteJSON(msg)
+}
+
+func (c *wsConn) close(code int, reason string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	err := c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	if errors.Is(err, websocket.ErrCloseSent) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,132 @@
+package parser
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func newTestWSServer(t *testing.T) (*WSServer, *Dispatcher, string) {
+	t.Helper()
+
+	tmpDir, _ := os.MkdirTemp("", "wsserver_test")
+	t.Cleanup(func() { _ = os.RemoveAll(tmpDir) })
+
+	mgr := NewParserManager(tmpDir, "")
+	d := NewDispatcher(mgr)
+	code := `package dynamic
+func Parse(data []byte) map[string]interface{} { return map[string]interface{}{"val": int(data[1])} }`
+	if err := mgr.RegisterParser("test_proto", code); err != nil {
+		t.Fatalf("RegisterParser failed: %v", err)
+	}
+	d.Bind([]byte{0x01}, "test_proto")
+	if err := mgr.GetEngine().CompileAndCache("test_proto", code); err != nil {
+		t.Fatalf("CompileAndCache failed: %v", err)
+	}
+
+	srv := NewWSServer(d, NewDiscoveryService(d, mgr, DiscoveryConfig{Provider: "ollama"}))
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return srv, d, "ws" + strings.TrimPrefix(ts.URL, "http")
+}
+
+func dialWS(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func roundTrip(t *testing.T, conn *websocket.Conn, msgType int, data []byte) WSMessage {
+	t.Helper()
+	if err := conn.WriteMessage(msgType, data); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var msg WSMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	return msg
+}
+
+func TestWSServer_ParsesBinaryFrames(t *testing.T) {
+	_, d, url := newTestWSServer(t)
+	conn := dialWS(t, url)
+
+	msg := roundTrip(t, conn, websocket.BinaryMessage, []byte{0x01, 0x2A})
+	if msg.Protocol != "test_proto" || msg.Error != "" || msg.Result["val"] != float64(42) {
+		t.Errorf("Unexpected reply: %+v", msg)
+	}
+
+	msg = roundTrip(t, conn, websocket.TextMessage, []byte("012A"))
+	if msg.Error == "" {
+		t.Errorf("Expected an error for a text message, got %+v", msg)
+	}
+
+	d.SetEnabled("test_proto", false)
+	msg = roundTrip(t, conn, websocket.BinaryMessage, []byte{0x01, 0x2A})
+	if msg.Protocol != "test_proto" || msg.Result != nil || !strings.Contains(msg.Error, "disabled") {
+		t.Errorf("Expected a disabled-protocol error, got %+v", msg)
+	}
+}
+
+func TestWSServer_ConcurrentClients(t *testing.T) {
+	_, _, url := newTestWSServer(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		conn := dialWS(t, url)
+		wg.Add(1)
+		go func(val byte) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if err := conn.WriteMessage(websocket.BinaryMessage, []byte{0x01, val}); err != nil {
+					t.Errorf("Write failed: %v", err)
+					return
+				}
+				var msg WSMessage
+				if err := conn.ReadJSON(&msg); err != nil {
+					t.Errorf("Read failed: %v", err)
+					return
+				}
+				if msg.Result["val"] != float64(val) {
+					t.Errorf("client %d got %+v", val, msg)
+				}
+			}
+		}(byte(i))
+	}
+	wg.Wait()
+}
+
+func TestWSServer_GracefulShutdown(t *testing.T) {
+	srv, _, url := newTestWSServer(t)
+	conn := dialWS(t, url)
+
+	if msg := roundTrip(t, conn, websocket.BinaryMessage, []byte{0x01, 0x07}); msg.Error != "" {
+		t.Fatalf("Unexpected error: %s", msg.Error)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("Expected a going-away close, got %v", err)
+	}
+	if _, _, err := websocket.DefaultDialer.Dial(url, nil); err == nil {
+		t.Error("Expected new connections to be refused after shutdown")
+	}
+}
@@ -0,0 +1,11 @@
+// Package seeds embeds the built-in parser seeds, so a binary can seed its storage
+// without a seeds directory next to it.
+package seeds
+
+import "embed"
+
+// FS holds the seed parsers. Seed file names start with an uppercase letter, which keeps
+// this file out of it.
+//
+//go:embed [A-Z]*.go
+var FS embed.FS